@@ -10,14 +10,25 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/dollarkillerx/MessageBoy/internal/client"
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
 )
 
 func main() {
 	configPath := flag.String("config", "configs/client.toml", "path to config file")
 	serverURL := flag.String("server", "", "server URL (overrides config)")
 	token := flag.String("token", "", "registration token (overrides config)")
+	selfTest := flag.Bool("selftest", false, "run protocol/crypto self-test and exit")
 	flag.Parse()
 
+	// 协议自检: 部署后快速验证二进制协议与加密, 失败时非零退出
+	if *selfTest {
+		if err := relay.SelfTest(); err != nil {
+			log.Fatal().Err(err).Msg("Self-test failed")
+		}
+		log.Info().Msg("Self-test passed")
+		return
+	}
+
 	// 加载配置
 	cfg, err := client.LoadClientConfig(*configPath)
 	if err != nil {
@@ -47,13 +58,8 @@ func main() {
 		}
 	}
 
-	// 命令行参数覆盖配置
-	if *serverURL != "" {
-		cfg.Client.ServerURL = *serverURL
-	}
-	if *token != "" {
-		cfg.Client.Token = *token
-	}
+	// 命令行参数覆盖配置 (优先级: 命令行参数 > 环境变量 > 配置文件 > 默认值)
+	client.ApplyFlagOverrides(cfg, *serverURL, *token)
 
 	// 验证必要配置
 	if cfg.Client.ServerURL == "" || cfg.Client.Token == "" {
@@ -13,13 +13,24 @@ import (
 	"github.com/dollarkillerx/MessageBoy/internal/api"
 	"github.com/dollarkillerx/MessageBoy/internal/conf"
 	"github.com/dollarkillerx/MessageBoy/internal/proxy"
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
 )
 
 func main() {
 	configPath := flag.String("config", "configs/server.toml", "path to config file")
+	selfTest := flag.Bool("selftest", false, "run protocol/crypto self-test and exit")
 	flag.Parse()
 
+	// 协议自检: 部署后快速验证二进制协议与加密, 失败时非零退出
+	if *selfTest {
+		if err := relay.SelfTest(); err != nil {
+			log.Fatal().Err(err).Msg("Self-test failed")
+		}
+		log.Info().Msg("Self-test passed")
+		return
+	}
+
 	// 加载配置
 	cfg, err := conf.LoadConfig(*configPath)
 	if err != nil {
@@ -43,32 +54,107 @@ func main() {
 
 	// 创建健康检查器
 	healthChecker := proxy.NewHealthChecker(store, server.GetWSServer())
+	healthChecker.SetInterval(time.Duration(cfg.Server.HealthCheckInterval) * time.Second)
 	healthChecker.Start()
 	defer healthChecker.Stop()
+	server.SetHealthChecker(healthChecker)
 
 	// 创建负载均衡器并注入到 API 服务器
 	loadBalancer := proxy.NewLoadBalancer(store)
+	// 选择节点时跳过 WebSocket 连接已断开的客户端 (DB 健康状态存在滞后)
+	loadBalancer.SetOnlineChecker(server.GetWSServer())
 	server.SetLoadBalancer(loadBalancer)
 
 	// 设置流量统计器
 	server.GetWSServer().SetTrafficCounter(store.Traffic)
+	// 规则建连并发上限查询 (未配置上限的规则不受限)
+	server.GetWSServer().SetConnectConcurrencyLookup(func(ruleID string) int {
+		rule, err := store.Forward.GetByID(ruleID)
+		if err != nil {
+			return 0
+		}
+		return rule.ConnectConcurrency
+	})
+	// 同 ID 重复连接策略
+	server.GetWSServer().SetDuplicatePolicy(relay.DuplicatePolicy(cfg.Server.DuplicateClientPolicy))
+	// 重连风暴抑制: 限制冷启动后 WebSocket 升级的放行速率
+	if cfg.Server.AdmissionRatePerSec > 0 {
+		server.GetWSServer().SetAdmissionLimits(
+			cfg.Server.AdmissionRatePerSec,
+			time.Duration(cfg.Server.AdmissionMaxWait)*time.Second,
+		)
+	}
+	// 端口检查等待队列上限: 客户端无响应时防止队列无界增长
+	server.GetWSServer().SetMaxPendingPortChecks(cfg.Server.MaxPendingPortChecks)
+	store.Traffic.SetBandwidthSmoothing(cfg.Server.BandwidthSmoothing)
+	// 统计行分桶粒度 (hourly 保留日内分辨率)
+	store.Traffic.SetPeriodGranularity(storage.TrafficPeriodGranularity(cfg.Server.TrafficPeriodGranularity))
+
+	// 启动空闲路由回收器: 超时无数据往来的中继路由回收并递减连接计数
+	if cfg.Server.RouteIdleTimeout > 0 {
+		server.GetWSServer().SetRouteIdleTimeout(time.Duration(cfg.Server.RouteIdleTimeout) * time.Second)
+		server.GetWSServer().StartIdleReaper()
+		defer server.GetWSServer().StopIdleReaper()
+	}
 
-	// 启动定期流量统计刷新 (每分钟)
-	trafficFlushStop := make(chan struct{})
+	// 启动离线扫描器: LastSeen 超过 OfflineThreshold 的客户端标记为离线
+	offlineSweeper := storage.NewOfflineSweeper(
+		store.Client,
+		time.Duration(cfg.WebSocket.PingInterval)*time.Second,
+		time.Duration(cfg.WebSocket.OfflineThreshold)*time.Second,
+	)
+	offlineSweeper.Start()
+	defer offlineSweeper.Stop()
+
+	// 启动密钥轮换器: 密钥使用超过上限的客户端自动换新, 旧密钥在宽限期内仍可用
+	if cfg.Server.SecretRotationInterval > 0 {
+		secretRotator := storage.NewSecretRotator(
+			store.Client,
+			time.Minute,
+			time.Duration(cfg.Server.SecretRotationInterval)*time.Second,
+		)
+		secretRotator.Start()
+		defer secretRotator.Stop()
+	}
+
+	// 启动规则定时启停调度器: 按 EnabledFrom/EnabledUntil 时间窗切换规则
+	ruleScheduler := storage.NewRuleScheduler(store.Forward, server.GetWSServer(), time.Minute)
+	ruleScheduler.Start()
+	defer ruleScheduler.Stop()
+
+	// 启动定期流量统计刷新 (每分钟); Stop 会等待最后一次刷新落库
+	trafficFlusher := storage.NewTrafficFlusher(store.Traffic, time.Minute)
+	trafficFlusher.Start()
+	defer trafficFlusher.Stop()
+
+	// 启动流量明细保留清理: 超过保留天数的按日统计行定期删除
+	if cfg.Server.TrafficRetentionDays > 0 {
+		trafficRetention := storage.NewTrafficRetention(
+			store.Traffic,
+			time.Hour,
+			time.Duration(cfg.Server.TrafficRetentionDays)*24*time.Hour,
+		)
+		trafficRetention.Start()
+		defer trafficRetention.Stop()
+	}
+
+	// SIGHUP 配置热加载: 只应用可热更字段 (日志级别、健康检查间隔、请求体上限),
+	// 不中断已建立的 WebSocket 连接; 不可热更字段记录告警
 	go func() {
-		ticker := time.NewTicker(time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				if err := store.Traffic.FlushToDatabase(); err != nil {
-					log.Error().Err(err).Msg("Failed to flush traffic stats")
-				}
-			case <-trafficFlushStop:
-				// 最后一次刷新
-				store.Traffic.FlushToDatabase()
-				return
+		hupCh := make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+		hooks := conf.ReloadHooks{
+			SetLogLevel:            applyLogLevel,
+			SetHealthCheckInterval: healthChecker.SetInterval,
+			SetMaxBodySize:         server.SetMaxBodySize,
+		}
+		for range hupCh {
+			newCfg, err := conf.LoadConfig(*configPath)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to reload config, keeping current config")
+				continue
 			}
+			conf.ApplyReload(cfg, newCfg, hooks)
 		}
 	}()
 
@@ -78,8 +164,9 @@ func main() {
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
 		log.Info().Msg("Shutting down...")
-		close(trafficFlushStop)
+		trafficFlusher.Stop()
 		healthChecker.Stop()
+		offlineSweeper.Stop()
 		os.Exit(0)
 	}()
 
@@ -90,13 +177,18 @@ func main() {
 }
 
 func initLogger(cfg *conf.Config) {
-	level, err := zerolog.ParseLevel(cfg.Logging.Level)
-	if err != nil {
-		level = zerolog.InfoLevel
-	}
-	zerolog.SetGlobalLevel(level)
+	applyLogLevel(cfg.Logging.Level)
 
 	if cfg.Server.Debug {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	}
 }
+
+// applyLogLevel 设置全局日志级别, 无法解析时回退到 info (配置热加载复用)
+func applyLogLevel(levelStr string) {
+	level, err := zerolog.ParseLevel(levelStr)
+	if err != nil {
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+}
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -12,6 +14,7 @@ import (
 	"github.com/dollarkillerx/MessageBoy/internal/proxy"
 	"github.com/dollarkillerx/MessageBoy/internal/relay"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/tlspolicy"
 )
 
 type ApiServer struct {
@@ -23,6 +26,33 @@ type ApiServer struct {
 	loadBalancer *proxy.LoadBalancer
 	webSSH       *WebSSHHandler
 	engine       *gin.Engine
+
+	// wsEngine 独立的 WebSocket 监听引擎, 仅在配置 WebSocket.ListenAddr 时创建
+	wsEngine *gin.Engine
+
+	// rateLimiter 按来源 IP 的请求限流器, 仅在配置 RateLimitPerIP 时创建
+	rateLimiter *middleware.RateLimiter
+
+	// getProxyGroupMethod 单独持有，便于后续注入健康检查器
+	getProxyGroupMethod *GetProxyGroupMethod
+
+	// 按需健康检查方法, 同样在健康检查器就绪后注入
+	checkProxyGroupNowMethod     *CheckProxyGroupNowMethod
+	checkProxyGroupNodeNowMethod *CheckProxyGroupNodeNowMethod
+
+	// simulateLoadBalanceMethod 单独持有，便于后续注入负载均衡器
+	simulateLoadBalanceMethod *SimulateLoadBalanceMethod
+
+	// updateSettingsMethod 单独持有, 健康检查器就绪后注入以便设置即时生效
+	updateSettingsMethod *UpdateSettingsMethod
+}
+
+// SetHealthChecker 注入健康检查器 (用于 getProxyGroup 暴露检查历史和按需检查)
+func (s *ApiServer) SetHealthChecker(hc *proxy.HealthChecker) {
+	s.getProxyGroupMethod.SetHealthChecker(hc)
+	s.checkProxyGroupNowMethod.SetHealthChecker(hc)
+	s.checkProxyGroupNodeNowMethod.SetHealthChecker(hc)
+	s.updateSettingsMethod.SetHealthChecker(hc)
 }
 
 // GetWSServer 返回 WebSocket 服务器实例
@@ -34,6 +64,13 @@ func (s *ApiServer) GetWSServer() *relay.WSServer {
 func (s *ApiServer) SetLoadBalancer(lb *proxy.LoadBalancer) {
 	s.loadBalancer = lb
 	s.wsServer.SetLoadBalancer(lb)
+	s.simulateLoadBalanceMethod.SetLoadBalancer(lb)
+	s.getProxyGroupMethod.SetLoadBalancer(lb)
+}
+
+// SetMaxBodySize 热更 RPC 请求体大小上限 (配置重载路径)
+func (s *ApiServer) SetMaxBodySize(limit int64) {
+	s.rpcHandler.SetMaxBodySize(limit)
 }
 
 // GetLoadBalancer 返回负载均衡器
@@ -49,6 +86,9 @@ func NewApiServer(cfg *conf.Config, storage *storage.Storage) *ApiServer {
 	jwtManager := middleware.NewJWTManager(&cfg.JWT)
 	rpcHandler := NewRpcHandler(jwtManager)
 	wsServer := relay.NewWSServer()
+	if cfg.Server.PayloadHistogram {
+		wsServer.EnablePayloadHistogram()
+	}
 	webSSH := NewWebSSHHandler(storage, jwtManager)
 
 	server := &ApiServer{
@@ -61,10 +101,24 @@ func NewApiServer(cfg *conf.Config, storage *storage.Storage) *ApiServer {
 		engine:     gin.New(),
 	}
 
+	// 按来源 IP 限流 (注册方法豁免, 避免限流阻断新客户端接入)
+	if cfg.Server.RateLimitPerIP > 0 {
+		server.rateLimiter = middleware.NewRateLimiter(cfg.Server.RateLimitPerIP, cfg.Server.RateLimitBurst)
+		rpcHandler.SetRateLimiter(server.rateLimiter, []string{"clientRegister"})
+	}
+
 	server.setupMiddleware()
 	server.setupRoutes()
 	server.registerRpcMethods()
 
+	rpcHandler.SetMaxBodySize(cfg.Server.MaxBodySize)
+
+	if len(cfg.Server.RPCAllowlist) > 0 {
+		if err := rpcHandler.SetMethodACL(cfg.Server.RPCAllowlist); err != nil {
+			log.Fatal().Err(err).Msg("Invalid RPC allowlist config")
+		}
+	}
+
 	return server
 }
 
@@ -76,59 +130,173 @@ func (s *ApiServer) setupMiddleware() {
 
 func (s *ApiServer) setupRoutes() {
 	s.engine.GET("/health", s.healthCheck)
+	s.engine.GET("/healthz", s.livenessCheck)
+	s.engine.GET("/readyz", s.readinessCheck)
+	s.engine.GET("/metrics", s.metricsHandler)
 	s.engine.POST("/api/rpc", s.rpcHandler.Handle)
-	s.engine.GET(s.cfg.WebSocket.Endpoint, s.handleWebSocket)
+
+	// WebSocket 升级端点按来源 IP 限流 (与 RPC 共用限流器)
+	wsHandlers := []gin.HandlerFunc{s.handleWebSocket}
+	if s.rateLimiter != nil {
+		wsHandlers = []gin.HandlerFunc{s.rateLimiter.Middleware(), s.handleWebSocket}
+	}
+
+	// 配置了独立监听地址时, WebSocket 隧道不注册到主 mux
+	if s.cfg.WebSocket.ListenAddr != "" {
+		s.wsEngine = gin.New()
+		s.wsEngine.Use(gin.Recovery())
+		s.wsEngine.GET(s.cfg.WebSocket.Endpoint, wsHandlers...)
+	} else {
+		s.engine.GET(s.cfg.WebSocket.Endpoint, wsHandlers...)
+	}
 	s.engine.GET("/api/ws/ssh/:clientId", s.webSSH.Handle)
+	s.engine.GET("/api/sse/connections/:clientId", s.handleConnectionStream)
+
+	// 仪表盘静态资源 (配置 StaticDir 时提供, 未匹配路由回退 index.html)
+	if s.cfg.Server.StaticDir != "" {
+		s.engine.NoRoute(staticHandler(s.cfg.Server.StaticDir))
+	}
 }
 
 func (s *ApiServer) handleWebSocket(c *gin.Context) {
 	s.wsServer.HandleConnection(c.Writer, c.Request)
 }
 
+// connectionStreamInterval SSE 连接快照推送间隔
+const connectionStreamInterval = 2 * time.Second
+
+// handleConnectionStream 以 SSE 持续推送某 client 的连接快照 (token 经 query 传递, 同 WebSSH)
+func (s *ApiServer) handleConnectionStream(c *gin.Context) {
+	clientID := c.Param("clientId")
+	token := c.Query("token")
+
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "token required"})
+		return
+	}
+	if _, err := s.jwtManager.ValidateToken(token); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+	if _, err := s.storage.Client.GetByID(clientID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "client not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	send := func() bool {
+		data, err := json.Marshal(s.wsServer.GetClientConnections(clientID))
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}
+
+	if !send() {
+		return
+	}
+
+	ticker := time.NewTicker(connectionStreamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			if !send() {
+				return
+			}
+		}
+	}
+}
+
+// audited 包装变更类方法, 执行成功后写入审计记录
+func (s *ApiServer) audited(m RpcMethod) RpcMethod {
+	return NewAuditedMethod(m, s.storage)
+}
+
 func (s *ApiServer) registerRpcMethods() {
 	// 基础方法
 	s.rpcHandler.Register(&PingMethod{})
 	s.rpcHandler.Register(NewAdminLoginMethod(&s.cfg.Admin, s.jwtManager))
 
-	// Client 管理方法
-	s.rpcHandler.Register(NewCreateClientMethod(s.storage, s.cfg))
+	// Client 管理方法 (变更类方法经 audited 包装写入审计记录)
+	s.rpcHandler.Register(s.audited(NewCreateClientMethod(s.storage, s.cfg)))
 	s.rpcHandler.Register(NewGetClientListMethod(s.storage))
 	s.rpcHandler.Register(NewGetClientMethod(s.storage))
-	s.rpcHandler.Register(NewUpdateClientMethod(s.storage))
-	s.rpcHandler.Register(NewDeleteClientMethod(s.storage))
-	s.rpcHandler.Register(NewRegenerateClientTokenMethod(s.storage, s.cfg))
+	s.rpcHandler.Register(s.audited(NewUpdateClientMethod(s.storage)))
+	s.rpcHandler.Register(s.audited(NewDeleteClientMethod(s.storage)))
+	s.rpcHandler.Register(s.audited(NewRegenerateClientTokenMethod(s.storage, s.cfg)))
+	s.rpcHandler.Register(s.audited(NewRotateClientSecretMethod(s.storage, s.cfg)))
 	s.rpcHandler.Register(NewGetClientInstallCommandMethod(s.storage, s.cfg))
+	s.rpcHandler.Register(NewSendClientCommandMethod(s.storage, s.wsServer))
+	s.rpcHandler.Register(NewGetClientConnectionsMethod(s.storage, s.wsServer))
+	s.rpcHandler.Register(NewGetClientDiagnosticsMethod(s.storage, s.wsServer))
 
 	// Client 内部方法
 	s.rpcHandler.Register(NewClientRegisterMethod(s.storage, s.cfg))
 	s.rpcHandler.Register(NewClientHeartbeatMethod(s.storage))
-	s.rpcHandler.Register(NewClientGetRulesMethod(s.storage))
+	s.rpcHandler.Register(NewClientGetRulesMethod(s.storage, s.wsServer, s.cfg.Server.RequireOnlineForRules))
 	s.rpcHandler.Register(NewClientReportTrafficMethod(s.storage))
 	s.rpcHandler.Register(NewClientReportRuleStatusMethod(s.storage))
+	s.rpcHandler.Register(NewGetResolvedClientRulesMethod(s.storage))
 
 	// 转发规则管理方法 (传入 wsServer 用于规则变更通知)
-	s.rpcHandler.Register(NewCreateForwardRuleMethod(s.storage, s.wsServer))
+	s.rpcHandler.Register(s.audited(NewCreateForwardRuleMethod(s.storage, s.wsServer, s.cfg)))
 	s.rpcHandler.Register(NewGetForwardRuleListMethod(s.storage))
 	s.rpcHandler.Register(NewGetForwardRuleMethod(s.storage))
-	s.rpcHandler.Register(NewUpdateForwardRuleMethod(s.storage, s.wsServer))
-	s.rpcHandler.Register(NewDeleteForwardRuleMethod(s.storage, s.wsServer))
-	s.rpcHandler.Register(NewToggleForwardRuleMethod(s.storage, s.wsServer))
+	s.rpcHandler.Register(s.audited(NewUpdateForwardRuleMethod(s.storage, s.wsServer, s.cfg)))
+	s.rpcHandler.Register(s.audited(NewDeleteForwardRuleMethod(s.storage, s.wsServer)))
+	s.rpcHandler.Register(s.audited(NewToggleForwardRuleMethod(s.storage, s.wsServer)))
+	s.rpcHandler.Register(s.audited(NewBulkToggleForwardRulesMethod(s.storage, s.wsServer)))
 
 	// 代理组管理方法
-	s.rpcHandler.Register(NewCreateProxyGroupMethod(s.storage))
+	s.rpcHandler.Register(s.audited(NewCreateProxyGroupMethod(s.storage)))
 	s.rpcHandler.Register(NewGetProxyGroupListMethod(s.storage))
-	s.rpcHandler.Register(NewGetProxyGroupMethod(s.storage))
-	s.rpcHandler.Register(NewUpdateProxyGroupMethod(s.storage))
-	s.rpcHandler.Register(NewDeleteProxyGroupMethod(s.storage))
-	s.rpcHandler.Register(NewAddProxyGroupNodeMethod(s.storage))
-	s.rpcHandler.Register(NewRemoveProxyGroupNodeMethod(s.storage))
-	s.rpcHandler.Register(NewUpdateProxyGroupNodeMethod(s.storage))
+	s.getProxyGroupMethod = NewGetProxyGroupMethod(s.storage)
+	s.rpcHandler.Register(s.getProxyGroupMethod)
+	s.rpcHandler.Register(s.audited(NewUpdateProxyGroupMethod(s.storage)))
+	s.rpcHandler.Register(s.audited(NewDeleteProxyGroupMethod(s.storage)))
+	s.rpcHandler.Register(NewGetProxyGroupUsageMethod(s.storage))
+	s.rpcHandler.Register(s.audited(NewAddProxyGroupNodeMethod(s.storage)))
+	s.rpcHandler.Register(s.audited(NewRemoveProxyGroupNodeMethod(s.storage)))
+	s.rpcHandler.Register(s.audited(NewUpdateProxyGroupNodeMethod(s.storage)))
+	s.simulateLoadBalanceMethod = NewSimulateLoadBalanceMethod()
+	s.rpcHandler.Register(s.simulateLoadBalanceMethod)
+	s.checkProxyGroupNowMethod = NewCheckProxyGroupNowMethod(s.storage)
+	s.rpcHandler.Register(s.checkProxyGroupNowMethod)
+	s.checkProxyGroupNodeNowMethod = NewCheckProxyGroupNodeNowMethod(s.storage)
+	s.rpcHandler.Register(s.checkProxyGroupNodeNowMethod)
 
 	// 流量统计方法
-	s.rpcHandler.Register(NewGetTrafficSummaryMethod(s.storage))
+	s.rpcHandler.Register(NewGetTrafficSummaryMethod(s.storage, s.wsServer))
 	s.rpcHandler.Register(NewGetTotalTrafficMethod(s.storage))
 	s.rpcHandler.Register(NewGetTodayTrafficMethod(s.storage))
 	s.rpcHandler.Register(NewGetClientBandwidthMethod(s.storage))
+	s.rpcHandler.Register(NewGetTopRulesByBandwidthMethod(s.storage))
+	s.rpcHandler.Register(NewGetRuleActiveConnsMethod(s.storage))
+	s.rpcHandler.Register(NewPurgeTrafficStatsMethod(s.storage))
+	s.rpcHandler.Register(NewGetPayloadSizeHistogramMethod(s.wsServer))
+
+	// 审计方法
+	s.rpcHandler.Register(NewGetAuditLogMethod(s.storage))
+
+	// 运维控制
+	s.rpcHandler.Register(s.audited(NewSetMaintenanceModeMethod(s.wsServer)))
+	s.rpcHandler.Register(NewGetServerStatsMethod(s.wsServer))
+
+	// 运行期设置
+	s.rpcHandler.Register(NewGetSettingsMethod(s.storage, s.cfg))
+	s.updateSettingsMethod = NewUpdateSettingsMethod(s.storage, s.cfg, s.rpcHandler)
+	s.rpcHandler.Register(s.audited(s.updateSettingsMethod))
 }
 
 // GetStorage 返回存储实例 (用于设置流量统计器)
@@ -143,10 +311,81 @@ func (s *ApiServer) healthCheck(c *gin.Context) {
 	})
 }
 
+// metricsHandler 运行时指标 (载荷大小直方图与压缩统计, 无数据时为空列表)
+func (s *ApiServer) metricsHandler(c *gin.Context) {
+	buckets := s.wsServer.PayloadHistogramSnapshot()
+	if buckets == nil {
+		buckets = []relay.HistogramBucket{}
+	}
+	metrics := gin.H{
+		"payload_size_histogram": buckets,
+		"compression_stats":      s.wsServer.CompressionStatsSnapshot(),
+	}
+	// 负载均衡各节点被选中次数 (观察选择分布是否倾斜)
+	if s.loadBalancer != nil {
+		metrics["node_selection_counts"] = s.loadBalancer.SelectionCountsSnapshot()
+	}
+	c.JSON(http.StatusOK, metrics)
+}
+
+// livenessCheck 存活探针: 进程存活即返回 200
+func (s *ApiServer) livenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "alive",
+	})
+}
+
+// readinessCheck 就绪探针: 数据库不可达时返回 503, 供上游负载均衡摘除
+func (s *ApiServer) readinessCheck(c *gin.Context) {
+	if err := s.storage.Ping(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unavailable",
+			"error":  err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+	})
+}
+
 func (s *ApiServer) Run() error {
 	addr := fmt.Sprintf("%s:%d", s.cfg.Server.Host, s.cfg.Server.Port)
+
+	// 独立的 WebSocket 监听 (数据面), 与管理 API (控制面) 分端口
+	if s.wsEngine != nil {
+		wsAddr := s.cfg.WebSocket.ListenAddr
+		go func() {
+			log.Info().Str("addr", wsAddr).Msg("Starting dedicated WebSocket listener")
+			if err := s.serve(wsAddr, s.wsEngine); err != nil {
+				log.Fatal().Err(err).Msg("WebSocket listener failed")
+			}
+		}()
+	}
+
 	log.Info().Str("addr", addr).Msg("Starting API server")
-	return s.engine.Run(addr)
+	return s.serve(addr, s.engine)
+}
+
+// serve 在指定地址上启动 HTTP 服务, 配置了证书时以 TLS 直出
+func (s *ApiServer) serve(addr string, handler http.Handler) error {
+	if s.cfg.Server.TLSCertFile != "" && s.cfg.Server.TLSKeyFile != "" {
+		tlsCfg, err := tlspolicy.Policy{
+			MinVersion:   s.cfg.Server.TLSMinVersion,
+			CipherSuites: s.cfg.Server.TLSCipherSuites,
+		}.Build()
+		if err != nil {
+			return fmt.Errorf("invalid TLS config: %w", err)
+		}
+		srv := &http.Server{
+			Addr:      addr,
+			Handler:   handler,
+			TLSConfig: tlsCfg,
+		}
+		return srv.ListenAndServeTLS(s.cfg.Server.TLSCertFile, s.cfg.Server.TLSKeyFile)
+	}
+
+	return http.ListenAndServe(addr, handler)
 }
 
 func corsMiddleware() gin.HandlerFunc {
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+)
+
+func setupHealthTestServer(t *testing.T) (*ApiServer, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	server := &ApiServer{
+		storage: &storage.Storage{DB: db},
+		engine:  gin.New(),
+	}
+	server.engine.GET("/healthz", server.livenessCheck)
+	server.engine.GET("/readyz", server.readinessCheck)
+	return server, db
+}
+
+func doHealthRequest(t *testing.T, server *ApiServer, path string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	server.engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestLivenessCheck_AlwaysOK(t *testing.T) {
+	server, _ := setupHealthTestServer(t)
+
+	if code := doHealthRequest(t, server, "/healthz"); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestReadinessCheck_HealthyDB(t *testing.T) {
+	server, _ := setupHealthTestServer(t)
+
+	if code := doHealthRequest(t, server, "/readyz"); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+}
+
+func TestReadinessCheck_ClosedDBReturns503(t *testing.T) {
+	server, db := setupHealthTestServer(t)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		t.Fatalf("failed to close database: %v", err)
+	}
+
+	if code := doHealthRequest(t, server, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+)
+
+func newSplitListenerServer(t *testing.T, wsListenAddr string) *ApiServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &conf.Config{}
+	cfg.Server.Debug = true
+	cfg.WebSocket.Endpoint = "/ws"
+	cfg.WebSocket.ListenAddr = wsListenAddr
+	return NewApiServer(cfg, &storage.Storage{})
+}
+
+func TestSeparateWSListener_WSReachableAPIIsNot(t *testing.T) {
+	server := newSplitListenerServer(t, "127.0.0.1:0")
+	if server.wsEngine == nil {
+		t.Fatal("expected dedicated WS engine when ListenAddr is configured")
+	}
+
+	// 专用监听上 WebSocket 可达
+	wsSrv := httptest.NewServer(server.wsEngine)
+	defer wsSrv.Close()
+
+	wsURL := "ws" + wsSrv.URL[len("http"):] + "/ws?client_id=split-client"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("expected websocket dial on dedicated listener to succeed: %v", err)
+	}
+	conn.Close()
+
+	// 专用监听上管理 API 不可达
+	resp, err := http.Post(wsSrv.URL+"/api/rpc", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for RPC on dedicated WS listener, got %d", resp.StatusCode)
+	}
+
+	// 主 mux 上 WebSocket 不再注册
+	apiSrv := httptest.NewServer(server.engine)
+	defer apiSrv.Close()
+
+	apiWSURL := "ws" + apiSrv.URL[len("http"):] + "/ws?client_id=split-client"
+	if conn, _, err := websocket.DefaultDialer.Dial(apiWSURL, nil); err == nil {
+		conn.Close()
+		t.Error("expected websocket dial on API listener to fail when split is enabled")
+	}
+}
+
+func TestSeparateWSListener_DisabledSharesMux(t *testing.T) {
+	server := newSplitListenerServer(t, "")
+	if server.wsEngine != nil {
+		t.Fatal("expected no dedicated WS engine without ListenAddr")
+	}
+
+	apiSrv := httptest.NewServer(server.engine)
+	defer apiSrv.Close()
+
+	wsURL := "ws" + apiSrv.URL[len("http"):] + "/ws?client_id=shared-client"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("expected websocket dial on shared listener to succeed: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dollarkillerx/MessageBoy/internal/middleware"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+// sensitiveParamKeys 审计落库前需要脱敏的参数字段
+var sensitiveParamKeys = map[string]bool{
+	"token":        true,
+	"secret_key":   true,
+	"password":     true,
+	"ssh_password": true,
+}
+
+const redactedPlaceholder = "[REDACTED]"
+
+// redactParams 返回脱敏后的参数摘要。无法解析时返回空对象, 避免把原文落库。
+func redactParams(params json.RawMessage) string {
+	var m map[string]interface{}
+	if err := json.Unmarshal(params, &m); err != nil {
+		return "{}"
+	}
+	for k := range m {
+		if sensitiveParamKeys[k] {
+			m[k] = redactedPlaceholder
+		}
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// AuditedMethod 包装变更类 RPC 方法, 执行成功后写入审计记录。
+// 审计失败只告警不影响原方法结果。
+type AuditedMethod struct {
+	inner   RpcMethod
+	storage *storage.Storage
+}
+
+func NewAuditedMethod(inner RpcMethod, s *storage.Storage) *AuditedMethod {
+	return &AuditedMethod{inner: inner, storage: s}
+}
+
+func (m *AuditedMethod) Name() string { return m.inner.Name() }
+
+func (m *AuditedMethod) RequireAuth() bool { return m.inner.RequireAuth() }
+
+func (m *AuditedMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	result, err := m.inner.Execute(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 操作者来自认证中间件写入的上下文 (未认证方法为空)
+	actor := ""
+	if c := GetGinContext(ctx); c != nil {
+		actor = middleware.GetCurrentUser(c)
+	}
+
+	entry := &model.AuditLog{
+		Method: m.inner.Name(),
+		Actor:  actor,
+		Params: redactParams(params),
+	}
+	if auditErr := m.storage.Audit.Create(entry); auditErr != nil {
+		log.Warn().Err(auditErr).Str("method", m.inner.Name()).Msg("Failed to write audit log")
+	}
+
+	return result, nil
+}
+
+// GetAuditLogMethod - 分页查询审计记录
+type GetAuditLogMethod struct {
+	storage *storage.Storage
+}
+
+func NewGetAuditLogMethod(s *storage.Storage) *GetAuditLogMethod {
+	return &GetAuditLogMethod{storage: s}
+}
+
+func (m *GetAuditLogMethod) Name() string { return "getAuditLog" }
+
+type GetAuditLogParams struct {
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+	Method string `json:"method"`
+	Actor  string `json:"actor"`
+}
+
+func (m *GetAuditLogMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GetAuditLogParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, resp.ErrInvalidParams("invalid params")
+		}
+	}
+
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Limit <= 0 || p.Limit > 100 {
+		p.Limit = 20
+	}
+
+	entries, total, err := m.storage.Audit.List(storage.AuditListParams{
+		Page:   p.Page,
+		Limit:  p.Limit,
+		Method: p.Method,
+		Actor:  p.Actor,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	pages := (total + int64(p.Limit) - 1) / int64(p.Limit)
+
+	entryList := make([]map[string]interface{}, len(entries))
+	for i, e := range entries {
+		entryList[i] = map[string]interface{}{
+			"id":         e.ID,
+			"method":     e.Method,
+			"actor":      e.Actor,
+			"params":     e.Params,
+			"created_at": e.CreatedAt,
+		}
+	}
+
+	return map[string]interface{}{
+		"entries": entryList,
+		"total":   total,
+		"page":    p.Page,
+		"limit":   p.Limit,
+		"pages":   pages,
+	}, nil
+}
+
+func (m *GetAuditLogMethod) RequireAuth() bool { return true }
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/middleware"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func setupAuditStorage(t *testing.T) *storage.Storage {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	if err := db.AutoMigrate(&model.Client{}, &model.AuditLog{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	store := &storage.Storage{}
+	store.Client = storage.NewClientRepository(db)
+	store.Audit = storage.NewAuditRepository(db)
+	return store
+}
+
+// auditContext 构造携带认证用户的调用上下文 (模拟认证中间件的写入)
+func auditContext(actor string) context.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(middleware.ContextUser, actor)
+	return context.WithValue(context.Background(), ginContextKey, c)
+}
+
+func TestAuditedMethod_CreateProducesRedactedEntry(t *testing.T) {
+	store := setupAuditStorage(t)
+	method := NewAuditedMethod(NewCreateClientMethod(store, &conf.Config{}), store)
+
+	params := `{"name": "audited-client", "ssh_user": "root", "ssh_password": "hunter2"}`
+	if _, err := method.Execute(auditContext("admin"), json.RawMessage(params)); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	entries, total, err := store.Audit.List(storage.AuditListParams{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to list audit log: %v", err)
+	}
+	if total != 1 || len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", total)
+	}
+
+	entry := entries[0]
+	if entry.Method != "createClient" {
+		t.Errorf("expected method 'createClient', got %q", entry.Method)
+	}
+	if entry.Actor != "admin" {
+		t.Errorf("expected actor 'admin', got %q", entry.Actor)
+	}
+	if strings.Contains(entry.Params, "hunter2") {
+		t.Errorf("audit params should not contain the raw password: %s", entry.Params)
+	}
+	if !strings.Contains(entry.Params, redactedPlaceholder) {
+		t.Errorf("expected sensitive fields to be redacted, got %s", entry.Params)
+	}
+	if !strings.Contains(entry.Params, "audited-client") {
+		t.Errorf("expected non-sensitive fields to be preserved, got %s", entry.Params)
+	}
+}
+
+func TestAuditedMethod_FailedCallNotRecorded(t *testing.T) {
+	store := setupAuditStorage(t)
+	method := NewAuditedMethod(NewCreateClientMethod(store, &conf.Config{}), store)
+
+	// name 缺失时创建失败, 不应留下审计记录
+	if _, err := method.Execute(auditContext("admin"), json.RawMessage(`{}`)); err == nil {
+		t.Fatal("expected create without name to fail")
+	}
+
+	_, total, err := store.Audit.List(storage.AuditListParams{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to list audit log: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("expected no audit entries for failed call, got %d", total)
+	}
+}
+
+func TestGetAuditLog_Paginated(t *testing.T) {
+	store := setupAuditStorage(t)
+	for i := 0; i < 5; i++ {
+		entry := &model.AuditLog{
+			Method: "createForwardRule",
+			Actor:  "admin",
+			Params: fmt.Sprintf(`{"name": "rule-%d"}`, i),
+		}
+		if err := store.Audit.Create(entry); err != nil {
+			t.Fatalf("failed to create audit entry: %v", err)
+		}
+	}
+
+	method := NewGetAuditLogMethod(store)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"page": 1, "limit": 2}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	res := result.(map[string]interface{})
+	if res["total"].(int64) != 5 {
+		t.Errorf("expected total 5, got %v", res["total"])
+	}
+	if res["pages"].(int64) != 3 {
+		t.Errorf("expected 3 pages, got %v", res["pages"])
+	}
+	if entries := res["entries"].([]map[string]interface{}); len(entries) != 2 {
+		t.Errorf("expected 2 entries on first page, got %d", len(entries))
+	}
+}
+
+func TestRedactParams_InvalidJSON(t *testing.T) {
+	if got := redactParams(json.RawMessage(`not-json`)); got != "{}" {
+		t.Errorf("expected empty object for unparseable params, got %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+// allowedClientCommands 可下发到客户端的命令白名单
+var allowedClientCommands = map[string]bool{
+	"ping":         true,
+	"reload-rules": true,
+	"dump-state":   true,
+}
+
+const (
+	defaultCommandTimeout = 5 * time.Second
+	maxCommandTimeout     = 30 * time.Second
+)
+
+// SendClientCommandMethod - 向客户端下发远程命令
+type SendClientCommandMethod struct {
+	storage  *storage.Storage
+	wsServer *relay.WSServer
+}
+
+func NewSendClientCommandMethod(s *storage.Storage, ws *relay.WSServer) *SendClientCommandMethod {
+	return &SendClientCommandMethod{storage: s, wsServer: ws}
+}
+
+func (m *SendClientCommandMethod) Name() string { return "sendClientCommand" }
+
+type SendClientCommandParams struct {
+	ClientID       string `json:"client_id"`
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+func (m *SendClientCommandMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p SendClientCommandParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ClientID == "" {
+		return nil, resp.ErrInvalidParams("client_id is required")
+	}
+	if p.Command == "" {
+		return nil, resp.ErrInvalidParams("command is required")
+	}
+	if !allowedClientCommands[p.Command] {
+		return nil, resp.ErrInvalidParams("command not allowed: " + p.Command)
+	}
+
+	// 验证 client 存在
+	if _, err := m.storage.Client.GetByID(p.ClientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resp.ErrNotFound("client not found")
+		}
+		return nil, fmt.Errorf("failed to verify client: %w", err)
+	}
+
+	timeout := defaultCommandTimeout
+	if p.TimeoutSeconds > 0 {
+		timeout = time.Duration(p.TimeoutSeconds) * time.Second
+		if timeout > maxCommandTimeout {
+			timeout = maxCommandTimeout
+		}
+	}
+
+	output, err := m.wsServer.SendCommand(p.ClientID, p.Command, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"client_id": p.ClientID,
+		"command":   p.Command,
+		"output":    output,
+	}, nil
+}
+
+func (m *SendClientCommandMethod) RequireAuth() bool { return true }
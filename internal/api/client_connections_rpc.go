@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+// GetClientConnectionsMethod - 查看某 client 当前的活跃连接
+type GetClientConnectionsMethod struct {
+	storage  *storage.Storage
+	wsServer *relay.WSServer
+}
+
+func NewGetClientConnectionsMethod(s *storage.Storage, ws *relay.WSServer) *GetClientConnectionsMethod {
+	return &GetClientConnectionsMethod{storage: s, wsServer: ws}
+}
+
+func (m *GetClientConnectionsMethod) Name() string { return "getClientConnections" }
+
+type GetClientConnectionsParams struct {
+	ClientID string `json:"client_id"`
+}
+
+func (m *GetClientConnectionsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GetClientConnectionsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ClientID == "" {
+		return nil, resp.ErrInvalidParams("client_id is required")
+	}
+
+	// 验证 client 存在
+	if _, err := m.storage.Client.GetByID(p.ClientID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resp.ErrNotFound("client not found")
+		}
+		return nil, fmt.Errorf("failed to verify client: %w", err)
+	}
+
+	conns := m.wsServer.GetClientConnections(p.ClientID)
+
+	return map[string]interface{}{
+		"client_id":   p.ClientID,
+		"connections": conns,
+		"total":       len(conns),
+	}, nil
+}
+
+func (m *GetClientConnectionsMethod) RequireAuth() bool { return true }
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+// GetClientDiagnosticsMethod - 客户端接入诊断汇总 (token 有效性、隧道在线状态、
+// 心跳时间、各规则运行状态), 用于排查安装/接入失败
+type GetClientDiagnosticsMethod struct {
+	storage  *storage.Storage
+	wsServer *relay.WSServer
+}
+
+func NewGetClientDiagnosticsMethod(s *storage.Storage, ws *relay.WSServer) *GetClientDiagnosticsMethod {
+	return &GetClientDiagnosticsMethod{storage: s, wsServer: ws}
+}
+
+func (m *GetClientDiagnosticsMethod) Name() string { return "getClientDiagnostics" }
+
+type GetClientDiagnosticsParams struct {
+	ID    string `json:"id"`    // client ID, 与 token 至少提供一个
+	Token string `json:"token"` // 安装 token, 按 token 定位并校验有效性
+}
+
+func (m *GetClientDiagnosticsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GetClientDiagnosticsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ID == "" && p.Token == "" {
+		return nil, resp.ErrInvalidParams("id or token is required")
+	}
+
+	// 优先按 ID 定位; 只给了 token 时按 token 定位, 查不到即 token 无效
+	var client *model.Client
+	var err error
+	if p.ID != "" {
+		client, err = m.storage.Client.GetByID(p.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, resp.ErrNotFound("client not found")
+			}
+			return nil, fmt.Errorf("failed to get client: %w", err)
+		}
+	} else {
+		client, err = m.storage.Client.GetByToken(p.Token)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return map[string]interface{}{
+					"token_valid": false,
+				}, nil
+			}
+			return nil, fmt.Errorf("failed to get client: %w", err)
+		}
+	}
+
+	// 同时给了 ID 和 token 时校验两者匹配
+	tokenValid := p.Token == "" || client.Token == p.Token
+
+	// 心跳距今秒数 (nil 表示从未上报心跳)
+	var heartbeatAge interface{}
+	if client.LastSeen != nil {
+		heartbeatAge = int64(time.Since(*client.LastSeen).Seconds())
+	}
+
+	// 含禁用规则, 诊断时需要完整视图
+	rules, err := m.storage.Forward.GetAllByClientID(client.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err)
+	}
+
+	running, errored := 0, 0
+	ruleList := make([]map[string]interface{}, len(rules))
+	for i, r := range rules {
+		switch r.Status {
+		case model.RuleStatusRunning:
+			running++
+		case model.RuleStatusError:
+			errored++
+		}
+		ruleList[i] = map[string]interface{}{
+			"rule_id":     r.ID,
+			"name":        r.Name,
+			"type":        r.Type,
+			"enabled":     r.Enabled,
+			"listen_addr": r.ListenAddr,
+			"status":      r.Status,
+			"last_error":  r.LastError,
+		}
+	}
+
+	return map[string]interface{}{
+		"client_id":             client.ID,
+		"name":                  client.Name,
+		"token_valid":           tokenValid,
+		"status":                client.Status,
+		"online":                m.wsServer.IsClientOnline(client.ID),
+		"last_ip":               client.LastIP,
+		"hostname":              client.Hostname,
+		"version":               client.Version,
+		"last_seen":             client.LastSeen,
+		"heartbeat_age_seconds": heartbeatAge,
+		"rules":                 ruleList,
+		"rules_total":           len(rules),
+		"rules_running":         running,
+		"rules_error":           errored,
+	}, nil
+}
+
+func (m *GetClientDiagnosticsMethod) RequireAuth() bool { return true }
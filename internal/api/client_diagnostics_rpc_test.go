@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func TestGetClientDiagnostics_OnlineClientWithMixedRules(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "client-1")
+	ids := createTestRules(t, store, "client-1", 2)
+
+	// 一条规则运行中, 一条启动失败
+	if err := store.Forward.UpdateStatus(ids[0], model.RuleStatusRunning, ""); err != nil {
+		t.Fatalf("failed to update rule status: %v", err)
+	}
+	if err := store.Forward.UpdateStatus(ids[1], model.RuleStatusError, "bind: address already in use"); err != nil {
+		t.Fatalf("failed to update rule status: %v", err)
+	}
+
+	// 模拟心跳上报, 带上最后来源 IP
+	if err := store.Client.UpdateStatus("client-1", model.ClientStatusOnline, "203.0.113.7"); err != nil {
+		t.Fatalf("failed to update client status: %v", err)
+	}
+
+	// 真实 WSServer + 已连接的 websocket client
+	wsServer := relay.NewWSServer()
+	srv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer srv.Close()
+
+	wsURL := "ws" + srv.URL[len("http"):] + "?client_id=client-1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for !wsServer.IsClientOnline("client-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered on ws server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	method := NewGetClientDiagnosticsMethod(store, wsServer)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"id": "client-1", "token": "token-client-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	report := result.(map[string]interface{})
+	if report["token_valid"] != true {
+		t.Error("expected token_valid = true for matching token")
+	}
+	if report["online"] != true {
+		t.Error("expected online = true with an established websocket")
+	}
+	if report["last_ip"] != "203.0.113.7" {
+		t.Errorf("last_ip = %v, want 203.0.113.7", report["last_ip"])
+	}
+	if age, ok := report["heartbeat_age_seconds"].(int64); !ok || age > 5 {
+		t.Errorf("heartbeat_age_seconds = %v, want a recent age", report["heartbeat_age_seconds"])
+	}
+	if report["rules_total"] != 2 || report["rules_running"] != 1 || report["rules_error"] != 1 {
+		t.Errorf("rule counts = total %v / running %v / error %v, want 2/1/1",
+			report["rules_total"], report["rules_running"], report["rules_error"])
+	}
+
+	// 出错的规则应带出错误详情
+	for _, entry := range report["rules"].([]map[string]interface{}) {
+		if entry["rule_id"] == ids[1] && entry["last_error"] != "bind: address already in use" {
+			t.Errorf("errored rule last_error = %v, want bind error", entry["last_error"])
+		}
+	}
+}
+
+func TestGetClientDiagnostics_UnknownTokenReportsInvalid(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+
+	method := NewGetClientDiagnosticsMethod(store, relay.NewWSServer())
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"token": "no-such-token"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	report := result.(map[string]interface{})
+	if report["token_valid"] != false {
+		t.Error("expected token_valid = false for unknown token")
+	}
+}
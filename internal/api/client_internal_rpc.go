@@ -10,7 +10,10 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/rulehash"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
@@ -37,17 +40,17 @@ type ClientRegisterParams struct {
 func (m *ClientRegisterMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p ClientRegisterParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Token == "" {
-		return nil, errors.New("token is required")
+		return nil, resp.ErrInvalidParams("token is required")
 	}
 
 	client, err := m.storage.Client.GetByToken(p.Token)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid token")
+			return nil, resp.ErrUnauthorized("invalid token")
 		}
 		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
@@ -110,11 +113,11 @@ type ClientHeartbeatParams struct {
 func (m *ClientHeartbeatMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p ClientHeartbeatParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ClientID == "" {
-		return nil, errors.New("client_id is required")
+		return nil, resp.ErrInvalidParams("client_id is required")
 	}
 
 	clientIP := ""
@@ -136,11 +139,16 @@ func (m *ClientHeartbeatMethod) RequireAuth() bool { return false }
 
 // ClientGetRulesMethod - Client 获取转发规则
 type ClientGetRulesMethod struct {
-	storage *storage.Storage
+	storage  *storage.Storage
+	wsServer *relay.WSServer
+
+	// requireOnline 开启后, 未建立 WebSocket 隧道的 client 只能拿到空规则集,
+	// 避免注册成功但隧道未通的 client 绑定服务端认为已托管的端口
+	requireOnline bool
 }
 
-func NewClientGetRulesMethod(s *storage.Storage) *ClientGetRulesMethod {
-	return &ClientGetRulesMethod{storage: s}
+func NewClientGetRulesMethod(s *storage.Storage, ws *relay.WSServer, requireOnline bool) *ClientGetRulesMethod {
+	return &ClientGetRulesMethod{storage: s, wsServer: ws, requireOnline: requireOnline}
 }
 
 func (m *ClientGetRulesMethod) Name() string { return "clientGetRules" }
@@ -152,11 +160,19 @@ type ClientGetRulesParams struct {
 func (m *ClientGetRulesMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p ClientGetRulesParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ClientID == "" {
-		return nil, errors.New("client_id is required")
+		return nil, resp.ErrInvalidParams("client_id is required")
+	}
+
+	// 规则只下发给隧道已建立的 client (隧道建立后会触发规则更新通知, client 随即重新拉取)
+	if m.requireOnline && !m.wsServer.IsClientOnline(p.ClientID) {
+		return map[string]interface{}{
+			"rules":   []model.ClientRule{},
+			"version": fmt.Sprintf("%d", time.Now().Unix()),
+		}, nil
 	}
 
 	rules, err := m.storage.Forward.GetByClientID(p.ClientID)
@@ -164,22 +180,59 @@ func (m *ClientGetRulesMethod) Execute(ctx context.Context, params json.RawMessa
 		return nil, fmt.Errorf("failed to get rules: %w", err)
 	}
 
-	ruleList := make([]map[string]interface{}, len(rules))
-	for i, r := range rules {
-		rule := map[string]interface{}{
-			"id":          r.ID,
-			"type":        r.Type,
-			"listen_addr": r.ListenAddr,
+	now := time.Now()
+	ruleList := make([]model.ClientRule, 0, len(rules))
+	for _, r := range rules {
+		// 配置了启用时间窗的规则只在窗口内下发
+		if !r.WithinSchedule(now) {
+			continue
 		}
-		if r.Type == model.ForwardTypeDirect {
-			rule["target_addr"] = r.TargetAddr
-		} else {
+		rule := model.ClientRule{
+			ID:          r.ID,
+			Type:        r.Type,
+			ListenAddr:  r.ListenAddr,
+			TCPNoDelay:  r.TCPNoDelay,
+			TCPFastOpen: r.TCPFastOpen,
+		}
+		if r.TCPKeepAliveInterval > 0 {
+			rule.TCPKeepAliveInterval = r.TCPKeepAliveInterval
+		}
+		switch r.Type {
+		case model.ForwardTypeDirect:
+			if r.Via != "" {
+				// Via 是单跳中继的简化写法: 下发为 relay 规则由中继机制承载
+				rule.Type = model.ForwardTypeRelay
+				rule.RelayChain = m.resolveRelayChain([]string{r.Via})
+				rule.ExitAddr = r.TargetAddr
+				break
+			}
+			rule.TargetAddr = r.TargetAddr
+			rule.CanaryTarget = r.CanaryTarget
+			rule.CanaryPercent = r.CanaryPercent
+			rule.MirrorAddr = r.MirrorAddr
+		case model.ForwardTypeSNI:
+			rule.SNIMap = r.SNIMap
+		case model.ForwardTypeSocks5, model.ForwardTypeHTTPConnect:
+			// 目标在代理请求中动态指定, 只下发出口相关配置
+			rule.RelayChain = m.resolveRelayChain(r.RelayChain)
+			if r.FallbackGroup != "" {
+				rule.FallbackGroup = resolveGroupRef(m.storage, r.FallbackGroup)
+			}
+			rule.VerboseLogging = r.VerboseLogging
+		default:
 			// 将代理组名称转换为 ID
-			resolvedChain := m.resolveRelayChain(r.RelayChain)
-			rule["relay_chain"] = resolvedChain
-			rule["exit_addr"] = r.ExitAddr
+			rule.RelayChain = m.resolveRelayChain(r.RelayChain)
+			rule.ExitAddr = r.ExitAddr
+			if r.FallbackGroup != "" {
+				rule.FallbackGroup = resolveGroupRef(m.storage, r.FallbackGroup)
+			}
+			rule.PoolConns = r.PoolConns
+			rule.DrainOnClose = r.DrainOnClose
+			rule.VerboseLogging = r.VerboseLogging
 		}
-		ruleList[i] = rule
+		// 规范化哈希在所有配置字段就位后计算, 供客户端检测序列化漂移
+		rule.ConfigHash = rulehash.CanonicalHashStruct(rule)
+		ruleList = append(ruleList, rule)
 	}
 
 	return map[string]interface{}{
@@ -192,6 +245,11 @@ func (m *ClientGetRulesMethod) RequireAuth() bool { return false }
 
 // resolveRelayChain 将中继链中的代理组名称转换为 ID
 func (m *ClientGetRulesMethod) resolveRelayChain(chain []string) []string {
+	return resolveRelayChain(m.storage, chain)
+}
+
+// resolveRelayChain 将中继链中的代理组名称转换为 ID
+func resolveRelayChain(s *storage.Storage, chain []string) []string {
 	if len(chain) == 0 {
 		return chain
 	}
@@ -200,20 +258,24 @@ func (m *ClientGetRulesMethod) resolveRelayChain(chain []string) []string {
 	for i, item := range chain {
 		if storage.IsGroupReference(item) {
 			groupRef := storage.ParseGroupReference(item)
-			// 先尝试按 ID 查找（如果已经是 ID 则直接使用）
-			group, err := m.storage.ProxyGroup.GetByID(groupRef)
-			if err == nil {
-				resolved[i] = "@" + group.ID
+			if cached, ok := relayGroupRefs.get(groupRef); ok {
+				resolved[i] = cached
 				continue
 			}
-			// 再尝试按名称查找
-			group, err = m.storage.ProxyGroup.GetByName(groupRef)
+			// 先尝试按 ID 查找（如果已经是 ID 则直接使用）
+			group, err := s.ProxyGroup.GetByID(groupRef)
+			if err != nil {
+				// 再尝试按名称查找
+				group, err = s.ProxyGroup.GetByName(groupRef)
+			}
 			if err == nil {
 				resolved[i] = "@" + group.ID
-				continue
+			} else {
+				// 找不到则保持原样
+				resolved[i] = item
 			}
-			// 找不到则保持原样
-			resolved[i] = item
+			// 未解析的引用也缓存, 避免对不存在的组反复查库
+			relayGroupRefs.put(groupRef, resolved[i])
 		} else {
 			resolved[i] = item
 		}
@@ -221,6 +283,120 @@ func (m *ClientGetRulesMethod) resolveRelayChain(chain []string) []string {
 	return resolved
 }
 
+// resolveGroupRef 将单个代理组名称/ID 转换为 "@groupID" 引用
+func resolveGroupRef(s *storage.Storage, group string) string {
+	if group == "" {
+		return ""
+	}
+	if !storage.IsGroupReference(group) {
+		group = "@" + group
+	}
+	return resolveRelayChain(s, []string{group})[0]
+}
+
+// GetResolvedClientRulesMethod - 管理端查看某 client 实际会收到的规则 (调试用)
+type GetResolvedClientRulesMethod struct {
+	storage *storage.Storage
+}
+
+func NewGetResolvedClientRulesMethod(s *storage.Storage) *GetResolvedClientRulesMethod {
+	return &GetResolvedClientRulesMethod{storage: s}
+}
+
+func (m *GetResolvedClientRulesMethod) Name() string { return "getResolvedClientRules" }
+
+type GetResolvedClientRulesParams struct {
+	ClientID string `json:"client_id"`
+}
+
+func (m *GetResolvedClientRulesMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GetResolvedClientRulesParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ClientID == "" {
+		return nil, resp.ErrInvalidParams("client_id is required")
+	}
+
+	rules, err := m.storage.Forward.GetByClientID(p.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %w", err)
+	}
+
+	ruleList := make([]map[string]interface{}, len(rules))
+	for i, r := range rules {
+		rule := map[string]interface{}{
+			"id":          r.ID,
+			"name":        r.Name,
+			"type":        r.Type,
+			"listen_addr": r.ListenAddr,
+		}
+		if r.Type == model.ForwardTypeDirect {
+			if r.Via != "" {
+				// Via 单跳中继与 clientGetRules 一致地展开为 relay
+				resolvedChain := resolveRelayChain(m.storage, []string{r.Via})
+				rule["type"] = model.ForwardTypeRelay
+				rule["via"] = r.Via
+				rule["relay_chain"] = resolvedChain
+				rule["exit_addr"] = r.TargetAddr
+				rule["chain_nodes"] = m.expandChainNodes(resolvedChain)
+			} else {
+				rule["target_addr"] = r.TargetAddr
+			}
+		} else if r.Type == model.ForwardTypeSNI {
+			rule["sni_map"] = r.SNIMap
+		} else {
+			// 与 clientGetRules 一致的解析逻辑, 外加展开代理组节点候选
+			resolvedChain := resolveRelayChain(m.storage, r.RelayChain)
+			rule["relay_chain"] = resolvedChain
+			rule["exit_addr"] = r.ExitAddr
+			if r.FallbackGroup != "" {
+				rule["fallback_group"] = resolveGroupRef(m.storage, r.FallbackGroup)
+			}
+			rule["pool_conns"] = r.PoolConns
+			rule["drain_on_close"] = r.DrainOnClose
+			rule["verbose_logging"] = r.VerboseLogging
+			rule["chain_nodes"] = m.expandChainNodes(resolvedChain)
+		}
+		ruleList[i] = rule
+	}
+
+	return map[string]interface{}{
+		"client_id": p.ClientID,
+		"rules":     ruleList,
+	}, nil
+}
+
+// expandChainNodes 将已解析的中继链中的 @groupID 展开为节点候选列表
+func (m *GetResolvedClientRulesMethod) expandChainNodes(chain []string) map[string]interface{} {
+	expanded := make(map[string]interface{})
+	for _, item := range chain {
+		if !storage.IsGroupReference(item) {
+			continue
+		}
+		groupID := storage.ParseGroupReference(item)
+		nodes, err := m.storage.ProxyGroup.GetNodesByGroupID(groupID)
+		if err != nil {
+			continue
+		}
+		candidates := make([]map[string]interface{}, len(nodes))
+		for i, n := range nodes {
+			candidates[i] = map[string]interface{}{
+				"node_id":   n.ID,
+				"client_id": n.ClientID,
+				"priority":  n.Priority,
+				"weight":    n.Weight,
+				"status":    n.Status,
+			}
+		}
+		expanded[item] = candidates
+	}
+	return expanded
+}
+
+func (m *GetResolvedClientRulesMethod) RequireAuth() bool { return true }
+
 // ClientReportTrafficMethod - Client 上报流量统计
 type ClientReportTrafficMethod struct {
 	storage *storage.Storage
@@ -248,11 +424,11 @@ type ClientReportTrafficParams struct {
 func (m *ClientReportTrafficMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p ClientReportTrafficParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ClientID == "" {
-		return nil, errors.New("client_id is required")
+		return nil, resp.ErrInvalidParams("client_id is required")
 	}
 
 	// 累加流量到统计器，并设置活跃连接数
@@ -299,11 +475,11 @@ type ClientReportRuleStatusParams struct {
 func (m *ClientReportRuleStatusMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p ClientReportRuleStatusParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ClientID == "" {
-		return nil, errors.New("client_id is required")
+		return nil, resp.ErrInvalidParams("client_id is required")
 	}
 
 	// 更新规则状态
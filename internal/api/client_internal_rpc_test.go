@@ -3,9 +3,16 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/rulehash"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -613,3 +620,263 @@ func TestClientReportRuleStatusMethod_Execute_NonExistentRule(t *testing.T) {
 		t.Error("expected ack to be true even for non-existent rule")
 	}
 }
+
+// ===== GetResolvedClientRules Tests =====
+
+func TestGetResolvedClientRules_ResolvesGroupNameToID(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+
+	group := &model.ProxyGroup{ID: "group-xyz", Name: "jp-nodes"}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	node := &model.ProxyGroupNode{
+		ID:       "node-1",
+		GroupID:  "group-xyz",
+		ClientID: "client-b",
+		Weight:   10,
+	}
+	if err := store.ProxyGroup.AddNode(node); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	rule := &model.ForwardRule{
+		ID:           "rule-relay",
+		Name:         "relay via group",
+		Type:         model.ForwardTypeRelay,
+		Enabled:      true,
+		ListenAddr:   "0.0.0.0:9000",
+		ListenClient: "client-a",
+		RelayChain:   model.StringSlice{"@jp-nodes"},
+		ExitAddr:     "example.com:80",
+	}
+	if err := store.Forward.Create(rule); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	method := NewGetResolvedClientRulesMethod(store)
+	params, _ := json.Marshal(map[string]interface{}{"client_id": "client-a"})
+
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	resultMap := result.(map[string]interface{})
+	rules := resultMap["rules"].([]map[string]interface{})
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+
+	chain := rules[0]["relay_chain"].([]string)
+	if len(chain) != 1 || chain[0] != "@group-xyz" {
+		t.Errorf("expected chain resolved to [@group-xyz], got %v", chain)
+	}
+
+	// 组引用应展开为节点候选
+	expanded := rules[0]["chain_nodes"].(map[string]interface{})
+	candidates, ok := expanded["@group-xyz"].([]map[string]interface{})
+	if !ok || len(candidates) != 1 {
+		t.Fatalf("expected 1 node candidate for @group-xyz, got %v", expanded)
+	}
+	if candidates[0]["client_id"] != "client-b" {
+		t.Errorf("expected candidate client-b, got %v", candidates[0]["client_id"])
+	}
+}
+
+func TestGetResolvedClientRules_MissingClientID(t *testing.T) {
+	method := NewGetResolvedClientRulesMethod(nil)
+	params, _ := json.Marshal(map[string]interface{}{})
+	if _, err := method.Execute(context.Background(), params); err == nil {
+		t.Error("expected error for missing client_id")
+	}
+}
+
+func TestClientGetRules_FiltersOutsideScheduleWindow(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	scheduled := []struct {
+		id    string
+		from  *time.Time
+		until *time.Time
+	}{
+		{"in-window", &past, &future},
+		{"no-window", nil, nil},
+		{"not-yet", &future, nil},
+		{"already-over", nil, &past},
+	}
+	for _, s := range scheduled {
+		rule := &model.ForwardRule{
+			ID:           s.id,
+			Name:         s.id,
+			Type:         model.ForwardTypeDirect,
+			Enabled:      true,
+			ListenAddr:   "0.0.0.0:8080",
+			ListenClient: "client-1",
+			TargetAddr:   "127.0.0.1:80",
+			EnabledFrom:  s.from,
+			EnabledUntil: s.until,
+		}
+		if err := store.Forward.Create(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+	}
+
+	method := NewClientGetRulesMethod(store, nil, false)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"client_id": "client-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	rules := result.(map[string]interface{})["rules"].([]model.ClientRule)
+	got := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		got[r.ID] = true
+	}
+	if len(got) != 2 || !got["in-window"] || !got["no-window"] {
+		t.Errorf("expected only in-window and no-window rules, got %v", got)
+	}
+}
+
+func TestClientGetRules_ConfigHashSurvivesJSONRoundTrip(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	rule := &model.ForwardRule{
+		ID:           "hashed-rule",
+		Name:         "Hashed Rule",
+		Type:         model.ForwardTypeRelay,
+		Enabled:      true,
+		ListenAddr:   "0.0.0.0:8080",
+		ListenClient: "client-1",
+		RelayChain:   model.StringSlice{"client-a"},
+		ExitAddr:     "10.0.0.1:80",
+	}
+	if err := store.Forward.Create(rule); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	method := NewClientGetRulesMethod(store, nil, false)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"client_id": "client-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// 模拟客户端: 结果经过 JSON 编解码后重新校验哈希
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	rules := decoded["rules"].([]interface{})
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	clientRule := rules[0].(map[string]interface{})
+	if clientRule[rulehash.HashKey] == "" {
+		t.Fatal("expected config_hash in rule")
+	}
+	if computed, ok := rulehash.Verify(clientRule); !ok {
+		t.Errorf("client-side hash %s does not match server-provided hash", computed)
+	}
+}
+
+func TestClientGetRules_WithheldUntilWebSocketOnline(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	createTestRules(t, store, "client-1", 2)
+
+	wsServer := relay.NewWSServer()
+	srv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer srv.Close()
+
+	method := NewClientGetRulesMethod(store, wsServer, true)
+	params := json.RawMessage(`{"client_id": "client-1"}`)
+
+	// 隧道未建立时不下发任何规则
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	rules := result.(map[string]interface{})["rules"].([]model.ClientRule)
+	if len(rules) != 0 {
+		t.Fatalf("expected no rules before websocket established, got %d", len(rules))
+	}
+
+	wsURL := "ws" + srv.URL[len("http"):] + "?client_id=client-1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for !wsServer.IsClientOnline("client-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered on ws server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 隧道建立后规则正常下发
+	result, err = method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	rules = result.(map[string]interface{})["rules"].([]model.ClientRule)
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules after websocket established, got %d", len(rules))
+	}
+}
+
+func TestClientGetRules_ViaTranslatesToOneHopRelay(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	rule := &model.ForwardRule{
+		ID:           "via-rule",
+		Name:         "Via Rule",
+		Type:         model.ForwardTypeDirect,
+		Enabled:      true,
+		ListenAddr:   "0.0.0.0:8080",
+		ListenClient: "client-entry",
+		TargetAddr:   "10.0.0.9:80",
+		Via:          "client-via",
+	}
+	if err := store.Forward.Create(rule); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	method := NewClientGetRulesMethod(store, nil, false)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"client_id": "client-entry"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	rules := result.(map[string]interface{})["rules"].([]model.ClientRule)
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+	got := rules[0]
+	if got.Type != model.ForwardTypeRelay {
+		t.Errorf("type = %q, want relay (via translation)", got.Type)
+	}
+	if len(got.RelayChain) != 1 || got.RelayChain[0] != "client-via" {
+		t.Errorf("relay_chain = %v, want [client-via]", got.RelayChain)
+	}
+	if got.ExitAddr != "10.0.0.9:80" {
+		t.Errorf("exit_addr = %q, want target addr", got.ExitAddr)
+	}
+	if got.TargetAddr != "" {
+		t.Errorf("target_addr = %q, want empty after translation", got.TargetAddr)
+	}
+
+	// 哈希按转换后的规则计算, 客户端重算时一致
+	verify := got
+	verify.ConfigHash = ""
+	if computed := rulehash.CanonicalHashStruct(verify); computed != got.ConfigHash {
+		t.Errorf("config hash %s does not match translated rule hash %s", got.ConfigHash, computed)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/dollarkillerx/MessageBoy/internal/conf"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
@@ -37,16 +38,18 @@ type CreateClientParams struct {
 	SSHUser     string `json:"ssh_user"`
 	SSHPassword string `json:"ssh_password"`
 	SSHKeyPath  string `json:"ssh_key_path"`
+	// 标签 (key=value), 供规则按 @tag:key=value 引用选择出口
+	Tags map[string]string `json:"tags"`
 }
 
 func (m *CreateClientMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p CreateClientParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Name == "" {
-		return nil, errors.New("name is required")
+		return nil, resp.ErrInvalidParams("name is required")
 	}
 
 	token := generateToken()
@@ -62,6 +65,7 @@ func (m *CreateClientMethod) Execute(ctx context.Context, params json.RawMessage
 		SSHUser:     p.SSHUser,
 		SSHPassword: p.SSHPassword,
 		SSHKeyPath:  p.SSHKeyPath,
+		Tags:        p.Tags,
 		Token:       token,
 		SecretKey:   secretKey,
 		Status:      model.ClientStatusOffline,
@@ -108,12 +112,14 @@ type GetClientListParams struct {
 	Limit  int    `json:"limit"`
 	Search string `json:"search"`
 	Status string `json:"status"`
+	// 可选的键集分页游标 (来自上一页的 next_cursor, 设置后忽略 page)
+	Cursor string `json:"cursor"`
 }
 
 func (m *GetClientListMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p GetClientListParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Page <= 0 {
@@ -123,12 +129,22 @@ func (m *GetClientListMethod) Execute(ctx context.Context, params json.RawMessag
 		p.Limit = 20
 	}
 
-	clients, total, err := m.storage.Client.List(storage.ClientListParams{
+	listParams := storage.ClientListParams{
 		Page:   p.Page,
 		Limit:  p.Limit,
 		Search: p.Search,
 		Status: p.Status,
-	})
+	}
+	if p.Cursor != "" {
+		afterCreatedAt, afterID, err := decodeListCursor(p.Cursor)
+		if err != nil {
+			return nil, resp.ErrInvalidParams("invalid cursor")
+		}
+		listParams.AfterCreatedAt = afterCreatedAt
+		listParams.AfterID = afterID
+	}
+
+	clients, total, err := m.storage.Client.List(listParams)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client list: %w", err)
 	}
@@ -149,16 +165,25 @@ func (m *GetClientListMethod) Execute(ctx context.Context, params json.RawMessag
 			"ssh_host":   c.SSHHost,
 			"ssh_port":   c.SSHPort,
 			"ssh_user":   c.SSHUser,
+			"tags":       c.Tags,
 			"created_at": c.CreatedAt,
 		}
 	}
 
+	// 满页时返回游标供下一页使用, 否则说明已到末尾
+	nextCursor := ""
+	if len(clients) == p.Limit {
+		last := clients[len(clients)-1]
+		nextCursor = encodeListCursor(last.CreatedAt, last.ID)
+	}
+
 	return map[string]interface{}{
-		"clients": clientList,
-		"total":   total,
-		"page":    p.Page,
-		"limit":   p.Limit,
-		"pages":   pages,
+		"clients":     clientList,
+		"total":       total,
+		"page":        p.Page,
+		"limit":       p.Limit,
+		"pages":       pages,
+		"next_cursor": nextCursor,
 	}, nil
 }
 
@@ -182,17 +207,17 @@ type GetClientParams struct {
 func (m *GetClientMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p GetClientParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	client, err := m.storage.Client.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("client not found")
+			return nil, resp.ErrNotFound("client not found")
 		}
 		return nil, fmt.Errorf("failed to get client: %w", err)
 	}
@@ -206,6 +231,7 @@ func (m *GetClientMethod) Execute(ctx context.Context, params json.RawMessage) (
 		"ssh_port":     client.SSHPort,
 		"ssh_user":     client.SSHUser,
 		"ssh_key_path": client.SSHKeyPath,
+		"tags":         client.Tags,
 		"status":       client.Status,
 		"last_ip":      client.LastIP,
 		"last_seen":    client.LastSeen,
@@ -230,31 +256,32 @@ func NewUpdateClientMethod(s *storage.Storage) *UpdateClientMethod {
 func (m *UpdateClientMethod) Name() string { return "updateClient" }
 
 type UpdateClientParams struct {
-	ID          string  `json:"id"`
-	Name        *string `json:"name"`
-	Description *string `json:"description"`
-	RelayIP     *string `json:"relay_ip"`
-	SSHHost     *string `json:"ssh_host"`
-	SSHPort     *int    `json:"ssh_port"`
-	SSHUser     *string `json:"ssh_user"`
-	SSHPassword *string `json:"ssh_password"`
-	SSHKeyPath  *string `json:"ssh_key_path"`
+	ID          string             `json:"id"`
+	Name        *string            `json:"name"`
+	Description *string            `json:"description"`
+	RelayIP     *string            `json:"relay_ip"`
+	SSHHost     *string            `json:"ssh_host"`
+	SSHPort     *int               `json:"ssh_port"`
+	SSHUser     *string            `json:"ssh_user"`
+	SSHPassword *string            `json:"ssh_password"`
+	SSHKeyPath  *string            `json:"ssh_key_path"`
+	Tags        *map[string]string `json:"tags"`
 }
 
 func (m *UpdateClientMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p UpdateClientParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	client, err := m.storage.Client.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("client not found")
+			return nil, resp.ErrNotFound("client not found")
 		}
 		return nil, fmt.Errorf("failed to get client: %w", err)
 	}
@@ -283,6 +310,9 @@ func (m *UpdateClientMethod) Execute(ctx context.Context, params json.RawMessage
 	if p.SSHKeyPath != nil {
 		client.SSHKeyPath = *p.SSHKeyPath
 	}
+	if p.Tags != nil {
+		client.Tags = *p.Tags
+	}
 
 	if err := m.storage.Client.Update(client); err != nil {
 		return nil, fmt.Errorf("failed to update client: %w", err)
@@ -313,11 +343,11 @@ type DeleteClientParams struct {
 func (m *DeleteClientMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p DeleteClientParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	if err := m.storage.Client.Delete(p.ID); err != nil {
@@ -350,17 +380,17 @@ type RegenerateClientTokenParams struct {
 func (m *RegenerateClientTokenMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p RegenerateClientTokenParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	_, err := m.storage.Client.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("client not found")
+			return nil, resp.ErrNotFound("client not found")
 		}
 		return nil, fmt.Errorf("failed to get client: %w", err)
 	}
@@ -385,6 +415,54 @@ func (m *RegenerateClientTokenMethod) generateInstallCommand(token string) strin
 
 func (m *RegenerateClientTokenMethod) RequireAuth() bool { return true }
 
+// RotateClientSecretMethod - 轮换密钥 (旧密钥在宽限期内仍可用)
+type RotateClientSecretMethod struct {
+	storage *storage.Storage
+	cfg     *conf.Config
+}
+
+func NewRotateClientSecretMethod(s *storage.Storage, cfg *conf.Config) *RotateClientSecretMethod {
+	return &RotateClientSecretMethod{storage: s, cfg: cfg}
+}
+
+func (m *RotateClientSecretMethod) Name() string { return "rotateClientSecret" }
+
+type RotateClientSecretParams struct {
+	ID string `json:"id"`
+}
+
+func (m *RotateClientSecretMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p RotateClientSecretParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ID == "" {
+		return nil, resp.ErrInvalidParams("id is required")
+	}
+
+	_, err := m.storage.Client.GetByID(p.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resp.ErrNotFound("client not found")
+		}
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	newKey := generateSecretKey()
+	if err := m.storage.Client.RotateSecret(p.ID, newKey); err != nil {
+		return nil, fmt.Errorf("failed to rotate secret: %w", err)
+	}
+
+	// 客户端需在宽限期内重新注册换取新密钥
+	return map[string]interface{}{
+		"secret_key":    newKey,
+		"grace_seconds": m.cfg.Server.SecretRotationGrace,
+	}, nil
+}
+
+func (m *RotateClientSecretMethod) RequireAuth() bool { return true }
+
 // GetClientInstallCommandMethod - 获取安装命令
 type GetClientInstallCommandMethod struct {
 	storage *storage.Storage
@@ -404,17 +482,17 @@ type GetClientInstallCommandParams struct {
 func (m *GetClientInstallCommandMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p GetClientInstallCommandParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	client, err := m.storage.Client.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("client not found")
+			return nil, resp.ErrNotFound("client not found")
 		}
 		return nil, fmt.Errorf("failed to get client: %w", err)
 	}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// encodeListCursor 将最后一条记录的 (created_at, id) 编码为不透明的分页游标
+// 列表按 created_at DESC, id DESC 排序, 游标指向下一页的起点
+func encodeListCursor(createdAt time.Time, id string) string {
+	raw := createdAt.Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeListCursor 解析 encodeListCursor 产生的游标
+func decodeListCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return time.Time{}, "", errors.New("malformed cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	return createdAt, parts[1], nil
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func TestListCursor_RoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 1, 2, 3, 4, 5, 678900000, time.UTC)
+	cursor := encodeListCursor(createdAt, "rule-1")
+
+	gotCreatedAt, gotID, err := decodeListCursor(cursor)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if !gotCreatedAt.Equal(createdAt) {
+		t.Errorf("expected created_at %v, got %v", createdAt, gotCreatedAt)
+	}
+	if gotID != "rule-1" {
+		t.Errorf("expected id 'rule-1', got %q", gotID)
+	}
+}
+
+func TestListCursor_RejectsGarbage(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "bm8tc2VwYXJhdG9y", ""} {
+		if _, _, err := decodeListCursor(cursor); err == nil {
+			t.Errorf("expected error for cursor %q", cursor)
+		}
+	}
+}
+
+// createCursorTestRules 创建 n 条 created_at 递减的规则, 返回按列表顺序排列的 ID
+func createCursorTestRules(t *testing.T, store *storage.Storage, clientID string, n int) []string {
+	t.Helper()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		rule := &model.ForwardRule{
+			ID:           fmt.Sprintf("%s-cursor-rule-%d", clientID, i),
+			Name:         fmt.Sprintf("Cursor Rule %d", i),
+			Type:         model.ForwardTypeDirect,
+			Enabled:      true,
+			ListenAddr:   fmt.Sprintf("0.0.0.0:91%02d", i),
+			ListenClient: clientID,
+			TargetAddr:   "127.0.0.1:80",
+			CreatedAt:    base.Add(-time.Duration(i) * time.Minute),
+		}
+		if err := store.Forward.Create(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+func listRulePage(t *testing.T, method *GetForwardRuleListMethod, limit int, cursor string) ([]string, string) {
+	t.Helper()
+	params, _ := json.Marshal(map[string]interface{}{"limit": limit, "cursor": cursor})
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	res := result.(map[string]interface{})
+	rules := res["rules"].([]map[string]interface{})
+	ids := make([]string, len(rules))
+	for i, r := range rules {
+		ids[i] = r["id"].(string)
+	}
+	return ids, res["next_cursor"].(string)
+}
+
+func TestGetForwardRuleList_CursorStableAcrossInsert(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "cursor-client")
+	want := createCursorTestRules(t, store, "cursor-client", 5)
+
+	method := NewGetForwardRuleListMethod(store)
+
+	page1, cursor := listRulePage(t, method, 2, "")
+	if cursor == "" {
+		t.Fatal("expected non-empty next_cursor on full page")
+	}
+
+	// 翻页间隙插入一条更新的规则: offset 分页会把旧记录整体后移导致重复,
+	// 游标分页应完全不受影响
+	newer := &model.ForwardRule{
+		ID:           "cursor-client-newer",
+		Name:         "Inserted Between Pages",
+		Type:         model.ForwardTypeDirect,
+		Enabled:      true,
+		ListenAddr:   "0.0.0.0:9199",
+		ListenClient: "cursor-client",
+		TargetAddr:   "127.0.0.1:80",
+		CreatedAt:    time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+	}
+	if err := store.Forward.Create(newer); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	page2, cursor := listRulePage(t, method, 2, cursor)
+	page3, cursor := listRulePage(t, method, 2, cursor)
+
+	got := append(append(page1, page2...), page3...)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rules across pages, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("position %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+	if cursor != "" {
+		t.Errorf("expected empty next_cursor on final partial page, got %q", cursor)
+	}
+}
+
+func TestGetForwardRuleList_InvalidCursorRejected(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	method := NewGetForwardRuleListMethod(store)
+
+	_, err := method.Execute(context.Background(), json.RawMessage(`{"cursor": "!!not-a-cursor!!"}`))
+	if err == nil {
+		t.Error("expected error for malformed cursor")
+	}
+}
+
+func TestGetClientList_CursorPagination(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		client := &model.Client{
+			ID:        fmt.Sprintf("cursor-c%d", i),
+			Name:      fmt.Sprintf("Cursor Client %d", i),
+			Token:     fmt.Sprintf("token-%d", i),
+			CreatedAt: base.Add(-time.Duration(i) * time.Minute),
+		}
+		if err := store.Client.Create(client); err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+	}
+
+	method := NewGetClientListMethod(store)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"limit": 2}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	res := result.(map[string]interface{})
+	cursor := res["next_cursor"].(string)
+	if cursor == "" {
+		t.Fatal("expected non-empty next_cursor on full page")
+	}
+
+	params, _ := json.Marshal(map[string]interface{}{"limit": 2, "cursor": cursor})
+	result, err = method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	res = result.(map[string]interface{})
+	clients := res["clients"].([]map[string]interface{})
+	if len(clients) != 1 {
+		t.Fatalf("expected 1 client on second page, got %d", len(clients))
+	}
+	if clients[0]["id"] != "cursor-c2" {
+		t.Errorf("expected cursor-c2 on second page, got %v", clients[0]["id"])
+	}
+	if res["next_cursor"].(string) != "" {
+		t.Error("expected empty next_cursor on final page")
+	}
+}
@@ -11,19 +11,25 @@ import (
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
 	"github.com/dollarkillerx/MessageBoy/internal/relay"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
+// ruleStopWaitTimeout 规则迁移时等待旧客户端停止确认的上限
+const ruleStopWaitTimeout = 5 * time.Second
+
 // CreateForwardRuleMethod - 创建转发规则
 type CreateForwardRuleMethod struct {
 	storage  *storage.Storage
 	wsServer *relay.WSServer
+	cfg      *conf.Config
 }
 
-func NewCreateForwardRuleMethod(s *storage.Storage, ws *relay.WSServer) *CreateForwardRuleMethod {
-	return &CreateForwardRuleMethod{storage: s, wsServer: ws}
+func NewCreateForwardRuleMethod(s *storage.Storage, ws *relay.WSServer, cfg *conf.Config) *CreateForwardRuleMethod {
+	return &CreateForwardRuleMethod{storage: s, wsServer: ws, cfg: cfg}
 }
 
 func (m *CreateForwardRuleMethod) Name() string { return "createForwardRule" }
@@ -33,64 +39,141 @@ type CreateForwardRuleParams struct {
 	Type         string   `json:"type"`
 	ListenAddr   string   `json:"listen_addr"`
 	ListenClient string   `json:"listen_client"`
-	TargetAddr   string   `json:"target_addr"`
-	RelayChain   []string `json:"relay_chain"`
-	ExitAddr     string   `json:"exit_addr"`
+	TargetAddr    string            `json:"target_addr"`
+	CanaryTarget  string            `json:"canary_target"`
+	CanaryPercent int               `json:"canary_percent"`
+	Via           string            `json:"via"`
+	RelayChain    []string          `json:"relay_chain"`
+	ExitAddr      string            `json:"exit_addr"`
+	FallbackGroup string            `json:"fallback_group"`
+	PinnedNodeID  string            `json:"pinned_node_id"`
+	SNIMap        map[string]string `json:"sni_map"`
+	PoolConns     bool              `json:"pool_conns"`
+	DrainOnClose  bool              `json:"drain_on_close"`
+
+	// 连接级详细日志 (默认关闭)
+	VerboseLogging bool `json:"verbose_logging"`
+
+	// 流量镜像 tap 地址 (可选, 仅 direct 规则生效)
+	MirrorAddr string `json:"mirror_addr"`
+
+	// 启用时间窗 (可选)
+	EnabledFrom  *time.Time `json:"enabled_from"`
+	EnabledUntil *time.Time `json:"enabled_until"`
+
+	TCPNoDelay           *bool `json:"tcp_nodelay"`
+	TCPKeepAliveInterval int   `json:"tcp_keepalive_interval"`
+	TCPFastOpen          bool  `json:"tcp_fast_open"`
+
+	// 建连并发上限 (0 = 不限制, 服务端在 Connect→ConnAck 阶段按规则限流)
+	ConnectConcurrency int `json:"connect_concurrency"`
 }
 
 func (m *CreateForwardRuleMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p CreateForwardRuleParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Name == "" {
-		return nil, errors.New("name is required")
+		return nil, resp.ErrInvalidParams("name is required")
 	}
 	if p.Type == "" {
-		return nil, errors.New("type is required")
+		return nil, resp.ErrInvalidParams("type is required")
 	}
 	if p.ListenAddr == "" {
-		return nil, errors.New("listen_addr is required")
+		return nil, resp.ErrInvalidParams("listen_addr is required")
 	}
 	if p.ListenClient == "" {
-		return nil, errors.New("listen_client is required")
+		return nil, resp.ErrInvalidParams("listen_client is required")
 	}
 
 	// 验证 client 存在
 	_, err := m.storage.Client.GetByID(p.ListenClient)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("listen_client not found")
+			return nil, resp.ErrNotFound("listen_client not found")
 		}
 		return nil, fmt.Errorf("failed to verify client: %w", err)
 	}
 
-	rule := &model.ForwardRule{
-		ID:           uuid.New().String(),
-		Name:         p.Name,
-		Type:         model.ForwardType(p.Type),
-		Enabled:      true,
-		ListenAddr:   p.ListenAddr,
-		ListenClient: p.ListenClient,
+	// 同一 client 上的启用规则不能监听相同地址
+	conflict, err := m.storage.Forward.HasEnabledListenConflict(p.ListenClient, p.ListenAddr, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check listen conflict: %w", err)
+	}
+	if conflict {
+		return nil, resp.ErrConflict("an enabled rule already listens on this address for this client")
 	}
 
+	if p.EnabledFrom != nil && p.EnabledUntil != nil && !p.EnabledFrom.Before(*p.EnabledUntil) {
+		return nil, resp.ErrInvalidParams("enabled_from must be before enabled_until")
+	}
+
+	rule := &model.ForwardRule{
+		ID:                   uuid.New().String(),
+		Name:                 p.Name,
+		Type:                 model.ForwardType(p.Type),
+		Enabled:              true,
+		ListenAddr:           p.ListenAddr,
+		ListenClient:         p.ListenClient,
+		PoolConns:            p.PoolConns,
+		DrainOnClose:         p.DrainOnClose,
+		VerboseLogging:       p.VerboseLogging,
+		EnabledFrom:          p.EnabledFrom,
+		EnabledUntil:         p.EnabledUntil,
+		TCPNoDelay:           p.TCPNoDelay,
+		TCPKeepAliveInterval: p.TCPKeepAliveInterval,
+		TCPFastOpen:          p.TCPFastOpen,
+		ConnectConcurrency:   p.ConnectConcurrency,
+	}
+	// 配置了时间窗时以当前时刻判断初始启用状态, 未到窗口的规则由调度器按时启用
+	rule.Enabled = rule.WithinSchedule(time.Now())
+
 	if p.Type == "direct" {
 		if p.TargetAddr == "" {
-			return nil, errors.New("target_addr is required for direct type")
+			return nil, resp.ErrInvalidParams("target_addr is required for direct type")
+		}
+		if p.CanaryPercent < 0 || p.CanaryPercent > 100 {
+			return nil, resp.ErrInvalidParams("canary_percent must be between 0 and 100")
+		}
+		if p.CanaryPercent > 0 && p.CanaryTarget == "" {
+			return nil, resp.ErrInvalidParams("canary_target is required when canary_percent > 0")
 		}
 		rule.TargetAddr = p.TargetAddr
+		rule.CanaryTarget = p.CanaryTarget
+		rule.CanaryPercent = p.CanaryPercent
+		rule.Via = p.Via
+		rule.MirrorAddr = p.MirrorAddr
 	} else if p.Type == "relay" {
 		if len(p.RelayChain) == 0 {
-			return nil, errors.New("relay_chain is required for relay type")
+			return nil, resp.ErrInvalidParams("relay_chain is required for relay type")
 		}
 		if p.ExitAddr == "" {
-			return nil, errors.New("exit_addr is required for relay type")
+			return nil, resp.ErrInvalidParams("exit_addr is required for relay type")
 		}
 		rule.RelayChain = p.RelayChain
 		rule.ExitAddr = p.ExitAddr
+		rule.FallbackGroup = p.FallbackGroup
+		rule.PinnedNodeID = p.PinnedNodeID
+	} else if p.Type == "sni" {
+		if len(p.SNIMap) == 0 {
+			return nil, resp.ErrInvalidParams("sni_map is required for sni type")
+		}
+		rule.SNIMap = p.SNIMap
+	} else if p.Type == "socks5" || p.Type == "http_connect" {
+		// 目标由本地应用在代理请求中动态指定; 中继链可选 (为空时监听客户端直接拨号)
+		rule.RelayChain = p.RelayChain
+		rule.FallbackGroup = p.FallbackGroup
 	} else {
-		return nil, errors.New("invalid type, must be 'direct' or 'relay'")
+		return nil, resp.ErrInvalidParams("invalid type, must be 'direct', 'relay', 'sni', 'socks5' or 'http_connect'")
+	}
+
+	// 中继链绕回监听 client 自身的规则只会空转或挂起, 默认拒绝 (可经配置关闭)
+	if rejectSelfLoopEnabled(m.cfg) && len(rule.RelayChain) > 0 {
+		if looped, hop := relayChainLoopsBack(m.storage, rule.ListenClient, rule.RelayChain); looped {
+			return nil, resp.ErrInvalidParams("relay chain loops back to listen client via " + hop)
+		}
 	}
 
 	if err := m.storage.Forward.Create(rule); err != nil {
@@ -128,12 +211,14 @@ type GetForwardRuleListParams struct {
 	ClientID string `json:"client_id"`
 	Type     string `json:"type"`
 	Enabled  *bool  `json:"enabled"`
+	// 可选的键集分页游标 (来自上一页的 next_cursor, 设置后忽略 page)
+	Cursor string `json:"cursor"`
 }
 
 func (m *GetForwardRuleListMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p GetForwardRuleListParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Page <= 0 {
@@ -143,13 +228,23 @@ func (m *GetForwardRuleListMethod) Execute(ctx context.Context, params json.RawM
 		p.Limit = 20
 	}
 
-	rules, total, err := m.storage.Forward.List(storage.ForwardListParams{
+	listParams := storage.ForwardListParams{
 		Page:     p.Page,
 		Limit:    p.Limit,
 		ClientID: p.ClientID,
 		Type:     p.Type,
 		Enabled:  p.Enabled,
-	})
+	}
+	if p.Cursor != "" {
+		afterCreatedAt, afterID, err := decodeListCursor(p.Cursor)
+		if err != nil {
+			return nil, resp.ErrInvalidParams("invalid cursor")
+		}
+		listParams.AfterCreatedAt = afterCreatedAt
+		listParams.AfterID = afterID
+	}
+
+	rules, total, err := m.storage.Forward.List(listParams)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get rule list: %w", err)
 	}
@@ -180,21 +275,55 @@ func (m *GetForwardRuleListMethod) Execute(ctx context.Context, params json.RawM
 			"last_error":         r.LastError,
 			"created_at":         r.CreatedAt,
 		}
-		if r.Type == model.ForwardTypeDirect {
+		if r.EnabledFrom != nil {
+			rule["enabled_from"] = r.EnabledFrom
+		}
+		if r.EnabledUntil != nil {
+			rule["enabled_until"] = r.EnabledUntil
+		}
+		switch r.Type {
+		case model.ForwardTypeDirect:
 			rule["target_addr"] = r.TargetAddr
-		} else {
+			if r.CanaryTarget != "" {
+				rule["canary_target"] = r.CanaryTarget
+				rule["canary_percent"] = r.CanaryPercent
+			}
+			if r.Via != "" {
+				rule["via"] = r.Via
+			}
+			if r.MirrorAddr != "" {
+				rule["mirror_addr"] = r.MirrorAddr
+			}
+		case model.ForwardTypeSNI:
+			rule["sni_map"] = r.SNIMap
+		default:
 			rule["relay_chain"] = r.RelayChain
 			rule["exit_addr"] = r.ExitAddr
+			rule["fallback_group"] = r.FallbackGroup
+			if r.PinnedNodeID != "" {
+				rule["pinned_node_id"] = r.PinnedNodeID
+			}
+			rule["pool_conns"] = r.PoolConns
+			rule["drain_on_close"] = r.DrainOnClose
+			rule["verbose_logging"] = r.VerboseLogging
 		}
 		ruleList[i] = rule
 	}
 
+	// 满页时返回游标供下一页使用, 否则说明已到末尾
+	nextCursor := ""
+	if len(rules) == p.Limit {
+		last := rules[len(rules)-1]
+		nextCursor = encodeListCursor(last.CreatedAt, last.ID)
+	}
+
 	return map[string]interface{}{
-		"rules": ruleList,
-		"total": total,
-		"page":  p.Page,
-		"limit": p.Limit,
-		"pages": pages,
+		"rules":       ruleList,
+		"total":       total,
+		"page":        p.Page,
+		"limit":       p.Limit,
+		"pages":       pages,
+		"next_cursor": nextCursor,
 	}, nil
 }
 
@@ -218,17 +347,17 @@ type GetForwardRuleParams struct {
 func (m *GetForwardRuleMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p GetForwardRuleParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	rule, err := m.storage.Forward.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("rule not found")
+			return nil, resp.ErrNotFound("rule not found")
 		}
 		return nil, fmt.Errorf("failed to get rule: %w", err)
 	}
@@ -243,12 +372,38 @@ func (m *GetForwardRuleMethod) Execute(ctx context.Context, params json.RawMessa
 		"created_at":    rule.CreatedAt,
 		"updated_at":    rule.UpdatedAt,
 	}
+	if rule.EnabledFrom != nil {
+		result["enabled_from"] = rule.EnabledFrom
+	}
+	if rule.EnabledUntil != nil {
+		result["enabled_until"] = rule.EnabledUntil
+	}
 
-	if rule.Type == model.ForwardTypeDirect {
+	switch rule.Type {
+	case model.ForwardTypeDirect:
 		result["target_addr"] = rule.TargetAddr
-	} else {
+		if rule.CanaryTarget != "" {
+			result["canary_target"] = rule.CanaryTarget
+			result["canary_percent"] = rule.CanaryPercent
+		}
+		if rule.Via != "" {
+			result["via"] = rule.Via
+		}
+		if rule.MirrorAddr != "" {
+			result["mirror_addr"] = rule.MirrorAddr
+		}
+	case model.ForwardTypeSNI:
+		result["sni_map"] = rule.SNIMap
+	default:
 		result["relay_chain"] = rule.RelayChain
 		result["exit_addr"] = rule.ExitAddr
+		result["fallback_group"] = rule.FallbackGroup
+		if rule.PinnedNodeID != "" {
+			result["pinned_node_id"] = rule.PinnedNodeID
+		}
+		result["pool_conns"] = rule.PoolConns
+		result["drain_on_close"] = rule.DrainOnClose
+		result["verbose_logging"] = rule.VerboseLogging
 	}
 
 	return result, nil
@@ -260,10 +415,11 @@ func (m *GetForwardRuleMethod) RequireAuth() bool { return true }
 type UpdateForwardRuleMethod struct {
 	storage  *storage.Storage
 	wsServer *relay.WSServer
+	cfg      *conf.Config
 }
 
-func NewUpdateForwardRuleMethod(s *storage.Storage, ws *relay.WSServer) *UpdateForwardRuleMethod {
-	return &UpdateForwardRuleMethod{storage: s, wsServer: ws}
+func NewUpdateForwardRuleMethod(s *storage.Storage, ws *relay.WSServer, cfg *conf.Config) *UpdateForwardRuleMethod {
+	return &UpdateForwardRuleMethod{storage: s, wsServer: ws, cfg: cfg}
 }
 
 func (m *UpdateForwardRuleMethod) Name() string { return "updateForwardRule" }
@@ -273,9 +429,34 @@ type UpdateForwardRuleParams struct {
 	Name         *string   `json:"name"`
 	ListenAddr   *string   `json:"listen_addr"`
 	ListenClient *string   `json:"listen_client"`
-	TargetAddr   *string   `json:"target_addr"`
-	RelayChain   *[]string `json:"relay_chain"`
-	ExitAddr     *string   `json:"exit_addr"`
+	TargetAddr    *string            `json:"target_addr"`
+	CanaryTarget  *string            `json:"canary_target"`
+	CanaryPercent *int               `json:"canary_percent"`
+	Via           *string            `json:"via"`
+	RelayChain    *[]string          `json:"relay_chain"`
+	ExitAddr      *string            `json:"exit_addr"`
+	FallbackGroup *string            `json:"fallback_group"`
+	PinnedNodeID  *string            `json:"pinned_node_id"`
+	SNIMap        *map[string]string `json:"sni_map"`
+	PoolConns     *bool              `json:"pool_conns"`
+	DrainOnClose  *bool              `json:"drain_on_close"`
+
+	// 连接级详细日志
+	VerboseLogging *bool `json:"verbose_logging"`
+
+	// 流量镜像 tap 地址 (空字符串表示关闭镜像)
+	MirrorAddr *string `json:"mirror_addr"`
+
+	// 启用时间窗 (可选)
+	EnabledFrom  *time.Time `json:"enabled_from"`
+	EnabledUntil *time.Time `json:"enabled_until"`
+
+	TCPNoDelay           *bool `json:"tcp_nodelay"`
+	TCPKeepAliveInterval *int  `json:"tcp_keepalive_interval"`
+	TCPFastOpen          *bool `json:"tcp_fast_open"`
+
+	// 建连并发上限 (0 = 不限制)
+	ConnectConcurrency *int `json:"connect_concurrency"`
 }
 
 func (m *UpdateForwardRuleMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
@@ -283,19 +464,19 @@ func (m *UpdateForwardRuleMethod) Execute(ctx context.Context, params json.RawMe
 
 	var p UpdateForwardRuleParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	log.Info().Str("rule_id", p.ID).Msg("Updating forward rule")
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	rule, err := m.storage.Forward.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("rule not found")
+			return nil, resp.ErrNotFound("rule not found")
 		}
 		return nil, fmt.Errorf("failed to get rule: %w", err)
 	}
@@ -315,7 +496,7 @@ func (m *UpdateForwardRuleMethod) Execute(ctx context.Context, params json.RawMe
 		_, err := m.storage.Client.GetByID(*p.ListenClient)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil, errors.New("new listen_client not found")
+				return nil, resp.ErrNotFound("new listen_client not found")
 			}
 			return nil, fmt.Errorf("failed to verify new client: %w", err)
 		}
@@ -324,12 +505,93 @@ func (m *UpdateForwardRuleMethod) Execute(ctx context.Context, params json.RawMe
 	if p.TargetAddr != nil {
 		rule.TargetAddr = *p.TargetAddr
 	}
+	if p.CanaryTarget != nil {
+		rule.CanaryTarget = *p.CanaryTarget
+	}
+	if p.CanaryPercent != nil {
+		rule.CanaryPercent = *p.CanaryPercent
+	}
+	if p.Via != nil {
+		rule.Via = *p.Via
+	}
+	if rule.Type == model.ForwardTypeDirect {
+		if rule.CanaryPercent < 0 || rule.CanaryPercent > 100 {
+			return nil, resp.ErrInvalidParams("canary_percent must be between 0 and 100")
+		}
+		if rule.CanaryPercent > 0 && rule.CanaryTarget == "" {
+			return nil, resp.ErrInvalidParams("canary_target is required when canary_percent > 0")
+		}
+	}
 	if p.RelayChain != nil {
 		rule.RelayChain = *p.RelayChain
 	}
 	if p.ExitAddr != nil {
 		rule.ExitAddr = *p.ExitAddr
 	}
+	if p.FallbackGroup != nil {
+		rule.FallbackGroup = *p.FallbackGroup
+	}
+	if p.PinnedNodeID != nil {
+		// 空字符串表示解除钉选, 恢复正常负载均衡
+		rule.PinnedNodeID = *p.PinnedNodeID
+	}
+	if p.SNIMap != nil {
+		if rule.Type == model.ForwardTypeSNI && len(*p.SNIMap) == 0 {
+			return nil, resp.ErrInvalidParams("sni_map cannot be empty for sni type")
+		}
+		rule.SNIMap = *p.SNIMap
+	}
+	if p.PoolConns != nil {
+		rule.PoolConns = *p.PoolConns
+	}
+	if p.DrainOnClose != nil {
+		rule.DrainOnClose = *p.DrainOnClose
+	}
+	if p.VerboseLogging != nil {
+		rule.VerboseLogging = *p.VerboseLogging
+	}
+	if p.MirrorAddr != nil {
+		rule.MirrorAddr = *p.MirrorAddr
+	}
+	if p.EnabledFrom != nil {
+		rule.EnabledFrom = p.EnabledFrom
+	}
+	if p.EnabledUntil != nil {
+		rule.EnabledUntil = p.EnabledUntil
+	}
+	if rule.EnabledFrom != nil && rule.EnabledUntil != nil && !rule.EnabledFrom.Before(*rule.EnabledUntil) {
+		return nil, resp.ErrInvalidParams("enabled_from must be before enabled_until")
+	}
+	if p.TCPNoDelay != nil {
+		rule.TCPNoDelay = p.TCPNoDelay
+	}
+	if p.TCPKeepAliveInterval != nil {
+		rule.TCPKeepAliveInterval = *p.TCPKeepAliveInterval
+	}
+	if p.TCPFastOpen != nil {
+		rule.TCPFastOpen = *p.TCPFastOpen
+	}
+	if p.ConnectConcurrency != nil {
+		rule.ConnectConcurrency = *p.ConnectConcurrency
+	}
+
+	// 更新后的中继链不能绕回监听 client 自身 (默认拒绝, 可经配置关闭)
+	if rejectSelfLoopEnabled(m.cfg) && len(rule.RelayChain) > 0 {
+		if looped, hop := relayChainLoopsBack(m.storage, rule.ListenClient, rule.RelayChain); looped {
+			return nil, resp.ErrInvalidParams("relay chain loops back to listen client via " + hop)
+		}
+	}
+
+	// 启用规则不能与同 client 上的其他启用规则监听同一地址
+	if rule.Enabled {
+		conflict, err := m.storage.Forward.HasEnabledListenConflict(rule.ListenClient, rule.ListenAddr, rule.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check listen conflict: %w", err)
+		}
+		if conflict {
+			return nil, resp.ErrConflict("an enabled rule already listens on this address for this client")
+		}
+	}
 
 	// 如果监听地址或客户端发生变化，检查新端口是否可用
 	if (rule.ListenAddr != oldListenAddr || rule.ListenClient != oldListenClient) && m.wsServer != nil {
@@ -340,19 +602,21 @@ func (m *UpdateForwardRuleMethod) Execute(ctx context.Context, params json.RawMe
 			Str("new_client", rule.ListenClient).
 			Msg("Listen config changed, checking port availability on new client")
 
-		available, errMsg := m.wsServer.CheckPortAvailable(
+		result := m.wsServer.CheckPort(
 			rule.ListenClient,
 			rule.ListenAddr,
 			rule.ID,
 			5*time.Second, // 超时时间
 		)
-		if !available {
+		if !result.Available {
 			log.Warn().
 				Str("addr", rule.ListenAddr).
 				Str("client", rule.ListenClient).
-				Str("error", errMsg).
+				Str("status", result.Status).
+				Str("owner_rule_id", result.OwnerRuleID).
+				Str("error", result.Error).
 				Msg("Port check failed")
-			return nil, fmt.Errorf("端口 %s 在客户端 %s 上不可用: %s", rule.ListenAddr, rule.ListenClient, errMsg)
+			return nil, fmt.Errorf("端口 %s 在客户端 %s 上不可用: %s", rule.ListenAddr, rule.ListenClient, result.Error)
 		}
 		log.Info().Str("addr", rule.ListenAddr).Str("client", rule.ListenClient).Msg("Port check passed")
 	}
@@ -371,21 +635,28 @@ func (m *UpdateForwardRuleMethod) Execute(ctx context.Context, params json.RawMe
 
 	// 通知相关 client 规则已更新
 	if m.wsServer != nil {
-		// 如果客户端发生变化，需要先通知旧客户端停止，等待后再通知新客户端启动
+		// 如果客户端发生变化，需要先让旧客户端停止规则，确认后再通知新客户端启动
 		if oldListenClient != rule.ListenClient {
-			// 通知旧客户端（让它停止这个规则）
-			ok := m.wsServer.NotifyRuleUpdate(oldListenClient)
-			log.Info().
-				Str("rule_id", rule.ID).
-				Str("old_client", oldListenClient).
-				Bool("notify_success", ok).
-				Msg("Notified old client to stop rule")
-
-			// 等待旧客户端停止规则并释放端口
-			time.Sleep(500 * time.Millisecond)
+			// 带确认的停止请求: 旧客户端关闭监听、排空活跃连接后回报,
+			// 取代原先固定 500ms 的盲等 (既慢又不保证端口已释放)
+			if err := m.wsServer.StopRuleAndWait(oldListenClient, rule.ID, ruleStopWaitTimeout); err != nil {
+				log.Warn().
+					Err(err).
+					Str("rule_id", rule.ID).
+					Str("old_client", oldListenClient).
+					Msg("Rule stop confirmation failed, falling back to notify and grace period")
+				// 旧客户端离线或不支持确认时退回通知 + 短暂等待, 尽力让端口先释放
+				m.wsServer.NotifyRuleUpdate(oldListenClient)
+				time.Sleep(500 * time.Millisecond)
+			} else {
+				log.Info().
+					Str("rule_id", rule.ID).
+					Str("old_client", oldListenClient).
+					Msg("Old client confirmed rule stopped and drained")
+			}
 
 			// 通知新客户端（让它启动这个规则）
-			ok = m.wsServer.NotifyRuleUpdate(rule.ListenClient)
+			ok := m.wsServer.NotifyRuleUpdate(rule.ListenClient)
 			log.Info().
 				Str("rule_id", rule.ID).
 				Str("new_client", rule.ListenClient).
@@ -430,11 +701,11 @@ type DeleteForwardRuleParams struct {
 func (m *DeleteForwardRuleMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p DeleteForwardRuleParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	// 先获取规则，以便知道要通知哪个 client
@@ -479,18 +750,18 @@ type ToggleForwardRuleParams struct {
 func (m *ToggleForwardRuleMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p ToggleForwardRuleParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	// 先获取规则，以便知道要通知哪个 client
 	rule, err := m.storage.Forward.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("rule not found")
+			return nil, resp.ErrNotFound("rule not found")
 		}
 		return nil, fmt.Errorf("failed to get rule: %w", err)
 	}
@@ -510,3 +781,95 @@ func (m *ToggleForwardRuleMethod) Execute(ctx context.Context, params json.RawMe
 }
 
 func (m *ToggleForwardRuleMethod) RequireAuth() bool { return true }
+
+// BulkToggleForwardRulesMethod - 批量启用/禁用转发规则
+type BulkToggleForwardRulesMethod struct {
+	storage  *storage.Storage
+	wsServer *relay.WSServer
+}
+
+func NewBulkToggleForwardRulesMethod(s *storage.Storage, ws *relay.WSServer) *BulkToggleForwardRulesMethod {
+	return &BulkToggleForwardRulesMethod{storage: s, wsServer: ws}
+}
+
+func (m *BulkToggleForwardRulesMethod) Name() string { return "bulkToggleForwardRules" }
+
+type BulkToggleForwardRulesParams struct {
+	ClientID string   `json:"client_id"`
+	RuleIDs  []string `json:"rule_ids"`
+	Enabled  bool     `json:"enabled"`
+}
+
+func (m *BulkToggleForwardRulesMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p BulkToggleForwardRulesParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ClientID == "" && len(p.RuleIDs) == 0 {
+		return nil, resp.ErrInvalidParams("client_id or rule_ids is required")
+	}
+
+	// 解析目标规则：按 client 查询全部规则，或按显式 ID 列表逐条查询
+	var rules []*model.ForwardRule
+	results := make([]map[string]interface{}, 0)
+
+	if p.ClientID != "" {
+		clientRules, err := m.storage.Forward.GetAllByClientID(p.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rules: %w", err)
+		}
+		for i := range clientRules {
+			rules = append(rules, &clientRules[i])
+		}
+	} else {
+		for _, id := range p.RuleIDs {
+			rule, err := m.storage.Forward.GetByID(id)
+			if err != nil {
+				// 不存在的规则记录失败，不中断整个请求
+				results = append(results, map[string]interface{}{
+					"rule_id": id,
+					"success": false,
+					"error":   "rule not found",
+				})
+				continue
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	ids := make([]string, 0, len(rules))
+	for _, r := range rules {
+		ids = append(ids, r.ID)
+	}
+
+	if err := m.storage.Forward.ToggleEnabledBulk(ids, p.Enabled); err != nil {
+		return nil, fmt.Errorf("failed to toggle rules: %w", err)
+	}
+
+	for _, r := range rules {
+		results = append(results, map[string]interface{}{
+			"rule_id": r.ID,
+			"success": true,
+		})
+	}
+
+	// 每个受影响的 client 只通知一次
+	if m.wsServer != nil {
+		notified := make(map[string]bool)
+		for _, r := range rules {
+			if notified[r.ListenClient] {
+				continue
+			}
+			notified[r.ListenClient] = true
+			m.wsServer.NotifyRuleUpdate(r.ListenClient)
+		}
+	}
+
+	return map[string]interface{}{
+		"results": results,
+		"enabled": p.Enabled,
+	}, nil
+}
+
+func (m *BulkToggleForwardRulesMethod) RequireAuth() bool { return true }
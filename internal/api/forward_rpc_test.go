@@ -0,0 +1,263 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+// ===== BulkToggleForwardRules Tests =====
+
+func TestBulkToggleForwardRulesMethod_Name(t *testing.T) {
+	method := NewBulkToggleForwardRulesMethod(nil, nil)
+	if method.Name() != "bulkToggleForwardRules" {
+		t.Errorf("expected name 'bulkToggleForwardRules', got '%s'", method.Name())
+	}
+}
+
+func TestBulkToggleForwardRulesMethod_RequireAuth(t *testing.T) {
+	method := NewBulkToggleForwardRulesMethod(nil, nil)
+	if !method.RequireAuth() {
+		t.Error("expected RequireAuth to return true")
+	}
+}
+
+func TestBulkToggleForwardRulesMethod_MissingParams(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	method := NewBulkToggleForwardRulesMethod(store, nil)
+
+	_, err := method.Execute(context.Background(), json.RawMessage(`{"enabled": false}`))
+	if err == nil {
+		t.Error("expected error when neither client_id nor rule_ids provided")
+	}
+}
+
+func createTestRules(t *testing.T, store *storage.Storage, clientID string, n int) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		rule := &model.ForwardRule{
+			ID:           clientID + "-rule-" + string(rune('a'+i)),
+			Name:         "Rule " + string(rune('A'+i)),
+			Type:         model.ForwardTypeDirect,
+			Enabled:      true,
+			ListenAddr:   "0.0.0.0:808" + string(rune('0'+i)),
+			ListenClient: clientID,
+			TargetAddr:   "127.0.0.1:80",
+		}
+		if err := store.Forward.Create(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+func TestBulkToggleForwardRules_DisableAllForClient(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	createTestRules(t, store, "client-1", 3)
+
+	// 真实 WSServer + 一条已连接的 websocket client，用于统计通知数量
+	wsServer := relay.NewWSServer()
+	srv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer srv.Close()
+
+	wsURL := "ws" + srv.URL[len("http"):] + "?client_id=client-1"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// 等待服务端登记连接
+	deadline := time.Now().Add(time.Second)
+	for !wsServer.IsClientOnline("client-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered on ws server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	method := NewBulkToggleForwardRulesMethod(store, wsServer)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"client_id": "client-1", "enabled": false}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	res := result.(map[string]interface{})
+	results := res["results"].([]map[string]interface{})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 per-rule results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r["success"] != true {
+			t.Errorf("expected rule %v to succeed", r["rule_id"])
+		}
+	}
+
+	// 所有规则应已禁用
+	rules, err := store.Forward.GetAllByClientID("client-1")
+	if err != nil {
+		t.Fatalf("failed to list rules: %v", err)
+	}
+	for _, r := range rules {
+		if r.Enabled {
+			t.Errorf("rule %s should be disabled", r.ID)
+		}
+	}
+
+	// 应该只收到一条 RuleUpdate 通知
+	notifications := 0
+	conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		msg, err := relay.UnmarshalTunnelMessage(data)
+		if err != nil {
+			continue
+		}
+		if msg.Type == relay.MsgTypeRuleUpdate {
+			notifications++
+		}
+	}
+	if notifications != 1 {
+		t.Errorf("expected exactly 1 rule update notification, got %d", notifications)
+	}
+}
+
+// ===== Listen Conflict Tests =====
+
+func seedTestClient(t *testing.T, store *storage.Storage, id string) {
+	t.Helper()
+	client := &model.Client{
+		ID:    id,
+		Name:  "Client " + id,
+		Token: "token-" + id,
+	}
+	if err := store.Client.Create(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+}
+
+func TestCreateForwardRule_ListenConflictRejected(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "client-c")
+	createTestRules(t, store, "client-c", 1) // 占用 0.0.0.0:8080
+
+	method := NewCreateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"name":          "conflict",
+		"type":          "direct",
+		"listen_addr":   "0.0.0.0:8080",
+		"listen_client": "client-c",
+		"target_addr":   "127.0.0.1:80",
+	})
+
+	_, err := method.Execute(context.Background(), params)
+	if err == nil {
+		t.Fatal("expected conflict error for duplicate listen address")
+	}
+}
+
+func TestCreateForwardRule_DifferentPortSucceeds(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "client-d")
+	createTestRules(t, store, "client-d", 1)
+
+	method := NewCreateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"name":          "other-port",
+		"type":          "direct",
+		"listen_addr":   "0.0.0.0:9090",
+		"listen_client": "client-d",
+		"target_addr":   "127.0.0.1:80",
+	})
+
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("expected create on different port to succeed, got: %v", err)
+	}
+}
+
+func TestCreateForwardRule_DisabledRuleDoesNotConflict(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "client-e")
+	ids := createTestRules(t, store, "client-e", 1)
+	if err := store.Forward.ToggleEnabled(ids[0], false); err != nil {
+		t.Fatalf("failed to disable rule: %v", err)
+	}
+
+	method := NewCreateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"name":          "reuse-addr",
+		"type":          "direct",
+		"listen_addr":   "0.0.0.0:8080",
+		"listen_client": "client-e",
+		"target_addr":   "127.0.0.1:80",
+	})
+
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("expected disabled rule not to conflict, got: %v", err)
+	}
+}
+
+func TestUpdateForwardRule_ListenConflictRejected(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "client-f")
+	ids := createTestRules(t, store, "client-f", 2) // :8080 和 :8081
+
+	method := NewUpdateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"id":          ids[1],
+		"listen_addr": "0.0.0.0:8080",
+	})
+
+	_, err := method.Execute(context.Background(), params)
+	if err == nil {
+		t.Fatal("expected conflict error when updating to an occupied listen address")
+	}
+}
+
+func TestBulkToggleForwardRules_ByRuleIDsWithMissing(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	ids := createTestRules(t, store, "client-2", 2)
+
+	method := NewBulkToggleForwardRulesMethod(store, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"rule_ids": append(ids, "no-such-rule"),
+		"enabled":  false,
+	})
+
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	res := result.(map[string]interface{})
+	results := res["results"].([]map[string]interface{})
+	if len(results) != 3 {
+		t.Fatalf("expected 3 per-rule results, got %d", len(results))
+	}
+
+	succeeded := 0
+	for _, r := range results {
+		if r["success"] == true {
+			succeeded++
+		} else if r["rule_id"] != "no-such-rule" {
+			t.Errorf("unexpected failure for rule %v", r["rule_id"])
+		}
+	}
+	if succeeded != 2 {
+		t.Errorf("expected 2 successes, got %d", succeeded)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// resolveRelayChain 对链上每个组引用都要查两次库 (GetByID + GetByName),
+// clientGetRules 被全部在线 client 周期性调用时开销是 O(链长 × client 数)。
+// 组的名称/ID 映射很少变化, 这里做短 TTL 缓存, 组创建/更新/删除时整体失效
+const groupRefCacheTTL = 30 * time.Second
+
+type groupRefEntry struct {
+	resolved  string
+	expiresAt time.Time
+}
+
+type groupRefCache struct {
+	mu      sync.Mutex
+	entries map[string]groupRefEntry
+}
+
+// relayGroupRefs 进程级缓存: key 为去掉 "@" 的组引用 (名称或 ID)
+var relayGroupRefs = &groupRefCache{entries: make(map[string]groupRefEntry)}
+
+func (c *groupRefCache) get(ref string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[ref]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.resolved, true
+}
+
+func (c *groupRefCache) put(ref, resolved string) {
+	c.mu.Lock()
+	c.entries[ref] = groupRefEntry{
+		resolved:  resolved,
+		expiresAt: time.Now().Add(groupRefCacheTTL),
+	}
+	c.mu.Unlock()
+}
+
+func (c *groupRefCache) invalidate() {
+	c.mu.Lock()
+	c.entries = make(map[string]groupRefEntry)
+	c.mu.Unlock()
+}
+
+// invalidateGroupRefCache 组创建/更新/删除后调用, 使解析缓存整体失效。
+// 变更频率远低于解析频率, 不值得做按条目的精确失效
+func invalidateGroupRefCache() {
+	relayGroupRefs.invalidate()
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func TestResolveRelayChain_CachesGroupResolution(t *testing.T) {
+	invalidateGroupRefCache()
+	t.Cleanup(invalidateGroupRefCache)
+
+	store := setupTestStorageWithGroups(t)
+	group := &model.ProxyGroup{ID: "grc-1", Name: "us-nodes"}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+
+	chain := resolveRelayChain(store, []string{"@us-nodes"})
+	if chain[0] != "@grc-1" {
+		t.Fatalf("expected @us-nodes resolved to @grc-1, got %v", chain)
+	}
+
+	// 绕过 RPC 直接改库 (不触发失效): 命中缓存时应返回旧结果而不是查库
+	group.Name = "us-west"
+	if err := store.ProxyGroup.Update(group); err != nil {
+		t.Fatalf("failed to rename group: %v", err)
+	}
+	chain = resolveRelayChain(store, []string{"@us-nodes"})
+	if chain[0] != "@grc-1" {
+		t.Fatalf("expected cached resolution @grc-1 despite rename, got %v", chain)
+	}
+}
+
+func TestUpdateProxyGroup_RenameInvalidatesResolutionCache(t *testing.T) {
+	invalidateGroupRefCache()
+	t.Cleanup(invalidateGroupRefCache)
+
+	store := setupTestStorageWithGroups(t)
+	group := &model.ProxyGroup{ID: "grc-2", Name: "old-pool"}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+
+	if chain := resolveRelayChain(store, []string{"@old-pool"}); chain[0] != "@grc-2" {
+		t.Fatalf("expected @old-pool resolved to @grc-2, got %v", chain)
+	}
+
+	// 通过 RPC 改名会使缓存失效
+	method := NewUpdateProxyGroupMethod(store)
+	params, _ := json.Marshal(map[string]interface{}{
+		"id":   "grc-2",
+		"name": "new-pool",
+	})
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("failed to rename group via RPC: %v", err)
+	}
+
+	if chain := resolveRelayChain(store, []string{"@new-pool"}); chain[0] != "@grc-2" {
+		t.Fatalf("expected @new-pool resolved to @grc-2 after rename, got %v", chain)
+	}
+	// 旧名称不再命中缓存, 重新查库后保持原样
+	if chain := resolveRelayChain(store, []string{"@old-pool"}); chain[0] != "@old-pool" {
+		t.Fatalf("expected stale name to stay unresolved after invalidation, got %v", chain)
+	}
+}
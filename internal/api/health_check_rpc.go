@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+// OnDemandHealthChecker 按需触发健康检查 (由 proxy.HealthChecker 实现)
+type OnDemandHealthChecker interface {
+	CheckGroupHealth(groupID string) error
+	CheckNodeHealth(nodeID string) error
+}
+
+// nodeStatusSummary 探测后的节点状态摘要
+func nodeStatusSummary(n *model.ProxyGroupNode) map[string]interface{} {
+	status := map[string]interface{}{
+		"node_id":       n.ID,
+		"client_id":     n.ClientID,
+		"status":        n.Status,
+		"last_check_at": n.LastCheckAt,
+		"last_check_ok": n.LastCheckOK,
+		"fail_count":    n.FailCount,
+	}
+	if n.LastCheckError != "" {
+		status["last_check_error"] = n.LastCheckError
+	}
+	return status
+}
+
+// CheckProxyGroupNowMethod - 立即检查整组节点健康
+type CheckProxyGroupNowMethod struct {
+	storage       *storage.Storage
+	healthChecker OnDemandHealthChecker
+}
+
+func NewCheckProxyGroupNowMethod(s *storage.Storage) *CheckProxyGroupNowMethod {
+	return &CheckProxyGroupNowMethod{storage: s}
+}
+
+// SetHealthChecker 注入健康检查器
+func (m *CheckProxyGroupNowMethod) SetHealthChecker(hc OnDemandHealthChecker) {
+	m.healthChecker = hc
+}
+
+func (m *CheckProxyGroupNowMethod) Name() string { return "checkProxyGroupNow" }
+
+type CheckProxyGroupNowParams struct {
+	GroupID string `json:"group_id"`
+}
+
+func (m *CheckProxyGroupNowMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p CheckProxyGroupNowParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.GroupID == "" {
+		return nil, resp.ErrInvalidParams("group_id is required")
+	}
+	if m.healthChecker == nil {
+		return nil, fmt.Errorf("health checker not initialized")
+	}
+
+	if err := m.healthChecker.CheckGroupHealth(p.GroupID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resp.ErrNotFound("group not found")
+		}
+		return nil, fmt.Errorf("failed to check group: %w", err)
+	}
+
+	// 返回探测后的最新节点状态
+	nodes, err := m.storage.ProxyGroup.GetNodesByGroupID(p.GroupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nodes: %w", err)
+	}
+
+	nodeList := make([]map[string]interface{}, len(nodes))
+	for i, n := range nodes {
+		nodeList[i] = nodeStatusSummary(&n)
+	}
+
+	return map[string]interface{}{
+		"group_id": p.GroupID,
+		"nodes":    nodeList,
+	}, nil
+}
+
+func (m *CheckProxyGroupNowMethod) RequireAuth() bool { return true }
+
+// CheckProxyGroupNodeNowMethod - 立即检查单个节点健康
+type CheckProxyGroupNodeNowMethod struct {
+	storage       *storage.Storage
+	healthChecker OnDemandHealthChecker
+}
+
+func NewCheckProxyGroupNodeNowMethod(s *storage.Storage) *CheckProxyGroupNodeNowMethod {
+	return &CheckProxyGroupNodeNowMethod{storage: s}
+}
+
+// SetHealthChecker 注入健康检查器
+func (m *CheckProxyGroupNodeNowMethod) SetHealthChecker(hc OnDemandHealthChecker) {
+	m.healthChecker = hc
+}
+
+func (m *CheckProxyGroupNodeNowMethod) Name() string { return "checkProxyGroupNodeNow" }
+
+type CheckProxyGroupNodeNowParams struct {
+	NodeID string `json:"node_id"`
+}
+
+func (m *CheckProxyGroupNodeNowMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p CheckProxyGroupNodeNowParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.NodeID == "" {
+		return nil, resp.ErrInvalidParams("node_id is required")
+	}
+	if m.healthChecker == nil {
+		return nil, fmt.Errorf("health checker not initialized")
+	}
+
+	if err := m.healthChecker.CheckNodeHealth(p.NodeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resp.ErrNotFound("node not found")
+		}
+		return nil, fmt.Errorf("failed to check node: %w", err)
+	}
+
+	node, err := m.storage.ProxyGroup.GetNode(p.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node: %w", err)
+	}
+
+	return nodeStatusSummary(node), nil
+}
+
+func (m *CheckProxyGroupNodeNowMethod) RequireAuth() bool { return true }
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dollarkillerx/MessageBoy/internal/proxy"
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func setupOnDemandCheck(t *testing.T) (*storage.Storage, *proxy.HealthChecker) {
+	t.Helper()
+	store := setupTestStorageWithGroup(t)
+
+	group := &model.ProxyGroup{
+		ID:                 "hc-group",
+		Name:               "hc-group",
+		HealthCheckEnabled: true,
+	}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	node := &model.ProxyGroupNode{
+		ID:       "hc-node",
+		GroupID:  "hc-group",
+		ClientID: "offline-client",
+		Status:   model.NodeStatusHealthy,
+	}
+	if err := store.ProxyGroup.AddNode(node); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	// 没有任何在线连接的 WSServer: 探测必然判定离线
+	hc := proxy.NewHealthChecker(store, relay.NewWSServer())
+	return store, hc
+}
+
+func TestCheckProxyGroupNodeNow_UpdatesStatus(t *testing.T) {
+	store, hc := setupOnDemandCheck(t)
+
+	method := NewCheckProxyGroupNodeNowMethod(store)
+	method.SetHealthChecker(hc)
+
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"node_id": "hc-node"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	summary := result.(map[string]interface{})
+	if summary["last_check_ok"] != false {
+		t.Errorf("expected last_check_ok false, got %v", summary["last_check_ok"])
+	}
+	if summary["status"] != model.NodeStatusUnhealthy {
+		t.Errorf("expected status %s, got %v", model.NodeStatusUnhealthy, summary["status"])
+	}
+
+	// 落库状态同步更新
+	node, err := store.ProxyGroup.GetNode("hc-node")
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if node.Status != model.NodeStatusUnhealthy {
+		t.Errorf("expected node marked unhealthy in storage, got %s", node.Status)
+	}
+	if node.LastCheckAt == nil {
+		t.Error("expected last_check_at to be set")
+	}
+}
+
+func TestCheckProxyGroupNow_ReturnsNodeStatuses(t *testing.T) {
+	store, hc := setupOnDemandCheck(t)
+
+	method := NewCheckProxyGroupNowMethod(store)
+	method.SetHealthChecker(hc)
+
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"group_id": "hc-group"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	res := result.(map[string]interface{})
+	nodes := res["nodes"].([]map[string]interface{})
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(nodes))
+	}
+	if nodes[0]["node_id"] != "hc-node" {
+		t.Errorf("expected node_id hc-node, got %v", nodes[0]["node_id"])
+	}
+	if nodes[0]["status"] != model.NodeStatusUnhealthy {
+		t.Errorf("expected status %s, got %v", model.NodeStatusUnhealthy, nodes[0]["status"])
+	}
+}
+
+func TestCheckProxyGroupNow_GroupNotFound(t *testing.T) {
+	store, hc := setupOnDemandCheck(t)
+
+	method := NewCheckProxyGroupNowMethod(store)
+	method.SetHealthChecker(hc)
+
+	_, err := method.Execute(context.Background(), json.RawMessage(`{"group_id": "no-such-group"}`))
+	if err == nil {
+		t.Fatal("expected error for unknown group")
+	}
+}
+
+func TestCheckProxyGroupNodeNow_CheckerNotReady(t *testing.T) {
+	store, _ := setupOnDemandCheck(t)
+
+	method := NewCheckProxyGroupNodeNowMethod(store)
+	if _, err := method.Execute(context.Background(), json.RawMessage(`{"node_id": "hc-node"}`)); err == nil {
+		t.Fatal("expected error when health checker is not injected")
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dollarkillerx/MessageBoy/internal/proxy"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+// simulateMaxCount 单次模拟的选择次数上限
+const simulateMaxCount = 100
+
+// SimulateLoadBalanceMethod - 预演负载均衡选择 (调试用)
+// 按给定的组和来源 IP 重放 SelectNode, 返回每次命中的节点, 不改动连接计数
+type SimulateLoadBalanceMethod struct {
+	loadBalancer *proxy.LoadBalancer
+}
+
+func NewSimulateLoadBalanceMethod() *SimulateLoadBalanceMethod {
+	return &SimulateLoadBalanceMethod{}
+}
+
+// SetLoadBalancer 注入负载均衡器 (与 ApiServer.SetLoadBalancer 同步调用)
+func (m *SimulateLoadBalanceMethod) SetLoadBalancer(lb *proxy.LoadBalancer) {
+	m.loadBalancer = lb
+}
+
+func (m *SimulateLoadBalanceMethod) Name() string { return "simulateLoadBalance" }
+
+type SimulateLoadBalanceParams struct {
+	Group    string `json:"group"`     // 组名或组 ID
+	ClientIP string `json:"client_ip"` // 采样来源 IP (ip_hash 时影响结果)
+	Count    int    `json:"count"`     // 模拟次数, 默认 1
+}
+
+func (m *SimulateLoadBalanceMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p SimulateLoadBalanceParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.Group == "" {
+		return nil, resp.ErrInvalidParams("group is required")
+	}
+	if p.Count <= 0 {
+		p.Count = 1
+	}
+	if p.Count > simulateMaxCount {
+		return nil, resp.ErrInvalidParams(fmt.Sprintf("count must be at most %d", simulateMaxCount))
+	}
+	if m.loadBalancer == nil {
+		return nil, fmt.Errorf("load balancer not ready")
+	}
+
+	selections := make([]map[string]interface{}, 0, p.Count)
+	for i := 0; i < p.Count; i++ {
+		clientID, nodeID, err := m.loadBalancer.ResolveTarget("@"+p.Group, p.ClientIP)
+		if err != nil {
+			if errors.Is(err, proxy.ErrGroupNotFound) {
+				return nil, resp.ErrNotFound("group not found")
+			}
+			if errors.Is(err, proxy.ErrNoHealthyNodes) {
+				return nil, resp.ErrNotFound("no healthy nodes available")
+			}
+			return nil, fmt.Errorf("failed to select node: %w", err)
+		}
+		selections = append(selections, map[string]interface{}{
+			"node_id":   nodeID,
+			"client_id": clientID,
+		})
+	}
+
+	return map[string]interface{}{
+		"group":      p.Group,
+		"client_ip":  p.ClientIP,
+		"count":      p.Count,
+		"selections": selections,
+	}, nil
+}
+
+func (m *SimulateLoadBalanceMethod) RequireAuth() bool { return true }
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/dollarkillerx/MessageBoy/internal/proxy"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func setupSimulateMethod(t *testing.T, method model.LoadBalanceMethod) (*SimulateLoadBalanceMethod, *storage.Storage) {
+	t.Helper()
+	store := setupTestStorageWithGroup(t)
+
+	group := &model.ProxyGroup{
+		ID:                "lb-group",
+		Name:              "lb-group",
+		LoadBalanceMethod: method,
+	}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		node := &model.ProxyGroupNode{
+			ID:       fmt.Sprintf("node-%d", i),
+			GroupID:  "lb-group",
+			ClientID: fmt.Sprintf("client-%d", i),
+			Priority: i,
+			Status:   model.NodeStatusHealthy,
+		}
+		if err := store.ProxyGroup.AddNode(node); err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+	}
+
+	m := NewSimulateLoadBalanceMethod()
+	m.SetLoadBalancer(proxy.NewLoadBalancer(store))
+	return m, store
+}
+
+func simulateSelections(t *testing.T, m *SimulateLoadBalanceMethod, params string) []map[string]interface{} {
+	t.Helper()
+	result, err := m.Execute(context.Background(), json.RawMessage(params))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	return result.(map[string]interface{})["selections"].([]map[string]interface{})
+}
+
+func TestSimulateLoadBalance_RoundRobinRotation(t *testing.T) {
+	m, _ := setupSimulateMethod(t, model.LoadBalanceRoundRobin)
+
+	selections := simulateSelections(t, m, `{"group": "lb-group", "count": 6}`)
+	if len(selections) != 6 {
+		t.Fatalf("expected 6 selections, got %d", len(selections))
+	}
+
+	// 轮询: 每个节点恰好命中两次, 且按周期 3 循环
+	hits := make(map[string]int)
+	for i, sel := range selections {
+		nodeID := sel["node_id"].(string)
+		hits[nodeID]++
+		if i >= 3 && nodeID != selections[i-3]["node_id"] {
+			t.Errorf("selection %d (%s) breaks rotation period", i, nodeID)
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("node-%d", i)
+		if hits[id] != 2 {
+			t.Errorf("expected node %s to be hit twice, got %d", id, hits[id])
+		}
+	}
+}
+
+func TestSimulateLoadBalance_IPHashStable(t *testing.T) {
+	m, _ := setupSimulateMethod(t, model.LoadBalanceIPHash)
+
+	selections := simulateSelections(t, m, `{"group": "lb-group", "client_ip": "203.0.113.7", "count": 5}`)
+	first := selections[0]["node_id"].(string)
+	for i, sel := range selections {
+		if sel["node_id"] != first {
+			t.Errorf("selection %d: expected stable node %s for fixed IP, got %v", i, first, sel["node_id"])
+		}
+	}
+
+	// 再次模拟同一 IP 应命中同一节点
+	again := simulateSelections(t, m, `{"group": "lb-group", "client_ip": "203.0.113.7"}`)
+	if again[0]["node_id"] != first {
+		t.Errorf("expected repeated simulation to stay on %s, got %v", first, again[0]["node_id"])
+	}
+}
+
+func TestSimulateLoadBalance_GroupByName(t *testing.T) {
+	m, _ := setupSimulateMethod(t, model.LoadBalanceRoundRobin)
+
+	// 组名和组 ID 都可作为 group 参数
+	byName := simulateSelections(t, m, `{"group": "lb-group"}`)
+	if len(byName) != 1 {
+		t.Fatalf("expected 1 selection, got %d", len(byName))
+	}
+}
+
+func TestSimulateLoadBalance_UnknownGroup(t *testing.T) {
+	m, _ := setupSimulateMethod(t, model.LoadBalanceRoundRobin)
+
+	if _, err := m.Execute(context.Background(), json.RawMessage(`{"group": "missing"}`)); err == nil {
+		t.Error("expected error for unknown group")
+	}
+}
+
+func TestSimulateLoadBalance_CountTooLarge(t *testing.T) {
+	m, _ := setupSimulateMethod(t, model.LoadBalanceRoundRobin)
+
+	if _, err := m.Execute(context.Background(), json.RawMessage(`{"group": "lb-group", "count": 1000}`)); err == nil {
+		t.Error("expected error for excessive count")
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+// SetMaintenanceModeMethod 设置维护模式。
+// 开启后服务器拒绝新的中继连接, 存量连接继续中继, 用于维护窗口前排空流量
+type SetMaintenanceModeMethod struct {
+	wsServer *relay.WSServer
+}
+
+func NewSetMaintenanceModeMethod(ws *relay.WSServer) *SetMaintenanceModeMethod {
+	return &SetMaintenanceModeMethod{wsServer: ws}
+}
+
+func (m *SetMaintenanceModeMethod) Name() string      { return "setMaintenanceMode" }
+func (m *SetMaintenanceModeMethod) RequireAuth() bool { return true }
+
+type SetMaintenanceModeParams struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (m *SetMaintenanceModeMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p SetMaintenanceModeParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	m.wsServer.SetMaintenanceMode(p.Enabled)
+	return map[string]interface{}{
+		"maintenance_mode": p.Enabled,
+	}, nil
+}
+
+// GetServerStatsMethod 获取服务器运行状态 (维护模式/在线客户端/活跃路由)
+type GetServerStatsMethod struct {
+	wsServer *relay.WSServer
+}
+
+func NewGetServerStatsMethod(ws *relay.WSServer) *GetServerStatsMethod {
+	return &GetServerStatsMethod{wsServer: ws}
+}
+
+func (m *GetServerStatsMethod) Name() string      { return "getServerStats" }
+func (m *GetServerStatsMethod) RequireAuth() bool { return true }
+
+func (m *GetServerStatsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"maintenance_mode": m.wsServer.InMaintenanceMode(),
+		"online_clients":   m.wsServer.OnlineClientCount(),
+		"active_routes":    m.wsServer.ActiveRouteCount(),
+	}, nil
+}
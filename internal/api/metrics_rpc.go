@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// GetPayloadSizeHistogramMethod - 获取中继载荷大小直方图
+type GetPayloadSizeHistogramMethod struct {
+	wsServer *relay.WSServer
+}
+
+func NewGetPayloadSizeHistogramMethod(ws *relay.WSServer) *GetPayloadSizeHistogramMethod {
+	return &GetPayloadSizeHistogramMethod{wsServer: ws}
+}
+
+func (m *GetPayloadSizeHistogramMethod) Name() string { return "getPayloadSizeHistogram" }
+
+func (m *GetPayloadSizeHistogramMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	buckets := m.wsServer.PayloadHistogramSnapshot()
+	enabled := buckets != nil
+	if buckets == nil {
+		buckets = []relay.HistogramBucket{}
+	}
+
+	return map[string]interface{}{
+		"enabled": enabled,
+		"buckets": buckets,
+	}, nil
+}
+
+func (m *GetPayloadSizeHistogramMethod) RequireAuth() bool { return true }
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"github.com/dollarkillerx/MessageBoy/internal/proxy"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
@@ -39,17 +42,17 @@ type CreateProxyGroupParams struct {
 func (m *CreateProxyGroupMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p CreateProxyGroupParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Name == "" {
-		return nil, errors.New("name is required")
+		return nil, resp.ErrInvalidParams("name is required")
 	}
 
 	// 检查名称是否已存在
 	_, err := m.storage.ProxyGroup.GetByName(p.Name)
 	if err == nil {
-		return nil, errors.New("group name already exists")
+		return nil, resp.ErrConflict("group name already exists")
 	}
 
 	group := &model.ProxyGroup{
@@ -60,7 +63,11 @@ func (m *CreateProxyGroupMethod) Execute(ctx context.Context, params json.RawMes
 	}
 
 	if p.LoadBalanceMethod != "" {
-		group.LoadBalanceMethod = model.LoadBalanceMethod(p.LoadBalanceMethod)
+		method := model.LoadBalanceMethod(p.LoadBalanceMethod)
+		if !method.Valid() {
+			return nil, resp.ErrInvalidParams("unknown load balance method: " + p.LoadBalanceMethod)
+		}
+		group.LoadBalanceMethod = method
 	}
 	if p.HealthCheckEnabled != nil {
 		group.HealthCheckEnabled = *p.HealthCheckEnabled
@@ -86,6 +93,7 @@ func (m *CreateProxyGroupMethod) Execute(ctx context.Context, params json.RawMes
 	if err := m.storage.ProxyGroup.Create(group); err != nil {
 		return nil, fmt.Errorf("failed to create group: %w", err)
 	}
+	invalidateGroupRefCache()
 
 	return map[string]any{
 		"id":   group.ID,
@@ -110,12 +118,14 @@ type GetProxyGroupListParams struct {
 	Page   int    `json:"page"`
 	Limit  int    `json:"limit"`
 	Search string `json:"search"`
+	// 可选的键集分页游标 (来自上一页的 next_cursor, 设置后忽略 page)
+	Cursor string `json:"cursor"`
 }
 
 func (m *GetProxyGroupListMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p GetProxyGroupListParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Page <= 0 {
@@ -125,11 +135,21 @@ func (m *GetProxyGroupListMethod) Execute(ctx context.Context, params json.RawMe
 		p.Limit = 20
 	}
 
-	groups, total, err := m.storage.ProxyGroup.List(storage.ProxyGroupListParams{
+	listParams := storage.ProxyGroupListParams{
 		Page:   p.Page,
 		Limit:  p.Limit,
 		Search: p.Search,
-	})
+	}
+	if p.Cursor != "" {
+		afterCreatedAt, afterID, err := decodeListCursor(p.Cursor)
+		if err != nil {
+			return nil, resp.ErrInvalidParams("invalid cursor")
+		}
+		listParams.AfterCreatedAt = afterCreatedAt
+		listParams.AfterID = afterID
+	}
+
+	groups, total, err := m.storage.ProxyGroup.List(listParams)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get group list: %w", err)
 	}
@@ -173,26 +193,57 @@ func (m *GetProxyGroupListMethod) Execute(ctx context.Context, params json.RawMe
 
 	pages := (total + int64(p.Limit) - 1) / int64(p.Limit)
 
+	// 满页时返回游标供下一页使用, 否则说明已到末尾
+	nextCursor := ""
+	if len(groups) == p.Limit {
+		last := groups[len(groups)-1]
+		nextCursor = encodeListCursor(last.CreatedAt, last.ID)
+	}
+
 	return map[string]any{
-		"groups": groupList,
-		"total":  total,
-		"page":   p.Page,
-		"limit":  p.Limit,
-		"pages":  pages,
+		"groups":      groupList,
+		"total":       total,
+		"page":        p.Page,
+		"limit":       p.Limit,
+		"pages":       pages,
+		"next_cursor": nextCursor,
 	}, nil
 }
 
 func (m *GetProxyGroupListMethod) RequireAuth() bool { return true }
 
+// NodeHealthHistory 提供节点健康检查历史 (由 proxy.HealthChecker 实现)
+type NodeHealthHistory interface {
+	NodeCheckHistory(nodeID string) []proxy.CheckResult
+	IsNodeFlapping(nodeID string) bool
+}
+
+// NodeSelectionCounts 提供节点被负载均衡选中的累计次数 (由 proxy.LoadBalancer 实现)
+type NodeSelectionCounts interface {
+	SelectionCount(nodeID string) uint64
+}
+
 // GetProxyGroupMethod - 获取单个代理组详情
 type GetProxyGroupMethod struct {
-	storage *storage.Storage
+	storage       *storage.Storage
+	healthChecker NodeHealthHistory
+	loadBalancer  NodeSelectionCounts
 }
 
 func NewGetProxyGroupMethod(s *storage.Storage) *GetProxyGroupMethod {
 	return &GetProxyGroupMethod{storage: s}
 }
 
+// SetHealthChecker 注入健康检查器，用于暴露节点检查历史
+func (m *GetProxyGroupMethod) SetHealthChecker(hc NodeHealthHistory) {
+	m.healthChecker = hc
+}
+
+// SetLoadBalancer 注入负载均衡器, 用于暴露节点被选中次数 (观察选择分布)
+func (m *GetProxyGroupMethod) SetLoadBalancer(lb NodeSelectionCounts) {
+	m.loadBalancer = lb
+}
+
 func (m *GetProxyGroupMethod) Name() string { return "getProxyGroup" }
 
 type GetProxyGroupParams struct {
@@ -202,17 +253,17 @@ type GetProxyGroupParams struct {
 func (m *GetProxyGroupMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p GetProxyGroupParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	group, nodes, err := m.storage.ProxyGroup.GetGroupWithNodes(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("group not found")
+			return nil, resp.ErrNotFound("group not found")
 		}
 		return nil, fmt.Errorf("failed to get group: %w", err)
 	}
@@ -230,10 +281,20 @@ func (m *GetProxyGroupMethod) Execute(ctx context.Context, params json.RawMessag
 			"last_check_ok": n.LastCheckOK,
 			"fail_count":    n.FailCount,
 		}
+		if n.LastCheckError != "" {
+			node["last_check_error"] = n.LastCheckError
+		}
 		if n.Client != nil {
 			node["client_name"] = n.Client.Name
 			node["client_status"] = n.Client.Status
 		}
+		if m.healthChecker != nil {
+			node["check_history"] = m.healthChecker.NodeCheckHistory(n.ID)
+			node["flapping"] = m.healthChecker.IsNodeFlapping(n.ID)
+		}
+		if m.loadBalancer != nil {
+			node["selection_count"] = m.loadBalancer.SelectionCount(n.ID)
+		}
 		nodeList[i] = node
 	}
 
@@ -279,17 +340,17 @@ type UpdateProxyGroupParams struct {
 func (m *UpdateProxyGroupMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p UpdateProxyGroupParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	group, err := m.storage.ProxyGroup.GetByID(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("group not found")
+			return nil, resp.ErrNotFound("group not found")
 		}
 		return nil, fmt.Errorf("failed to get group: %w", err)
 	}
@@ -301,7 +362,11 @@ func (m *UpdateProxyGroupMethod) Execute(ctx context.Context, params json.RawMes
 		group.Description = *p.Description
 	}
 	if p.LoadBalanceMethod != nil {
-		group.LoadBalanceMethod = model.LoadBalanceMethod(*p.LoadBalanceMethod)
+		method := model.LoadBalanceMethod(*p.LoadBalanceMethod)
+		if !method.Valid() {
+			return nil, resp.ErrInvalidParams("unknown load balance method: " + *p.LoadBalanceMethod)
+		}
+		group.LoadBalanceMethod = method
 	}
 	if p.HealthCheckEnabled != nil {
 		group.HealthCheckEnabled = *p.HealthCheckEnabled
@@ -319,12 +384,104 @@ func (m *UpdateProxyGroupMethod) Execute(ctx context.Context, params json.RawMes
 	if err := m.storage.ProxyGroup.Update(group); err != nil {
 		return nil, fmt.Errorf("failed to update group: %w", err)
 	}
+	// 名称可能变化, 让组引用解析缓存失效
+	invalidateGroupRefCache()
 
 	return map[string]any{"success": true}, nil
 }
 
 func (m *UpdateProxyGroupMethod) RequireAuth() bool { return true }
 
+// findGroupReferences 扫描引用了指定代理组的 relay 规则
+// 同时匹配 @name 和 @id 两种引用形式
+func findGroupReferences(s *storage.Storage, group *model.ProxyGroup) ([]model.ForwardRule, error) {
+	rules, err := s.Forward.GetRelayRules()
+	if err != nil {
+		return nil, err
+	}
+
+	var referencing []model.ForwardRule
+	for _, rule := range rules {
+		for _, item := range rule.RelayChain {
+			if !storage.IsGroupReference(item) {
+				continue
+			}
+			ref := storage.ParseGroupReference(item)
+			if ref == group.ID || ref == group.Name {
+				referencing = append(referencing, rule)
+				break
+			}
+		}
+	}
+	return referencing, nil
+}
+
+// GetProxyGroupUsageMethod - 查询哪些规则/客户端引用了代理组
+type GetProxyGroupUsageMethod struct {
+	storage *storage.Storage
+}
+
+func NewGetProxyGroupUsageMethod(s *storage.Storage) *GetProxyGroupUsageMethod {
+	return &GetProxyGroupUsageMethod{storage: s}
+}
+
+func (m *GetProxyGroupUsageMethod) Name() string { return "getProxyGroupUsage" }
+
+type GetProxyGroupUsageParams struct {
+	ID string `json:"id"`
+}
+
+func (m *GetProxyGroupUsageMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
+	var p GetProxyGroupUsageParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	if p.ID == "" {
+		return nil, resp.ErrInvalidParams("id is required")
+	}
+
+	group, err := m.storage.ProxyGroup.GetByID(p.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, resp.ErrNotFound("group not found")
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	rules, err := findGroupReferences(m.storage, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan rules: %w", err)
+	}
+
+	ruleList := make([]map[string]any, len(rules))
+	clientIDs := make(map[string]bool)
+	for i, r := range rules {
+		ruleList[i] = map[string]any{
+			"id":            r.ID,
+			"name":          r.Name,
+			"listen_client": r.ListenClient,
+			"enabled":       r.Enabled,
+		}
+		clientIDs[r.ListenClient] = true
+	}
+
+	clients := make([]string, 0, len(clientIDs))
+	for id := range clientIDs {
+		clients = append(clients, id)
+	}
+
+	return map[string]any{
+		"group_id":   group.ID,
+		"group_name": group.Name,
+		"rules":      ruleList,
+		"clients":    clients,
+		"referenced": len(rules) > 0,
+	}, nil
+}
+
+func (m *GetProxyGroupUsageMethod) RequireAuth() bool { return true }
+
 // DeleteProxyGroupMethod - 删除代理组
 type DeleteProxyGroupMethod struct {
 	storage *storage.Storage
@@ -337,22 +494,44 @@ func NewDeleteProxyGroupMethod(s *storage.Storage) *DeleteProxyGroupMethod {
 func (m *DeleteProxyGroupMethod) Name() string { return "deleteProxyGroup" }
 
 type DeleteProxyGroupParams struct {
-	ID string `json:"id"`
+	ID    string `json:"id"`
+	Force bool   `json:"force"` // 为 true 时即使被规则引用也删除
 }
 
 func (m *DeleteProxyGroupMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p DeleteProxyGroupParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
+	}
+
+	// 删除前检查是否被 relay 规则引用，避免留下悬空的 @group 引用
+	group, err := m.storage.ProxyGroup.GetByID(p.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	if group != nil && err == nil && !p.Force {
+		rules, err := findGroupReferences(m.storage, group)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rules: %w", err)
+		}
+		if len(rules) > 0 {
+			names := make([]string, len(rules))
+			for i, r := range rules {
+				names[i] = r.Name
+			}
+			return nil, fmt.Errorf("group is referenced by %d rule(s): %s (use force to delete anyway)",
+				len(rules), strings.Join(names, ", "))
+		}
 	}
 
 	if err := m.storage.ProxyGroup.Delete(p.ID); err != nil {
 		return nil, fmt.Errorf("failed to delete group: %w", err)
 	}
+	invalidateGroupRefCache()
 
 	return map[string]any{"success": true}, nil
 }
@@ -382,21 +561,21 @@ type AddProxyGroupNodeParams struct {
 func (m *AddProxyGroupNodeMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p AddProxyGroupNodeParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.GroupID == "" {
-		return nil, errors.New("group_id is required")
+		return nil, resp.ErrInvalidParams("group_id is required")
 	}
 	if p.ClientID == "" {
-		return nil, errors.New("client_id is required")
+		return nil, resp.ErrInvalidParams("client_id is required")
 	}
 
 	// 验证组存在
 	_, err := m.storage.ProxyGroup.GetByID(p.GroupID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("group not found")
+			return nil, resp.ErrNotFound("group not found")
 		}
 		return nil, fmt.Errorf("failed to verify group: %w", err)
 	}
@@ -405,7 +584,7 @@ func (m *AddProxyGroupNodeMethod) Execute(ctx context.Context, params json.RawMe
 	client, err := m.storage.Client.GetByID(p.ClientID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("client not found")
+			return nil, resp.ErrNotFound("client not found")
 		}
 		return nil, fmt.Errorf("failed to verify client: %w", err)
 	}
@@ -417,7 +596,7 @@ func (m *AddProxyGroupNodeMethod) Execute(ctx context.Context, params json.RawMe
 	}
 	for _, n := range existingNodes {
 		if n.ClientID == p.ClientID {
-			return nil, errors.New("该客户端已在代理组中")
+			return nil, resp.ErrConflict("该客户端已在代理组中")
 		}
 	}
 
@@ -475,7 +654,7 @@ type RemoveProxyGroupNodeParams struct {
 func (m *RemoveProxyGroupNodeMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p RemoveProxyGroupNodeParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID != "" {
@@ -487,7 +666,7 @@ func (m *RemoveProxyGroupNodeMethod) Execute(ctx context.Context, params json.Ra
 			return nil, fmt.Errorf("failed to remove node: %w", err)
 		}
 	} else {
-		return nil, errors.New("id or (group_id and client_id) is required")
+		return nil, resp.ErrInvalidParams("id or (group_id and client_id) is required")
 	}
 
 	return map[string]any{"success": true}, nil
@@ -515,17 +694,17 @@ type UpdateProxyGroupNodeParams struct {
 func (m *UpdateProxyGroupNodeMethod) Execute(ctx context.Context, params json.RawMessage) (any, error) {
 	var p UpdateProxyGroupNodeParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.ID == "" {
-		return nil, errors.New("id is required")
+		return nil, resp.ErrInvalidParams("id is required")
 	}
 
 	node, err := m.storage.ProxyGroup.GetNode(p.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("node not found")
+			return nil, resp.ErrNotFound("node not found")
 		}
 		return nil, fmt.Errorf("failed to get node: %w", err)
 	}
@@ -0,0 +1,303 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestStorageWithGroup(t *testing.T) *storage.Storage {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	err = db.AutoMigrate(
+		&model.Client{},
+		&model.ForwardRule{},
+		&model.ProxyGroup{},
+		&model.ProxyGroupNode{},
+		&model.TrafficStats{},
+	)
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	store := &storage.Storage{}
+	store.Forward = storage.NewForwardRepository(db)
+	store.ProxyGroup = storage.NewProxyGroupRepository(db)
+
+	return store
+}
+
+func createGroupWithRule(t *testing.T, store *storage.Storage, chainRef string) *model.ProxyGroup {
+	t.Helper()
+
+	group := &model.ProxyGroup{
+		ID:   "group-1",
+		Name: "us-relay",
+	}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+
+	if chainRef != "" {
+		rule := &model.ForwardRule{
+			ID:           "rule-1",
+			Name:         "relay-rule",
+			Type:         model.ForwardTypeRelay,
+			Enabled:      true,
+			ListenAddr:   "0.0.0.0:8080",
+			ListenClient: "client-a",
+			RelayChain:   model.StringSlice{chainRef},
+			ExitAddr:     "example.com:80",
+		}
+		if err := store.Forward.Create(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+	}
+
+	return group
+}
+
+func TestGetProxyGroupUsage_ReferencedByName(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "@us-relay")
+
+	method := NewGetProxyGroupUsageMethod(store)
+	params, _ := json.Marshal(map[string]any{"id": "group-1"})
+
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	resultMap := result.(map[string]any)
+	if !resultMap["referenced"].(bool) {
+		t.Error("expected group to be referenced")
+	}
+
+	rules := resultMap["rules"].([]map[string]any)
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 referencing rule, got %d", len(rules))
+	}
+	if rules[0]["id"] != "rule-1" {
+		t.Errorf("expected rule-1, got %v", rules[0]["id"])
+	}
+
+	clients := resultMap["clients"].([]string)
+	if len(clients) != 1 || clients[0] != "client-a" {
+		t.Errorf("expected clients [client-a], got %v", clients)
+	}
+}
+
+func TestGetProxyGroupUsage_ReferencedByID(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "@group-1")
+
+	method := NewGetProxyGroupUsageMethod(store)
+	params, _ := json.Marshal(map[string]any{"id": "group-1"})
+
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	resultMap := result.(map[string]any)
+	if !resultMap["referenced"].(bool) {
+		t.Error("expected @id reference to be detected")
+	}
+}
+
+func TestGetProxyGroupUsage_NotReferenced(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "")
+
+	method := NewGetProxyGroupUsageMethod(store)
+	params, _ := json.Marshal(map[string]any{"id": "group-1"})
+
+	result, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	resultMap := result.(map[string]any)
+	if resultMap["referenced"].(bool) {
+		t.Error("expected group to be unreferenced")
+	}
+	if rules := resultMap["rules"].([]map[string]any); len(rules) != 0 {
+		t.Errorf("expected no referencing rules, got %d", len(rules))
+	}
+}
+
+func TestDeleteProxyGroup_BlockedWhenReferenced(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "@us-relay")
+
+	method := NewDeleteProxyGroupMethod(store)
+	params, _ := json.Marshal(map[string]any{"id": "group-1"})
+
+	_, err := method.Execute(context.Background(), params)
+	if err == nil {
+		t.Fatal("expected delete to be blocked for referenced group")
+	}
+	if !strings.Contains(err.Error(), "referenced") {
+		t.Errorf("expected reference error, got: %v", err)
+	}
+
+	// 组应该仍然存在
+	if _, err := store.ProxyGroup.GetByID("group-1"); err != nil {
+		t.Errorf("group should still exist: %v", err)
+	}
+}
+
+func TestDeleteProxyGroup_ForceDeletesReferenced(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "@us-relay")
+
+	method := NewDeleteProxyGroupMethod(store)
+	params, _ := json.Marshal(map[string]any{"id": "group-1", "force": true})
+
+	_, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("force delete failed: %v", err)
+	}
+
+	if _, err := store.ProxyGroup.GetByID("group-1"); err == nil {
+		t.Error("group should have been deleted")
+	}
+}
+
+func TestDeleteProxyGroup_UnreferencedDeletes(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "")
+
+	method := NewDeleteProxyGroupMethod(store)
+	params, _ := json.Marshal(map[string]any{"id": "group-1"})
+
+	_, err := method.Execute(context.Background(), params)
+	if err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	if _, err := store.ProxyGroup.GetByID("group-1"); err == nil {
+		t.Error("group should have been deleted")
+	}
+}
+
+func TestGetProxyGroup_ReturnsLastCheckError(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "")
+
+	node := &model.ProxyGroupNode{
+		ID:       "node-1",
+		GroupID:  "group-1",
+		ClientID: "client-a",
+	}
+	if err := store.ProxyGroup.AddNode(node); err != nil {
+		t.Fatalf("failed to add node: %v", err)
+	}
+
+	// 探测失败: 错误文本落库
+	probeErr := "client offline: no active websocket connection"
+	if err := store.ProxyGroup.UpdateNodeHealth("node-1", false, probeErr); err != nil {
+		t.Fatalf("failed to update node health: %v", err)
+	}
+
+	method := NewGetProxyGroupMethod(store)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"id": "group-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	nodes := result.(map[string]any)["nodes"].([]map[string]any)
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(nodes))
+	}
+	if got := nodes[0]["last_check_error"]; got != probeErr {
+		t.Errorf("expected last_check_error %q, got %v", probeErr, got)
+	}
+
+	// 探测恢复: 错误文本清空且不再返回
+	if err := store.ProxyGroup.UpdateNodeHealth("node-1", true, ""); err != nil {
+		t.Fatalf("failed to update node health: %v", err)
+	}
+	result, err = method.Execute(context.Background(), json.RawMessage(`{"id": "group-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	nodes = result.(map[string]any)["nodes"].([]map[string]any)
+	if _, ok := nodes[0]["last_check_error"]; ok {
+		t.Error("expected last_check_error to be cleared after a healthy probe")
+	}
+}
+
+func TestCreateProxyGroup_ValidatesLoadBalanceMethod(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	method := NewCreateProxyGroupMethod(store)
+
+	// 已知方法接受
+	params, _ := json.Marshal(map[string]any{"name": "lb-ok", "load_balance_method": "least_conn"})
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("valid method rejected: %v", err)
+	}
+	group, err := store.ProxyGroup.GetByName("lb-ok")
+	if err != nil {
+		t.Fatalf("failed to load created group: %v", err)
+	}
+	if group.LoadBalanceMethod != model.LoadBalanceLeastConn {
+		t.Errorf("load balance method = %q, want least_conn", group.LoadBalanceMethod)
+	}
+
+	// 未知方法拒绝
+	params, _ = json.Marshal(map[string]any{"name": "lb-bad", "load_balance_method": "roundrobin"})
+	_, err = method.Execute(context.Background(), params)
+	if err == nil {
+		t.Fatal("unknown method should be rejected")
+	}
+	if !strings.Contains(err.Error(), "unknown load balance method") {
+		t.Errorf("error = %v, want unknown load balance method", err)
+	}
+}
+
+func TestUpdateProxyGroup_ValidatesLoadBalanceMethod(t *testing.T) {
+	store := setupTestStorageWithGroup(t)
+	createGroupWithRule(t, store, "")
+
+	method := NewUpdateProxyGroupMethod(store)
+
+	params, _ := json.Marshal(map[string]any{"id": "group-1", "load_balance_method": "ip_hash"})
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("valid method rejected: %v", err)
+	}
+	group, err := store.ProxyGroup.GetByID("group-1")
+	if err != nil {
+		t.Fatalf("failed to load group: %v", err)
+	}
+	if group.LoadBalanceMethod != model.LoadBalanceIPHash {
+		t.Errorf("load balance method = %q, want ip_hash", group.LoadBalanceMethod)
+	}
+
+	params, _ = json.Marshal(map[string]any{"id": "group-1", "load_balance_method": "fastest"})
+	if _, err := method.Execute(context.Background(), params); err == nil {
+		t.Fatal("unknown method should be rejected")
+	}
+
+	// 拒绝后原值保持不变
+	group, err = store.ProxyGroup.GetByID("group-1")
+	if err != nil {
+		t.Fatalf("failed to reload group: %v", err)
+	}
+	if group.LoadBalanceMethod != model.LoadBalanceIPHash {
+		t.Errorf("load balance method after rejected update = %q, want ip_hash", group.LoadBalanceMethod)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dollarkillerx/MessageBoy/internal/middleware"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+func setupRateLimitEngine(t *testing.T, ratePerSec float64, burst int, exempt []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	handler := NewRpcHandler(nil)
+	handler.Register(&PingMethod{})
+	handler.SetRateLimiter(middleware.NewRateLimiter(ratePerSec, burst), exempt)
+
+	engine := gin.New()
+	engine.POST("/api/rpc", handler.Handle)
+	return engine
+}
+
+func TestRpcHandler_RateLimit_ThrottlesExcessiveRequestsPerIP(t *testing.T) {
+	// 速率接近 0, 只靠突发容量 3 放行请求
+	engine := setupRateLimitEngine(t, 0.001, 3, nil)
+
+	for i := 0; i < 3; i++ {
+		rpcResp := doRpcRequest(t, engine, "10.0.0.1:12345", "ping")
+		if rpcResp.Error != nil {
+			t.Fatalf("request %d within burst should succeed, got error: %v", i+1, rpcResp.Error.Message)
+		}
+	}
+
+	rpcResp := doRpcRequest(t, engine, "10.0.0.1:12345", "ping")
+	if rpcResp.Error == nil {
+		t.Fatal("expected request beyond burst to be throttled")
+	}
+	if rpcResp.Error.Message != "rate limit exceeded" {
+		t.Errorf("error message = %q, want rate limit exceeded", rpcResp.Error.Message)
+	}
+
+	// 其他来源 IP 有独立的桶, 不受影响
+	other := doRpcRequest(t, engine, "10.0.0.2:12345", "ping")
+	if other.Error != nil {
+		t.Fatalf("request from another IP should not be throttled, got error: %v", other.Error.Message)
+	}
+}
+
+func TestRpcHandler_RateLimit_ExemptMethodNotThrottled(t *testing.T) {
+	engine := setupRateLimitEngine(t, 0.001, 1, []string{"ping"})
+
+	for i := 0; i < 10; i++ {
+		rpcResp := doRpcRequest(t, engine, "10.0.0.1:12345", "ping")
+		if rpcResp.Error != nil {
+			t.Fatalf("exempt method should never be throttled, request %d got error: %v", i+1, rpcResp.Error.Message)
+		}
+	}
+}
+
+func TestRateLimiter_Middleware_Returns429(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limiter := middleware.NewRateLimiter(0.001, 2)
+	engine := gin.New()
+	engine.GET("/ws", limiter.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	status := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := status("10.0.0.1:12345"); code != http.StatusOK {
+			t.Fatalf("request %d within burst: status = %d, want 200", i+1, code)
+		}
+	}
+	if code := status("10.0.0.1:12345"); code != http.StatusTooManyRequests {
+		t.Errorf("request beyond burst: status = %d, want 429", code)
+	}
+	if code := status("10.0.0.2:12345"); code != http.StatusOK {
+		t.Errorf("request from another IP: status = %d, want 200", code)
+	}
+}
+
+func TestRpcHandler_RateLimit_Returns429StatusCode(t *testing.T) {
+	engine := setupRateLimitEngine(t, 0.001, 1, nil)
+
+	doRpcRequest(t, engine, "10.0.0.1:12345", "ping")
+
+	body := []byte(`{"jsonrpc":"2.0","id":"test","method":"ping","params":{}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/rpc", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "10.0.0.1:12345"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", w.Code)
+	}
+	var rpcResp resp.RpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &rpcResp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if rpcResp.Error == nil || rpcResp.Error.Code != resp.ErrCodeServerError {
+		t.Errorf("expected server error code in throttled response, got %+v", rpcResp.Error)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+)
+
+// rejectSelfLoopEnabled 是否启用中继链自环校验 (配置缺省时启用)
+func rejectSelfLoopEnabled(cfg *conf.Config) bool {
+	return cfg == nil || cfg.Server.RejectSelfLoopRules
+}
+
+// relayChainLoopsBack 检查中继链是否绕回监听 client 自身。
+// 链元素为 client ID 或代理组引用 (@group_name): 组引用解析为当前成员的
+// client 集合, 任一成员等于监听 client 即视为自环。
+// 标签引用 (@tag:...) 的成员随客户端标签实时变化, 创建时无法可靠判定, 跳过;
+// 解析不到的组同样跳过 (组可能尚未创建), 留给运行时处理。
+// 返回 (是否自环, 命中的链元素)
+func relayChainLoopsBack(store *storage.Storage, listenClient string, chain []string) (bool, string) {
+	for _, hop := range chain {
+		if hop == listenClient {
+			return true, hop
+		}
+		if !storage.IsGroupReference(hop) || strings.HasPrefix(hop, "@tag:") {
+			continue
+		}
+		ref := storage.ParseGroupReference(hop)
+		group, err := store.ProxyGroup.GetByName(ref)
+		if err != nil {
+			group, err = store.ProxyGroup.GetByID(ref)
+		}
+		if err != nil {
+			log.Debug().Str("group_ref", hop).Msg("Relay chain group not resolvable, skipping self-loop check for this hop")
+			continue
+		}
+		nodes, err := store.ProxyGroup.GetNodesByGroupID(group.ID)
+		if err != nil {
+			continue
+		}
+		for _, node := range nodes {
+			if node.ClientID == listenClient {
+				return true, hop
+			}
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+// setupTestStorageWithGroups 在基础 fixture 之上带上代理组仓库 (组引用解析需要)
+func setupTestStorageWithGroups(t *testing.T) *storage.Storage {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(
+		&model.Client{},
+		&model.ForwardRule{},
+		&model.ProxyGroup{},
+		&model.ProxyGroupNode{},
+	); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	store := &storage.Storage{}
+	store.Client = storage.NewClientRepository(db)
+	store.Forward = storage.NewForwardRepository(db)
+	store.ProxyGroup = storage.NewProxyGroupRepository(db)
+	return store
+}
+
+func TestCreateForwardRule_DirectSelfLoopRejected(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "entry")
+	seedTestClient(t, store, "exit")
+
+	method := NewCreateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"name":          "self-loop",
+		"type":          "relay",
+		"listen_addr":   "0.0.0.0:7070",
+		"listen_client": "entry",
+		"relay_chain":   []string{"entry"},
+		"exit_addr":     "127.0.0.1:80",
+	})
+
+	if _, err := method.Execute(context.Background(), params); err == nil {
+		t.Fatal("expected self-loop relay chain to be rejected")
+	}
+
+	// 正常链 (不含监听 client) 照常创建
+	params, _ = json.Marshal(map[string]interface{}{
+		"name":          "no-loop",
+		"type":          "relay",
+		"listen_addr":   "0.0.0.0:7070",
+		"listen_client": "entry",
+		"relay_chain":   []string{"exit"},
+		"exit_addr":     "127.0.0.1:80",
+	})
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("expected non-loop relay chain to be accepted, got: %v", err)
+	}
+}
+
+func TestCreateForwardRule_GroupChainReturningToOriginRejected(t *testing.T) {
+	store := setupTestStorageWithGroups(t)
+	seedTestClient(t, store, "entry")
+	seedTestClient(t, store, "hop")
+
+	// 组成员包含监听 client 本身, 链经该组可能绕回源头
+	group := &model.ProxyGroup{ID: "grp-1", Name: "exits"}
+	if err := store.ProxyGroup.Create(group); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	for i, clientID := range []string{"hop", "entry"} {
+		node := &model.ProxyGroupNode{
+			ID:       "node-" + clientID,
+			GroupID:  group.ID,
+			ClientID: clientID,
+			Priority: i,
+			Status:   model.NodeStatusHealthy,
+		}
+		if err := store.ProxyGroup.AddNode(node); err != nil {
+			t.Fatalf("failed to add node: %v", err)
+		}
+	}
+
+	method := NewCreateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"name":          "group-loop",
+		"type":          "relay",
+		"listen_addr":   "0.0.0.0:7071",
+		"listen_client": "entry",
+		"relay_chain":   []string{"hop", "@exits"},
+		"exit_addr":     "127.0.0.1:80",
+	})
+
+	if _, err := method.Execute(context.Background(), params); err == nil {
+		t.Fatal("expected chain routing back to listen client via group to be rejected")
+	}
+}
+
+func TestUpdateForwardRule_SelfLoopRejected(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "entry")
+	seedTestClient(t, store, "exit")
+
+	rule := &model.ForwardRule{
+		ID:           "relay-rule",
+		Name:         "relay",
+		Type:         model.ForwardTypeRelay,
+		Enabled:      true,
+		ListenAddr:   "0.0.0.0:7072",
+		ListenClient: "entry",
+		RelayChain:   model.StringSlice{"exit"},
+		ExitAddr:     "127.0.0.1:80",
+	}
+	if err := store.Forward.Create(rule); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+
+	method := NewUpdateForwardRuleMethod(store, nil, nil)
+	params, _ := json.Marshal(map[string]interface{}{
+		"id":          "relay-rule",
+		"relay_chain": []string{"entry", "exit"},
+	})
+
+	if _, err := method.Execute(context.Background(), params); err == nil {
+		t.Fatal("expected updated chain containing listen client to be rejected")
+	}
+}
+
+func TestCreateForwardRule_SelfLoopAllowedWhenCheckDisabled(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "entry")
+
+	cfg := &conf.Config{}
+	cfg.Server.RejectSelfLoopRules = false
+	method := NewCreateForwardRuleMethod(store, nil, cfg)
+	params, _ := json.Marshal(map[string]interface{}{
+		"name":          "loop-allowed",
+		"type":          "relay",
+		"listen_addr":   "0.0.0.0:7073",
+		"listen_client": "entry",
+		"relay_chain":   []string{"entry"},
+		"exit_addr":     "127.0.0.1:80",
+	})
+
+	if _, err := method.Execute(context.Background(), params); err != nil {
+		t.Fatalf("expected self-loop to pass with check disabled, got: %v", err)
+	}
+}
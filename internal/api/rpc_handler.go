@@ -3,7 +3,12 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 
@@ -21,6 +26,16 @@ type RpcHandler struct {
 	methods    map[string]RpcMethod
 	mu         sync.RWMutex
 	jwtManager *middleware.JWTManager
+
+	// methodACL 方法 -> 允许的来源网段 (未配置的方法不受限)
+	methodACL map[string][]*net.IPNet
+
+	// rateLimiter 按来源 IP 的请求限流 (nil 不限流); rateLimitExempt 为豁免的方法集合
+	rateLimiter     *middleware.RateLimiter
+	rateLimitExempt map[string]bool
+
+	// maxBodySize 请求体大小上限 (字节, <=0 不限制), 原子读写以支持配置热更
+	maxBodySize atomic.Int64
 }
 
 func NewRpcHandler(jwtManager *middleware.JWTManager) *RpcHandler {
@@ -36,9 +51,88 @@ func (h *RpcHandler) Register(method RpcMethod) {
 	h.methods[method.Name()] = method
 }
 
+// SetMethodACL 配置方法级来源网段白名单 (method -> CIDR 列表)。
+// 主要用于收紧无需认证的 client 内部方法，纵深防御。
+func (h *RpcHandler) SetMethodACL(acl map[string][]string) error {
+	parsed := make(map[string][]*net.IPNet, len(acl))
+	for method, cidrs := range acl {
+		nets := make([]*net.IPNet, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return fmt.Errorf("invalid CIDR %q for method %q: %w", cidr, method, err)
+			}
+			nets = append(nets, ipNet)
+		}
+		parsed[method] = nets
+	}
+
+	h.mu.Lock()
+	h.methodACL = parsed
+	h.mu.Unlock()
+	return nil
+}
+
+// SetRateLimiter 配置按来源 IP 的请求限流器。
+// exemptMethods 中的方法不受限 (典型为 clientRegister, 避免限流阻断新客户端接入)
+func (h *RpcHandler) SetRateLimiter(limiter *middleware.RateLimiter, exemptMethods []string) {
+	exempt := make(map[string]bool, len(exemptMethods))
+	for _, m := range exemptMethods {
+		exempt[m] = true
+	}
+
+	h.mu.Lock()
+	h.rateLimiter = limiter
+	h.rateLimitExempt = exempt
+	h.mu.Unlock()
+}
+
+// SetMaxBodySize 配置请求体大小上限 (字节), 超限请求返回 413。可在运行期调用热更
+func (h *RpcHandler) SetMaxBodySize(limit int64) {
+	h.maxBodySize.Store(limit)
+}
+
+// sourceAllowed 检查调用来源是否在方法的网段白名单内 (未配置白名单时放行)
+func (h *RpcHandler) sourceAllowed(c *gin.Context, method string) bool {
+	h.mu.RLock()
+	nets, ok := h.methodACL[method]
+	h.mu.RUnlock()
+
+	if !ok {
+		return true
+	}
+
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *RpcHandler) Handle(c *gin.Context) {
+	if limit := h.maxBodySize.Load(); limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+
 	var request resp.RpcRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
+		// 超限请求返回 413, 其余解析失败走标准 parse error
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, resp.RpcResponse{
+				JsonRPC: resp.JSONRPCVersion,
+				Error: &resp.RpcError{
+					Code:    resp.ErrCodeInvalidRequest,
+					Message: "request body too large",
+				},
+			})
+			return
+		}
 		resp.ErrorResponse(c, "", resp.ErrCodeParseError, "invalid JSON")
 		return
 	}
@@ -62,6 +156,30 @@ func (h *RpcHandler) Handle(c *gin.Context) {
 		return
 	}
 
+	// 按来源 IP 限流 (豁免方法除外)
+	h.mu.RLock()
+	limiter, exempt := h.rateLimiter, h.rateLimitExempt[request.Method]
+	h.mu.RUnlock()
+	if limiter != nil && !exempt {
+		if !limiter.Allow(c.ClientIP()) {
+			c.JSON(http.StatusTooManyRequests, resp.RpcResponse{
+				JsonRPC: resp.JSONRPCVersion,
+				ID:      request.ID,
+				Error: &resp.RpcError{
+					Code:    resp.ErrCodeServerError,
+					Message: "rate limit exceeded",
+				},
+			})
+			return
+		}
+	}
+
+	// 来源网段白名单 (仅对配置过的方法生效)
+	if !h.sourceAllowed(c, request.Method) {
+		resp.ErrorResponse(c, request.ID, resp.ErrCodePermDenied, "method not allowed from this source")
+		return
+	}
+
 	// 检查认证
 	if method.RequireAuth() {
 		if !h.isAuthenticated(c) {
@@ -75,6 +193,12 @@ func (h *RpcHandler) Handle(c *gin.Context) {
 
 	result, err := method.Execute(ctx, request.Params)
 	if err != nil {
+		// 带码错误透传稳定错误码, 其余落到通用 server error
+		var coded *resp.CodedError
+		if errors.As(err, &coded) {
+			resp.ErrorResponse(c, request.ID, coded.Code, coded.Message)
+			return
+		}
 		resp.ErrorResponse(c, request.ID, resp.ErrCodeServerError, err.Error())
 		return
 	}
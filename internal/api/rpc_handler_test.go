@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+func doRpcRequest(t *testing.T, engine *gin.Engine, remoteAddr, method string) resp.RpcResponse {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "test",
+		"method":  method,
+		"params":  map[string]interface{}{},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/rpc", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = remoteAddr
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	var rpcResp resp.RpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &rpcResp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return rpcResp
+}
+
+func setupACLTestEngine(t *testing.T, acl map[string][]string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	handler := NewRpcHandler(nil)
+	handler.Register(&PingMethod{})
+	if acl != nil {
+		if err := handler.SetMethodACL(acl); err != nil {
+			t.Fatalf("SetMethodACL failed: %v", err)
+		}
+	}
+
+	engine := gin.New()
+	engine.POST("/api/rpc", handler.Handle)
+	return engine
+}
+
+func TestRpcHandler_MethodACL_RejectsDisallowedSource(t *testing.T) {
+	engine := setupACLTestEngine(t, map[string][]string{
+		"ping": {"10.0.0.0/8"},
+	})
+
+	rpcResp := doRpcRequest(t, engine, "192.168.1.5:12345", "ping")
+	if rpcResp.Error == nil {
+		t.Fatal("expected error for disallowed source IP")
+	}
+	if rpcResp.Error.Code != resp.ErrCodePermDenied {
+		t.Errorf("expected code %d, got %d", resp.ErrCodePermDenied, rpcResp.Error.Code)
+	}
+}
+
+func TestRpcHandler_MethodACL_AllowsMatchingSource(t *testing.T) {
+	engine := setupACLTestEngine(t, map[string][]string{
+		"ping": {"10.0.0.0/8"},
+	})
+
+	rpcResp := doRpcRequest(t, engine, "10.1.2.3:12345", "ping")
+	if rpcResp.Error != nil {
+		t.Fatalf("expected success from allowed source, got error: %v", rpcResp.Error.Message)
+	}
+}
+
+func TestRpcHandler_MethodACL_UnlistedMethodUnrestricted(t *testing.T) {
+	engine := setupACLTestEngine(t, map[string][]string{
+		"clientRegister": {"10.0.0.0/8"},
+	})
+
+	// ping 未配置白名单，任意来源可调用
+	rpcResp := doRpcRequest(t, engine, "192.168.1.5:12345", "ping")
+	if rpcResp.Error != nil {
+		t.Fatalf("expected unlisted method to be unrestricted, got error: %v", rpcResp.Error.Message)
+	}
+}
+
+func TestRpcHandler_SetMethodACL_InvalidCIDR(t *testing.T) {
+	handler := NewRpcHandler(nil)
+	if err := handler.SetMethodACL(map[string][]string{
+		"ping": {"not-a-cidr"},
+	}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+// ===== Body Size Limit Tests =====
+
+func setupBodyLimitEngine(t *testing.T, limit int64) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	handler := NewRpcHandler(nil)
+	handler.Register(&PingMethod{})
+	handler.SetMaxBodySize(limit)
+
+	engine := gin.New()
+	engine.POST("/api/rpc", handler.Handle)
+	return engine
+}
+
+func doSizedRpcRequest(engine *gin.Engine, paddingLen int) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "test",
+		"method":  "ping",
+		"params":  map[string]interface{}{"padding": strings.Repeat("x", paddingLen)},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/rpc", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestRpcHandler_OversizedBodyRejected(t *testing.T) {
+	engine := setupBodyLimitEngine(t, 256)
+
+	w := doSizedRpcRequest(engine, 1024)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+	}
+}
+
+func TestRpcHandler_BodyWithinLimitAccepted(t *testing.T) {
+	engine := setupBodyLimitEngine(t, 4096)
+
+	w := doSizedRpcRequest(engine, 64)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var rpcResp resp.RpcResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &rpcResp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if rpcResp.Error != nil {
+		t.Errorf("expected success within limit, got error: %v", rpcResp.Error.Message)
+	}
+}
+
+// ===== CodedError Tests =====
+
+func TestRpcHandler_CodedErrorMappedToResponseCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := setupTestStorageWithClient(t)
+	handler := NewRpcHandler(nil)
+	handler.Register(NewClientHeartbeatMethod(store))
+
+	engine := gin.New()
+	engine.POST("/api/rpc", handler.Handle)
+
+	// 缺少 client_id 应返回 invalid params 错误码
+	rpcResp := doRpcRequest(t, engine, "127.0.0.1:12345", "clientHeartbeat")
+	if rpcResp.Error == nil {
+		t.Fatal("expected error for missing client_id")
+	}
+	if rpcResp.Error.Code != resp.ErrCodeInvalidParams {
+		t.Errorf("expected code %d, got %d", resp.ErrCodeInvalidParams, rpcResp.Error.Code)
+	}
+}
+
+func TestGetForwardRuleMethod_NotFoundCode(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	method := NewGetForwardRuleMethod(store)
+
+	params, _ := json.Marshal(map[string]interface{}{"id": "no-such-rule"})
+	_, err := method.Execute(context.Background(), params)
+	if err == nil {
+		t.Fatal("expected error for unknown rule")
+	}
+
+	var coded *resp.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("expected CodedError, got %T", err)
+	}
+	if coded.Code != resp.ErrCodeNotFound {
+		t.Errorf("expected code %d, got %d", resp.ErrCodeNotFound, coded.Code)
+	}
+}
+
+func TestClientRegisterMethod_InvalidTokenCode(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	// 无效 token 在使用配置之前就会报错，空配置即可
+	method := NewClientRegisterMethod(store, &conf.Config{})
+
+	params, _ := json.Marshal(map[string]interface{}{"token": "bogus"})
+	_, err := method.Execute(context.Background(), params)
+	if err == nil {
+		t.Fatal("expected error for invalid token")
+	}
+
+	var coded *resp.CodedError
+	if !errors.As(err, &coded) {
+		t.Fatalf("expected CodedError, got %T", err)
+	}
+	if coded.Code != resp.ErrCodeAuthRequired {
+		t.Errorf("expected code %d, got %d", resp.ErrCodeAuthRequired, coded.Code)
+	}
+}
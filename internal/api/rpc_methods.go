@@ -8,6 +8,7 @@ import (
 
 	"github.com/dollarkillerx/MessageBoy/internal/conf"
 	"github.com/dollarkillerx/MessageBoy/internal/middleware"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
 )
 
 const Version = "2.0.0"
@@ -50,15 +51,15 @@ type AdminLoginParams struct {
 func (m *AdminLoginMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
 	var p AdminLoginParams
 	if err := json.Unmarshal(params, &p); err != nil {
-		return nil, errors.New("invalid params")
+		return nil, resp.ErrInvalidParams("invalid params")
 	}
 
 	if p.Username == "" || p.Password == "" {
-		return nil, errors.New("username and password are required")
+		return nil, resp.ErrInvalidParams("username and password are required")
 	}
 
 	if p.Username != m.adminCfg.Username || p.Password != m.adminCfg.Password {
-		return nil, errors.New("invalid credentials")
+		return nil, resp.ErrUnauthorized("invalid credentials")
 	}
 
 	token, expireAt, err := m.jwtManager.GenerateToken(p.Username)
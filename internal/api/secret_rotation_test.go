@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func setupRotateSecretMethod(t *testing.T) (*RotateClientSecretMethod, *storage.Storage) {
+	t.Helper()
+	store := setupTestStorageWithClient(t)
+
+	client := &model.Client{
+		ID:        "rotate-client",
+		Name:      "rotate-client",
+		Token:     "rotate-token",
+		SecretKey: "old-secret",
+	}
+	if err := store.Client.Create(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	cfg := &conf.Config{}
+	cfg.Server.SecretRotationGrace = 300
+	return NewRotateClientSecretMethod(store, cfg), store
+}
+
+func TestRotateClientSecret_GracePeriodDualAcceptance(t *testing.T) {
+	method, store := setupRotateSecretMethod(t)
+
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"id": "rotate-client"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	newKey := result.(map[string]interface{})["secret_key"].(string)
+	if newKey == "" || newKey == "old-secret" {
+		t.Fatalf("expected a fresh secret key, got %q", newKey)
+	}
+
+	client, err := store.Client.GetByID("rotate-client")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+
+	grace := 5 * time.Minute
+	if !client.AcceptsSecretKey(newKey, grace) {
+		t.Error("new secret key should be accepted")
+	}
+	// 宽限期内旧密钥仍可用
+	if !client.AcceptsSecretKey("old-secret", grace) {
+		t.Error("old secret key should be accepted within grace period")
+	}
+	if client.AcceptsSecretKey("bogus", grace) {
+		t.Error("unknown key should be rejected")
+	}
+}
+
+func TestRotateClientSecret_OldKeyRejectedAfterGrace(t *testing.T) {
+	method, store := setupRotateSecretMethod(t)
+
+	if _, err := method.Execute(context.Background(), json.RawMessage(`{"id": "rotate-client"}`)); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	client, err := store.Client.GetByID("rotate-client")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+
+	// 把轮换时间拨回到宽限期之外
+	expired := time.Now().Add(-10 * time.Minute)
+	client.SecretRotatedAt = &expired
+	if err := store.Client.Update(client); err != nil {
+		t.Fatalf("failed to update client: %v", err)
+	}
+
+	client, _ = store.Client.GetByID("rotate-client")
+	grace := 5 * time.Minute
+	if client.AcceptsSecretKey("old-secret", grace) {
+		t.Error("old secret key should be rejected after grace period")
+	}
+	if !client.AcceptsSecretKey(client.SecretKey, grace) {
+		t.Error("current secret key should still be accepted")
+	}
+}
+
+func TestRotateClientSecret_UnknownClient(t *testing.T) {
+	method, _ := setupRotateSecretMethod(t)
+
+	if _, err := method.Execute(context.Background(), json.RawMessage(`{"id": "no-such-client"}`)); err == nil {
+		t.Fatal("expected error for unknown client")
+	}
+}
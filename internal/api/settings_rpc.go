@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+)
+
+// 运行期可调设置的键。默认值来自 TOML 配置, settings 表中的值覆盖并跨重启保留
+const (
+	SettingHealthCheckInterval = "health_check_interval" // 代理组健康检查间隔 (秒)
+	SettingMaxBodySize         = "max_body_size"         // RPC 请求体大小上限 (字节)
+)
+
+// IntervalSetter 支持运行期调整调度间隔的子系统 (由 proxy.HealthChecker 实现)
+type IntervalSetter interface {
+	SetInterval(d time.Duration)
+}
+
+// settingDefaults 从 TOML 配置取各设置的默认值
+func settingDefaults(cfg *conf.Config) map[string]string {
+	return map[string]string{
+		SettingHealthCheckInterval: strconv.Itoa(cfg.Server.HealthCheckInterval),
+		SettingMaxBodySize:         strconv.FormatInt(cfg.Server.MaxBodySize, 10),
+	}
+}
+
+// GetSettingsMethod - 查询服务端运行期设置 (默认值与已保存的覆盖值合并)
+type GetSettingsMethod struct {
+	storage *storage.Storage
+	cfg     *conf.Config
+}
+
+func NewGetSettingsMethod(s *storage.Storage, cfg *conf.Config) *GetSettingsMethod {
+	return &GetSettingsMethod{storage: s, cfg: cfg}
+}
+
+func (m *GetSettingsMethod) Name() string { return "getSettings" }
+
+func (m *GetSettingsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	settings := settingDefaults(m.cfg)
+
+	stored, err := m.storage.Setting.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	for key, value := range stored {
+		settings[key] = value
+	}
+
+	return map[string]interface{}{
+		"settings": settings,
+	}, nil
+}
+
+func (m *GetSettingsMethod) RequireAuth() bool { return true }
+
+// UpdateSettingsMethod - 更新服务端运行期设置并在子系统支持时即时生效
+type UpdateSettingsMethod struct {
+	storage *storage.Storage
+	cfg     *conf.Config
+
+	// 健康检查器就绪后由 ApiServer 注入, 用于即时应用新的检查间隔
+	healthChecker IntervalSetter
+	rpcHandler    *RpcHandler
+}
+
+func NewUpdateSettingsMethod(s *storage.Storage, cfg *conf.Config, h *RpcHandler) *UpdateSettingsMethod {
+	return &UpdateSettingsMethod{storage: s, cfg: cfg, rpcHandler: h}
+}
+
+// SetHealthChecker 注入健康检查器
+func (m *UpdateSettingsMethod) SetHealthChecker(hc IntervalSetter) {
+	m.healthChecker = hc
+}
+
+func (m *UpdateSettingsMethod) Name() string { return "updateSettings" }
+
+type UpdateSettingsParams struct {
+	Settings map[string]string `json:"settings"`
+}
+
+func (m *UpdateSettingsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p UpdateSettingsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+	if len(p.Settings) == 0 {
+		return nil, resp.ErrInvalidParams("settings is required")
+	}
+
+	// 先整体校验, 避免部分写入
+	for key, value := range p.Settings {
+		if err := validateSetting(key, value); err != nil {
+			return nil, resp.ErrInvalidParams(err.Error())
+		}
+	}
+
+	applied := make([]string, 0, len(p.Settings))
+	for key, value := range p.Settings {
+		if err := m.storage.Setting.Set(key, value); err != nil {
+			return nil, fmt.Errorf("failed to save setting %q: %w", key, err)
+		}
+		if m.applyLive(key, value) {
+			applied = append(applied, key)
+		}
+	}
+
+	return map[string]interface{}{
+		"updated":      len(p.Settings),
+		"applied_live": applied,
+	}, nil
+}
+
+func (m *UpdateSettingsMethod) RequireAuth() bool { return true }
+
+// applyLive 在子系统支持时即时应用设置, 返回是否已生效
+func (m *UpdateSettingsMethod) applyLive(key, value string) bool {
+	switch key {
+	case SettingHealthCheckInterval:
+		if m.healthChecker == nil {
+			return false
+		}
+		seconds, _ := strconv.Atoi(value)
+		m.healthChecker.SetInterval(time.Duration(seconds) * time.Second)
+		return true
+	case SettingMaxBodySize:
+		if m.rpcHandler == nil {
+			return false
+		}
+		limit, _ := strconv.ParseInt(value, 10, 64)
+		m.rpcHandler.SetMaxBodySize(limit)
+		return true
+	}
+	return false
+}
+
+// validateSetting 校验设置键与取值
+func validateSetting(key, value string) error {
+	switch key {
+	case SettingHealthCheckInterval:
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("%s must be a positive integer (seconds)", key)
+		}
+	case SettingMaxBodySize:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("%s must be an integer (bytes)", key)
+		}
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/dollarkillerx/MessageBoy/internal/conf"
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func setupSettingsStorage(t *testing.T) *storage.Storage {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.Setting{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	store := &storage.Storage{}
+	store.Setting = storage.NewSettingRepository(db)
+	return store
+}
+
+func settingsTestConfig() *conf.Config {
+	cfg := &conf.Config{}
+	cfg.Server.HealthCheckInterval = 10
+	cfg.Server.MaxBodySize = 1 << 20
+	return cfg
+}
+
+func TestGetSettings_Defaults(t *testing.T) {
+	store := setupSettingsStorage(t)
+	method := NewGetSettingsMethod(store, settingsTestConfig())
+
+	result, err := method.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	settings := result.(map[string]interface{})["settings"].(map[string]string)
+	if settings[SettingHealthCheckInterval] != "10" {
+		t.Errorf("health_check_interval = %q, want %q", settings[SettingHealthCheckInterval], "10")
+	}
+	if settings[SettingMaxBodySize] != "1048576" {
+		t.Errorf("max_body_size = %q, want %q", settings[SettingMaxBodySize], "1048576")
+	}
+}
+
+// fakeIntervalSetter 记录最近一次应用的间隔
+type fakeIntervalSetter struct {
+	interval time.Duration
+}
+
+func (f *fakeIntervalSetter) SetInterval(d time.Duration) { f.interval = d }
+
+func TestUpdateSettings_AppliesHealthCheckInterval(t *testing.T) {
+	store := setupSettingsStorage(t)
+	cfg := settingsTestConfig()
+
+	update := NewUpdateSettingsMethod(store, cfg, nil)
+	checker := &fakeIntervalSetter{}
+	update.SetHealthChecker(checker)
+
+	result, err := update.Execute(context.Background(), json.RawMessage(`{"settings": {"health_check_interval": "30"}}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	if checker.interval != 30*time.Second {
+		t.Errorf("checker interval = %v, want 30s", checker.interval)
+	}
+
+	applied := result.(map[string]interface{})["applied_live"].([]string)
+	if len(applied) != 1 || applied[0] != SettingHealthCheckInterval {
+		t.Errorf("applied_live = %v, want [health_check_interval]", applied)
+	}
+
+	// 覆盖值落库, getSettings 返回新值
+	get := NewGetSettingsMethod(store, cfg)
+	getResult, err := get.Execute(context.Background(), json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("getSettings failed: %v", err)
+	}
+	settings := getResult.(map[string]interface{})["settings"].(map[string]string)
+	if settings[SettingHealthCheckInterval] != "30" {
+		t.Errorf("stored health_check_interval = %q, want %q", settings[SettingHealthCheckInterval], "30")
+	}
+}
+
+func TestUpdateSettings_RejectsInvalid(t *testing.T) {
+	store := setupSettingsStorage(t)
+	method := NewUpdateSettingsMethod(store, settingsTestConfig(), nil)
+
+	cases := []string{
+		`{"settings": {"unknown_key": "1"}}`,
+		`{"settings": {"health_check_interval": "abc"}}`,
+		`{"settings": {"health_check_interval": "0"}}`,
+		`{"settings": {}}`,
+	}
+	for _, params := range cases {
+		if _, err := method.Execute(context.Background(), json.RawMessage(params)); err == nil {
+			t.Errorf("expected error for params %s", params)
+		}
+	}
+
+	// 整体校验失败时不应有部分写入
+	stored, err := store.Setting.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll failed: %v", err)
+	}
+	if len(stored) != 0 {
+		t.Errorf("expected no settings stored, got %v", stored)
+	}
+}
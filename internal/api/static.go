@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hashedAssetRe 识别文件名携带构建哈希的资源 (vite 产物如 index-B2fk9Qe3.js)。
+// 此类文件内容变化时文件名必变, 可以长期缓存
+var hashedAssetRe = regexp.MustCompile(`[.-][A-Za-z0-9_-]{8,}\.[A-Za-z0-9]+$`)
+
+// staticHandler 提供仪表盘静态资源: 带哈希的资源长期缓存,
+// 未匹配的非 API 路径回退 index.html (SPA 深链接由前端路由接管)
+func staticHandler(dir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		path := c.Request.URL.Path
+		if strings.HasPrefix(path, "/api/") {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		// index.html 不能走 http.ServeFile (会 301 重定向), 直接读出返回
+		serveIndex := func() {
+			data, err := os.ReadFile(filepath.Join(dir, "index.html"))
+			if err != nil {
+				c.Status(http.StatusNotFound)
+				return
+			}
+			c.Header("Cache-Control", "no-cache")
+			c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+		}
+
+		// 防目录穿越: 先按根路径清理再拼到静态目录下
+		full := filepath.Join(dir, filepath.Clean("/"+path))
+
+		if info, err := os.Stat(full); err == nil && !info.IsDir() {
+			if filepath.Base(full) == "index.html" {
+				serveIndex()
+				return
+			}
+			if hashedAssetRe.MatchString(filepath.Base(full)) {
+				c.Header("Cache-Control", "public, max-age=31536000, immutable")
+			} else {
+				c.Header("Cache-Control", "no-cache")
+			}
+			c.File(full)
+			return
+		}
+
+		// SPA 回退: 未知路径返回 index.html
+		serveIndex()
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupStaticTestEngine(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	writeFile := func(rel, content string) {
+		full := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	writeFile("index.html", "<html>dashboard</html>")
+	writeFile("assets/index-B2fk9Qe3.js", "console.log('app')")
+
+	engine := gin.New()
+	engine.NoRoute(staticHandler(dir))
+	return engine, dir
+}
+
+func doStaticRequest(t *testing.T, engine *gin.Engine, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestStaticHandler_DeepLinkFallsBackToIndex(t *testing.T) {
+	engine, _ := setupStaticTestEngine(t)
+
+	w := doStaticRequest(t, engine, "/rules/detail/42")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Body.String() != "<html>dashboard</html>" {
+		t.Errorf("deep link should return index.html, got %q", w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("index fallback Cache-Control = %q, want no-cache", cc)
+	}
+}
+
+func TestStaticHandler_HashedAssetGetsLongCache(t *testing.T) {
+	engine, _ := setupStaticTestEngine(t)
+
+	w := doStaticRequest(t, engine, "/assets/index-B2fk9Qe3.js")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if w.Body.String() != "console.log('app')" {
+		t.Errorf("asset body = %q", w.Body.String())
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Errorf("hashed asset Cache-Control = %q, want immutable", cc)
+	}
+}
+
+func TestStaticHandler_IndexNotLongCached(t *testing.T) {
+	engine, _ := setupStaticTestEngine(t)
+
+	w := doStaticRequest(t, engine, "/index.html")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("index.html Cache-Control = %q, want no-cache", cc)
+	}
+}
+
+func TestStaticHandler_APIPathsNotFallenBack(t *testing.T) {
+	engine, _ := setupStaticTestEngine(t)
+
+	w := doStaticRequest(t, engine, "/api/unknown")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("API path status = %d, want 404", w.Code)
+	}
+}
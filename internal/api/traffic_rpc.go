@@ -3,18 +3,22 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"time"
 
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
 // GetTrafficSummaryMethod 获取流量汇总
 type GetTrafficSummaryMethod struct {
-	storage *storage.Storage
+	storage  *storage.Storage
+	wsServer *relay.WSServer
 }
 
-func NewGetTrafficSummaryMethod(s *storage.Storage) *GetTrafficSummaryMethod {
-	return &GetTrafficSummaryMethod{storage: s}
+func NewGetTrafficSummaryMethod(s *storage.Storage, ws *relay.WSServer) *GetTrafficSummaryMethod {
+	return &GetTrafficSummaryMethod{storage: s, wsServer: ws}
 }
 
 func (m *GetTrafficSummaryMethod) Name() string        { return "getTrafficSummary" }
@@ -25,6 +29,20 @@ func (m *GetTrafficSummaryMethod) Execute(ctx context.Context, params json.RawMe
 	if err != nil {
 		return nil, err
 	}
+
+	// 附加压缩统计 (有记录的规则才填充)
+	if m.wsServer != nil {
+		for i := range summaries {
+			snap, ok := m.wsServer.CompressionRuleSnapshot(summaries[i].RuleID)
+			if !ok {
+				continue
+			}
+			summaries[i].CompressionRawBytes = snap.RawBytes
+			summaries[i].CompressionCompressedBytes = snap.CompressedBytes
+			summaries[i].CompressionRatio = snap.Ratio
+		}
+	}
+
 	return summaries, nil
 }
 
@@ -97,6 +115,87 @@ func (m *GetTodayTrafficMethod) Execute(ctx context.Context, params json.RawMess
 	}, nil
 }
 
+// GetRuleActiveConnsMethod 获取规则的实时活跃连接数 (直接读内存计数器, 供前端实时指示)
+type GetRuleActiveConnsMethod struct {
+	storage *storage.Storage
+}
+
+func NewGetRuleActiveConnsMethod(s *storage.Storage) *GetRuleActiveConnsMethod {
+	return &GetRuleActiveConnsMethod{storage: s}
+}
+
+func (m *GetRuleActiveConnsMethod) Name() string      { return "getRuleActiveConns" }
+func (m *GetRuleActiveConnsMethod) RequireAuth() bool { return true }
+
+type GetRuleActiveConnsParams struct {
+	RuleID   string `json:"rule_id"`
+	ClientID string `json:"client_id"`
+}
+
+func (m *GetRuleActiveConnsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GetRuleActiveConnsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+
+	// 指定 rule_id 时返回单条规则的连接数, 否则按 client_id 返回该客户端全部规则
+	if p.RuleID != "" {
+		return map[string]interface{}{
+			"rule_id":      p.RuleID,
+			"active_conns": m.storage.Traffic.GetRuleActiveConns(p.RuleID),
+		}, nil
+	}
+
+	if p.ClientID == "" {
+		return nil, resp.ErrInvalidParams("rule_id or client_id is required")
+	}
+
+	rules := m.storage.Traffic.GetClientActiveConnsByRule(p.ClientID)
+	total := 0
+	for _, n := range rules {
+		total += n
+	}
+	return map[string]interface{}{
+		"client_id": p.ClientID,
+		"rules":     rules,
+		"total":     total,
+	}, nil
+}
+
+// PurgeTrafficStatsMethod 按需清理超过保留天数的流量明细 (配合定时保留任务, 供手动立即回收)
+type PurgeTrafficStatsMethod struct {
+	storage *storage.Storage
+}
+
+func NewPurgeTrafficStatsMethod(s *storage.Storage) *PurgeTrafficStatsMethod {
+	return &PurgeTrafficStatsMethod{storage: s}
+}
+
+func (m *PurgeTrafficStatsMethod) Name() string      { return "purgeTrafficStats" }
+func (m *PurgeTrafficStatsMethod) RequireAuth() bool { return true }
+
+type PurgeTrafficStatsParams struct {
+	Days int `json:"days"` // 保留最近 N 天, 更早的按日明细删除
+}
+
+func (m *PurgeTrafficStatsMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p PurgeTrafficStatsParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+	if p.Days <= 0 {
+		return nil, resp.ErrInvalidParams("days must be greater than 0")
+	}
+
+	purged, err := m.storage.Traffic.PurgeTrafficStatsBefore(time.Now().AddDate(0, 0, -p.Days))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"purged": purged,
+	}, nil
+}
+
 // GetClientBandwidthMethod 获取客户端带宽统计
 type GetClientBandwidthMethod struct {
 	storage *storage.Storage
@@ -120,13 +219,76 @@ func (m *GetClientBandwidthMethod) Execute(ctx context.Context, params json.RawM
 	result := make([]map[string]interface{}, 0, len(bandwidths))
 	for _, bw := range bandwidths {
 		result = append(result, map[string]interface{}{
-			"client_id":         bw.ClientID,
-			"bandwidth_in":      bw.BandwidthIn,
-			"bandwidth_out":     bw.BandwidthOut,
-			"bandwidth_in_str":  model.FormatBandwidth(bw.BandwidthIn),
-			"bandwidth_out_str": model.FormatBandwidth(bw.BandwidthOut),
+			"client_id":             bw.ClientID,
+			"bandwidth_in":          bw.BandwidthIn,
+			"bandwidth_out":         bw.BandwidthOut,
+			"bandwidth_in_str":      model.FormatBandwidth(bw.BandwidthIn),
+			"bandwidth_out_str":     model.FormatBandwidth(bw.BandwidthOut),
+			"bandwidth_in_ema":      bw.BandwidthInEMA,
+			"bandwidth_out_ema":     bw.BandwidthOutEMA,
+			"bandwidth_in_ema_str":  model.FormatBandwidth(bw.BandwidthInEMA),
+			"bandwidth_out_ema_str": model.FormatBandwidth(bw.BandwidthOutEMA),
 		})
 	}
 
 	return result, nil
 }
+
+// defaultTopRulesLimit 未指定条数时返回的规则数
+const defaultTopRulesLimit = 10
+
+// GetTopRulesByBandwidthMethod 按当前带宽返回最重的前 N 条规则 (直接读内存速率, 不扫全量汇总)
+type GetTopRulesByBandwidthMethod struct {
+	storage *storage.Storage
+}
+
+func NewGetTopRulesByBandwidthMethod(s *storage.Storage) *GetTopRulesByBandwidthMethod {
+	return &GetTopRulesByBandwidthMethod{storage: s}
+}
+
+func (m *GetTopRulesByBandwidthMethod) Name() string      { return "getTopRulesByBandwidth" }
+func (m *GetTopRulesByBandwidthMethod) RequireAuth() bool { return true }
+
+type GetTopRulesByBandwidthParams struct {
+	Limit int `json:"limit"` // 返回条数, <=0 使用默认
+}
+
+func (m *GetTopRulesByBandwidthMethod) Execute(ctx context.Context, params json.RawMessage) (interface{}, error) {
+	var p GetTopRulesByBandwidthParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, resp.ErrInvalidParams("invalid params")
+	}
+	if p.Limit <= 0 {
+		p.Limit = defaultTopRulesLimit
+	}
+
+	// 更新带宽计算
+	m.storage.Traffic.UpdateBandwidth()
+
+	top := m.storage.Traffic.GetTopRulesByBandwidth(p.Limit)
+
+	result := make([]map[string]interface{}, 0, len(top))
+	for _, rb := range top {
+		total := rb.BandwidthIn + rb.BandwidthOut
+		entry := map[string]interface{}{
+			"rule_id":             rb.RuleID,
+			"rule_name":           "",
+			"client_name":         "",
+			"bandwidth_in":        rb.BandwidthIn,
+			"bandwidth_out":       rb.BandwidthOut,
+			"bandwidth_total":     total,
+			"bandwidth_in_str":    model.FormatBandwidth(rb.BandwidthIn),
+			"bandwidth_out_str":   model.FormatBandwidth(rb.BandwidthOut),
+			"bandwidth_total_str": model.FormatBandwidth(total),
+		}
+		// 名称查不到时保持空串 (规则可能已删除, 内存统计尚在)
+		if rule, err := m.storage.Forward.GetByID(rb.RuleID); err == nil {
+			entry["rule_name"] = rule.Name
+			if client, err := m.storage.Client.GetByID(rule.ListenClient); err == nil {
+				entry["client_name"] = client.Name
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/storage"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/resp"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+// setupTrafficStorage 活跃连接计数只在内存中, 不需要数据库
+func setupTrafficStorage() *storage.Storage {
+	return &storage.Storage{Traffic: storage.NewTrafficRepository(nil)}
+}
+
+func TestGetRuleActiveConns_ReflectsCounter(t *testing.T) {
+	store := setupTrafficStorage()
+	method := NewGetRuleActiveConnsMethod(store)
+
+	store.Traffic.IncrementConn("rule-1", "client-a")
+	store.Traffic.IncrementConn("rule-1", "client-b")
+	store.Traffic.IncrementConn("rule-2", "client-a")
+
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"rule_id": "rule-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	got := result.(map[string]interface{})
+	if got["active_conns"] != 2 {
+		t.Errorf("active_conns = %v, want 2", got["active_conns"])
+	}
+
+	// 递减后反映当前值
+	store.Traffic.DecrementConn("rule-1", "client-a")
+	result, err = method.Execute(context.Background(), json.RawMessage(`{"rule_id": "rule-1"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	got = result.(map[string]interface{})
+	if got["active_conns"] != 1 {
+		t.Errorf("active_conns after decrement = %v, want 1", got["active_conns"])
+	}
+}
+
+func TestGetRuleActiveConns_ByClient(t *testing.T) {
+	store := setupTrafficStorage()
+	method := NewGetRuleActiveConnsMethod(store)
+
+	store.Traffic.IncrementConn("rule-1", "client-a")
+	store.Traffic.IncrementConn("rule-1", "client-a")
+	store.Traffic.IncrementConn("rule-2", "client-a")
+	store.Traffic.IncrementConn("rule-3", "client-b")
+
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"client_id": "client-a"}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	got := result.(map[string]interface{})
+	rules := got["rules"].(map[string]int)
+	if rules["rule-1"] != 2 || rules["rule-2"] != 1 {
+		t.Errorf("rules = %v, want rule-1=2 rule-2=1", rules)
+	}
+	if _, ok := rules["rule-3"]; ok {
+		t.Error("rule-3 belongs to another client, should not appear")
+	}
+	if got["total"] != 3 {
+		t.Errorf("total = %v, want 3", got["total"])
+	}
+}
+
+func TestGetRuleActiveConns_MissingParams(t *testing.T) {
+	method := NewGetRuleActiveConnsMethod(setupTrafficStorage())
+
+	_, err := method.Execute(context.Background(), json.RawMessage(`{}`))
+	coded, ok := err.(*resp.CodedError)
+	if !ok {
+		t.Fatalf("expected CodedError, got %v", err)
+	}
+	if coded.Code != resp.ErrCodeInvalidParams {
+		t.Errorf("code = %d, want %d", coded.Code, resp.ErrCodeInvalidParams)
+	}
+}
+
+func TestGetTopRulesByBandwidth_RanksByCurrentRate(t *testing.T) {
+	store := setupTestStorageWithClient(t)
+	seedTestClient(t, store, "entry")
+
+	for _, r := range []struct{ id, name, listen string }{
+		{"rule-heavy", "Heavy", "0.0.0.0:9101"},
+		{"rule-mid", "Mid", "0.0.0.0:9102"},
+		{"rule-light", "Light", "0.0.0.0:9103"},
+	} {
+		rule := &model.ForwardRule{
+			ID:           r.id,
+			Name:         r.name,
+			Type:         model.ForwardTypeDirect,
+			Enabled:      true,
+			ListenAddr:   r.listen,
+			ListenClient: "entry",
+			TargetAddr:   "127.0.0.1:80",
+		}
+		if err := store.Forward.Create(rule); err != nil {
+			t.Fatalf("failed to create rule: %v", err)
+		}
+	}
+
+	// 第一次采样只建立基线, 基线之后的增量才构成当前速率
+	for _, id := range []string{"rule-heavy", "rule-mid", "rule-light"} {
+		store.Traffic.AddBytesIn(id, "entry", 1)
+	}
+	store.Traffic.UpdateBandwidth()
+
+	store.Traffic.AddBytesIn("rule-heavy", "entry", 600000)
+	store.Traffic.AddBytesIn("rule-mid", "entry", 20000)
+	store.Traffic.AddBytesOut("rule-mid", "entry", 10000)
+	store.Traffic.AddBytesIn("rule-light", "entry", 100)
+	// 两次采样间隔不足 0.5s 时带宽不更新
+	time.Sleep(600 * time.Millisecond)
+
+	method := NewGetTopRulesByBandwidthMethod(store)
+	result, err := method.Execute(context.Background(), json.RawMessage(`{"limit": 2}`))
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	entries := result.([]map[string]interface{})
+	if len(entries) != 2 {
+		t.Fatalf("expected top 2 rules, got %d", len(entries))
+	}
+	if entries[0]["rule_id"] != "rule-heavy" || entries[1]["rule_id"] != "rule-mid" {
+		t.Errorf("ranking = [%v, %v], want [rule-heavy, rule-mid]",
+			entries[0]["rule_id"], entries[1]["rule_id"])
+	}
+	if entries[0]["rule_name"] != "Heavy" || entries[0]["client_name"] != "Client entry" {
+		t.Errorf("names = %v/%v, want Heavy/Client entry",
+			entries[0]["rule_name"], entries[0]["client_name"])
+	}
+	if total := entries[0]["bandwidth_total"].(int64); total <= 0 {
+		t.Errorf("bandwidth_total = %d, want > 0", total)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"net"
+	"sync"
+)
+
+// defaultAcceptQueueSize 启用工作池但未配置队列长度时的默认值
+const defaultAcceptQueueSize = 128
+
+// acceptPool 预热的连接处理工作池:
+// 固定数量的 worker 从有界队列消费 accept 到的连接, 避免突发时海量 goroutine 同时创建;
+// 队列满时直接关闭新连接快速卸载, 不阻塞 accept 循环
+type acceptPool struct {
+	queue   chan net.Conn
+	handler func(net.Conn)
+
+	stopCh    chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newAcceptPool(workers, queueSize int, handler func(net.Conn)) *acceptPool {
+	if queueSize <= 0 {
+		queueSize = defaultAcceptQueueSize
+	}
+	p := &acceptPool{
+		queue:   make(chan net.Conn, queueSize),
+		handler: handler,
+		stopCh:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// newAcceptPoolFromConfig 按 ForwarderSection 创建工作池, 未启用时返回 nil (调用方退回每连接一 goroutine)
+func newAcceptPoolFromConfig(cfg ForwarderSection, handler func(net.Conn)) *acceptPool {
+	if cfg.WorkerPoolSize <= 0 {
+		return nil
+	}
+	return newAcceptPool(cfg.WorkerPoolSize, cfg.WorkerQueueSize, handler)
+}
+
+func (p *acceptPool) worker() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case conn := <-p.queue:
+			p.handler(conn)
+		}
+	}
+}
+
+// Submit 将连接入队; 队列已满或池已关闭时关闭连接并返回 false
+func (p *acceptPool) Submit(conn net.Conn) bool {
+	select {
+	case <-p.stopCh:
+		conn.Close()
+		return false
+	default:
+	}
+	select {
+	case p.queue <- conn:
+		return true
+	default:
+		conn.Close()
+		return false
+	}
+}
+
+// Close 停止所有 worker (等待进行中的连接处理完) 并关闭仍滞留在队列中的连接
+func (p *acceptPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.stopCh)
+	})
+	p.wg.Wait()
+	for {
+		select {
+		case conn := <-p.queue:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewAcceptPoolFromConfig_DisabledReturnsNil(t *testing.T) {
+	pool := newAcceptPoolFromConfig(ForwarderSection{}, func(net.Conn) {})
+	if pool != nil {
+		pool.Close()
+		t.Error("expected nil pool when WorkerPoolSize is 0")
+	}
+}
+
+func TestAcceptPool_BoundsConcurrentHandlers(t *testing.T) {
+	const workers = 2
+
+	var current, peak int64
+	release := make(chan struct{})
+	pool := newAcceptPool(workers, 16, func(conn net.Conn) {
+		defer conn.Close()
+		n := atomic.AddInt64(&current, 1)
+		for {
+			old := atomic.LoadInt64(&peak)
+			if n <= old || atomic.CompareAndSwapInt64(&peak, old, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt64(&current, -1)
+	})
+
+	for i := 0; i < 8; i++ {
+		local, remote := net.Pipe()
+		defer local.Close()
+		if !pool.Submit(remote) {
+			t.Fatalf("submit %d rejected with room in queue", i)
+		}
+	}
+
+	// 等待 worker 全部进入 handler
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&current) < workers {
+		if time.Now().After(deadline) {
+			t.Fatal("workers never picked up connections")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(release)
+	pool.Close()
+
+	if got := atomic.LoadInt64(&peak); got != workers {
+		t.Errorf("expected at most %d concurrent handlers, peak was %d", workers, got)
+	}
+}
+
+func TestAcceptPool_ShedsWhenQueueFull(t *testing.T) {
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	pool := newAcceptPool(1, 1, func(conn net.Conn) {
+		defer conn.Close()
+		started <- struct{}{}
+		<-release
+	})
+	defer func() {
+		close(release)
+		pool.Close()
+	}()
+
+	// 第一条连接占住唯一的 worker
+	busyLocal, busyRemote := net.Pipe()
+	defer busyLocal.Close()
+	if !pool.Submit(busyRemote) {
+		t.Fatal("first submit should be accepted")
+	}
+	<-started
+
+	// 第二条进入队列
+	queuedLocal, queuedRemote := net.Pipe()
+	defer queuedLocal.Close()
+	if !pool.Submit(queuedRemote) {
+		t.Fatal("second submit should fit in the queue")
+	}
+
+	// 第三条被快速卸载: Submit 返回 false 且连接被关闭
+	shedLocal, shedRemote := net.Pipe()
+	defer shedLocal.Close()
+	if pool.Submit(shedRemote) {
+		t.Fatal("third submit should be rejected with a full queue")
+	}
+
+	shedLocal.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := shedLocal.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected shed connection to be closed (EOF), got %v", err)
+	}
+}
+
+func TestForwarder_WorkerPoolForwardsData(t *testing.T) {
+	// 目标: 简单 echo 服务
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create target listener: %v", err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	tempListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create temp listener: %v", err)
+	}
+	addr := tempListener.Addr().String()
+	tempListener.Close()
+
+	cfg := ForwarderSection{
+		ConnectTimeout: 5,
+		WorkerPoolSize: 2,
+	}
+
+	ready := make(chan struct{}, 1)
+	f := NewForwarder("pool-rule", addr, target.Addr().String(), cfg, nil, func(ruleID, status, errMsg string) {
+		select {
+		case ready <- struct{}{}:
+		default:
+		}
+	})
+	go f.Start()
+	defer f.Stop()
+	<-ready
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	buf := make([]byte, 5)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("expected echo 'hello', got %q", buf)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +16,9 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/rulehash"
+	"github.com/dollarkillerx/MessageBoy/pkg/common/tlspolicy"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
 type Client struct {
@@ -24,16 +28,29 @@ type Client struct {
 	wsEndpoint string
 
 	// wsConn 用 atomic.Pointer 保护，connectWebSocket 写入，各处读取
-	wsConn         atomic.Pointer[relay.WSClientConn]
-	forwarders     map[string]ForwarderInterface
+	wsConn     atomic.Pointer[relay.WSClientConn]
+	forwarders map[string]ForwarderInterface
+	// pendingRules 因 WebSocket 未连接而暂缓启动的规则 (mu 保护),
+	// 隧道建立后由 startPendingForwarders 补启动
+	pendingRules   map[string]model.ClientRule
 	mu             sync.RWMutex
 	trafficCounter *TrafficCounter
+	connPool       *ConnPool
+	dialBackoff    *dialBackoff
 
 	// asyncWg 跟踪 handleTunnelMessages 里拉起的异步 goroutine
 	asyncWg sync.WaitGroup
 	// loopWg 跟踪 mainLoop/heartbeatLoop/trafficReportLoop 三个主循环
 	loopWg sync.WaitGroup
 
+	// portCheckSem 限制并发端口检查数量, 避免同一客户端批量规则变更时
+	// 探测 goroutine 之间互相抢占端口
+	portCheckSem chan struct{}
+
+	// 本机调试端点 (可选, 仅回环; metricsAddr 为实际监听地址)
+	metricsSrv  *http.Server
+	metricsAddr string
+
 	stopCh      chan struct{}
 	reconnectCh chan struct{} // 触发重连
 }
@@ -55,7 +72,11 @@ func New(cfg *ClientConfig) *Client {
 	return &Client{
 		cfg:            cfg,
 		forwarders:     make(map[string]ForwarderInterface),
+		pendingRules:   make(map[string]model.ClientRule),
 		trafficCounter: NewTrafficCounter(),
+		connPool:       NewConnPool(0, 0),
+		dialBackoff:    newDialBackoff(defaultBackoffThreshold, defaultBackoffCooldown),
+		portCheckSem:   make(chan struct{}, portCheckConcurrency(cfg)),
 		stopCh:         make(chan struct{}),
 		reconnectCh:    make(chan struct{}, 1),
 	}
@@ -71,6 +92,16 @@ func (c *Client) Run() error {
 
 	log.Info().Str("client_id", c.clientID).Msg("Registered successfully")
 
+	// 启动出口连接池清理
+	c.connPool.Start()
+
+	// 本机调试端点 (可选): 启动失败只告警, 不影响转发
+	if c.cfg.Client.MetricsAddr != "" {
+		if err := c.startMetricsServer(c.cfg.Client.MetricsAddr); err != nil {
+			log.Warn().Err(err).Str("addr", c.cfg.Client.MetricsAddr).Msg("Failed to start metrics endpoint")
+		}
+	}
+
 	// 启动主循环
 	c.loopWg.Add(3)
 	go func() { defer c.loopWg.Done(); c.mainLoop() }()
@@ -124,6 +155,9 @@ func (c *Client) mainLoop() {
 			continue
 		}
 
+		// 补启动断线期间下发、因隧道不可用而暂缓的 forwarder
+		c.startPendingForwarders()
+
 		// 获取初始规则
 		if err := c.fetchAndApplyRules(); err != nil {
 			log.Warn().Err(err).Msg("Failed to fetch initial rules")
@@ -168,6 +202,14 @@ func (c *Client) Stop() {
 	// 等所有异步任务和主循环退出
 	c.asyncWg.Wait()
 	c.loopWg.Wait()
+
+	// 关闭出口连接池中的空闲连接
+	c.connPool.Stop()
+
+	// 关闭本机调试端点
+	if c.metricsSrv != nil {
+		c.metricsSrv.Close()
+	}
 }
 
 func (c *Client) register() error {
@@ -178,9 +220,10 @@ func (c *Client) register() error {
 		"hostname": hostname,
 		"version":  "2.0.0",
 	}
-	// 如果配置了 ReportIP，则上报（用于显示）
-	if c.cfg.Client.ReportIP != "" {
-		params["report_ip"] = c.cfg.Client.ReportIP
+	// 上报外部 IP: 优先 STUN 发现的公网 IP, 其次手动配置的 ReportIP,
+	// 都没有时服务端使用连接来源 IP
+	if reportIP := c.resolveReportIP(); reportIP != "" {
+		params["report_ip"] = reportIP
 	}
 
 	req := map[string]interface{}{
@@ -210,12 +253,52 @@ func (c *Client) register() error {
 	return nil
 }
 
+// resolveReportIP 决定注册时上报的外部 IP: 配置了 STUNServer 时先经 STUN 发现,
+// 失败时回退到手动配置的 ReportIP (返回空串表示交给服务端用连接 IP)
+func (c *Client) resolveReportIP() string {
+	if c.cfg.Client.STUNServer != "" {
+		ip, err := discoverPublicIP(c.cfg.Client.STUNServer, 5*time.Second)
+		if err == nil {
+			log.Info().Str("stun_server", c.cfg.Client.STUNServer).Str("public_ip", ip).Msg("Discovered public IP via STUN")
+			return ip
+		}
+		log.Warn().Err(err).Str("stun_server", c.cfg.Client.STUNServer).Msg("STUN discovery failed, falling back to configured ReportIP")
+	}
+	return c.cfg.Client.ReportIP
+}
+
 func (c *Client) connectWebSocket() error {
 	wsConn, err := relay.NewWSClientConn(c.wsEndpoint, c.clientID, c.secretKey)
 	if err != nil {
 		return err
 	}
 
+	// 应用配置的拨号 header 和子协议
+	if len(c.cfg.Connection.WSHeaders) > 0 {
+		headers := make(http.Header, len(c.cfg.Connection.WSHeaders))
+		for k, v := range c.cfg.Connection.WSHeaders {
+			headers.Set(k, v)
+		}
+		wsConn.SetDialHeaders(headers)
+	}
+	if c.cfg.Connection.WSSubprotocol != "" {
+		wsConn.SetSubprotocol(c.cfg.Connection.WSSubprotocol)
+	}
+	tlsCfg, err := tlspolicy.Policy{
+		MinVersion:   c.cfg.Connection.TLSMinVersion,
+		CipherSuites: c.cfg.Connection.TLSCipherSuites,
+	}.Build()
+	if err != nil {
+		return fmt.Errorf("invalid TLS config: %w", err)
+	}
+	wsConn.SetTLSConfig(tlsCfg)
+	if c.cfg.Connection.CloseDrainTimeout > 0 {
+		wsConn.SetCloseDrainTimeout(time.Duration(c.cfg.Connection.CloseDrainTimeout) * time.Second)
+	}
+	if c.cfg.Forwarder.MaxStreams > 0 {
+		wsConn.GetStreams().SetMaxStreams(c.cfg.Forwarder.MaxStreams)
+	}
+
 	if err := wsConn.Connect(); err != nil {
 		return err
 	}
@@ -258,12 +341,12 @@ func (c *Client) handleTunnelMessages() {
 			}
 
 		case relay.MsgTypeData:
-			// 数据消息，转发到对应的 stream
+			// 数据消息，转发到对应的 stream。缓冲满时阻塞等待消费端 (慢目标) 取走,
+			// 停止读 WebSocket 让 TCP 流控把背压传回上游, 而不是丢数据破坏流
 			stream := ws.GetStreams().GetStream(msg.StreamID)
 			if stream != nil {
-				if !stream.Write(msg.Payload) {
-					// 缓冲区满，说明下游消费不过来，关闭流以触发上游停止
-					log.Warn().Uint32("stream_id", msg.StreamID).Msg("Stream buffer full, closing stream")
+				if !stream.WriteBlocking(msg.Payload) {
+					// 只有流已关闭才会失败, 确保清理
 					ws.GetStreams().RemoveStream(msg.StreamID)
 				}
 			}
@@ -273,14 +356,19 @@ func (c *Client) handleTunnelMessages() {
 			ws.GetStreams().RemoveStream(msg.StreamID)
 
 		case relay.MsgTypeError:
-			// 错误消息，通知等待的 stream
+			// 错误消息，通知等待的 stream (附带错误类别)
+			category := relay.ErrCategoryGeneral
+			if len(msg.Payload) > 0 {
+				category = msg.Payload[0]
+			}
 			log.Debug().
 				Uint32("stream_id", msg.StreamID).
 				Str("error", msg.Error).
+				Str("category", relay.ErrCategoryName(category)).
 				Msg("Received error message")
 			stream := ws.GetStreams().GetStream(msg.StreamID)
 			if stream != nil {
-				stream.Write([]byte{relay.MsgTypeError})
+				stream.Write([]byte{relay.MsgTypeError, category})
 				stream.Close()
 			} else {
 				log.Warn().Uint32("stream_id", msg.StreamID).Msg("Received Error but stream not found")
@@ -301,6 +389,14 @@ func (c *Client) handleTunnelMessages() {
 		case relay.MsgTypeCheckPort:
 			// 端口检查请求
 			c.spawnAsync(func() { c.handleCheckPort(ws, msg) })
+
+		case relay.MsgTypeCommand:
+			// 远程命令请求
+			c.spawnAsync(func() { c.handleCommand(ws, msg) })
+
+		case relay.MsgTypeRuleStop:
+			// 规则停止请求 (规则迁移), 排空完成后回报确认
+			c.spawnAsync(func() { c.handleRuleStop(ws, msg) })
 		}
 	}
 }
@@ -322,38 +418,101 @@ func (c *Client) spawnAsync(fn func()) {
 // handleIncomingConnect 处理入站的连接请求 (作为出口节点)
 func (c *Client) handleIncomingConnect(ws *relay.WSClientConn, msg *relay.TunnelMessage) {
 	target := msg.Target
-	log.Debug().
-		Uint32("stream_id", msg.StreamID).
-		Str("target", target).
-		Msg("Handling incoming connect request")
-
-	// 连接目标
-	targetConn, err := net.DialTimeout("tcp", target, time.Duration(c.cfg.Forwarder.ConnectTimeout)*time.Second)
-	if err != nil {
-		log.Warn().Err(err).Str("target", target).Msg("Failed to connect to target")
-		// 发送错误响应
-		errMsg := &relay.TunnelMessage{
-			Type:     relay.MsgTypeError,
-			StreamID: msg.StreamID,
-			Error:    err.Error(),
-		}
-		ws.Send(errMsg)
-		return
+	poolable := msg.Flags&relay.ConnFlagPoolable != 0
+	// 半关排空只对非池化连接生效: 池化连接要保持可复用, 不能关闭写方向
+	drain := !poolable && msg.Flags&relay.ConnFlagDrainClose != 0
+	// 连接级 Debug 日志只对开启 verbose_logging 的规则输出
+	verbose := msg.Flags&relay.ConnFlagVerbose != 0
+	if verbose {
+		log.Debug().
+			Uint32("stream_id", msg.StreamID).
+			Str("target", target).
+			Bool("poolable", poolable).
+			Bool("drain", drain).
+			Msg("Handling incoming connect request")
 	}
-	tuneTCPConn(targetConn)
 
-	// 创建一个 stream 用于跟踪此连接
+	// 并发流上限: 超限直接拒绝, 不再拨号。
+	// 提前注册 stream 占位, 保证上限检查与注册原子完成
 	stream := &relay.Stream{
 		ID:      msg.StreamID,
 		Target:  target,
-		DataCh:  make(chan []byte, 1024),
+		DataCh:  make(chan []byte, c.streamBufferSize()),
 		CloseCh: make(chan struct{}),
 	}
-
-	// 手动添加到 streams 管理器
-	ws.GetStreams().AddStream(stream)
+	if !ws.GetStreams().TryAddStream(stream) {
+		log.Warn().
+			Uint32("stream_id", msg.StreamID).
+			Int("max_streams", c.cfg.Forwarder.MaxStreams).
+			Msg("Max concurrent streams reached, rejecting connect")
+		ws.Send(&relay.TunnelMessage{
+			Type:     relay.MsgTypeError,
+			StreamID: msg.StreamID,
+			Error:    "max concurrent streams reached",
+		})
+		return
+	}
 	defer ws.GetStreams().RemoveStream(msg.StreamID)
-	defer targetConn.Close()
+
+	// 规则允许复用时优先从连接池取
+	var targetConn net.Conn
+	if poolable {
+		targetConn = c.connPool.Get(target)
+	}
+	if targetConn == nil {
+		// 冷却期内快速失败, 避免高频冲击连续拨号失败的目标
+		if !c.dialBackoff.Allow(target) {
+			log.Warn().Str("target", target).Msg("Target in dial backoff cooldown, rejecting connect")
+			errMsg := &relay.TunnelMessage{
+				Type:     relay.MsgTypeError,
+				StreamID: msg.StreamID,
+				Error:    "target in backoff cooldown after repeated connect failures",
+			}
+			ws.Send(errMsg)
+			return
+		}
+
+		// 连接目标 (出口拨号, 支持配置源 IP/端口范围)
+		conn, err := dialExit(c.cfg.Forwarder, target, time.Duration(c.cfg.Forwarder.ConnectTimeout)*time.Second)
+		if err != nil {
+			c.dialBackoff.RecordFailure(target)
+			log.Warn().Err(err).Str("target", target).Msg("Failed to connect to target")
+			// 发送错误响应 (Payload 首字节携带错误类别, 入口据此区分超时与拒绝)
+			errMsg := &relay.TunnelMessage{
+				Type:     relay.MsgTypeError,
+				StreamID: msg.StreamID,
+				Error:    err.Error(),
+				Payload:  []byte{relay.ClassifyDialError(err)},
+			}
+			ws.Send(errMsg)
+			return
+		}
+		c.dialBackoff.RecordSuccess(target)
+		tuneTCPConn(conn)
+		targetConn = conn
+	}
+
+	// fast open: 入口随 Connect 带来的首包数据先写入目标再回 ConnAck,
+	// 首包不必等待 Connect→ConnAck 的完整往返
+	if msg.Flags&relay.ConnFlagEarlyData != 0 && len(msg.Payload) > 0 {
+		if _, err := targetConn.Write(msg.Payload); err != nil {
+			log.Warn().Err(err).Str("target", target).Msg("Failed to write early data to target")
+			targetConn.Close()
+			errMsg := &relay.TunnelMessage{
+				Type:     relay.MsgTypeError,
+				StreamID: msg.StreamID,
+				Error:    "early data write failed: " + err.Error(),
+			}
+			ws.Send(errMsg)
+			return
+		}
+		if verbose {
+			log.Debug().
+				Uint32("stream_id", msg.StreamID).
+				Int("bytes", len(msg.Payload)).
+				Msg("Early data written to target before ConnAck")
+		}
+	}
 
 	// 发送 ConnAck
 	ackMsg := &relay.TunnelMessage{
@@ -362,33 +521,68 @@ func (c *Client) handleIncomingConnect(ws *relay.WSClientConn, msg *relay.Tunnel
 	}
 	if err := ws.Send(ackMsg); err != nil {
 		log.Warn().Err(err).Uint32("stream_id", msg.StreamID).Msg("Failed to send ConnAck")
+		targetConn.Close()
 		return
 	}
 
-	log.Debug().
-		Uint32("stream_id", msg.StreamID).
-		Str("target", target).
-		Msg("ConnAck sent, tunnel connected to target")
+	if verbose {
+		log.Debug().
+			Uint32("stream_id", msg.StreamID).
+			Str("target", target).
+			Msg("ConnAck sent, tunnel connected to target")
+	}
 
 	// 双向转发：一方结束时关闭双端，双方都退出后才返回
 	var wg sync.WaitGroup
 	wg.Add(2)
 
+	// returnToPool 标记目标连接在流结束后仍健康，可以归还连接池
+	var returnToPool int32
+
+	// finishTarget 隧道侧结束时调用：池化连接先打断阻塞的 Read 由读端判定能否归还，
+	// 开启排空的连接半关写方向让尾部数据送达目标，其余按配置的宽限窗口关闭
+	finishTarget := func() {
+		stream.Close()
+		switch {
+		case poolable:
+			targetConn.SetReadDeadline(time.Now())
+		case drain:
+			c.drainCloseTarget(targetConn)
+		default:
+			c.graceCloseTarget(targetConn)
+		}
+	}
+
 	// 目标 -> 隧道（零拷贝优化）
 	go func() {
 		defer wg.Done()
 		defer stream.Close()
-		defer targetConn.Close()
 
 		buf := relay.GetBuffer()
 		defer relay.PutBuffer(buf)
 
+		coalesce := c.cfg.Forwarder.CoalesceReads
 		for {
 			n, err := targetConn.Read((*buf)[relay.HeaderSize:])
 			if err != nil {
+				// 隧道侧已结束且只是读超时被打断，说明连接仍健康
+				if poolable && n == 0 && stream.IsClosed() && isTimeoutErr(err) {
+					atomic.StoreInt32(&returnToPool, 1)
+				} else {
+					targetConn.Close()
+				}
 				return
 			}
 
+			if coalesce && n < len(*buf)-relay.HeaderSize {
+				n = coalesceRead(targetConn, (*buf)[relay.HeaderSize:], n)
+				// 合并读清除了读截止时间; 池化连接若在此期间被隧道侧打断,
+				// 重新设置截止时间让下一次阻塞读立即超时, 走归还连接池的路径
+				if poolable && stream.IsClosed() {
+					targetConn.SetReadDeadline(time.Now())
+				}
+			}
+
 			dataMsg := &relay.TunnelMessage{
 				Type:     relay.MsgTypeData,
 				StreamID: msg.StreamID,
@@ -396,6 +590,7 @@ func (c *Client) handleIncomingConnect(ws *relay.WSClientConn, msg *relay.Tunnel
 			}
 
 			if err := ws.Send(dataMsg); err != nil {
+				targetConn.Close()
 				return
 			}
 		}
@@ -404,19 +599,26 @@ func (c *Client) handleIncomingConnect(ws *relay.WSClientConn, msg *relay.Tunnel
 	// 隧道 -> 目标
 	go func() {
 		defer wg.Done()
-		defer stream.Close()
-		defer targetConn.Close()
 
 		for {
 			select {
 			case data, ok := <-stream.DataCh:
 				if !ok {
+					finishTarget()
 					return
 				}
 				if _, err := targetConn.Write(data); err != nil {
+					stream.Close()
+					targetConn.Close()
 					return
 				}
 			case <-stream.CloseCh:
+				// CloseCh 与 DataCh 同时就绪时 select 不保证先消费数据;
+				// 排空模式下先把缓冲的尾部数据写给目标再收尾
+				if drain {
+					flushPendingData(stream, targetConn)
+				}
+				finishTarget()
 				return
 			}
 		}
@@ -424,6 +626,11 @@ func (c *Client) handleIncomingConnect(ws *relay.WSClientConn, msg *relay.Tunnel
 
 	wg.Wait()
 
+	if atomic.LoadInt32(&returnToPool) == 1 {
+		targetConn.SetReadDeadline(time.Time{})
+		c.connPool.Put(target, targetConn)
+	}
+
 	// 发送关闭消息
 	closeMsg := &relay.TunnelMessage{
 		Type:     relay.MsgTypeClose,
@@ -432,8 +639,117 @@ func (c *Client) handleIncomingConnect(ws *relay.WSClientConn, msg *relay.Tunnel
 	ws.Send(closeMsg)
 }
 
+// defaultStreamBufferSize 隧道->目标方向每个流的默认读缓冲条数
+const defaultStreamBufferSize = 1024
+
+// streamBufferSize 返回配置的流读缓冲条数, 未配置时使用默认值
+func (c *Client) streamBufferSize() int {
+	if c.cfg.Forwarder.StreamBufferSize > 0 {
+		return c.cfg.Forwarder.StreamBufferSize
+	}
+	return defaultStreamBufferSize
+}
+
+// coalesceDrainWindow 合并读的聚合窗口: 窗口内到达的数据并入当前帧。
+// 窗口过大会增加转发延迟, 过小聚合不到数据, 取亚毫秒级平衡两者
+const coalesceDrainWindow = 500 * time.Microsecond
+
+// coalesceRead 在一次阻塞读之后于短窗口内继续读取已到达的数据,
+// 将多次小读合并为一帧发送以减少帧数。窗口超时视为没有更多数据,
+// 返回累计字节数, 返回前清除读截止时间恢复阻塞读
+func coalesceRead(conn net.Conn, buf []byte, n int) int {
+	conn.SetReadDeadline(time.Now().Add(coalesceDrainWindow))
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+	return n
+}
+
+// flushPendingData 非阻塞排空流中尚未写出的数据 (用于关闭前的尾部数据交付)
+func flushPendingData(stream *relay.Stream, targetConn net.Conn) {
+	for {
+		select {
+		case data, ok := <-stream.DataCh:
+			if !ok {
+				return
+			}
+			if _, err := targetConn.Write(data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// defaultCloseDrainTimeout 出口半关排空的默认等待秒数
+const defaultCloseDrainTimeout = 5
+
+// drainCloseTarget 半关排空目标连接: 先关闭写方向, 让内核把已缓冲的数据连同 FIN 送达目标,
+// 再设置读截止时间, 由读端 goroutine 排空对端余量 (EOF 或超时) 后关闭连接
+func (c *Client) drainCloseTarget(conn net.Conn) {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		conn.Close()
+		return
+	}
+	timeout := c.cfg.Forwarder.CloseDrainTimeout
+	if timeout <= 0 {
+		timeout = defaultCloseDrainTimeout
+	}
+	// SO_LINGER 保证最终 Close 时内核继续发送未送出的数据而不是直接丢弃
+	tc.SetLinger(timeout)
+	if err := tc.CloseWrite(); err != nil {
+		tc.Close()
+		return
+	}
+	tc.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+}
+
+// graceCloseTarget 在配置的宽限窗口内优雅关闭目标连接: 半关写方向先送出 FIN,
+// 再设置读截止时间, 读端 goroutine 在窗口内弃置目标的余量数据 (EOF 或超时) 后关闭。
+// 接收缓冲还有未读数据时直接 Close 会以 RST 终止连接, 未配置宽限时保持原行为
+func (c *Client) graceCloseTarget(conn net.Conn) {
+	grace := time.Duration(c.cfg.Forwarder.CloseGraceMs) * time.Millisecond
+	tc, ok := conn.(*net.TCPConn)
+	if grace <= 0 || !ok {
+		conn.Close()
+		return
+	}
+	if err := tc.CloseWrite(); err != nil {
+		tc.Close()
+		return
+	}
+	tc.SetReadDeadline(time.Now().Add(grace))
+}
+
+// portCheckConcurrency 返回端口检查并发上限 (配置 <=0 时串行)
+func portCheckConcurrency(cfg *ClientConfig) int {
+	if cfg != nil && cfg.Forwarder.PortCheckConcurrency > 0 {
+		return cfg.Forwarder.PortCheckConcurrency
+	}
+	return 1
+}
+
+// withPortCheckSlot 在并发上限内执行一次端口检查
+func (c *Client) withPortCheckSlot(fn func()) {
+	c.portCheckSem <- struct{}{}
+	defer func() { <-c.portCheckSem }()
+	fn()
+}
+
 // handleCheckPort 处理端口检查请求
 func (c *Client) handleCheckPort(ws *relay.WSClientConn, msg *relay.TunnelMessage) {
+	c.withPortCheckSlot(func() { c.checkPort(ws, msg) })
+}
+
+// checkPort 执行单次端口检查并回送结果
+func (c *Client) checkPort(ws *relay.WSClientConn, msg *relay.TunnelMessage) {
 	addr := msg.Target
 	currentRuleID := msg.RuleID
 
@@ -443,26 +759,37 @@ func (c *Client) handleCheckPort(ws *relay.WSClientConn, msg *relay.TunnelMessag
 		Uint32("request_id", msg.StreamID).
 		Msg("=== Received port check request ===")
 
-	var errMsg string
-
-	// 检查是否是当前规则正在使用的端口（如果是，则允许更新）
+	// 检查端口是否被本客户端的某条规则占用
 	c.mu.RLock()
-	if currentRuleID != "" {
-		if f, exists := c.forwarders[currentRuleID]; exists {
-			if f.GetListenAddr() == addr {
-				log.Info().
-					Str("addr", addr).
-					Str("rule_id", currentRuleID).
-					Msg("Port is used by current rule, will be restarted - available")
-				c.mu.RUnlock()
-				c.sendPortCheckResult(ws, msg.StreamID, "")
-				return
-			}
+	var ownerRuleID string
+	for id, f := range c.forwarders {
+		if f.GetListenAddr() == addr {
+			ownerRuleID = id
+			break
 		}
 	}
 	c.mu.RUnlock()
 
+	if ownerRuleID != "" {
+		// 当前规则自己占用的端口在更新时会先重启, 视为可用
+		if ownerRuleID == currentRuleID {
+			log.Info().
+				Str("addr", addr).
+				Str("rule_id", currentRuleID).
+				Msg("Port is used by current rule, will be restarted - available")
+			c.sendPortCheckResult(ws, msg.StreamID, "", "")
+			return
+		}
+		log.Warn().
+			Str("addr", addr).
+			Str("owner_rule_id", ownerRuleID).
+			Msg("Port check failed - port used by another rule")
+		c.sendPortCheckResult(ws, msg.StreamID, "该端口已被规则 "+ownerRuleID+" 占用", ownerRuleID)
+		return
+	}
+
 	// 尝试监听端口
+	var errMsg string
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		errMsg = "该端口已被其他程序占用"
@@ -472,15 +799,16 @@ func (c *Client) handleCheckPort(ws *relay.WSClientConn, msg *relay.TunnelMessag
 		log.Info().Str("addr", addr).Msg("Port check passed - port available")
 	}
 
-	c.sendPortCheckResult(ws, msg.StreamID, errMsg)
+	c.sendPortCheckResult(ws, msg.StreamID, errMsg, "")
 }
 
-// sendPortCheckResult 发送端口检查结果
-func (c *Client) sendPortCheckResult(ws *relay.WSClientConn, requestID uint32, errMsg string) {
+// sendPortCheckResult 发送端口检查结果, ownerRuleID 为占用端口的规则 (无则为空)
+func (c *Client) sendPortCheckResult(ws *relay.WSClientConn, requestID uint32, errMsg string, ownerRuleID string) {
 	resultMsg := &relay.TunnelMessage{
 		Type:     relay.MsgTypeCheckPortResult,
 		StreamID: requestID,
 		Error:    errMsg,
+		RuleID:   ownerRuleID,
 	}
 
 	if err := ws.Send(resultMsg); err != nil {
@@ -494,6 +822,83 @@ func (c *Client) sendPortCheckResult(ws *relay.WSClientConn, requestID uint32, e
 	}
 }
 
+// handleCommand 处理服务端下发的远程命令 (仅支持白名单动词, Target 字段携带命令)
+func (c *Client) handleCommand(ws *relay.WSClientConn, msg *relay.TunnelMessage) {
+	command := msg.Target
+	log.Info().
+		Str("command", command).
+		Uint32("request_id", msg.StreamID).
+		Msg("Received remote command")
+
+	var output, errMsg string
+	switch command {
+	case "ping":
+		output = "pong"
+
+	case "reload-rules":
+		if err := c.fetchAndApplyRules(); err != nil {
+			errMsg = err.Error()
+		} else {
+			output = "rules reloaded"
+		}
+
+	case "dump-state":
+		c.mu.RLock()
+		forwarders := len(c.forwarders)
+		c.mu.RUnlock()
+		output = fmt.Sprintf("forwarders=%d connected=%t", forwarders, c.getWSConn() != nil)
+
+	default:
+		errMsg = "unknown command: " + command
+	}
+
+	resultMsg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeCommandResult,
+		StreamID: msg.StreamID,
+		Error:    errMsg,
+		Payload:  []byte(output),
+	}
+	if err := ws.Send(resultMsg); err != nil {
+		log.Warn().Err(err).Str("command", command).Msg("Failed to send command result")
+	}
+}
+
+// handleRuleStop 处理服务端下发的规则停止请求 (规则迁移到其他 client 时):
+// 停止对应 forwarder 并关闭其活跃连接, 完成后回报确认,
+// 服务端收到确认才会通知新 client 启动, 避免两端同时持有规则
+func (c *Client) handleRuleStop(ws *relay.WSClientConn, msg *relay.TunnelMessage) {
+	ruleID := msg.RuleID
+	log.Info().
+		Str("rule_id", ruleID).
+		Uint32("request_id", msg.StreamID).
+		Msg("Received rule stop request")
+
+	c.mu.Lock()
+	f, ok := c.forwarders[ruleID]
+	if ok {
+		delete(c.forwarders, ruleID)
+	}
+	delete(c.pendingRules, ruleID)
+	c.mu.Unlock()
+
+	// 锁外停止: Stop 会关闭活跃连接并等待处理协程退出
+	if ok {
+		f.Stop()
+		log.Info().Str("rule_id", ruleID).Msg("Forwarder stopped and drained for rule move")
+	} else {
+		log.Debug().Str("rule_id", ruleID).Msg("Rule stop request for rule not running locally")
+	}
+
+	resultMsg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeRuleStopResult,
+		StreamID: msg.StreamID,
+		RuleID:   ruleID,
+	}
+	if err := ws.Send(resultMsg); err != nil {
+		log.Warn().Err(err).Str("rule_id", ruleID).Msg("Failed to send rule stop result")
+	}
+}
+
 func (c *Client) heartbeatLoop() {
 	ticker := time.NewTicker(time.Duration(c.cfg.Connection.HeartbeatInterval) * time.Second)
 	defer ticker.Stop()
@@ -544,49 +949,115 @@ func (c *Client) fetchAndApplyRules() error {
 		return fmt.Errorf("invalid response")
 	}
 
-	rules, ok := result["rules"].([]interface{})
+	rawRules, ok := result["rules"].([]interface{})
 	if !ok {
 		return fmt.Errorf("invalid rules format")
 	}
 
-	c.applyRules(rules)
+	c.applyRules(decodeClientRules(rawRules))
 	return nil
 }
 
 // computeRuleConfigHash 计算规则配置的哈希值
-func computeRuleConfigHash(rule map[string]interface{}) string {
-	ruleType := rule["type"].(string)
-	listenAddr := rule["listen_addr"].(string)
-
-	if ruleType == "direct" {
-		targetAddr := ""
-		if ta, ok := rule["target_addr"].(string); ok {
-			targetAddr = ta
+func computeRuleConfigHash(rule model.ClientRule) string {
+	if rule.Type == model.ForwardTypeDirect {
+		hash := "direct:" + rule.ListenAddr + ":" + rule.TargetAddr
+		if rule.CanaryTarget != "" && rule.CanaryPercent > 0 {
+			hash += ":canary:" + rule.CanaryTarget + ":" + strconv.Itoa(rule.CanaryPercent)
+		}
+		if rule.MirrorAddr != "" {
+			hash += ":mirror:" + rule.MirrorAddr
 		}
-		return "direct:" + listenAddr + ":" + targetAddr
+		if rule.TCPFastOpen {
+			hash += ":tfo"
+		}
+		return hash
+	}
+
+	if rule.Type == model.ForwardTypeSNI {
+		// 复用 SNIForwarder 的哈希逻辑，保证 diff 一致
+		f := &SNIForwarder{listenAddr: rule.ListenAddr, sniMap: rule.SNIMap}
+		return f.GetConfigHash()
+	}
+
+	if rule.Type == model.ForwardTypeSocks5 {
+		f := &Socks5Forwarder{listenAddr: rule.ListenAddr, relayChain: rule.RelayChain}
+		return f.GetConfigHash()
+	}
+
+	if rule.Type == model.ForwardTypeHTTPConnect {
+		f := &HTTPConnectForwarder{listenAddr: rule.ListenAddr, relayChain: rule.RelayChain}
+		return f.GetConfigHash()
 	}
 
 	// relay type
-	exitAddr := ""
-	if ea, ok := rule["exit_addr"].(string); ok {
-		exitAddr = ea
+	hash := "relay:" + rule.ListenAddr + ":" + rule.ExitAddr + ":"
+	for _, hop := range rule.RelayChain {
+		hash += hop + ","
 	}
-	hash := "relay:" + listenAddr + ":" + exitAddr + ":"
-	if chain, ok := rule["relay_chain"].([]interface{}); ok {
-		for _, v := range chain {
-			hash += v.(string) + ","
+	return hash
+}
+
+// ruleTCPOptions 从规则中解析 TCP 调优参数，未配置时返回默认值
+func ruleTCPOptions(rule model.ClientRule) (noDelay bool, keepAlive time.Duration) {
+	noDelay = true
+	keepAlive = defaultKeepAlivePeriod
+	if rule.TCPNoDelay != nil {
+		noDelay = *rule.TCPNoDelay
+	}
+	if rule.TCPKeepAliveInterval > 0 {
+		keepAlive = time.Duration(rule.TCPKeepAliveInterval) * time.Second
+	}
+	return noDelay, keepAlive
+}
+
+// parseRule 将响应中的单条规则解码为共享 DTO 并校验必需字段
+// (服务端按同一结构序列化, 见 model.ClientRule)
+func parseRule(raw interface{}) (model.ClientRule, error) {
+	var rule model.ClientRule
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return rule, err
+	}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return rule, err
+	}
+	if err := rule.Validate(); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+// decodeClientRules 解码规则列表, 坏规则跳过并告警, 不影响其余规则生效
+func decodeClientRules(rawRules []interface{}) []model.ClientRule {
+	rules := make([]model.ClientRule, 0, len(rawRules))
+	for _, raw := range rawRules {
+		rule, err := parseRule(raw)
+		if err != nil {
+			log.Warn().Err(err).Interface("rule", raw).Msg("Skipping malformed rule from server")
+			continue
 		}
+		rules = append(rules, rule)
 	}
-	return hash
+	return rules
 }
 
-func (c *Client) applyRules(rules []interface{}) {
+func (c *Client) applyRules(rules []model.ClientRule) {
 	log.Info().Int("rule_count", len(rules)).Msg("Applying rules")
 
 	newRuleIDs := make(map[string]bool, len(rules))
-	for _, r := range rules {
-		rule := r.(map[string]interface{})
-		newRuleIDs[rule["id"].(string)] = true
+	for _, rule := range rules {
+		newRuleIDs[rule.ID] = true
+
+		// 校验服务端下发的规范化哈希, 发现序列化漂移时仅告警不中断
+		if rule.ConfigHash != "" {
+			if computed := rulehash.CanonicalHashStruct(rule); computed != rule.ConfigHash {
+				log.Warn().
+					Str("rule_id", rule.ID).
+					Str("computed", computed).
+					Msg("Rule config hash drift detected, server and client serialize rules differently")
+			}
+		}
 	}
 
 	// 第一阶段：锁内计算 diff（需要停掉哪些、需要重建哪些）
@@ -599,9 +1070,8 @@ func (c *Client) applyRules(rules []interface{}) {
 			delete(c.forwarders, id)
 		}
 	}
-	for _, r := range rules {
-		rule := r.(map[string]interface{})
-		id := rule["id"].(string)
+	for _, rule := range rules {
+		id := rule.ID
 		if existing, ok := c.forwarders[id]; ok {
 			if existing.GetConfigHash() == computeRuleConfigHash(rule) {
 				skipRestart[id] = true
@@ -611,6 +1081,12 @@ func (c *Client) applyRules(rules []interface{}) {
 			delete(c.forwarders, id)
 		}
 	}
+	// 断线期间被删除的规则不再补启动
+	for id := range c.pendingRules {
+		if !newRuleIDs[id] {
+			delete(c.pendingRules, id)
+		}
+	}
 	c.mu.Unlock()
 
 	// 第二阶段：锁外停旧 forwarder（避免 Stop 内 wg.Wait 阻塞住整个客户端）
@@ -624,75 +1100,221 @@ func (c *Client) applyRules(rules []interface{}) {
 	}
 
 	// 第三阶段：启动新的/变更的 forwarder
-	for _, r := range rules {
-		rule := r.(map[string]interface{})
-		id := rule["id"].(string)
-		if skipRestart[id] {
+	for _, rule := range rules {
+		if skipRestart[rule.ID] {
 			continue
 		}
+		c.startRuleForwarder(rule, statusCallback)
+	}
+}
 
-		ruleType := rule["type"].(string)
-
-		switch ruleType {
-		case "direct":
-			f := NewForwarder(
-				id,
-				rule["listen_addr"].(string),
-				rule["target_addr"].(string),
-				c.cfg.Forwarder,
-				c.trafficCounter,
-				statusCallback,
-			)
-			c.mu.Lock()
-			c.forwarders[id] = f
-			c.mu.Unlock()
-			c.spawnAsync(func() { f.Start() })
-			log.Info().
+// startRuleForwarder 按类型启动单条规则的 forwarder (applyRules 第三阶段与
+// 隧道恢复后的补启动共用)。依赖隧道的类型在 WebSocket 未连接时不直接失败,
+// 而是记入 pendingRules, 等隧道建立后重试
+func (c *Client) startRuleForwarder(rule model.ClientRule, statusCallback func(ruleID, status, errMsg string)) {
+	id := rule.ID
+
+	// 超出 forwarder 上限的规则拒绝启动并上报, 防止误配置耗尽客户端
+	if limit := c.cfg.Forwarder.MaxForwarders; limit > 0 {
+		c.mu.RLock()
+		count := len(c.forwarders)
+		c.mu.RUnlock()
+		if count >= limit {
+			log.Warn().
 				Str("rule_id", id).
-				Str("listen", rule["listen_addr"].(string)).
-				Str("target", rule["target_addr"].(string)).
-				Msg("Started direct forwarder")
-
-		case "relay":
-			if c.getWSConn() == nil {
-				log.Warn().Str("rule_id", id).Msg("Cannot start relay forwarder: WebSocket not connected")
-				c.spawnAsync(func() { c.reportRuleStatus(id, "error", "WebSocket not connected") })
-				continue
-			}
+				Int("max_forwarders", limit).
+				Msg("Max forwarder limit reached, refusing rule")
+			c.spawnAsync(func() {
+				c.reportRuleStatus(id, "error", fmt.Sprintf("max forwarder limit reached (%d)", limit))
+			})
+			return
+		}
+	}
 
-			var relayChain []string
-			if chain, ok := rule["relay_chain"].([]interface{}); ok {
-				for _, v := range chain {
-					relayChain = append(relayChain, v.(string))
-				}
-			}
+	switch rule.Type {
+	case model.ForwardTypeDirect:
+		f := NewForwarder(
+			id,
+			rule.ListenAddr,
+			rule.TargetAddr,
+			c.cfg.Forwarder,
+			c.trafficCounter,
+			statusCallback,
+		)
+		f.SetTCPOptions(ruleTCPOptions(rule))
+		if rule.CanaryTarget != "" && rule.CanaryPercent > 0 {
+			f.SetCanary(rule.CanaryTarget, rule.CanaryPercent)
+		}
+		if rule.MirrorAddr != "" {
+			f.SetMirrorAddr(rule.MirrorAddr)
+		}
+		if rule.TCPFastOpen {
+			f.SetTCPFastOpen(true)
+		}
+		c.mu.Lock()
+		c.forwarders[id] = f
+		c.mu.Unlock()
+		c.spawnAsync(func() { f.Start() })
+		log.Info().
+			Str("rule_id", id).
+			Str("listen", rule.ListenAddr).
+			Str("target", rule.TargetAddr).
+			Msg("Started direct forwarder")
+
+	case model.ForwardTypeSNI:
+		f := NewSNIForwarder(
+			id,
+			rule.ListenAddr,
+			rule.SNIMap,
+			c.cfg.Forwarder,
+			c.trafficCounter,
+			statusCallback,
+		)
+		f.SetTCPOptions(ruleTCPOptions(rule))
+		c.mu.Lock()
+		c.forwarders[id] = f
+		c.mu.Unlock()
+		c.spawnAsync(func() { f.Start() })
+		log.Info().
+			Str("rule_id", id).
+			Str("listen", rule.ListenAddr).
+			Int("sni_entries", len(rule.SNIMap)).
+			Msg("Started SNI forwarder")
+
+	case model.ForwardTypeSocks5:
+		// 目标在 SOCKS5 请求中动态指定; 隧道只在配置了中继链时需要
+		if len(rule.RelayChain) > 0 && c.getWSConn() == nil {
+			log.Warn().Str("rule_id", id).Msg("SOCKS5 forwarder deferred: WebSocket not connected")
+			c.deferRuleStart(rule)
+			c.spawnAsync(func() { c.reportRuleStatus(id, "error", "WebSocket not connected") })
+			return
+		}
 
-			exitAddr := ""
-			if ea, ok := rule["exit_addr"].(string); ok {
-				exitAddr = ea
-			}
+		f := NewSocks5Forwarder(
+			id,
+			rule.ListenAddr,
+			rule.RelayChain,
+			c.cfg.Forwarder,
+			c.getWSConn,
+			c.trafficCounter,
+			statusCallback,
+		)
+		f.SetTCPOptions(ruleTCPOptions(rule))
+		f.SetVerboseLogging(rule.VerboseLogging)
+		f.SetFallbackGroup(rule.FallbackGroup)
+		c.mu.Lock()
+		delete(c.pendingRules, id)
+		c.forwarders[id] = f
+		c.mu.Unlock()
+		c.spawnAsync(func() { f.Start() })
+		log.Info().
+			Str("rule_id", id).
+			Str("listen", rule.ListenAddr).
+			Strs("relay_chain", rule.RelayChain).
+			Msg("Started SOCKS5 forwarder")
+
+	case model.ForwardTypeHTTPConnect:
+		// 目标在 CONNECT 请求中动态指定; 隧道只在配置了中继链时需要
+		if len(rule.RelayChain) > 0 && c.getWSConn() == nil {
+			log.Warn().Str("rule_id", id).Msg("HTTP CONNECT forwarder deferred: WebSocket not connected")
+			c.deferRuleStart(rule)
+			c.spawnAsync(func() { c.reportRuleStatus(id, "error", "WebSocket not connected") })
+			return
+		}
 
-			f := NewRelayForwarder(
-				id,
-				rule["listen_addr"].(string),
-				exitAddr,
-				relayChain,
-				c.cfg.Forwarder,
-				c.getWSConn,
-				c.trafficCounter,
-				statusCallback,
-			)
-			c.mu.Lock()
-			c.forwarders[id] = f
-			c.mu.Unlock()
-			c.spawnAsync(func() { f.Start() })
-			log.Info().
-				Str("rule_id", id).
-				Str("listen", rule["listen_addr"].(string)).
-				Str("exit", exitAddr).
-				Strs("relay_chain", relayChain).
-				Msg("Started relay forwarder")
+		f := NewHTTPConnectForwarder(
+			id,
+			rule.ListenAddr,
+			rule.RelayChain,
+			c.cfg.Forwarder,
+			c.getWSConn,
+			c.trafficCounter,
+			statusCallback,
+		)
+		f.SetTCPOptions(ruleTCPOptions(rule))
+		f.SetVerboseLogging(rule.VerboseLogging)
+		f.SetFallbackGroup(rule.FallbackGroup)
+		c.mu.Lock()
+		delete(c.pendingRules, id)
+		c.forwarders[id] = f
+		c.mu.Unlock()
+		c.spawnAsync(func() { f.Start() })
+		log.Info().
+			Str("rule_id", id).
+			Str("listen", rule.ListenAddr).
+			Strs("relay_chain", rule.RelayChain).
+			Msg("Started HTTP CONNECT forwarder")
+
+	case model.ForwardTypeRelay:
+		if c.getWSConn() == nil {
+			log.Warn().Str("rule_id", id).Msg("Relay forwarder deferred: WebSocket not connected")
+			c.deferRuleStart(rule)
+			c.spawnAsync(func() { c.reportRuleStatus(id, "error", "WebSocket not connected") })
+			return
 		}
+
+		f := NewRelayForwarder(
+			id,
+			rule.ListenAddr,
+			rule.ExitAddr,
+			rule.RelayChain,
+			c.cfg.Forwarder,
+			c.getWSConn,
+			c.trafficCounter,
+			statusCallback,
+		)
+		f.SetTCPOptions(ruleTCPOptions(rule))
+		f.SetPoolConns(rule.PoolConns)
+		f.SetDrainOnClose(rule.DrainOnClose)
+		f.SetVerboseLogging(rule.VerboseLogging)
+		f.SetFallbackGroup(rule.FallbackGroup)
+		c.mu.Lock()
+		delete(c.pendingRules, id)
+		c.forwarders[id] = f
+		c.mu.Unlock()
+		c.spawnAsync(func() { f.Start() })
+		log.Info().
+			Str("rule_id", id).
+			Str("listen", rule.ListenAddr).
+			Str("exit", rule.ExitAddr).
+			Strs("relay_chain", rule.RelayChain).
+			Msg("Started relay forwarder")
+	}
+}
+
+// deferRuleStart 记录因 WebSocket 未连接而暂缓启动的规则
+func (c *Client) deferRuleStart(rule model.ClientRule) {
+	c.mu.Lock()
+	c.pendingRules[rule.ID] = rule
+	c.mu.Unlock()
+}
+
+// startPendingForwarders 补启动暂缓的规则。主循环在隧道建立后调用;
+// 正常路径下随后的规则拉取会重新应用全量规则, 这里兜底拉取失败
+// 或规则在断线期间经通知下发的场景
+func (c *Client) startPendingForwarders() {
+	c.mu.Lock()
+	pending := make([]model.ClientRule, 0, len(c.pendingRules))
+	for id, rule := range c.pendingRules {
+		// 已被后续 applyRules 启动的直接清掉
+		if _, ok := c.forwarders[id]; ok {
+			delete(c.pendingRules, id)
+			continue
+		}
+		pending = append(pending, rule)
+	}
+	c.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	log.Info().Int("count", len(pending)).Msg("Starting forwarders deferred while WebSocket was down")
+	statusCallback := func(ruleID, status, errMsg string) {
+		c.spawnAsync(func() { c.reportRuleStatus(ruleID, status, errMsg) })
+	}
+	for _, rule := range pending {
+		c.startRuleForwarder(rule, statusCallback)
 	}
 }
 
@@ -713,26 +1335,37 @@ func (c *Client) trafficReportLoop() {
 	}
 }
 
+// maxTrafficReportBatch 单次上报的最大规则数, 分批发送保证请求体不超过服务端的大小限制
+const maxTrafficReportBatch = 500
+
 func (c *Client) reportTraffic() {
 	reports := c.trafficCounter.GetAndReset()
 	if len(reports) == 0 {
 		return
 	}
 
-	req := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      "reportTraffic",
-		"method":  "clientReportTraffic",
-		"params": map[string]interface{}{
-			"client_id": c.clientID,
-			"reports":   reports,
-		},
-	}
+	for len(reports) > 0 {
+		batch := reports
+		if len(batch) > maxTrafficReportBatch {
+			batch = reports[:maxTrafficReportBatch]
+		}
+		reports = reports[len(batch):]
+
+		req := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      "reportTraffic",
+			"method":  "clientReportTraffic",
+			"params": map[string]interface{}{
+				"client_id": c.clientID,
+				"reports":   batch,
+			},
+		}
 
-	if _, err := c.rpcCall(req); err != nil {
-		log.Warn().Err(err).Msg("Failed to report traffic")
-	} else {
-		log.Debug().Int("rules", len(reports)).Msg("Traffic reported")
+		if _, err := c.rpcCall(req); err != nil {
+			log.Warn().Err(err).Msg("Failed to report traffic")
+		} else {
+			log.Debug().Int("rules", len(batch)).Msg("Traffic reported")
+		}
 	}
 }
 
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// startCommandTestHarness 搭建最小化的命令测试环境:
+// 一个统计 clientGetRules 调用次数的 RPC 服务 + 一个真实 WSServer + 已连接的 Client
+func startCommandTestHarness(t *testing.T, fetches *int32) (*Client, *relay.WSServer, func()) {
+	t.Helper()
+
+	apiSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+			if req["method"] == "clientGetRules" {
+				atomic.AddInt32(fetches, 1)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":"getRules","result":{"rules":[],"version":"1"}}`))
+	}))
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+
+	c := New(&ClientConfig{
+		Client: ClientSection{ServerURL: apiSrv.URL},
+	})
+	c.clientID = "cmd-client"
+
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "cmd-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+	c.wsConn.Store(ws)
+	go c.handleTunnelMessages()
+
+	// 等待服务端登记连接
+	deadline := time.Now().Add(time.Second)
+	for !wsServer.IsClientOnline("cmd-client") {
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered on ws server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cleanup := func() {
+		ws.Close()
+		wsSrv.Close()
+		apiSrv.Close()
+	}
+	return c, wsServer, cleanup
+}
+
+func TestSendCommand_ReloadRulesTriggersFetch(t *testing.T) {
+	var fetches int32
+	_, wsServer, cleanup := startCommandTestHarness(t, &fetches)
+	defer cleanup()
+
+	output, err := wsServer.SendCommand("cmd-client", "reload-rules", 3*time.Second)
+	if err != nil {
+		t.Fatalf("SendCommand failed: %v", err)
+	}
+	if output != "rules reloaded" {
+		t.Errorf("expected output 'rules reloaded', got %q", output)
+	}
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("expected 1 rule fetch, got %d", got)
+	}
+}
+
+func TestSendCommand_Ping(t *testing.T) {
+	var fetches int32
+	_, wsServer, cleanup := startCommandTestHarness(t, &fetches)
+	defer cleanup()
+
+	output, err := wsServer.SendCommand("cmd-client", "ping", 3*time.Second)
+	if err != nil {
+		t.Fatalf("SendCommand failed: %v", err)
+	}
+	if output != "pong" {
+		t.Errorf("expected output 'pong', got %q", output)
+	}
+}
+
+func TestSendCommand_UnknownVerbRejected(t *testing.T) {
+	var fetches int32
+	_, wsServer, cleanup := startCommandTestHarness(t, &fetches)
+	defer cleanup()
+
+	if _, err := wsServer.SendCommand("cmd-client", "rm-rf", 3*time.Second); err == nil {
+		t.Error("expected error for unknown command verb")
+	}
+}
+
+func TestSendCommand_OfflineClient(t *testing.T) {
+	wsServer := relay.NewWSServer()
+	if _, err := wsServer.SendCommand("no-such-client", "ping", time.Second); err == nil {
+		t.Error("expected error for offline client")
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
 // mockForwarder implements ForwarderInterface for testing.
@@ -51,10 +55,10 @@ func TestNew(t *testing.T) {
 }
 
 func TestComputeRuleConfigHash_Direct(t *testing.T) {
-	rule := map[string]interface{}{
-		"type":        "direct",
-		"listen_addr": ":8080",
-		"target_addr": "localhost:80",
+	rule := model.ClientRule{
+		Type:       model.ForwardTypeDirect,
+		ListenAddr: ":8080",
+		TargetAddr: "localhost:80",
 	}
 
 	expected := "direct::8080:localhost:80"
@@ -64,11 +68,11 @@ func TestComputeRuleConfigHash_Direct(t *testing.T) {
 }
 
 func TestComputeRuleConfigHash_Relay(t *testing.T) {
-	rule := map[string]interface{}{
-		"type":        "relay",
-		"listen_addr": ":8080",
-		"exit_addr":   "10.0.0.1:80",
-		"relay_chain": []interface{}{"c1", "c2"},
+	rule := model.ClientRule{
+		Type:       model.ForwardTypeRelay,
+		ListenAddr: ":8080",
+		ExitAddr:   "10.0.0.1:80",
+		RelayChain: []string{"c1", "c2"},
 	}
 
 	expected := "relay::8080:10.0.0.1:80:c1,c2,"
@@ -158,12 +162,12 @@ func TestApplyRules_NewDirectRule(t *testing.T) {
 	}
 	c := New(cfg)
 
-	rules := []interface{}{
-		map[string]interface{}{
-			"id":          "rule-1",
-			"type":        "direct",
-			"listen_addr": "127.0.0.1:0",
-			"target_addr": "127.0.0.1:9999",
+	rules := []model.ClientRule{
+		{
+			ID:         "rule-1",
+			Type:       model.ForwardTypeDirect,
+			ListenAddr: "127.0.0.1:0",
+			TargetAddr: "127.0.0.1:9999",
 		},
 	}
 
@@ -190,7 +194,7 @@ func TestApplyRules_RuleRemoved(t *testing.T) {
 	c.forwarders["old-rule"] = mock
 
 	// Apply empty rules
-	c.applyRules([]interface{}{})
+	c.applyRules(nil)
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -216,12 +220,12 @@ func TestApplyRules_ConfigUnchanged(t *testing.T) {
 	}
 	c.forwarders["rule-1"] = mock
 
-	rules := []interface{}{
-		map[string]interface{}{
-			"id":          "rule-1",
-			"type":        "direct",
-			"listen_addr": ":8080",
-			"target_addr": "localhost:80",
+	rules := []model.ClientRule{
+		{
+			ID:         "rule-1",
+			Type:       model.ForwardTypeDirect,
+			ListenAddr: ":8080",
+			TargetAddr: "localhost:80",
 		},
 	}
 
@@ -239,3 +243,223 @@ func TestApplyRules_ConfigUnchanged(t *testing.T) {
 		t.Error("expected same forwarder instance to remain in map")
 	}
 }
+
+func TestApplyRules_MalformedRuleSkipped(t *testing.T) {
+	cfg := &ClientConfig{
+		Forwarder: ForwarderSection{ConnectTimeout: 5, BufferSize: 32768},
+	}
+	c := New(cfg)
+
+	rawRules := []interface{}{
+		// 缺少 listen_addr 的坏规则, 不应 panic 也不应生效
+		map[string]interface{}{
+			"id":          "broken-rule",
+			"type":        "direct",
+			"target_addr": "127.0.0.1:9999",
+		},
+		// 根本不是对象
+		"garbage",
+		// 正常规则应照常生效
+		map[string]interface{}{
+			"id":          "good-rule",
+			"type":        "direct",
+			"listen_addr": "127.0.0.1:0",
+			"target_addr": "127.0.0.1:9999",
+		},
+	}
+
+	c.applyRules(decodeClientRules(rawRules))
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if _, exists := c.forwarders["broken-rule"]; exists {
+		t.Error("expected malformed rule to be skipped")
+	}
+	if _, exists := c.forwarders["good-rule"]; !exists {
+		t.Error("expected valid rule to still be applied")
+	}
+
+	// Clean up
+	for _, f := range c.forwarders {
+		f.Stop()
+	}
+}
+
+func TestApplyRules_MaxForwardersCap(t *testing.T) {
+	// 捕获超限规则上报的状态
+	reported := make(chan map[string]interface{}, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["method"] == "clientReportRuleStatus" {
+			params, _ := req["params"].(map[string]interface{})
+			for _, rep := range params["reports"].([]interface{}) {
+				reported <- rep.(map[string]interface{})
+			}
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","result":{}}`))
+	}))
+	defer server.Close()
+
+	cfg := &ClientConfig{
+		Client:    ClientSection{ServerURL: server.URL},
+		Forwarder: ForwarderSection{ConnectTimeout: 5, BufferSize: 32768, MaxForwarders: 1},
+	}
+	c := New(cfg)
+
+	rules := []model.ClientRule{
+		{ID: "rule-1", Type: model.ForwardTypeDirect, ListenAddr: "127.0.0.1:0", TargetAddr: "127.0.0.1:9999"},
+		{ID: "rule-2", Type: model.ForwardTypeDirect, ListenAddr: "127.0.0.1:0", TargetAddr: "127.0.0.1:9999"},
+		{ID: "rule-3", Type: model.ForwardTypeDirect, ListenAddr: "127.0.0.1:0", TargetAddr: "127.0.0.1:9999"},
+	}
+
+	c.applyRules(rules)
+
+	c.mu.RLock()
+	count := len(c.forwarders)
+	c.mu.RUnlock()
+	if count != 1 {
+		t.Errorf("expected 1 forwarder under cap, got %d", count)
+	}
+
+	// 两条超限规则都应上报 error 状态 (rule-1 会正常上报 running, 忽略)
+	got := map[string]string{}
+	for len(got) < 2 {
+		select {
+		case rep := <-reported:
+			ruleID, _ := rep["rule_id"].(string)
+			status, _ := rep["status"].(string)
+			if ruleID == "rule-2" || ruleID == "rule-3" {
+				got[ruleID] = status
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("excess rules never reported error status, got %v", got)
+		}
+	}
+	for _, id := range []string{"rule-2", "rule-3"} {
+		if got[id] != "error" {
+			t.Errorf("rule %s status = %q, want %q", id, got[id], "error")
+		}
+	}
+
+	// Clean up
+	c.mu.RLock()
+	fs := make([]ForwarderInterface, 0, len(c.forwarders))
+	for _, f := range c.forwarders {
+		fs = append(fs, f)
+	}
+	c.mu.RUnlock()
+	for _, f := range fs {
+		f.Stop()
+	}
+}
+
+func TestApplyRules_RelayDeferredUntilWSConnected(t *testing.T) {
+	// 状态上报打到本地 stub, 只关心 forwarder 是否补启动
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","result":{}}`))
+	}))
+	defer server.Close()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	cfg := &ClientConfig{
+		Client:    ClientSection{ServerURL: server.URL},
+		Forwarder: ForwarderSection{ConnectTimeout: 5, BufferSize: 32768},
+	}
+	c := New(cfg)
+
+	rules := []model.ClientRule{
+		{
+			ID:         "relay-rule",
+			Type:       model.ForwardTypeRelay,
+			ListenAddr: "127.0.0.1:0",
+			ExitAddr:   "127.0.0.1:9999",
+			RelayChain: []string{"exit-client"},
+		},
+	}
+
+	// WS 未连接: 不启动, 但应记入暂缓列表等待重连
+	c.applyRules(rules)
+
+	c.mu.RLock()
+	_, started := c.forwarders["relay-rule"]
+	_, pending := c.pendingRules["relay-rule"]
+	c.mu.RUnlock()
+	if started {
+		t.Fatal("relay forwarder should not start without a tunnel")
+	}
+	if !pending {
+		t.Fatal("expected relay rule to be recorded as pending")
+	}
+
+	// 隧道建立后补启动 (mainLoop 在 connectWebSocket 成功后调用)
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "entry-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+	defer ws.Close()
+	c.wsConn.Store(ws)
+
+	c.startPendingForwarders()
+
+	// 等待 forwarder 真正监听
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mu.RLock()
+		f, ok := c.forwarders["relay-rule"]
+		c.mu.RUnlock()
+		if ok {
+			rf := f.(*RelayForwarder)
+			rf.listenerMu.Lock()
+			listening := rf.listener != nil
+			rf.listenerMu.Unlock()
+			if listening {
+				defer f.Stop()
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("relay forwarder did not start after WebSocket came up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.mu.RLock()
+	_, stillPending := c.pendingRules["relay-rule"]
+	c.mu.RUnlock()
+	if stillPending {
+		t.Error("expected pending entry to be cleared after start")
+	}
+}
+
+func TestParseRule_RejectsInvalid(t *testing.T) {
+	cases := []interface{}{
+		42,
+		"garbage",
+		map[string]interface{}{"type": "direct", "listen_addr": ":1", "target_addr": ":2"}, // 缺 id
+		map[string]interface{}{"id": "r", "listen_addr": ":1"},                             // 缺 type
+		map[string]interface{}{"id": "r", "type": "direct", "listen_addr": ":1"},           // direct 缺 target_addr
+		map[string]interface{}{"id": "r", "type": "bogus", "listen_addr": ":1"},            // 未知类型
+		map[string]interface{}{"id": "r", "type": "relay", "listen_addr": 80},              // 字段类型不符
+	}
+	for i, tc := range cases {
+		if _, err := parseRule(tc); err == nil {
+			t.Errorf("case %d: expected parseRule to reject %v", i, tc)
+		}
+	}
+
+	rule, err := parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1"})
+	if err != nil {
+		t.Errorf("expected minimal relay rule to be accepted, got %v", err)
+	}
+	if rule.ID != "r" || rule.Type != model.ForwardTypeRelay {
+		t.Errorf("unexpected parsed rule: %+v", rule)
+	}
+}
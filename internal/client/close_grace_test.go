@@ -0,0 +1,84 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+func TestHandleIncomingConnect_GraceCloseEndsWithFIN(t *testing.T) {
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	c := New(&ClientConfig{
+		Forwarder: ForwarderSection{ConnectTimeout: 3, CloseGraceMs: 1000},
+	})
+	c.clientID = "grace-client"
+
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "grace-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+	defer ws.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// 目标在隧道侧关闭后仍写出数据再读: 宽限关闭应弃置余量并以 FIN 收尾,
+	// 目标读到 EOF 而不是 connection reset
+	tunnelClosed := make(chan struct{})
+	readErr := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-tunnelClosed
+		conn.Write([]byte("buffered-output-after-close"))
+		_, err = io.ReadAll(conn)
+		readErr <- err
+	}()
+
+	msg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: 88,
+		Target:   ln.Addr().String(),
+	}
+	done := make(chan struct{})
+	go func() {
+		c.handleIncomingConnect(ws, msg)
+		close(done)
+	}()
+
+	stream := waitForStream(t, ws, 88)
+	stream.Close()
+	close(tunnelClosed)
+
+	select {
+	case err := <-readErr:
+		if err != nil {
+			t.Errorf("expected target to observe clean FIN (EOF), got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("target read never completed")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handleIncomingConnect did not return")
+	}
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// tcpPair 建立一对 loopback TCP 连接
+func tcpPair(t testing.TB) (net.Conn, net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	type acceptResult struct {
+		conn net.Conn
+		err  error
+	}
+	acceptCh := make(chan acceptResult, 1)
+	go func() {
+		conn, err := ln.Accept()
+		acceptCh <- acceptResult{conn, err}
+	}()
+
+	dialed, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	accepted := <-acceptCh
+	if accepted.err != nil {
+		dialed.Close()
+		t.Fatalf("failed to accept: %v", accepted.err)
+	}
+	return dialed, accepted.conn
+}
+
+func TestCoalesceRead_MergesAvailableData(t *testing.T) {
+	writer, reader := tcpPair(t)
+	defer writer.Close()
+	defer reader.Close()
+
+	payload := make([]byte, 4096)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	if _, err := writer.Write(payload); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	// 等数据全部到达读端的内核缓冲
+	time.Sleep(100 * time.Millisecond)
+
+	buf := make([]byte, len(payload))
+	n, err := reader.Read(buf[:1])
+	if err != nil || n != 1 {
+		t.Fatalf("first read: n=%d err=%v", n, err)
+	}
+
+	// 追加读应把已到达的剩余数据一次性并入
+	n = coalesceRead(reader, buf, n)
+	if n != len(payload) {
+		t.Fatalf("coalesceRead returned %d bytes, want %d", n, len(payload))
+	}
+	if !bytes.Equal(buf, payload) {
+		t.Error("coalesced data does not match written payload")
+	}
+
+	// 截止时间已恢复, 后续阻塞读正常工作
+	go writer.Write([]byte("tail"))
+	tail := make([]byte, 4)
+	if _, err := reader.Read(tail); err != nil {
+		t.Fatalf("blocking read after coalesce failed: %v", err)
+	}
+}
+
+// chunkedConn 按块投递数据的 net.Conn 实现: 每次 Read 最多返回一个数据块,
+// 稳定模拟目标以小块产出数据的到达节奏 (真实 TCP 的内核缓冲会把块合并, 无法复现小读)
+type chunkedConn struct {
+	ch       chan []byte
+	pending  []byte
+	deadline time.Time
+}
+
+func (c *chunkedConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		if c.deadline.IsZero() {
+			chunk, ok := <-c.ch
+			if !ok {
+				return 0, io.EOF
+			}
+			c.pending = chunk
+		} else {
+			wait := time.Until(c.deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			select {
+			case chunk, ok := <-c.ch:
+				if !ok {
+					return 0, io.EOF
+				}
+				c.pending = chunk
+			case <-time.After(wait):
+				return 0, os.ErrDeadlineExceeded
+			}
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *chunkedConn) Write(p []byte) (int, error)        { return len(p), nil }
+func (c *chunkedConn) Close() error                       { return nil }
+func (c *chunkedConn) LocalAddr() net.Addr                { return nil }
+func (c *chunkedConn) RemoteAddr() net.Addr               { return nil }
+func (c *chunkedConn) SetDeadline(t time.Time) error      { return nil }
+func (c *chunkedConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *chunkedConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+// benchmarkBulkFrames 模拟出口读循环对一次批量传输成帧, 统计帧数。
+// 数据以 1KB 块到达, 读端每次循环产出一帧 (可选合并读), 对比两种模式的帧数开销
+func benchmarkBulkFrames(b *testing.B, coalesce bool) {
+	const (
+		totalBytes = 1 << 20
+		chunkSize  = 1024
+	)
+
+	var totalFrames int
+	for i := 0; i < b.N; i++ {
+		conn := &chunkedConn{ch: make(chan []byte, 64)}
+
+		go func() {
+			chunk := make([]byte, chunkSize)
+			for sent := 0; sent < totalBytes; sent += chunkSize {
+				conn.ch <- chunk
+			}
+			close(conn.ch)
+		}()
+
+		buf := relay.GetBuffer()
+		frames, received := 0, 0
+		for received < totalBytes {
+			n, err := conn.Read((*buf)[relay.HeaderSize:])
+			if err != nil {
+				b.Fatalf("read failed: %v", err)
+			}
+			if coalesce && n < len(*buf)-relay.HeaderSize {
+				n = coalesceRead(conn, (*buf)[relay.HeaderSize:], n)
+			}
+			received += n
+			frames++
+		}
+		relay.PutBuffer(buf)
+		totalFrames += frames
+	}
+
+	b.SetBytes(totalBytes)
+	b.ReportMetric(float64(totalFrames)/float64(b.N), "frames/op")
+}
+
+func BenchmarkExitBulkTransfer_Uncoalesced(b *testing.B) { benchmarkBulkFrames(b, false) }
+
+func BenchmarkExitBulkTransfer_Coalesced(b *testing.B) { benchmarkBulkFrames(b, true) }
@@ -2,6 +2,7 @@ package client
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -18,12 +19,32 @@ type ClientSection struct {
 	ServerURL string `mapstructure:"ServerURL"`
 	Token     string `mapstructure:"Token"`
 	ReportIP  string `mapstructure:"ReportIP"` // 可选，上报给服务器的外部 IP（用于显示）
+
+	// 可选, STUN 服务器地址 (host:port, 省略端口默认 3478)。
+	// 配置后注册时先经 STUN 发现公网 IP 上报, 失败回退到 ReportIP / 连接 IP
+	STUNServer string `mapstructure:"STUNServer"`
+
+	// MetricsAddr 本机调试端点监听地址 (可选, 仅允许回环地址, 为空关闭)。
+	// 暴露 forwarder 数量/各规则流量计数/WebSocket 连接状态, 供宿主机排障
+	MetricsAddr string `mapstructure:"MetricsAddr"`
 }
 
 type ConnectionSection struct {
 	ReconnectInterval    int `mapstructure:"ReconnectInterval"`
 	MaxReconnectInterval int `mapstructure:"MaxReconnectInterval"`
 	HeartbeatInterval    int `mapstructure:"HeartbeatInterval"`
+
+	// WebSocket 拨号可选项：子协议与自定义 header (用于 LB/WAF 路由)
+	WSSubprotocol string            `mapstructure:"WSSubprotocol"`
+	WSHeaders     map[string]string `mapstructure:"WSHeaders"`
+
+	// wss 拨号的 TLS 策略: 最低版本 ("1.2" 默认 / "1.3") 与密码套件白名单 (IANA 名称)
+	TLSMinVersion   string   `mapstructure:"TLSMinVersion"`
+	TLSCipherSuites []string `mapstructure:"TLSCipherSuites"`
+
+	// CloseDrainTimeout 主动关闭 WebSocket 前排空发送队列的最长等待秒数 (0 = 不排空),
+	// 保证关闭时已入队的控制/数据帧不丢失
+	CloseDrainTimeout int `mapstructure:"CloseDrainTimeout"`
 }
 
 type LoggingSection struct {
@@ -35,6 +56,70 @@ type ForwarderSection struct {
 	BufferSize     int `mapstructure:"BufferSize"`
 	ConnectTimeout int `mapstructure:"ConnectTimeout"`
 	IdleTimeout    int `mapstructure:"IdleTimeout"`
+
+	// 出口拨号的源地址控制 (用于上游按源端口做 ACL 的场景)
+	// SourcePortMin/Max 为 0 时使用系统临时端口
+	SourceIP      string `mapstructure:"SourceIP"`
+	SourcePortMin int    `mapstructure:"SourcePortMin"`
+	SourcePortMax int    `mapstructure:"SourcePortMax"`
+
+	// ListenBacklog 监听 socket 的内核 accept 队列长度 (仅 Linux 生效, <=0 使用系统默认)
+	ListenBacklog int `mapstructure:"ListenBacklog"`
+
+	// 预热工作池: WorkerPoolSize > 0 时启用, accept 到的连接经有界队列交给固定 worker 处理,
+	// 平滑连接突发; 队列满时新连接被直接关闭。WorkerQueueSize 为 0 时使用默认队列长度
+	WorkerPoolSize  int `mapstructure:"WorkerPoolSize"`
+	WorkerQueueSize int `mapstructure:"WorkerQueueSize"`
+
+	// 出口半关排空的最长等待秒数 (规则开启 drain_on_close 时生效, 0 使用默认)
+	CloseDrainTimeout int `mapstructure:"CloseDrainTimeout"`
+
+	// CloseGraceMs 出口关闭目标连接前的宽限毫秒数 (0 = 立即关闭)。
+	// 接收缓冲里还有目标未读数据时立即 Close 会以 RST 收尾, 部分目标会记成错误;
+	// 宽限窗口内先半关写方向送出 FIN, 弃置目标余量后再关闭, 换来干净的挥手
+	CloseGraceMs int `mapstructure:"CloseGraceMs"`
+
+	// AllowedExitCIDRs 出口目标 IP 白名单 (CIDR 或裸 IP 列表, 空 = 不限制)。
+	// 校验应用在拨号时解析出的 IP 上而非域名, 域名重绑定到白名单外的 IP 会被拒绝
+	AllowedExitCIDRs []string `mapstructure:"AllowedExitCIDRs"`
+
+	// 出口 DNS: DNSServer 非空时目标域名经该服务器解析 (host:port, 省略端口默认 53),
+	// 解析结果按 DNSCacheTTL 秒做正/负缓存 (<=0 关闭缓存); 为空时沿用系统解析
+	DNSServer   string `mapstructure:"DNSServer"`
+	DNSCacheTTL int    `mapstructure:"DNSCacheTTL"`
+
+	// MaxForwarders 单客户端同时运行的 forwarder 上限 (0 = 不限制)。
+	// 超出上限的规则拒绝启动并上报 error 状态, 防止误配置下发海量规则耗尽客户端
+	MaxForwarders int `mapstructure:"MaxForwarders"`
+
+	// StreamBufferSize 隧道->目标方向每个流的读缓冲条数 (0 使用默认)。
+	// 目标写入慢时缓冲写满会阻塞隧道接收循环形成背压, 调大可减少慢目标对其他流的影响
+	StreamBufferSize int `mapstructure:"StreamBufferSize"`
+
+	// CoalesceReads 出口读合并: 每次阻塞读之后再以非阻塞追加读聚合已到达的数据,
+	// 把多次小读合并为一帧 (上限为最大 payload), 降低高吞吐链路的帧数开销
+	CoalesceReads bool `mapstructure:"CoalesceReads"`
+
+	// MaxStreams 单条 WebSocket 隧道的并发流上限 (0 = 不限制)。
+	// 作为出口时超限的入站 Connect 直接回错误, 防止对端把本机资源耗尽
+	MaxStreams int `mapstructure:"MaxStreams"`
+
+	// PortCheckConcurrency 端口检查并发上限 (<=0 串行)。
+	// 服务端批量下发 CheckPort 时限制同时探测的数量, 避免探测之间互相抢占端口
+	PortCheckConcurrency int `mapstructure:"PortCheckConcurrency"`
+
+	// TCPFastOpen 出口拨号请求 TCP Fast Open (TCP_FASTOPEN_CONNECT),
+	// 对反复建连的短连接省一个往返; 仅 Linux 且内核支持时生效, 否则静默回退
+	TCPFastOpen bool `mapstructure:"TCPFastOpen"`
+
+	// FastOpen 中继入口快速打开: 接受连接后短暂等待首包并随 Connect 一起发出,
+	// 出口拨号成功后先写入首包再回 ConnAck, 省掉首字节前的一个完整往返。
+	// 仅对客户端先发话的协议 (HTTP 等) 有收益, 默认关闭
+	FastOpen bool `mapstructure:"FastOpen"`
+
+	// TrafficLabel 附加到中继 Connect 的统计标签 (如租户 ID),
+	// 服务端按 (规则, 标签) 细分流量统计; 为空不附加
+	TrafficLabel string `mapstructure:"TrafficLabel"`
 }
 
 func LoadClientConfig(configPath string) (*ClientConfig, error) {
@@ -58,16 +143,48 @@ func LoadClientConfig(configPath string) (*ClientConfig, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	applyClientEnvOverrides(&cfg)
+
 	return &cfg, nil
 }
 
+// applyClientEnvOverrides 应用环境变量覆盖 (容器部署无需挂载配置文件即可注入 Token 等)。
+// 优先级: 命令行参数 > 环境变量 > 配置文件 > 默认值
+func applyClientEnvOverrides(cfg *ClientConfig) {
+	setString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+
+	setString("MB_CLIENT_SERVER_URL", &cfg.Client.ServerURL)
+	setString("MB_CLIENT_TOKEN", &cfg.Client.Token)
+	setString("MB_CLIENT_REPORT_IP", &cfg.Client.ReportIP)
+	setString("MB_CLIENT_LOG_LEVEL", &cfg.Logging.Level)
+}
+
+// ApplyFlagOverrides 应用命令行参数覆盖 (空值表示未指定)。
+// 命令行参数优先级最高, 覆盖环境变量与配置文件
+func ApplyFlagOverrides(cfg *ClientConfig, serverURL, token string) {
+	if serverURL != "" {
+		cfg.Client.ServerURL = serverURL
+	}
+	if token != "" {
+		cfg.Client.Token = token
+	}
+}
+
 func setClientDefaults(v *viper.Viper) {
 	v.SetDefault("Client.ServerURL", "http://localhost:8080")
 	v.SetDefault("Client.Token", "")
+	v.SetDefault("Client.STUNServer", "")
+	v.SetDefault("Client.MetricsAddr", "")
 
 	v.SetDefault("Connection.ReconnectInterval", 5)
 	v.SetDefault("Connection.MaxReconnectInterval", 60)
 	v.SetDefault("Connection.HeartbeatInterval", 30)
+	v.SetDefault("Connection.TLSMinVersion", "1.2")
+	v.SetDefault("Connection.CloseDrainTimeout", 2)
 
 	v.SetDefault("Logging.Level", "info")
 	v.SetDefault("Logging.File", "")
@@ -75,4 +192,20 @@ func setClientDefaults(v *viper.Viper) {
 	v.SetDefault("Forwarder.BufferSize", 32768)
 	v.SetDefault("Forwarder.ConnectTimeout", 10)
 	v.SetDefault("Forwarder.IdleTimeout", 300)
+	v.SetDefault("Forwarder.ListenBacklog", 0)
+	v.SetDefault("Forwarder.WorkerPoolSize", 0)
+	v.SetDefault("Forwarder.WorkerQueueSize", defaultAcceptQueueSize)
+	v.SetDefault("Forwarder.CloseDrainTimeout", defaultCloseDrainTimeout)
+	v.SetDefault("Forwarder.CloseGraceMs", 0)
+	v.SetDefault("Forwarder.MaxForwarders", 0)
+	v.SetDefault("Forwarder.StreamBufferSize", defaultStreamBufferSize)
+	v.SetDefault("Forwarder.CoalesceReads", false)
+	v.SetDefault("Forwarder.FastOpen", false)
+	v.SetDefault("Forwarder.TCPFastOpen", false)
+	v.SetDefault("Forwarder.MaxStreams", 0)
+	v.SetDefault("Forwarder.PortCheckConcurrency", 1)
+	v.SetDefault("Forwarder.AllowedExitCIDRs", []string{})
+	v.SetDefault("Forwarder.DNSServer", "")
+	v.SetDefault("Forwarder.DNSCacheTTL", 30)
+	v.SetDefault("Forwarder.TrafficLabel", "")
 }
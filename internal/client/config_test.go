@@ -122,3 +122,58 @@ func TestLoadClientConfig_InvalidTOML(t *testing.T) {
 		t.Error("expected error for invalid TOML, got nil")
 	}
 }
+
+func TestLoadClientConfig_EnvOverridesFile(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+	content := `
+[Client]
+ServerURL = "https://file.example.com"
+Token = "file-token"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	t.Setenv("MB_CLIENT_TOKEN", "env-token")
+
+	cfg, err := LoadClientConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadClientConfig error: %v", err)
+	}
+	if cfg.Client.Token != "env-token" {
+		t.Errorf("Token = %q, want env override", cfg.Client.Token)
+	}
+	if cfg.Client.ServerURL != "https://file.example.com" {
+		t.Errorf("ServerURL = %q, want file value (no env set)", cfg.Client.ServerURL)
+	}
+}
+
+func TestApplyFlagOverrides_BeatsEnvAndFile(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.toml")
+	content := `
+[Client]
+ServerURL = "https://file.example.com"
+Token = "file-token"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	t.Setenv("MB_CLIENT_TOKEN", "env-token")
+
+	cfg, err := LoadClientConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadClientConfig error: %v", err)
+	}
+
+	// 命令行参数优先级最高; 未指定的参数 (空值) 不覆盖
+	ApplyFlagOverrides(cfg, "", "flag-token")
+	if cfg.Client.Token != "flag-token" {
+		t.Errorf("Token = %q, want flag override", cfg.Client.Token)
+	}
+	if cfg.Client.ServerURL != "https://file.example.com" {
+		t.Errorf("ServerURL = %q, want unchanged", cfg.Client.ServerURL)
+	}
+}
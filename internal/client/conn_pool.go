@@ -0,0 +1,177 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 出口连接池默认参数
+const (
+	poolMaxIdlePerTarget = 4
+	poolIdleTimeout      = 60 * time.Second
+	poolSweepInterval    = 30 * time.Second
+	poolProbeTimeout     = time.Millisecond
+)
+
+// pooledConn 池中的空闲连接
+type pooledConn struct {
+	conn      net.Conn
+	idleSince time.Time
+}
+
+// ConnPool 按目标地址缓存出口空闲连接
+// 仅供标记了 pool_conns 的规则复用 (只对可复用连接的协议安全)
+type ConnPool struct {
+	mu          sync.Mutex
+	idle        map[string][]pooledConn
+	maxIdle     int
+	idleTimeout time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewConnPool 创建连接池。maxIdle/idleTimeout <= 0 时使用默认值。
+func NewConnPool(maxIdle int, idleTimeout time.Duration) *ConnPool {
+	if maxIdle <= 0 {
+		maxIdle = poolMaxIdlePerTarget
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = poolIdleTimeout
+	}
+	return &ConnPool{
+		idle:        make(map[string][]pooledConn),
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start 启动空闲连接清理循环
+func (p *ConnPool) Start() {
+	p.wg.Add(1)
+	go p.run()
+}
+
+// Stop 停止清理循环并关闭所有空闲连接
+func (p *ConnPool) Stop() {
+	close(p.stopCh)
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conns := range p.idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+	p.idle = make(map[string][]pooledConn)
+}
+
+func (p *ConnPool) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(poolSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.evictIdle()
+		}
+	}
+}
+
+// evictIdle 关闭超过空闲时限的连接
+func (p *ConnPool) evictIdle() {
+	cutoff := time.Now().Add(-p.idleTimeout)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for target, conns := range p.idle {
+		kept := conns[:0]
+		for _, pc := range conns {
+			if pc.idleSince.Before(cutoff) {
+				pc.conn.Close()
+				continue
+			}
+			kept = append(kept, pc)
+		}
+		if len(kept) == 0 {
+			delete(p.idle, target)
+		} else {
+			p.idle[target] = kept
+		}
+	}
+}
+
+// Get 取出一个到 target 的健康空闲连接，没有可用连接时返回 nil
+func (p *ConnPool) Get(target string) net.Conn {
+	for {
+		p.mu.Lock()
+		conns := p.idle[target]
+		if len(conns) == 0 {
+			p.mu.Unlock()
+			return nil
+		}
+		// 后进先出，优先复用最近归还的连接
+		pc := conns[len(conns)-1]
+		p.idle[target] = conns[:len(conns)-1]
+		p.mu.Unlock()
+
+		if connAlive(pc.conn) {
+			log.Debug().Str("target", target).Msg("Reusing pooled connection")
+			return pc.conn
+		}
+		pc.conn.Close()
+	}
+}
+
+// Put 归还连接，池已满时直接关闭
+func (p *ConnPool) Put(target string, conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.stopCh:
+		conn.Close()
+		return
+	default:
+	}
+
+	if len(p.idle[target]) >= p.maxIdle {
+		conn.Close()
+		return
+	}
+	p.idle[target] = append(p.idle[target], pooledConn{conn: conn, idleSince: time.Now()})
+}
+
+// connAlive 用短超时读探测连接是否仍然可用（对端已关闭会立即返回 EOF）
+// 注意超时必须是未来时刻: 已过期的 deadline 会让 Read 直接返回而不碰 socket
+func connAlive(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(poolProbeTimeout)); err != nil {
+		return false
+	}
+	var b [1]byte
+	n, err := conn.Read(b[:])
+	if n > 0 {
+		// 空闲连接不应有未消费数据，保守起见弃用
+		return false
+	}
+	if !isTimeoutErr(err) {
+		return false
+	}
+	return conn.SetReadDeadline(time.Time{}) == nil
+}
+
+// isTimeoutErr 判断是否为读写超时错误
+func isTimeoutErr(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnPool_ReusesPooledConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	pool := NewConnPool(2, time.Minute)
+	target := ln.Addr().String()
+
+	first, err := net.Dial("tcp", target)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	// 第一次取: 池为空
+	if got := pool.Get(target); got != nil {
+		t.Fatal("expected empty pool to return nil")
+	}
+
+	// 归还后第二次取应复用同一连接
+	pool.Put(target, first)
+	got := pool.Get(target)
+	if got == nil {
+		t.Fatal("expected pooled connection to be reused")
+	}
+	if got.LocalAddr().String() != first.LocalAddr().String() {
+		t.Errorf("expected reused connection %v, got %v", first.LocalAddr(), got.LocalAddr())
+	}
+	got.Close()
+}
+
+func TestConnPool_DropsDeadConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}()
+
+	pool := NewConnPool(2, time.Minute)
+	target := ln.Addr().String()
+
+	conn, err := net.Dial("tcp", target)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	pool.Put(target, conn)
+
+	// 对端关闭后, Get 应丢弃死连接而不是复用
+	serverSide := <-accepted
+	serverSide.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if got := pool.Get(target); got != nil {
+		got.Close()
+		t.Error("expected dead connection to be dropped")
+	}
+}
+
+func TestConnPool_MaxIdlePerTarget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	pool := NewConnPool(1, time.Minute)
+	target := ln.Addr().String()
+
+	first, err := net.Dial("tcp", target)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	second, err := net.Dial("tcp", target)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	pool.Put(target, first)
+	pool.Put(target, second) // 超出 maxIdle, 应被直接关闭
+
+	pool.mu.Lock()
+	idle := len(pool.idle[target])
+	pool.mu.Unlock()
+	if idle != 1 {
+		t.Errorf("expected 1 idle connection, got %d", idle)
+	}
+}
+
+func TestConnPool_EvictIdle(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+	}()
+
+	pool := NewConnPool(2, 10*time.Millisecond)
+	target := ln.Addr().String()
+
+	conn, err := net.Dial("tcp", target)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	pool.Put(target, conn)
+
+	time.Sleep(30 * time.Millisecond)
+	pool.evictIdle()
+
+	if got := pool.Get(target); got != nil {
+		got.Close()
+		t.Error("expected idle connection to be evicted")
+	}
+}
+
+func TestRulePoolConns(t *testing.T) {
+	rule, err := parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1"})
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if rule.PoolConns {
+		t.Error("pool_conns should default to false")
+	}
+
+	rule, err = parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1", "pool_conns": true})
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if !rule.PoolConns {
+		t.Error("pool_conns should be true when configured")
+	}
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBackoffThreshold 连续拨号失败多少次后进入冷却期
+	defaultBackoffThreshold = 3
+	// defaultBackoffCooldown 冷却期时长, 冷却期内对该目标的新连接快速失败
+	defaultBackoffCooldown = 5 * time.Second
+)
+
+// dialBackoff 按目标地址跟踪连续拨号失败。连续失败达到阈值后进入冷却期,
+// 冷却期内 Allow 返回 false 让调用方快速失败, 避免高频冲击已经过载的目标。
+// 冷却期结束后放行一次试探, 成功则清除记录, 再失败则立即重新进入冷却。
+type dialBackoff struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu      sync.Mutex
+	targets map[string]*targetBackoff
+}
+
+type targetBackoff struct {
+	failures int
+	until    time.Time
+}
+
+func newDialBackoff(threshold int, cooldown time.Duration) *dialBackoff {
+	return &dialBackoff{
+		threshold: threshold,
+		cooldown:  cooldown,
+		targets:   make(map[string]*targetBackoff),
+	}
+}
+
+// Allow 返回当前是否允许向该目标拨号
+func (b *dialBackoff) Allow(target string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.targets[target]
+	if !ok {
+		return true
+	}
+	return !time.Now().Before(t.until)
+}
+
+// RecordFailure 记录一次拨号失败, 达到阈值时开启冷却期
+func (b *dialBackoff) RecordFailure(target string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.targets[target]
+	if !ok {
+		t = &targetBackoff{}
+		b.targets[target] = t
+	}
+	t.failures++
+	if t.failures >= b.threshold {
+		t.until = time.Now().Add(b.cooldown)
+	}
+}
+
+// RecordSuccess 拨号成功, 清除该目标的失败记录
+func (b *dialBackoff) RecordSuccess(target string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.targets, target)
+}
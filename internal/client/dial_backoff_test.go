@@ -0,0 +1,82 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+func TestDialBackoff_EngagesAfterThresholdAndClearsOnSuccess(t *testing.T) {
+	b := newDialBackoff(3, 50*time.Millisecond)
+	target := "10.0.0.1:80"
+
+	// 阈值以下不触发冷却
+	b.RecordFailure(target)
+	b.RecordFailure(target)
+	if !b.Allow(target) {
+		t.Fatal("backoff should not engage below the failure threshold")
+	}
+
+	// 第三次失败进入冷却期
+	b.RecordFailure(target)
+	if b.Allow(target) {
+		t.Fatal("backoff should engage after consecutive failures")
+	}
+
+	// 冷却期结束后放行试探
+	time.Sleep(60 * time.Millisecond)
+	if !b.Allow(target) {
+		t.Fatal("backoff should allow a probe after the cooldown expires")
+	}
+
+	// 试探再失败立即重新进入冷却
+	b.RecordFailure(target)
+	if b.Allow(target) {
+		t.Fatal("backoff should re-engage when the probe fails")
+	}
+
+	// 成功后清除记录
+	b.RecordSuccess(target)
+	if !b.Allow(target) {
+		t.Fatal("backoff should clear on success")
+	}
+}
+
+func TestDialBackoff_TargetsIndependent(t *testing.T) {
+	b := newDialBackoff(1, time.Minute)
+
+	b.RecordFailure("10.0.0.1:80")
+	if b.Allow("10.0.0.1:80") {
+		t.Error("failing target should be in cooldown")
+	}
+	if !b.Allow("10.0.0.2:80") {
+		t.Error("other targets should not be affected")
+	}
+}
+
+func TestHandleIncomingConnect_RepeatedFailuresEngageBackoff(t *testing.T) {
+	c, ws, cleanup := startDrainTestHarness(t)
+	defer cleanup()
+
+	// 找一个必然拒绝连接的端口: 监听后立即关闭
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	target := ln.Addr().String()
+	ln.Close()
+
+	for i := 0; i < defaultBackoffThreshold; i++ {
+		c.handleIncomingConnect(ws, &relay.TunnelMessage{
+			Type:     relay.MsgTypeConnect,
+			StreamID: uint32(100 + i),
+			Target:   target,
+		})
+	}
+
+	if c.dialBackoff.Allow(target) {
+		t.Error("expected target to be in backoff cooldown after repeated connect failures")
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dnsDialTimeout 自定义 DNS 服务器的查询连接超时
+const dnsDialTimeout = 5 * time.Second
+
+// hostLookupper 域名解析接口 (*net.Resolver 实现, 测试用桩替换)
+type hostLookupper interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// dnsCacheEntry 单个域名的缓存项; 解析失败同样缓存 (负缓存), 避免反复打爆坏域名
+type dnsCacheEntry struct {
+	addrs     []string
+	err       error
+	expiresAt time.Time
+}
+
+// cachingResolver 带正/负缓存的 DNS 解析器。
+// 出口每次拨号都解析域名会放大延迟, 短 TTL 缓存把热点目标的解析摊平
+type cachingResolver struct {
+	lookup hostLookupper
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*dnsCacheEntry
+}
+
+// newCachingResolver 创建解析器: dnsServer 非空时查询走该服务器 (省略端口默认 53),
+// 否则用系统解析器; ttl <= 0 时关闭缓存
+func newCachingResolver(dnsServer string, ttl time.Duration) *cachingResolver {
+	var lookup hostLookupper = net.DefaultResolver
+	if dnsServer != "" {
+		server := dnsServer
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		lookup = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{Timeout: dnsDialTimeout}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return &cachingResolver{
+		lookup: lookup,
+		ttl:    ttl,
+		cache:  make(map[string]*dnsCacheEntry),
+	}
+}
+
+// LookupHost 解析域名, TTL 内的重复查询直接命中缓存 (包括失败结果)
+func (r *cachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r.ttl <= 0 {
+		return r.lookup.LookupHost(ctx, host)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	if entry, ok := r.cache[host]; ok && now.Before(entry.expiresAt) {
+		r.mu.Unlock()
+		return entry.addrs, entry.err
+	}
+	r.mu.Unlock()
+
+	addrs, err := r.lookup.LookupHost(ctx, host)
+
+	r.mu.Lock()
+	r.cache[host] = &dnsCacheEntry{addrs: addrs, err: err, expiresAt: now.Add(r.ttl)}
+	r.mu.Unlock()
+	return addrs, err
+}
+
+// 进程级出口解析器, 首次拨号时按 Forwarder 配置初始化
+var (
+	exitResolver     *cachingResolver
+	exitResolverOnce sync.Once
+)
+
+func exitResolverFor(cfg ForwarderSection) *cachingResolver {
+	exitResolverOnce.Do(func() {
+		exitResolver = newCachingResolver(cfg.DNSServer, time.Duration(cfg.DNSCacheTTL)*time.Second)
+	})
+	return exitResolver
+}
+
+// resolveExitTarget 为出口拨号解析目标, 返回候选地址列表 (ip:port)。
+// 未配置自定义 DNS、目标是 IP 或解析失败时原样返回, 让拨号走系统路径
+func resolveExitTarget(cfg ForwarderSection, target string, timeout time.Duration) []string {
+	if cfg.DNSServer == "" {
+		return []string{target}
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || net.ParseIP(host) != nil {
+		return []string{target}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addrs, err := exitResolverFor(cfg).LookupHost(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		log.Debug().Err(err).Str("host", host).Msg("Custom DNS resolution failed, falling back to system resolver")
+		return []string{target}
+	}
+
+	candidates := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		candidates = append(candidates, net.JoinHostPort(addr, port))
+	}
+	return candidates
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubLookupper 记录调用次数的解析桩
+type stubLookupper struct {
+	calls int
+	addrs []string
+	err   error
+}
+
+func (s *stubLookupper) LookupHost(ctx context.Context, host string) ([]string, error) {
+	s.calls++
+	return s.addrs, s.err
+}
+
+func TestCachingResolver_PositiveCacheAvoidsRepeatQueries(t *testing.T) {
+	stub := &stubLookupper{addrs: []string{"10.0.0.1", "10.0.0.2"}}
+	r := &cachingResolver{lookup: stub, ttl: time.Minute, cache: make(map[string]*dnsCacheEntry)}
+
+	for i := 0; i < 3; i++ {
+		addrs, err := r.LookupHost(context.Background(), "example.com")
+		if err != nil {
+			t.Fatalf("lookup %d failed: %v", i, err)
+		}
+		if len(addrs) != 2 || addrs[0] != "10.0.0.1" {
+			t.Fatalf("lookup %d returned %v", i, addrs)
+		}
+	}
+
+	if stub.calls != 1 {
+		t.Errorf("resolver queried %d times, want 1 (cached)", stub.calls)
+	}
+}
+
+func TestCachingResolver_NegativeCache(t *testing.T) {
+	stub := &stubLookupper{err: errors.New("no such host")}
+	r := &cachingResolver{lookup: stub, ttl: time.Minute, cache: make(map[string]*dnsCacheEntry)}
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.LookupHost(context.Background(), "missing.example.com"); err == nil {
+			t.Fatalf("lookup %d expected error", i)
+		}
+	}
+
+	if stub.calls != 1 {
+		t.Errorf("resolver queried %d times, want 1 (negative cached)", stub.calls)
+	}
+}
+
+func TestCachingResolver_ExpiresAfterTTL(t *testing.T) {
+	stub := &stubLookupper{addrs: []string{"10.0.0.1"}}
+	r := &cachingResolver{lookup: stub, ttl: 20 * time.Millisecond, cache: make(map[string]*dnsCacheEntry)}
+
+	r.LookupHost(context.Background(), "example.com")
+	time.Sleep(40 * time.Millisecond)
+	r.LookupHost(context.Background(), "example.com")
+
+	if stub.calls != 2 {
+		t.Errorf("resolver queried %d times, want 2 after TTL expiry", stub.calls)
+	}
+}
+
+func TestCachingResolver_ZeroTTLDisablesCache(t *testing.T) {
+	stub := &stubLookupper{addrs: []string{"10.0.0.1"}}
+	r := &cachingResolver{lookup: stub, ttl: 0, cache: make(map[string]*dnsCacheEntry)}
+
+	r.LookupHost(context.Background(), "example.com")
+	r.LookupHost(context.Background(), "example.com")
+
+	if stub.calls != 2 {
+		t.Errorf("resolver queried %d times, want 2 with cache disabled", stub.calls)
+	}
+}
+
+func TestResolveExitTarget_PassthroughCases(t *testing.T) {
+	timeout := time.Second
+
+	// 未配置自定义 DNS: 原样返回
+	got := resolveExitTarget(ForwarderSection{}, "example.com:80", timeout)
+	if len(got) != 1 || got[0] != "example.com:80" {
+		t.Errorf("no DNS server: got %v", got)
+	}
+
+	// 目标本身是 IP: 不解析
+	cfg := ForwarderSection{DNSServer: "127.0.0.1:5353"}
+	got = resolveExitTarget(cfg, "10.1.2.3:443", timeout)
+	if len(got) != 1 || got[0] != "10.1.2.3:443" {
+		t.Errorf("IP target: got %v", got)
+	}
+
+	// 无端口的畸形目标: 原样返回交给拨号报错
+	got = resolveExitTarget(cfg, "example.com", timeout)
+	if len(got) != 1 || got[0] != "example.com" {
+		t.Errorf("malformed target: got %v", got)
+	}
+}
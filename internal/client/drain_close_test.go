@@ -0,0 +1,129 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// startDrainTestHarness 搭建出口侧排空测试环境: 真实 WSServer + 已连接的出口 Client
+// (不启动 handleTunnelMessages, 由测试直接驱动 stream)
+func startDrainTestHarness(t *testing.T) (*Client, *relay.WSClientConn, func()) {
+	t.Helper()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+
+	c := New(&ClientConfig{
+		Forwarder: ForwarderSection{ConnectTimeout: 3, CloseDrainTimeout: 2},
+	})
+	c.clientID = "drain-client"
+
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "drain-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+
+	cleanup := func() {
+		ws.Close()
+		wsSrv.Close()
+	}
+	return c, ws, cleanup
+}
+
+// waitForStream 等待出口侧为指定 StreamID 建立 stream
+func waitForStream(t *testing.T, ws *relay.WSClientConn, id uint32) *relay.Stream {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if stream := ws.GetStreams().GetStream(id); stream != nil {
+			return stream
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stream never created on exit side")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleIncomingConnect_DrainDeliversTailData(t *testing.T) {
+	c, ws, cleanup := startDrainTestHarness(t)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// 目标读到 EOF 为止, 半关排空应把关闭前写入的数据连同 FIN 一起送达
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	msg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: 77,
+		Target:   ln.Addr().String(),
+		Flags:    relay.ConnFlagDrainClose,
+	}
+	done := make(chan struct{})
+	go func() {
+		c.handleIncomingConnect(ws, msg)
+		close(done)
+	}()
+
+	stream := waitForStream(t, ws, 77)
+
+	// 数据入队后立刻关闭, 模拟"最后一笔写入紧跟连接关闭"
+	stream.DataCh <- []byte("tail-data")
+	stream.Close()
+
+	select {
+	case data := <-received:
+		if string(data) != "tail-data" {
+			t.Errorf("expected target to receive %q, got %q", "tail-data", string(data))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("target never received tail data written before close")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("handleIncomingConnect did not return")
+	}
+}
+
+func TestRuleDrainOnClose(t *testing.T) {
+	rule, err := parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1"})
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if rule.DrainOnClose {
+		t.Error("drain_on_close should default to false")
+	}
+
+	rule, err = parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1", "drain_on_close": true})
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if !rule.DrainOnClose {
+		t.Error("drain_on_close should be true when configured")
+	}
+}
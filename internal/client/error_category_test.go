@@ -0,0 +1,162 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// closedPortAddr 返回一个刚刚释放、当前无人监听的本地地址
+func closedPortAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// timeoutDialError 用过期的 Deadline 制造确定性的拨号超时错误
+func timeoutDialError(t *testing.T) error {
+	t.Helper()
+	dialer := net.Dialer{Deadline: time.Now().Add(-time.Second)}
+	_, err := dialer.Dial("tcp", "127.0.0.1:9")
+	if err == nil {
+		t.Fatal("expected dial with expired deadline to fail")
+	}
+	return err
+}
+
+func TestClassifyDialError(t *testing.T) {
+	_, err := net.DialTimeout("tcp", closedPortAddr(t), 2*time.Second)
+	if err == nil {
+		t.Fatal("expected dial to closed port to fail")
+	}
+	if got := relay.ClassifyDialError(err); got != relay.ErrCategoryRefused {
+		t.Errorf("refused dial classified as %s", relay.ErrCategoryName(got))
+	}
+
+	if got := relay.ClassifyDialError(timeoutDialError(t)); got != relay.ErrCategoryTimeout {
+		t.Errorf("timeout dial classified as %s", relay.ErrCategoryName(got))
+	}
+
+	if got := relay.ClassifyDialError(errors.New("boom")); got != relay.ErrCategoryGeneral {
+		t.Errorf("plain error classified as %s", relay.ErrCategoryName(got))
+	}
+}
+
+// TestErrorCategory_DistinguishableAtEntry 验证出口拨号失败的类别
+// 经 服务端转发 -> 入口分发 后在入口流上仍可区分超时与拒绝
+func TestErrorCategory_DistinguishableAtEntry(t *testing.T) {
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	// 真实出口客户端: 收到 Connect 后拨号目标, 失败时回带类别的 Error
+	exitClient := New(&ClientConfig{Forwarder: ForwarderSection{ConnectTimeout: 2}})
+	exitClient.clientID = "exit-client"
+	wsExit, err := relay.NewWSClientConn(wsSrv.URL, "exit-client", "")
+	if err != nil {
+		t.Fatalf("failed to create exit ws client: %v", err)
+	}
+	if err := wsExit.Connect(); err != nil {
+		t.Fatalf("failed to connect exit ws: %v", err)
+	}
+	defer wsExit.Close()
+	go runTunnelDispatch(exitClient, wsExit)
+
+	// 模拟拨号超时的出口: 对 Connect 一律按真实超时错误回 Error
+	wsSlow, err := relay.NewWSClientConn(wsSrv.URL, "slow-exit", "")
+	if err != nil {
+		t.Fatalf("failed to create slow exit ws client: %v", err)
+	}
+	if err := wsSlow.Connect(); err != nil {
+		t.Fatalf("failed to connect slow exit ws: %v", err)
+	}
+	defer wsSlow.Close()
+	go func() {
+		for {
+			msg := wsSlow.Recv()
+			if msg == nil {
+				return
+			}
+			if msg.Type == relay.MsgTypeConnect {
+				dialer := net.Dialer{Deadline: time.Now().Add(-time.Second)}
+				_, dialErr := dialer.Dial("tcp", msg.Target)
+				if dialErr == nil {
+					continue
+				}
+				wsSlow.Send(&relay.TunnelMessage{
+					Type:     relay.MsgTypeError,
+					StreamID: msg.StreamID,
+					Error:    dialErr.Error(),
+					Payload:  []byte{relay.ClassifyDialError(dialErr)},
+				})
+			}
+		}
+	}()
+
+	// 入口客户端: 直接读 stream 上的错误信号
+	wsEntry, err := relay.NewWSClientConn(wsSrv.URL, "entry-client", "")
+	if err != nil {
+		t.Fatalf("failed to create entry ws client: %v", err)
+	}
+	if err := wsEntry.Connect(); err != nil {
+		t.Fatalf("failed to connect entry ws: %v", err)
+	}
+	defer wsEntry.Close()
+	go runTunnelDispatch(New(&ClientConfig{}), wsEntry)
+
+	connectVia := func(streamID uint32, exitID, target string) byte {
+		t.Helper()
+		stream := &relay.Stream{
+			ID:      streamID,
+			Target:  target,
+			DataCh:  make(chan []byte, 4),
+			CloseCh: make(chan struct{}),
+		}
+		wsEntry.GetStreams().AddStream(stream)
+		defer wsEntry.GetStreams().RemoveStream(streamID)
+
+		if err := wsEntry.Send(&relay.TunnelMessage{
+			Type:     relay.MsgTypeConnect,
+			StreamID: streamID,
+			Target:   target,
+			Payload:  []byte(exitID),
+		}); err != nil {
+			t.Fatalf("failed to send connect: %v", err)
+		}
+
+		select {
+		case data := <-stream.DataCh:
+			if len(data) < 2 || data[0] != relay.MsgTypeError {
+				t.Fatalf("expected error signal with category, got %v", data)
+			}
+			return data[1]
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for error signal at entry")
+			return 0
+		}
+	}
+
+	refusedCat := connectVia(1, "exit-client", closedPortAddr(t))
+	if refusedCat != relay.ErrCategoryRefused {
+		t.Errorf("refusal surfaced as %s, want refused", relay.ErrCategoryName(refusedCat))
+	}
+
+	timeoutCat := connectVia(2, "slow-exit", "192.0.2.1:80")
+	if timeoutCat != relay.ErrCategoryTimeout {
+		t.Errorf("timeout surfaced as %s, want timeout", relay.ErrCategoryName(timeoutCat))
+	}
+
+	if refusedCat == timeoutCat {
+		t.Error("timeout and refusal categories should be distinguishable at the entry")
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// exitAllowlist 出口目标 IP 白名单 (解析后的 CIDR 集合)
+type exitAllowlist struct {
+	nets []*net.IPNet
+}
+
+// parseExitAllowlist 解析白名单配置, 空列表返回 nil (不限制)。
+// 条目支持 CIDR 与裸 IP (按 /32 或 /128 处理)
+func parseExitAllowlist(cidrs []string) (*exitAllowlist, error) {
+	if len(cidrs) == 0 {
+		return nil, nil
+	}
+
+	al := &exitAllowlist{nets: make([]*net.IPNet, 0, len(cidrs))}
+	for _, entry := range cidrs {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			al.nets = append(al.nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid allowed exit CIDR: %s", entry)
+		}
+		bits := 32
+		if ip.To4() == nil {
+			bits = 128
+		}
+		al.nets = append(al.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	if len(al.nets) == 0 {
+		return nil, nil
+	}
+	return al, nil
+}
+
+// allows 判断 IP 是否落在白名单内
+func (a *exitAllowlist) allows(ip net.IP) bool {
+	for _, n := range a.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// pinAllowedCandidates 把候选地址固定为白名单内的 IP 字面量:
+// 域名在此处解析并逐 IP 过滤, 后续拨号不再二次解析,
+// 避免 DNS 重绑定把连接引到白名单之外。全部候选被过滤时拒绝拨号
+func pinAllowedCandidates(candidates []string, allowlist *exitAllowlist, timeout time.Duration) ([]string, error) {
+	pinned := make([]string, 0, len(candidates))
+	for _, addr := range candidates {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+
+		if ip := net.ParseIP(host); ip != nil {
+			if allowlist.allows(ip) {
+				pinned = append(pinned, addr)
+			}
+			continue
+		}
+
+		// 域名走系统解析 (自定义 DNS 在 resolveExitTarget 已解析为 IP)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		cancel()
+		if err != nil {
+			continue
+		}
+		for _, ipStr := range ips {
+			if ip := net.ParseIP(ipStr); ip != nil && allowlist.allows(ip) {
+				pinned = append(pinned, net.JoinHostPort(ipStr, port))
+			}
+		}
+	}
+
+	if len(pinned) == 0 {
+		return nil, fmt.Errorf("target resolved outside allowed exit CIDRs")
+	}
+	return pinned, nil
+}
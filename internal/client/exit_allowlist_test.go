@@ -0,0 +1,93 @@
+package client
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExitAllowlist(t *testing.T) {
+	al, err := parseExitAllowlist(nil)
+	if err != nil || al != nil {
+		t.Errorf("empty list should disable allowlist, got %v, %v", al, err)
+	}
+
+	al, err = parseExitAllowlist([]string{"10.0.0.0/8", "192.168.1.5", "::1"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	cases := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"::1", true},
+		{"8.8.8.8", false},
+	}
+	for _, tc := range cases {
+		if got := al.allows(net.ParseIP(tc.ip)); got != tc.want {
+			t.Errorf("allows(%s) = %v, want %v", tc.ip, got, tc.want)
+		}
+	}
+
+	if _, err := parseExitAllowlist([]string{"not-a-cidr"}); err == nil {
+		t.Error("invalid entry should fail to parse")
+	}
+}
+
+// TestDialExit_AllowlistRefusesDisallowedResolvedIP 验证域名解析到白名单外的 IP 时拒绝拨号
+func TestDialExit_AllowlistRefusesDisallowedResolvedIP(t *testing.T) {
+	cfg := ForwarderSection{
+		AllowedExitCIDRs: []string{"10.0.0.0/8"},
+	}
+
+	// localhost 解析为回环地址, 不在 10/8 白名单内
+	_, err := dialExit(cfg, "localhost:80", 2*time.Second)
+	if err == nil {
+		t.Fatal("dial to hostname resolving outside allowlist should be refused")
+	}
+	if !strings.Contains(err.Error(), "allowed exit CIDRs") {
+		t.Errorf("expected allowlist refusal, got: %v", err)
+	}
+}
+
+func TestDialExit_AllowlistRefusesDisallowedIPLiteral(t *testing.T) {
+	cfg := ForwarderSection{
+		AllowedExitCIDRs: []string{"127.0.0.0/8"},
+	}
+
+	_, err := dialExit(cfg, "192.0.2.1:80", 2*time.Second)
+	if err == nil {
+		t.Fatal("dial to IP outside allowlist should be refused")
+	}
+	if !strings.Contains(err.Error(), "allowed exit CIDRs") {
+		t.Errorf("expected allowlist refusal, got: %v", err)
+	}
+}
+
+func TestDialExit_AllowlistPermitsAllowedTarget(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	cfg := ForwarderSection{
+		AllowedExitCIDRs: []string{"127.0.0.0/8"},
+	}
+
+	conn, err := dialExit(cfg, ln.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("dial to allowed target failed: %v", err)
+	}
+	conn.Close()
+}
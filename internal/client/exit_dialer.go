@@ -0,0 +1,88 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// exitDialAttempts 源端口冲突时的重试次数
+const exitDialAttempts = 8
+
+// exitPortCursor 端口轮转游标, 避免每次都从范围起点尝试
+var exitPortCursor uint64
+
+// dialExit 按 ForwarderSection 的源地址与 DNS 配置拨号目标。
+// 配置了自定义 DNS 时先经缓存解析, 按候选地址依次尝试;
+// 配置了出口白名单时候选地址固定为白名单内的 IP 字面量 (DNS pinning)
+func dialExit(cfg ForwarderSection, target string, timeout time.Duration) (net.Conn, error) {
+	allowlist, err := parseExitAllowlist(cfg.AllowedExitCIDRs)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := resolveExitTarget(cfg, target, timeout)
+	if allowlist != nil {
+		candidates, err = pinAllowedCandidates(candidates, allowlist, timeout)
+		if err != nil {
+			log.Warn().Err(err).Str("target", target).Msg("Exit dial refused by allowlist")
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for _, addr := range candidates {
+		conn, err := dialExitAddr(cfg, addr, timeout)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// dialExitAddr 拨号单个地址。未配置端口范围时等价于 net.DialTimeout。
+func dialExitAddr(cfg ForwarderSection, target string, timeout time.Duration) (net.Conn, error) {
+	if cfg.SourcePortMin <= 0 || cfg.SourcePortMax < cfg.SourcePortMin {
+		dialer := net.Dialer{Timeout: timeout}
+		if cfg.SourceIP != "" {
+			dialer.LocalAddr = &net.TCPAddr{IP: net.ParseIP(cfg.SourceIP)}
+		}
+		applyFastOpenDial(&dialer, cfg.TCPFastOpen)
+		return dialer.Dial("tcp", target)
+	}
+
+	var sourceIP net.IP
+	if cfg.SourceIP != "" {
+		sourceIP = net.ParseIP(cfg.SourceIP)
+		if sourceIP == nil {
+			return nil, fmt.Errorf("invalid source IP: %s", cfg.SourceIP)
+		}
+	}
+
+	rangeSize := uint64(cfg.SourcePortMax - cfg.SourcePortMin + 1)
+
+	var lastErr error
+	for i := 0; i < exitDialAttempts; i++ {
+		// 轮转选端口, 减少并发连接间的冲突
+		port := cfg.SourcePortMin + int(atomic.AddUint64(&exitPortCursor, 1)%rangeSize)
+
+		dialer := net.Dialer{
+			Timeout:   timeout,
+			LocalAddr: &net.TCPAddr{IP: sourceIP, Port: port},
+		}
+		applyFastOpenDial(&dialer, cfg.TCPFastOpen)
+		conn, err := dialer.Dial("tcp", target)
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		log.Debug().Err(err).Int("source_port", port).Str("target", target).Msg("Exit dial attempt failed, rotating port")
+	}
+
+	return nil, fmt.Errorf("no source port available in range %d-%d: %w",
+		cfg.SourcePortMin, cfg.SourcePortMax, lastErr)
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialExit_SourcePortInRange(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	cfg := ForwarderSection{
+		ConnectTimeout: 5,
+		SourceIP:       "127.0.0.1",
+		SourcePortMin:  42000,
+		SourcePortMax:  42050,
+	}
+
+	for i := 0; i < 3; i++ {
+		conn, err := dialExit(cfg, ln.Addr().String(), 5*time.Second)
+		if err != nil {
+			t.Fatalf("dialExit failed: %v", err)
+		}
+
+		localPort := conn.LocalAddr().(*net.TCPAddr).Port
+		if localPort < cfg.SourcePortMin || localPort > cfg.SourcePortMax {
+			t.Errorf("local port %d outside configured range %d-%d",
+				localPort, cfg.SourcePortMin, cfg.SourcePortMax)
+		}
+		conn.Close()
+	}
+}
+
+func TestDialExit_NoRangeUsesEphemeral(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := dialExit(ForwarderSection{}, ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("dialExit failed: %v", err)
+	}
+	conn.Close()
+}
+
+func TestDialExit_InvalidSourceIP(t *testing.T) {
+	cfg := ForwarderSection{
+		SourceIP:      "not-an-ip",
+		SourcePortMin: 42000,
+		SourcePortMax: 42001,
+	}
+	if _, err := dialExit(cfg, "127.0.0.1:1", time.Second); err == nil {
+		t.Error("expected error for invalid source IP")
+	}
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"net"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// earlyDataReadTimeout fast open 等待首包的窗口: 足够客户端先发话的协议 (HTTP 等) 送达首包,
+// 又不至于对服务端先发话的协议 (SMTP 等) 引入可感知的建连延迟
+const earlyDataReadTimeout = 20 * time.Millisecond
+
+// earlyDataMaxSize 随 Connect 携带的首包上限 (远小于 MaxPayloadSize, 避免挤占隧道消息)
+const earlyDataMaxSize = relay.SmallBufSize
+
+// readEarlyData 在短暂窗口内读取连接上已到达的首包数据, 窗口内读不到返回 nil。
+// 读取后清除 deadline, 不影响后续正常转发
+func readEarlyData(conn net.Conn) []byte {
+	buf := make([]byte, earlyDataMaxSize)
+	_ = conn.SetReadDeadline(time.Now().Add(earlyDataReadTimeout))
+	n, _ := conn.Read(buf)
+	_ = conn.SetReadDeadline(time.Time{})
+	if n <= 0 {
+		return nil
+	}
+	return buf[:n]
+}
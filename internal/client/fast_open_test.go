@@ -0,0 +1,231 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// runTunnelDispatchDroppingConnAck 与 runTunnelDispatch 相同, 但故意丢弃 ConnAck,
+// 用于验证 fast open 首包不依赖 Connect→ConnAck 往返即可送达目标
+func runTunnelDispatchDroppingConnAck(c *Client, ws *relay.WSClientConn) {
+	for {
+		msg := ws.Recv()
+		if msg == nil {
+			return
+		}
+		switch msg.Type {
+		case relay.MsgTypeConnect:
+			go c.handleIncomingConnect(ws, msg)
+		case relay.MsgTypeConnAck:
+			// 丢弃: 入口永远等不到 ConnAck
+		case relay.MsgTypeData:
+			if stream := ws.GetStreams().GetStream(msg.StreamID); stream != nil {
+				if !stream.WriteBlocking(msg.Payload) {
+					ws.GetStreams().RemoveStream(msg.StreamID)
+				}
+			}
+		case relay.MsgTypeClose:
+			ws.GetStreams().RemoveStream(msg.StreamID)
+		case relay.MsgTypeError:
+			if stream := ws.GetStreams().GetStream(msg.StreamID); stream != nil {
+				category := relay.ErrCategoryGeneral
+				if len(msg.Payload) > 0 {
+					category = msg.Payload[0]
+				}
+				stream.Write([]byte{relay.MsgTypeError, category})
+				stream.Close()
+			}
+		}
+	}
+}
+
+// TestFastOpen_EarlyDataReachesTargetWithoutConnAck 验证开启 FastOpen 后,
+// 客户端的首包随 Connect 送到出口并写入目标, 即使入口始终收不到 ConnAck
+func TestFastOpen_EarlyDataReachesTargetWithoutConnAck(t *testing.T) {
+	// 目标只记录收到的首包
+	targetLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer targetLn.Close()
+
+	payload := []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := targetLn.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, len(payload))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		received <- buf
+	}()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	// 出口客户端: 正常处理 Connect 并拨号目标
+	exitClient := New(&ClientConfig{Forwarder: ForwarderSection{ConnectTimeout: 2}})
+	exitClient.clientID = "exit-client"
+	wsExit, err := relay.NewWSClientConn(wsSrv.URL, "exit-client", "")
+	if err != nil {
+		t.Fatalf("failed to create exit ws client: %v", err)
+	}
+	if err := wsExit.Connect(); err != nil {
+		t.Fatalf("failed to connect exit ws: %v", err)
+	}
+	defer wsExit.Close()
+	go runTunnelDispatch(exitClient, wsExit)
+
+	// 入口客户端: 开启 FastOpen, 且分发循环丢弃 ConnAck
+	entryCfg := ForwarderSection{ConnectTimeout: 2, FastOpen: true}
+	entryClient := New(&ClientConfig{Forwarder: entryCfg})
+	entryClient.clientID = "entry-client"
+	wsEntry, err := relay.NewWSClientConn(wsSrv.URL, "entry-client", "")
+	if err != nil {
+		t.Fatalf("failed to create entry ws client: %v", err)
+	}
+	if err := wsEntry.Connect(); err != nil {
+		t.Fatalf("failed to connect entry ws: %v", err)
+	}
+	defer wsEntry.Close()
+	go runTunnelDispatchDroppingConnAck(entryClient, wsEntry)
+
+	f := NewRelayForwarder("fast-open-rule", "127.0.0.1:0", targetLn.Addr().String(), []string{"exit-client"}, entryCfg,
+		func() *relay.WSClientConn { return wsEntry }, nil, nil)
+	go f.Start()
+	defer f.Stop()
+
+	var listenAddr string
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.listenerMu.Lock()
+		if f.listener != nil {
+			listenAddr = f.listener.Addr().String()
+		}
+		f.listenerMu.Unlock()
+		if listenAddr != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("forwarder never started listening")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	// ConnAck 永远到不了入口, 首包仍应经 Connect payload 送达目标
+	select {
+	case data := <-received:
+		if string(data) != string(payload) {
+			t.Errorf("target received %q, want %q", data, payload)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("target never received early data (fast open did not bypass ConnAck round trip)")
+	}
+}
+
+// TestHandleIncomingConnect_EarlyDataWrittenBeforeTunnelData 验证出口先写入首包再处理
+// 隧道数据, 目标按 "首包在前" 的顺序收到完整数据
+func TestHandleIncomingConnect_EarlyDataWrittenBeforeTunnelData(t *testing.T) {
+	c, ws, cleanup := startDrainTestHarness(t)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	msg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: 88,
+		Target:   ln.Addr().String(),
+		Flags:    relay.ConnFlagEarlyData | relay.ConnFlagDrainClose,
+		Payload:  []byte("early-"),
+	}
+	done := make(chan struct{})
+	go func() {
+		c.handleIncomingConnect(ws, msg)
+		close(done)
+	}()
+
+	stream := waitForStream(t, ws, 88)
+	stream.DataCh <- []byte("rest")
+	stream.Close()
+
+	select {
+	case data := <-received:
+		if string(data) != "early-rest" {
+			t.Errorf("target received %q, want %q", data, "early-rest")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("target never received data")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("handleIncomingConnect did not return")
+	}
+}
+
+func TestReadEarlyData(t *testing.T) {
+	t.Run("data available", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		go client.Write([]byte("first-bytes"))
+
+		data := readEarlyData(server)
+		if string(data) != "first-bytes" {
+			t.Errorf("readEarlyData = %q, want %q", data, "first-bytes")
+		}
+	})
+
+	t.Run("no data within window", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		start := time.Now()
+		data := readEarlyData(server)
+		if data != nil {
+			t.Errorf("readEarlyData = %q, want nil", data)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("readEarlyData blocked %v, should give up after the short window", elapsed)
+		}
+	})
+}
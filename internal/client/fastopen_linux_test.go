@@ -0,0 +1,89 @@
+package client
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// getTCPSockoptInt 读取 TCP 层 socket 选项, 内核不支持该选项时跳过测试
+func getTCPSockoptInt(t *testing.T, sc syscall.RawConn, opt int) int {
+	t.Helper()
+	var val int
+	var sockErr error
+	if err := sc.Control(func(fd uintptr) {
+		val, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, opt)
+	}); err != nil {
+		t.Fatalf("raw conn control failed: %v", err)
+	}
+	if sockErr != nil {
+		t.Skipf("getsockopt failed, kernel without TFO support: %v", sockErr)
+	}
+	return val
+}
+
+func TestListenTCPOpts_FastOpenRequested(t *testing.T) {
+	ln, err := listenTCPOpts("127.0.0.1:0", 0, true)
+	if err != nil {
+		t.Fatalf("listenTCPOpts failed: %v", err)
+	}
+	defer ln.Close()
+
+	sc, err := ln.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		t.Fatalf("failed to get raw conn: %v", err)
+	}
+	if v := getTCPSockoptInt(t, sc, tcpFastOpen); v <= 0 {
+		t.Errorf("TCP_FASTOPEN = %d, want > 0 on fast-open listener", v)
+	}
+}
+
+func TestListenTCPOpts_FastOpenWithBacklog(t *testing.T) {
+	ln, err := listenTCPOpts("127.0.0.1:0", 4, true)
+	if err != nil {
+		t.Fatalf("listenTCPOpts with backlog failed: %v", err)
+	}
+	defer ln.Close()
+
+	sc, err := ln.(*net.TCPListener).SyscallConn()
+	if err != nil {
+		t.Fatalf("failed to get raw conn: %v", err)
+	}
+	if v := getTCPSockoptInt(t, sc, tcpFastOpen); v <= 0 {
+		t.Errorf("TCP_FASTOPEN = %d, want > 0 on fast-open listener with custom backlog", v)
+	}
+}
+
+func TestApplyFastOpenDial_ConnectOptionRequested(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	dialer := net.Dialer{Timeout: 2 * time.Second}
+	applyFastOpenDial(&dialer, true)
+	conn, err := dialer.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	sc, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("failed to get raw conn: %v", err)
+	}
+	if v := getTCPSockoptInt(t, sc, tcpFastOpenConnect); v != 1 {
+		t.Errorf("TCP_FASTOPEN_CONNECT = %d, want 1 on fast-open dialer", v)
+	}
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"io"
+	"math/rand"
 	"net"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -70,11 +72,30 @@ type Forwarder struct {
 	cfg        ForwarderSection
 
 	listener       net.Listener
+	pool           *acceptPool
 	listenerMu     sync.Mutex
 	stopCh         chan struct{}
 	wg             sync.WaitGroup
 	trafficCounter *TrafficCounter
 	statusCallback StatusCallback
+
+	// 每规则 TCP 调优参数
+	tcpNoDelay   bool
+	tcpKeepAlive time.Duration
+
+	// 金丝雀分流: canaryPercent% 的新连接转发到 canaryTarget
+	canaryTarget  string
+	canaryPercent int
+
+	// 流量镜像 tap 地址 (为空不镜像)
+	mirrorAddr string
+
+	// 监听与拨号侧请求 TCP Fast Open (仅内核支持时生效)
+	tcpFastOpen bool
+
+	// activeConns 活跃连接表: Stop 时主动关闭, 规则迁移/下线不被长连接拖住
+	activeConns   map[net.Conn]struct{}
+	activeConnsMu sync.Mutex
 }
 
 func NewForwarder(id, listenAddr, targetAddr string, cfg ForwarderSection, tc *TrafficCounter, cb StatusCallback) *Forwarder {
@@ -86,11 +107,61 @@ func NewForwarder(id, listenAddr, targetAddr string, cfg ForwarderSection, tc *T
 		stopCh:         make(chan struct{}),
 		trafficCounter: tc,
 		statusCallback: cb,
+		tcpNoDelay:     true,
+		tcpKeepAlive:   defaultKeepAlivePeriod,
+		activeConns:    make(map[net.Conn]struct{}),
+	}
+}
+
+// trackConn 登记活跃连接, 供 Stop 时主动关闭
+func (f *Forwarder) trackConn(conn net.Conn) {
+	f.activeConnsMu.Lock()
+	f.activeConns[conn] = struct{}{}
+	f.activeConnsMu.Unlock()
+}
+
+// untrackConn 注销活跃连接
+func (f *Forwarder) untrackConn(conn net.Conn) {
+	f.activeConnsMu.Lock()
+	delete(f.activeConns, conn)
+	f.activeConnsMu.Unlock()
+}
+
+// SetTCPOptions 设置本规则的 TCP 调优参数 (应在 Start 前调用)
+func (f *Forwarder) SetTCPOptions(noDelay bool, keepAlive time.Duration) {
+	f.tcpNoDelay = noDelay
+	f.tcpKeepAlive = keepAlive
+}
+
+// SetCanary 配置金丝雀分流 (应在 Start 前调用): percent% 的新连接转发到 target
+func (f *Forwarder) SetCanary(target string, percent int) {
+	f.canaryTarget = target
+	f.canaryPercent = percent
+}
+
+// SetMirrorAddr 配置流量镜像 tap 地址 (应在 Start 前调用, 空字符串关闭镜像)
+func (f *Forwarder) SetMirrorAddr(addr string) {
+	f.mirrorAddr = addr
+}
+
+// SetTCPFastOpen 开启 TCP Fast Open (应在 Start 前调用, 内核不支持时静默回退)
+func (f *Forwarder) SetTCPFastOpen(enabled bool) {
+	f.tcpFastOpen = enabled
+}
+
+// pickTarget 按金丝雀百分比为新连接选择目标
+func (f *Forwarder) pickTarget() string {
+	if f.canaryTarget == "" || f.canaryPercent <= 0 {
+		return f.targetAddr
+	}
+	if f.canaryPercent >= 100 || rand.Intn(100) < f.canaryPercent {
+		return f.canaryTarget
 	}
+	return f.targetAddr
 }
 
 func (f *Forwarder) Start() error {
-	listener, err := net.Listen("tcp", f.listenAddr)
+	listener, err := listenTCPOpts(f.listenAddr, f.cfg.ListenBacklog, f.tcpFastOpen)
 	if err != nil {
 		// 上报错误状态
 		if f.statusCallback != nil {
@@ -98,8 +169,13 @@ func (f *Forwarder) Start() error {
 		}
 		return err
 	}
+	pool := newAcceptPoolFromConfig(f.cfg, func(conn net.Conn) {
+		f.wg.Add(1)
+		f.handleConnection(conn)
+	})
 	f.listenerMu.Lock()
 	f.listener = listener
+	f.pool = pool
 	f.listenerMu.Unlock()
 
 	// 上报运行状态
@@ -113,6 +189,13 @@ func (f *Forwarder) Start() error {
 		Str("target", f.targetAddr).
 		Msg("Forwarder started")
 
+	return f.acceptLoop(listener, pool)
+}
+
+// acceptLoop 接受连接直至停止或遇到致命错误。
+// 临时错误 (如 EMFILE 描述符耗尽) 指数退避后继续, 致命错误上报状态并退出
+func (f *Forwarder) acceptLoop(listener net.Listener, pool *acceptPool) error {
+	var backoff acceptBackoff
 	for {
 		select {
 		case <-f.stopCh:
@@ -126,9 +209,30 @@ func (f *Forwarder) Start() error {
 			case <-f.stopCh:
 				return nil
 			default:
-				log.Warn().Err(err).Msg("Accept error")
-				continue
 			}
+			if !isTemporaryAcceptError(err) {
+				log.Error().Err(err).Str("id", f.id).Msg("Fatal accept error, stopping forwarder")
+				if f.statusCallback != nil {
+					f.statusCallback(f.id, "error", err.Error())
+				}
+				return err
+			}
+			d := backoff.next()
+			log.Warn().Err(err).Dur("backoff", d).Str("id", f.id).Msg("Temporary accept error, backing off")
+			select {
+			case <-f.stopCh:
+				return nil
+			case <-time.After(d):
+			}
+			continue
+		}
+		backoff.reset()
+
+		if pool != nil {
+			if !pool.Submit(conn) {
+				log.Warn().Str("id", f.id).Msg("Accept queue full, connection dropped")
+			}
+			continue
 		}
 
 		f.wg.Add(1)
@@ -136,19 +240,40 @@ func (f *Forwarder) Start() error {
 	}
 }
 
+// Stop 关闭监听并主动关闭活跃连接, 等待全部处理协程退出。
+// 不关闭活跃连接的话, 长连接会让规则迁移/停止被拖到连接自然结束
 func (f *Forwarder) Stop() {
 	close(f.stopCh)
 	f.listenerMu.Lock()
 	if f.listener != nil {
 		f.listener.Close()
 	}
+	pool := f.pool
 	f.listenerMu.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
+	f.activeConnsMu.Lock()
+	for conn := range f.activeConns {
+		conn.Close()
+	}
+	f.activeConnsMu.Unlock()
 	f.wg.Wait()
 }
 
 // GetConfigHash 返回配置的哈希值，用于比较配置是否变化
 func (f *Forwarder) GetConfigHash() string {
-	return "direct:" + f.listenAddr + ":" + f.targetAddr
+	hash := "direct:" + f.listenAddr + ":" + f.targetAddr
+	if f.canaryTarget != "" && f.canaryPercent > 0 {
+		hash += ":canary:" + f.canaryTarget + ":" + strconv.Itoa(f.canaryPercent)
+	}
+	if f.mirrorAddr != "" {
+		hash += ":mirror:" + f.mirrorAddr
+	}
+	if f.tcpFastOpen {
+		hash += ":tfo"
+	}
+	return hash
 }
 
 // GetListenAddr 返回监听地址
@@ -160,7 +285,10 @@ func (f *Forwarder) handleConnection(clientConn net.Conn) {
 	defer f.wg.Done()
 	defer clientConn.Close()
 
-	tuneTCPConn(clientConn)
+	f.trackConn(clientConn)
+	defer f.untrackConn(clientConn)
+
+	tuneTCPConnOpts(clientConn, f.tcpNoDelay, f.tcpKeepAlive)
 
 	// 统计连接数
 	if f.trafficCounter != nil {
@@ -168,14 +296,19 @@ func (f *Forwarder) handleConnection(clientConn net.Conn) {
 		defer f.trafficCounter.DecrementConn(f.id)
 	}
 
-	// 连接目标
-	targetConn, err := net.DialTimeout("tcp", f.targetAddr, time.Duration(f.cfg.ConnectTimeout)*time.Second)
+	// 连接目标 (配置了金丝雀分流时按百分比选择)
+	target := f.pickTarget()
+	dialer := net.Dialer{Timeout: time.Duration(f.cfg.ConnectTimeout) * time.Second}
+	applyFastOpenDial(&dialer, f.tcpFastOpen)
+	targetConn, err := dialer.Dial("tcp", target)
 	if err != nil {
-		log.Warn().Err(err).Str("target", f.targetAddr).Msg("Failed to connect to target")
+		log.Warn().Err(err).Str("target", target).Msg("Failed to connect to target")
 		return
 	}
 	defer targetConn.Close()
-	tuneTCPConn(targetConn)
+	f.trackConn(targetConn)
+	defer f.untrackConn(targetConn)
+	tuneTCPConnOpts(targetConn, f.tcpNoDelay, f.tcpKeepAlive)
 
 	// 预解析 *RuleTraffic，省掉每次 copyAndCount 的 map lookup；nil counter 触发 splice 快路径
 	var stat *RuleTraffic
@@ -183,6 +316,16 @@ func (f *Forwarder) handleConnection(clientConn net.Conn) {
 		stat = f.trafficCounter.GetOrCreateStat(f.id)
 	}
 
+	// 流量镜像: 两个方向的载荷副本尽力而为送往 tap, 镜像写入永不阻塞主链路
+	toTarget := io.Writer(targetConn)
+	toClient := io.Writer(clientConn)
+	if f.mirrorAddr != "" {
+		mirror := newTrafficMirror(f.mirrorAddr)
+		defer mirror.Close()
+		toTarget = io.MultiWriter(targetConn, mirror)
+		toClient = io.MultiWriter(clientConn, mirror)
+	}
+
 	// 双向转发：任一方向结束时 close 双端触发对端退出，两侧都退出后才返回
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -192,7 +335,7 @@ func (f *Forwarder) handleConnection(clientConn net.Conn) {
 		defer wg.Done()
 		defer targetConn.Close()
 		defer clientConn.Close()
-		copyAndCount(targetConn, clientConn, stat, false)
+		copyAndCount(toTarget, clientConn, stat, false)
 	}()
 
 	// 目标 -> 客户端 (入站流量)
@@ -200,7 +343,7 @@ func (f *Forwarder) handleConnection(clientConn net.Conn) {
 		defer wg.Done()
 		defer clientConn.Close()
 		defer targetConn.Close()
-		copyAndCount(clientConn, targetConn, stat, true)
+		copyAndCount(toClient, targetConn, stat, true)
 	}()
 
 	wg.Wait()
@@ -3,8 +3,12 @@ package client
 import (
 	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
 func TestForwarderStatusCallback_Success(t *testing.T) {
@@ -189,6 +193,95 @@ func TestForwarder_GetConfigHash(t *testing.T) {
 	}
 }
 
+func TestForwarder_GetConfigHash_WithCanary(t *testing.T) {
+	cfg := ForwarderSection{}
+	f := NewForwarder("r1", "0.0.0.0:8080", "localhost:80", cfg, nil, nil)
+	f.SetCanary("localhost:81", 10)
+
+	expected := "direct:0.0.0.0:8080:localhost:80:canary:localhost:81:10"
+	if got := f.GetConfigHash(); got != expected {
+		t.Errorf("GetConfigHash() = %q, want %q", got, expected)
+	}
+}
+
+// countingListener 启动一个接受连接即关闭的监听, 返回地址和连接计数
+func countingListener(t *testing.T) (string, *int64) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var count int64
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt64(&count, 1)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String(), &count
+}
+
+func TestForwarder_CanarySplit(t *testing.T) {
+	primaryAddr, primaryCount := countingListener(t)
+	canaryAddr, canaryCount := countingListener(t)
+
+	tempListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create temp listener: %v", err)
+	}
+	listenAddr := tempListener.Addr().String()
+	tempListener.Close()
+
+	cfg := ForwarderSection{ConnectTimeout: 5, BufferSize: 32768}
+	f := NewForwarder("canary-rule", listenAddr, primaryAddr, cfg, nil, nil)
+	f.SetCanary(canaryAddr, 10)
+
+	ready := make(chan struct{})
+	go func() {
+		close(ready)
+		f.Start()
+	}()
+	<-ready
+	time.Sleep(100 * time.Millisecond)
+	defer f.Stop()
+
+	const attempts = 400
+	for i := 0; i < attempts; i++ {
+		conn, err := net.Dial("tcp", listenAddr)
+		if err != nil {
+			t.Fatalf("failed to dial forwarder: %v", err)
+		}
+		conn.Close()
+	}
+
+	// 目标侧计数是异步的, 等待全部连接到达
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt64(primaryCount)+atomic.LoadInt64(canaryCount) >= attempts {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	canary := atomic.LoadInt64(canaryCount)
+	total := atomic.LoadInt64(primaryCount) + canary
+	if total < attempts {
+		t.Fatalf("only %d of %d connections reached a target", total, attempts)
+	}
+
+	// 10% ± 容差: 400 次尝试下标准差约 1.5%, 取 [4%, 18%] 避免偶发失败
+	ratio := float64(canary) / float64(total)
+	if ratio < 0.04 || ratio > 0.18 {
+		t.Errorf("canary ratio = %.3f (%d/%d), want ~0.10", ratio, canary, total)
+	}
+}
+
 func TestForwarder_GetListenAddr(t *testing.T) {
 	cfg := ForwarderSection{}
 	f := NewForwarder("r1", "0.0.0.0:9090", "localhost:80", cfg, nil, nil)
@@ -205,3 +298,96 @@ func TestForwarder_StopBeforeStart(t *testing.T) {
 	// Should not panic
 	f.Stop()
 }
+
+// ===== TCP tuning =====
+
+func getSockoptInt(t *testing.T, conn net.Conn, level, opt int) int {
+	t.Helper()
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		t.Fatal("not a TCP connection")
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn: %v", err)
+	}
+	var val int
+	var optErr error
+	raw.Control(func(fd uintptr) {
+		val, optErr = syscall.GetsockoptInt(int(fd), level, opt)
+	})
+	if optErr != nil {
+		t.Fatalf("GetsockoptInt: %v", optErr)
+	}
+	return val
+}
+
+func dialTCPPair(t *testing.T) (net.Conn, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		t.Fatalf("dial: %v", err)
+	}
+	return conn, func() {
+		conn.Close()
+		ln.Close()
+	}
+}
+
+func TestTuneTCPConnOpts_NoDelayAndKeepAlive(t *testing.T) {
+	conn, cleanup := dialTCPPair(t)
+	defer cleanup()
+
+	tuneTCPConnOpts(conn, true, 45*time.Second)
+
+	if got := getSockoptInt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY); got == 0 {
+		t.Error("expected TCP_NODELAY to be set")
+	}
+	if got := getSockoptInt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); got == 0 {
+		t.Error("expected SO_KEEPALIVE to be set")
+	}
+}
+
+func TestTuneTCPConnOpts_Disabled(t *testing.T) {
+	conn, cleanup := dialTCPPair(t)
+	defer cleanup()
+
+	tuneTCPConnOpts(conn, false, 0)
+
+	if got := getSockoptInt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY); got != 0 {
+		t.Error("expected TCP_NODELAY to be cleared")
+	}
+	if got := getSockoptInt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); got != 0 {
+		t.Error("expected SO_KEEPALIVE to be disabled")
+	}
+}
+
+func TestRuleTCPOptions_Defaults(t *testing.T) {
+	noDelay, keepAlive := ruleTCPOptions(model.ClientRule{})
+	if !noDelay {
+		t.Error("default noDelay should be true")
+	}
+	if keepAlive != defaultKeepAlivePeriod {
+		t.Errorf("default keepAlive = %v, want %v", keepAlive, defaultKeepAlivePeriod)
+	}
+}
+
+func TestRuleTCPOptions_Configured(t *testing.T) {
+	noDelay := false
+	rule := model.ClientRule{
+		TCPNoDelay:           &noDelay,
+		TCPKeepAliveInterval: 45,
+	}
+	gotNoDelay, keepAlive := ruleTCPOptions(rule)
+	if gotNoDelay {
+		t.Error("noDelay should be false when configured off")
+	}
+	if keepAlive != 45*time.Second {
+		t.Errorf("keepAlive = %v, want 45s", keepAlive)
+	}
+}
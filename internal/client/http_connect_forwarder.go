@@ -0,0 +1,442 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// httpConnectTimeout 读取 CONNECT 请求的超时, 避免慢客户端占住 goroutine
+const httpConnectTimeout = 10 * time.Second
+
+// HTTP CONNECT 代理应答
+const (
+	httpConnectEstablished = "HTTP/1.1 200 Connection Established\r\n\r\n"
+	httpConnectBadGateway  = "HTTP/1.1 502 Bad Gateway\r\n\r\n"
+	httpConnectNotAllowed  = "HTTP/1.1 405 Method Not Allowed\r\n\r\n"
+)
+
+// HTTPConnectForwarder HTTP CONNECT 代理转发器:
+// 监听端口讲 HTTP CONNECT, 目标地址由客户端在请求行中动态指定 (标准 HTTP 工具
+// 的正向代理), 出口由规则决定 — 无中继链时直接拨号, 有中继链时经隧道由出口拨号
+type HTTPConnectForwarder struct {
+	id         string
+	listenAddr string
+	relayChain []string
+	cfg        ForwarderSection
+
+	// wsConnProvider 每次调用返回最新的 wsConn (仅中继出口使用)
+	wsConnProvider WSConnProvider
+	listener       net.Listener
+	pool           *acceptPool
+	listenerMu     sync.Mutex
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+	trafficCounter *TrafficCounter
+	statusCallback StatusCallback
+
+	// 每规则 TCP 调优参数
+	tcpNoDelay   bool
+	tcpKeepAlive time.Duration
+
+	// verboseLogging 规则开启连接级详细日志
+	verboseLogging bool
+
+	// fallbackGroup 备用代理组引用 (形如 "@groupID"), 首跳离线时服务端改走该组
+	fallbackGroup string
+}
+
+func NewHTTPConnectForwarder(id, listenAddr string, relayChain []string, cfg ForwarderSection, provider WSConnProvider, tc *TrafficCounter, cb StatusCallback) *HTTPConnectForwarder {
+	return &HTTPConnectForwarder{
+		id:             id,
+		listenAddr:     listenAddr,
+		relayChain:     relayChain,
+		cfg:            cfg,
+		wsConnProvider: provider,
+		stopCh:         make(chan struct{}),
+		trafficCounter: tc,
+		statusCallback: cb,
+		tcpNoDelay:     true,
+		tcpKeepAlive:   defaultKeepAlivePeriod,
+	}
+}
+
+// SetTCPOptions 设置本规则的 TCP 调优参数 (应在 Start 前调用)
+func (f *HTTPConnectForwarder) SetTCPOptions(noDelay bool, keepAlive time.Duration) {
+	f.tcpNoDelay = noDelay
+	f.tcpKeepAlive = keepAlive
+}
+
+// SetVerboseLogging 标记本规则开启连接级详细日志 (应在 Start 前调用)
+func (f *HTTPConnectForwarder) SetVerboseLogging(enabled bool) {
+	f.verboseLogging = enabled
+}
+
+// SetFallbackGroup 设置备用代理组引用 (应在 Start 前调用)
+func (f *HTTPConnectForwarder) SetFallbackGroup(group string) {
+	f.fallbackGroup = group
+}
+
+func (f *HTTPConnectForwarder) Start() error {
+	listener, err := listenTCP(f.listenAddr, f.cfg.ListenBacklog)
+	if err != nil {
+		if f.statusCallback != nil {
+			f.statusCallback(f.id, "error", err.Error())
+		}
+		return err
+	}
+	pool := newAcceptPoolFromConfig(f.cfg, func(conn net.Conn) {
+		f.wg.Add(1)
+		f.handleConnection(conn)
+	})
+	f.listenerMu.Lock()
+	f.listener = listener
+	f.pool = pool
+	f.listenerMu.Unlock()
+
+	if f.statusCallback != nil {
+		f.statusCallback(f.id, "running", "")
+	}
+
+	log.Info().
+		Str("id", f.id).
+		Str("listen", f.listenAddr).
+		Strs("relay_chain", f.relayChain).
+		Msg("HTTP CONNECT forwarder started")
+
+	var backoff acceptBackoff
+	for {
+		select {
+		case <-f.stopCh:
+			return nil
+		default:
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-f.stopCh:
+				return nil
+			default:
+			}
+			if !isTemporaryAcceptError(err) {
+				log.Error().Err(err).Str("id", f.id).Msg("Fatal accept error, stopping HTTP CONNECT forwarder")
+				if f.statusCallback != nil {
+					f.statusCallback(f.id, "error", err.Error())
+				}
+				return err
+			}
+			d := backoff.next()
+			log.Warn().Err(err).Dur("backoff", d).Str("id", f.id).Msg("Temporary accept error, backing off")
+			select {
+			case <-f.stopCh:
+				return nil
+			case <-time.After(d):
+			}
+			continue
+		}
+		backoff.reset()
+
+		if pool != nil {
+			if !pool.Submit(conn) {
+				log.Warn().Str("id", f.id).Msg("Accept queue full, connection dropped")
+			}
+			continue
+		}
+
+		f.wg.Add(1)
+		go f.handleConnection(conn)
+	}
+}
+
+func (f *HTTPConnectForwarder) Stop() {
+	close(f.stopCh)
+	f.listenerMu.Lock()
+	if f.listener != nil {
+		f.listener.Close()
+	}
+	pool := f.pool
+	f.listenerMu.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
+	f.wg.Wait()
+}
+
+// GetConfigHash 返回配置的哈希值，用于比较配置是否变化
+func (f *HTTPConnectForwarder) GetConfigHash() string {
+	return "http_connect:" + f.listenAddr + ":" + strings.Join(f.relayChain, ",")
+}
+
+// GetListenAddr 返回监听地址
+func (f *HTTPConnectForwarder) GetListenAddr() string {
+	return f.listenAddr
+}
+
+func (f *HTTPConnectForwarder) handleConnection(clientConn net.Conn) {
+	defer f.wg.Done()
+	defer clientConn.Close()
+
+	tuneTCPConnOpts(clientConn, f.tcpNoDelay, f.tcpKeepAlive)
+
+	// 统计连接数
+	if f.trafficCounter != nil {
+		f.trafficCounter.IncrementConn(f.id)
+		defer f.trafficCounter.DecrementConn(f.id)
+	}
+
+	// 读取并解析 CONNECT 请求 (带超时)
+	clientConn.SetReadDeadline(time.Now().Add(httpConnectTimeout))
+	br := bufio.NewReader(clientConn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		log.Warn().Err(err).Str("rule_id", f.id).Msg("Failed to read CONNECT request")
+		return
+	}
+	clientConn.SetReadDeadline(time.Time{})
+
+	if req.Method != http.MethodConnect {
+		log.Warn().Str("rule_id", f.id).Str("method", req.Method).Msg("Non-CONNECT request on HTTP proxy listener")
+		clientConn.Write([]byte(httpConnectNotAllowed))
+		return
+	}
+
+	target := req.Host
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		// CONNECT 目标缺省端口按 HTTPS 处理
+		target = net.JoinHostPort(target, "443")
+	}
+
+	// 乐观客户端可能在收到 200 前就发数据, 这部分已被 bufio 读走, 建连后回放
+	var early []byte
+	if n := br.Buffered(); n > 0 {
+		early, _ = br.Peek(n)
+	}
+
+	if f.verboseLogging {
+		log.Debug().
+			Str("rule_id", f.id).
+			Str("target", target).
+			Msg("HTTP CONNECT request")
+	}
+
+	if len(f.relayChain) > 0 {
+		f.handleViaTunnel(clientConn, target, early)
+		return
+	}
+	f.handleDirect(clientConn, target, early)
+}
+
+// handleDirect 监听客户端直接拨号目标
+func (f *HTTPConnectForwarder) handleDirect(clientConn net.Conn, target string, early []byte) {
+	targetConn, err := net.DialTimeout("tcp", target, time.Duration(f.cfg.ConnectTimeout)*time.Second)
+	if err != nil {
+		log.Warn().Err(err).Str("target", target).Msg("Failed to connect to CONNECT target")
+		clientConn.Write([]byte(httpConnectBadGateway))
+		return
+	}
+	defer targetConn.Close()
+	tuneTCPConnOpts(targetConn, f.tcpNoDelay, f.tcpKeepAlive)
+
+	if _, err := clientConn.Write([]byte(httpConnectEstablished)); err != nil {
+		return
+	}
+
+	var stat *RuleTraffic
+	if f.trafficCounter != nil {
+		stat = f.trafficCounter.GetOrCreateStat(f.id)
+	}
+
+	if len(early) > 0 {
+		if _, err := targetConn.Write(early); err != nil {
+			return
+		}
+	}
+
+	// 双向转发：任一方向结束时 close 双端触发对端退出，两侧都退出后才返回
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// 客户端 -> 目标 (出站流量)
+	go func() {
+		defer wg.Done()
+		defer targetConn.Close()
+		defer clientConn.Close()
+		copyAndCount(targetConn, clientConn, stat, false)
+	}()
+
+	// 目标 -> 客户端 (入站流量)
+	go func() {
+		defer wg.Done()
+		defer clientConn.Close()
+		defer targetConn.Close()
+		copyAndCount(clientConn, targetConn, stat, true)
+	}()
+
+	wg.Wait()
+}
+
+// handleViaTunnel 经 WebSocket 隧道由中继链上的出口拨号目标
+func (f *HTTPConnectForwarder) handleViaTunnel(clientConn net.Conn, target string, early []byte) {
+	ws := f.wsConnProvider()
+	if ws == nil {
+		log.Warn().Str("rule_id", f.id).Msg("HTTP CONNECT forwarder dropping connection: wsConn unavailable")
+		clientConn.Write([]byte(httpConnectBadGateway))
+		return
+	}
+
+	stream := ws.GetStreams().NewStream(target)
+	defer ws.GetStreams().RemoveStream(stream.ID)
+
+	connectMsg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: stream.ID,
+		Target:   target,
+		RuleID:   f.id, // 用于服务端流量统计
+	}
+	if f.verboseLogging {
+		connectMsg.Flags |= relay.ConnFlagVerbose
+	}
+	nextHop := f.relayChain[0]
+	if f.fallbackGroup != "" {
+		nextHop += "|" + f.fallbackGroup
+	}
+	connectMsg.Payload = []byte(nextHop)
+
+	if err := ws.Send(connectMsg); err != nil {
+		log.Warn().Err(err).Msg("Failed to send connect message")
+		clientConn.Write([]byte(httpConnectBadGateway))
+		return
+	}
+
+	if !f.waitForConnAck(stream, target) {
+		clientConn.Write([]byte(httpConnectBadGateway))
+		return
+	}
+
+	if _, err := clientConn.Write([]byte(httpConnectEstablished)); err != nil {
+		return
+	}
+
+	if len(early) > 0 {
+		if f.trafficCounter != nil {
+			f.trafficCounter.AddBytesOut(f.id, int64(len(early)))
+		}
+		if err := ws.Send(&relay.TunnelMessage{
+			Type:     relay.MsgTypeData,
+			StreamID: stream.ID,
+			Payload:  early,
+		}); err != nil {
+			return
+		}
+	}
+
+	// 双向转发：任一方向结束都关闭对端并等待两侧都退出
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go f.forwardToTunnel(ws, clientConn, stream, &wg)
+	go f.forwardFromTunnel(clientConn, stream, &wg)
+	wg.Wait()
+
+	ws.Send(&relay.TunnelMessage{
+		Type:     relay.MsgTypeClose,
+		StreamID: stream.ID,
+	})
+}
+
+// waitForConnAck 等待连接确认
+func (f *HTTPConnectForwarder) waitForConnAck(stream *relay.Stream, target string) bool {
+	timeout := time.After(time.Duration(f.cfg.ConnectTimeout) * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			log.Warn().
+				Uint32("stream_id", stream.ID).
+				Str("target", target).
+				Strs("relay_chain", f.relayChain).
+				Msg("CONNECT tunnel connect timeout")
+			return false
+		case <-stream.CloseCh:
+			return false
+		case data := <-stream.DataCh:
+			// 这里收到的是来自 handleTunnelMessage 的信号
+			if len(data) == 1 && data[0] == relay.MsgTypeConnAck {
+				return true
+			} else if len(data) >= 1 && data[0] == relay.MsgTypeError {
+				category := relay.ErrCategoryGeneral
+				if len(data) >= 2 {
+					category = data[1]
+				}
+				log.Warn().
+					Uint32("stream_id", stream.ID).
+					Str("target", target).
+					Str("category", relay.ErrCategoryName(category)).
+					Msg("CONNECT tunnel connect rejected")
+				return false
+			}
+		}
+	}
+}
+
+// forwardToTunnel 从客户端转发到隧道
+func (f *HTTPConnectForwarder) forwardToTunnel(ws *relay.WSClientConn, clientConn net.Conn, stream *relay.Stream, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer stream.Close()
+	defer clientConn.Close()
+
+	buf := relay.GetBuffer()
+	defer relay.PutBuffer(buf)
+
+	for {
+		n, err := clientConn.Read((*buf)[relay.HeaderSize:])
+		if err != nil {
+			return
+		}
+
+		if f.trafficCounter != nil {
+			f.trafficCounter.AddBytesOut(f.id, int64(n))
+		}
+
+		msg := &relay.TunnelMessage{
+			Type:     relay.MsgTypeData,
+			StreamID: stream.ID,
+			Payload:  (*buf)[relay.HeaderSize : relay.HeaderSize+n],
+		}
+
+		if err := ws.Send(msg); err != nil {
+			return
+		}
+	}
+}
+
+// forwardFromTunnel 从隧道转发到客户端
+func (f *HTTPConnectForwarder) forwardFromTunnel(clientConn net.Conn, stream *relay.Stream, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer stream.Close()
+	defer clientConn.Close()
+
+	for {
+		select {
+		case data, ok := <-stream.DataCh:
+			if !ok {
+				return
+			}
+			if f.trafficCounter != nil {
+				f.trafficCounter.AddBytesIn(f.id, int64(len(data)))
+			}
+			if _, err := clientConn.Write(data); err != nil {
+				return
+			}
+		case <-stream.CloseCh:
+			return
+		}
+	}
+}
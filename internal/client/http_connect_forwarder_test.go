@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// waitHTTPConnectListen 等待转发器监听就绪并返回实际地址
+func waitHTTPConnectListen(t *testing.T, f *HTTPConnectForwarder) string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.listenerMu.Lock()
+		var addr string
+		if f.listener != nil {
+			addr = f.listener.Addr().String()
+		}
+		f.listenerMu.Unlock()
+		if addr != "" {
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("forwarder never started listening")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// doHTTPConnect 发送 CONNECT 请求并返回状态码和 (成功时) 用于后续隧道读写的 reader
+func doHTTPConnect(t *testing.T, conn net.Conn, target string) (int, *bufio.Reader) {
+	t.Helper()
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatalf("failed to write CONNECT: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("failed to read CONNECT response: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, br
+}
+
+// TestHTTPConnectForwarder_TunnelsData 验证 CONNECT 建连后数据双向透传
+func TestHTTPConnectForwarder_TunnelsData(t *testing.T) {
+	echoAddr := startEchoServer(t)
+
+	cfg := ForwarderSection{ConnectTimeout: 3}
+	f := NewHTTPConnectForwarder("connect-rule", "127.0.0.1:0", nil, cfg, nil, nil, nil)
+	go f.Start()
+	defer f.Stop()
+	listenAddr := waitHTTPConnectListen(t, f)
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	status, br := doHTTPConnect(t, conn, echoAddr)
+	if status != http.StatusOK {
+		t.Fatalf("CONNECT status = %d, want 200", status)
+	}
+
+	payload := []byte("hello http connect")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(payload))
+	if _, err := io.ReadFull(br, buf); err != nil {
+		t.Fatalf("failed to read echo back: %v", err)
+	}
+	if string(buf) != string(payload) {
+		t.Errorf("echoed data = %q, want %q", buf, payload)
+	}
+}
+
+// TestHTTPConnectForwarder_BadGatewayOnDialFailure 验证目标不可达时返回 502
+func TestHTTPConnectForwarder_BadGatewayOnDialFailure(t *testing.T) {
+	// 监听后立即关闭, 得到一个必然拒绝连接的地址
+	deadLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	deadAddr := deadLn.Addr().String()
+	deadLn.Close()
+
+	cfg := ForwarderSection{ConnectTimeout: 2}
+	f := NewHTTPConnectForwarder("connect-rule", "127.0.0.1:0", nil, cfg, nil, nil, nil)
+	go f.Start()
+	defer f.Stop()
+	listenAddr := waitHTTPConnectListen(t, f)
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	status, _ := doHTTPConnect(t, conn, deadAddr)
+	if status != http.StatusBadGateway {
+		t.Errorf("CONNECT status = %d, want 502", status)
+	}
+}
+
+// TestHTTPConnectForwarder_RejectsNonConnect 验证普通请求方法被 405 拒绝
+func TestHTTPConnectForwarder_RejectsNonConnect(t *testing.T) {
+	cfg := ForwarderSection{ConnectTimeout: 2}
+	f := NewHTTPConnectForwarder("connect-rule", "127.0.0.1:0", nil, cfg, nil, nil, nil)
+	go f.Start()
+	defer f.Stop()
+	listenAddr := waitHTTPConnectListen(t, f)
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodGet})
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", resp.StatusCode)
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// listenTCP 创建 TCP 监听。backlog > 0 时使用平台实现设置内核 accept 队列长度
+// (标准库固定使用系统默认, Linux 下为 net.core.somaxconn), <= 0 时沿用标准库行为
+func listenTCP(addr string, backlog int) (net.Listener, error) {
+	return listenTCPOpts(addr, backlog, false)
+}
+
+// listenTCPOpts 创建 TCP 监听, 可选设置 backlog 与 TCP Fast Open。
+// Fast Open 仅 Linux 生效, 其他平台与内核拒绝时静默回退普通监听
+func listenTCPOpts(addr string, backlog int, fastOpen bool) (net.Listener, error) {
+	if backlog > 0 {
+		return listenTCPBacklog(addr, backlog, fastOpen)
+	}
+	if fastOpen {
+		lc := fastOpenListenConfig()
+		return lc.Listen(context.Background(), "tcp", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// applyFastOpenDial 在拨号器上请求 TCP Fast Open Connect (仅 Linux 生效,
+// 内核不支持时静默回退普通连接)
+func applyFastOpenDial(d *net.Dialer, enabled bool) {
+	if enabled {
+		d.Control = fastOpenDialControl
+	}
+}
+
+// isTemporaryAcceptError 判断 accept 错误是否为临时错误。
+// 监听器被关闭 (net.ErrClosed) 或描述符失效为致命错误, accept 循环应退出;
+// 文件描述符耗尽 (EMFILE/ENFILE)、连接在 accept 前被对端中止等退避后可恢复,
+// 未识别的错误也按临时处理, 避免偶发错误误杀转发器
+func isTemporaryAcceptError(err error) bool {
+	if errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.EBADF) || errors.Is(err, syscall.EINVAL) {
+		return false
+	}
+	return true
+}
+
+// acceptBackoff 临时 accept 错误的指数退避: 5ms 起步翻倍至上限 1 秒, accept 成功后重置
+type acceptBackoff struct {
+	delay time.Duration
+}
+
+const (
+	acceptBackoffMin = 5 * time.Millisecond
+	acceptBackoffMax = time.Second
+)
+
+func (b *acceptBackoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = acceptBackoffMin
+	} else {
+		b.delay *= 2
+		if b.delay > acceptBackoffMax {
+			b.delay = acceptBackoffMax
+		}
+	}
+	return b.delay
+}
+
+func (b *acceptBackoff) reset() {
+	b.delay = 0
+}
@@ -0,0 +1,100 @@
+//go:build linux
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/rs/zerolog/log"
+)
+
+// fastOpenQueueLen TCP Fast Open 服务端待握手队列长度
+const fastOpenQueueLen = 256
+
+// tcpFastOpen TCP_FASTOPEN 监听侧选项 (syscall 包未定义)
+const tcpFastOpen = 0x17
+
+// tcpFastOpenConnect TCP_FASTOPEN_CONNECT 选项 (Linux 4.11+, syscall 包未定义)。
+// 设置后 connect 延迟到首次写入, SYN 随数据一起发出
+const tcpFastOpenConnect = 0x1e
+
+// fastOpenListenConfig 返回在 listen 前请求 TCP_FASTOPEN 的 ListenConfig。
+// 内核不支持或被 sysctl 关闭时仅记录 debug 日志, 监听照常建立
+func fastOpenListenConfig() net.ListenConfig {
+	return net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return c.Control(func(fd uintptr) {
+				if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpen, fastOpenQueueLen); err != nil {
+					log.Debug().Err(err).Str("addr", address).Msg("TCP_FASTOPEN not supported, falling back")
+				}
+			})
+		},
+	}
+}
+
+// fastOpenDialControl 在 connect 前的 socket 上请求 TCP_FASTOPEN_CONNECT,
+// 失败时静默回退普通连接
+func fastOpenDialControl(network, address string, c syscall.RawConn) error {
+	return c.Control(func(fd uintptr) {
+		if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpenConnect, 1); err != nil {
+			log.Debug().Err(err).Str("addr", address).Msg("TCP_FASTOPEN_CONNECT not supported, falling back")
+		}
+	})
+}
+
+// listenTCPBacklog 手工 socket/bind/listen 以指定内核 accept 队列长度。
+// 标准库的 backlog 固定取 net.core.somaxconn, 不支持按监听器配置
+func listenTCPBacklog(addr string, backlog int, fastOpen bool) (net.Listener, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := tcpAddr.IP
+	if ip == nil {
+		ip = net.IPv4zero
+	}
+
+	family := syscall.AF_INET
+	var sa syscall.Sockaddr
+	if ip4 := ip.To4(); ip4 != nil {
+		sa4 := &syscall.SockaddrInet4{Port: tcpAddr.Port}
+		copy(sa4.Addr[:], ip4)
+		sa = sa4
+	} else {
+		family = syscall.AF_INET6
+		sa6 := &syscall.SockaddrInet6{Port: tcpAddr.Port}
+		copy(sa6.Addr[:], ip.To16())
+		sa = sa6
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_TCP)
+	if err != nil {
+		return nil, fmt.Errorf("socket: %w", err)
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("setsockopt: %w", err)
+	}
+	if fastOpen {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, tcpFastOpen, fastOpenQueueLen); err != nil {
+			log.Debug().Err(err).Str("addr", addr).Msg("TCP_FASTOPEN not supported, falling back")
+		}
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("bind %s: %w", addr, err)
+	}
+	if err := syscall.Listen(fd, backlog); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("listen %s: %w", addr, err)
+	}
+
+	// FileListener 复制描述符并纳入 netpoll, 原 fd 随 File 关闭
+	f := os.NewFile(uintptr(fd), "listen:"+addr)
+	defer f.Close()
+	return net.FileListener(f)
+}
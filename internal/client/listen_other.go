@@ -0,0 +1,23 @@
+//go:build !linux
+
+package client
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenTCPBacklog 非 Linux 平台不支持按监听器配置 backlog, 回退标准库默认
+func listenTCPBacklog(addr string, _ int, _ bool) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+// fastOpenListenConfig 非 Linux 平台不支持 TCP Fast Open, 回退普通监听
+func fastOpenListenConfig() net.ListenConfig {
+	return net.ListenConfig{}
+}
+
+// fastOpenDialControl 非 Linux 平台为空操作
+func fastOpenDialControl(network, address string, c syscall.RawConn) error {
+	return nil
+}
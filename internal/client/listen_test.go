@@ -0,0 +1,166 @@
+package client
+
+import (
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// acceptStep 脚本化 Accept 的一步: conn 或 err 二选一
+type acceptStep struct {
+	conn net.Conn
+	err  error
+}
+
+// scriptedListener 按脚本返回 Accept 结果, 脚本耗尽后表现为已关闭的监听器
+type scriptedListener struct {
+	steps  chan acceptStep
+	closed chan struct{}
+}
+
+func newScriptedListener(steps ...acceptStep) *scriptedListener {
+	ch := make(chan acceptStep, len(steps))
+	for _, s := range steps {
+		ch <- s
+	}
+	return &scriptedListener{steps: ch, closed: make(chan struct{})}
+}
+
+func (l *scriptedListener) Accept() (net.Conn, error) {
+	select {
+	case s := <-l.steps:
+		return s.conn, s.err
+	default:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *scriptedListener) Close() error   { return nil }
+func (l *scriptedListener) Addr() net.Addr { return &net.TCPAddr{IP: net.IPv4zero} }
+
+func TestAcceptLoop_TemporaryErrorBacksOffAndContinues(t *testing.T) {
+	targetAddr, targetCount := countingListener(t)
+
+	clientSide, serverSide := net.Pipe()
+	clientSide.Close() // 连接立即结束, handleConnection 连上目标后即返回
+
+	ln := newScriptedListener(
+		acceptStep{err: &net.OpError{Op: "accept", Err: syscall.EMFILE}},
+		acceptStep{err: &net.OpError{Op: "accept", Err: syscall.EMFILE}},
+		acceptStep{conn: serverSide},
+	)
+
+	f := NewForwarder("accept-test", "127.0.0.1:0", targetAddr, ForwarderSection{ConnectTimeout: 2}, nil, nil)
+
+	start := time.Now()
+	err := f.acceptLoop(ln, nil)
+	elapsed := time.Since(start)
+
+	// 脚本耗尽后 Accept 返回 net.ErrClosed, 属致命错误, 循环应退出
+	if err == nil {
+		t.Fatal("acceptLoop should return the fatal accept error")
+	}
+
+	f.wg.Wait()
+	if got := atomic.LoadInt64(targetCount); got != 1 {
+		t.Errorf("target connections = %d, want 1 (loop should continue past temporary errors)", got)
+	}
+
+	// 两次临时错误至少退避 5ms + 10ms
+	if elapsed < 15*time.Millisecond {
+		t.Errorf("elapsed = %v, want >= 15ms of backoff before accepting", elapsed)
+	}
+}
+
+func TestAcceptLoop_StopDuringBackoff(t *testing.T) {
+	ln := newScriptedListener(
+		acceptStep{err: &net.OpError{Op: "accept", Err: syscall.EMFILE}},
+	)
+	f := NewForwarder("accept-stop", "127.0.0.1:0", "127.0.0.1:1", ForwarderSection{ConnectTimeout: 1}, nil, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- f.acceptLoop(ln, nil) }()
+
+	time.Sleep(2 * time.Millisecond)
+	close(f.stopCh)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("acceptLoop after stop = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acceptLoop did not exit after stop")
+	}
+}
+
+func TestIsTemporaryAcceptError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"emfile", &net.OpError{Op: "accept", Err: syscall.EMFILE}, true},
+		{"enfile", &net.OpError{Op: "accept", Err: syscall.ENFILE}, true},
+		{"conn_aborted", &net.OpError{Op: "accept", Err: syscall.ECONNABORTED}, true},
+		{"listener_closed", net.ErrClosed, false},
+		{"wrapped_closed", &net.OpError{Op: "accept", Err: net.ErrClosed}, false},
+		{"ebadf", &net.OpError{Op: "accept", Err: syscall.EBADF}, false},
+	}
+	for _, tc := range cases {
+		if got := isTemporaryAcceptError(tc.err); got != tc.want {
+			t.Errorf("%s: isTemporaryAcceptError = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAcceptBackoff_GrowsAndResets(t *testing.T) {
+	var b acceptBackoff
+	if d := b.next(); d != acceptBackoffMin {
+		t.Errorf("first delay = %v, want %v", d, acceptBackoffMin)
+	}
+	if d := b.next(); d != 2*acceptBackoffMin {
+		t.Errorf("second delay = %v, want %v", d, 2*acceptBackoffMin)
+	}
+	for i := 0; i < 20; i++ {
+		b.next()
+	}
+	if d := b.next(); d != acceptBackoffMax {
+		t.Errorf("capped delay = %v, want %v", d, acceptBackoffMax)
+	}
+	b.reset()
+	if d := b.next(); d != acceptBackoffMin {
+		t.Errorf("delay after reset = %v, want %v", d, acceptBackoffMin)
+	}
+}
+
+func TestListenTCP_WithBacklog(t *testing.T) {
+	ln, err := listenTCP("127.0.0.1:0", 4)
+	if err != nil {
+		t.Fatalf("listenTCP with backlog failed: %v", err)
+	}
+	defer ln.Close()
+
+	done := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		close(done)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	conn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("listener with custom backlog never accepted")
+	}
+}
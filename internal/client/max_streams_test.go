@@ -0,0 +1,108 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// recvTunnelMsg 带超时地读一条隧道消息
+func recvTunnelMsg(t *testing.T, ws *relay.WSClientConn, timeout time.Duration) *relay.TunnelMessage {
+	t.Helper()
+	ch := make(chan *relay.TunnelMessage, 1)
+	go func() { ch <- ws.Recv() }()
+	select {
+	case msg := <-ch:
+		if msg == nil {
+			t.Fatal("ws connection closed while waiting for message")
+		}
+		return msg
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for tunnel message")
+		return nil
+	}
+}
+
+// TestHandleIncomingConnect_RefusedBeyondMaxStreams 验证出口达到并发流上限后,
+// 下一个入站 Connect 被直接拒绝并回错误
+func TestHandleIncomingConnect_RefusedBeyondMaxStreams(t *testing.T) {
+	// 目标 echo 服务保持连接打开, 占住出口的流
+	echoLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer echoLn.Close()
+	go func() {
+		for {
+			conn, err := echoLn.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	exitClient := New(&ClientConfig{Forwarder: ForwarderSection{ConnectTimeout: 2, MaxStreams: 1}})
+	exitClient.clientID = "exit-client"
+	wsExit, err := relay.NewWSClientConn(wsSrv.URL, "exit-client", "")
+	if err != nil {
+		t.Fatalf("failed to create exit ws client: %v", err)
+	}
+	if err := wsExit.Connect(); err != nil {
+		t.Fatalf("failed to connect exit ws: %v", err)
+	}
+	defer wsExit.Close()
+	wsExit.GetStreams().SetMaxStreams(exitClient.cfg.Forwarder.MaxStreams)
+	go runTunnelDispatch(exitClient, wsExit)
+
+	wsEntry, err := relay.NewWSClientConn(wsSrv.URL, "entry-client", "")
+	if err != nil {
+		t.Fatalf("failed to create entry ws client: %v", err)
+	}
+	if err := wsEntry.Connect(); err != nil {
+		t.Fatalf("failed to connect entry ws: %v", err)
+	}
+	defer wsEntry.Close()
+
+	sendConnect := func(streamID uint32) {
+		t.Helper()
+		if err := wsEntry.Send(&relay.TunnelMessage{
+			Type:     relay.MsgTypeConnect,
+			StreamID: streamID,
+			Target:   echoLn.Addr().String(),
+			Payload:  []byte("exit-client"),
+		}); err != nil {
+			t.Fatalf("failed to send connect: %v", err)
+		}
+	}
+
+	// 第一条连接占满上限
+	sendConnect(1)
+	msg := recvTunnelMsg(t, wsEntry, 5*time.Second)
+	if msg.Type != relay.MsgTypeConnAck || msg.StreamID != 1 {
+		t.Fatalf("expected ConnAck for stream 1, got type=%d stream=%d", msg.Type, msg.StreamID)
+	}
+
+	// 第二条连接超限, 应收到错误
+	sendConnect(2)
+	msg = recvTunnelMsg(t, wsEntry, 5*time.Second)
+	if msg.Type != relay.MsgTypeError || msg.StreamID != 2 {
+		t.Fatalf("expected Error for stream 2, got type=%d stream=%d", msg.Type, msg.StreamID)
+	}
+	if !strings.Contains(msg.Error, "max concurrent streams") {
+		t.Errorf("error = %q, want max concurrent streams message", msg.Error)
+	}
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// startMetricsServer 启动本机调试端点 (可选, 仅允许绑定回环地址)。
+// 暴露 forwarder 数量、各规则流量计数、活跃连接数与 WebSocket 连接状态,
+// 供运维在宿主机上排障, 不对外提供服务
+func (c *Client) startMetricsServer(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid metrics addr: %w", err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return fmt.Errorf("metrics addr must be a loopback address, got %q", host)
+		}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", c.handleMetrics)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	c.metricsSrv = srv
+	c.metricsAddr = ln.Addr().String()
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Warn().Err(err).Msg("Metrics endpoint stopped unexpectedly")
+		}
+	}()
+
+	log.Info().Str("addr", c.metricsAddr).Msg("Local metrics endpoint started")
+	return nil
+}
+
+// handleMetrics 输出客户端运行状态的 JSON 快照
+func (c *Client) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	forwarderCount := len(c.forwarders)
+	c.mu.RUnlock()
+
+	rules := c.trafficCounter.Snapshot()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].RuleID < rules[j].RuleID })
+
+	var activeConns int32
+	for _, rep := range rules {
+		activeConns = activeConns + rep.ActiveConns
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"client_id":       c.clientID,
+		"ws_connected":    c.getWSConn() != nil,
+		"forwarder_count": forwarderCount,
+		"active_conns":    activeConns,
+		"rules":           rules,
+	})
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fetchMetrics 请求调试端点并解码 JSON 响应
+func fetchMetrics(t *testing.T, addr string) map[string]interface{} {
+	t.Helper()
+	httpClient := &http.Client{Timeout: 2 * time.Second}
+	res, err := httpClient.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to fetch metrics: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("metrics status = %d, want 200", res.StatusCode)
+	}
+
+	var out map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		t.Fatalf("failed to decode metrics: %v", err)
+	}
+	return out
+}
+
+func TestMetricsEndpoint_ReportsForwardersAndTraffic(t *testing.T) {
+	c := New(&ClientConfig{})
+	c.clientID = "metrics-client"
+
+	c.mu.Lock()
+	c.forwarders["rule-1"] = &mockForwarder{listenAddr: ":8080"}
+	c.forwarders["rule-2"] = &mockForwarder{listenAddr: ":8081"}
+	c.mu.Unlock()
+
+	c.trafficCounter.AddBytesIn("rule-1", 100)
+	c.trafficCounter.AddBytesOut("rule-1", 50)
+	c.trafficCounter.IncrementConn("rule-1")
+	c.trafficCounter.AddBytesIn("rule-2", 30)
+
+	if err := c.startMetricsServer("127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to start metrics server: %v", err)
+	}
+	defer c.metricsSrv.Close()
+
+	out := fetchMetrics(t, c.metricsAddr)
+
+	if got := out["forwarder_count"].(float64); got != 2 {
+		t.Errorf("forwarder_count = %v, want 2", got)
+	}
+	if got := out["ws_connected"].(bool); got {
+		t.Error("ws_connected = true, want false without a tunnel")
+	}
+	if got := out["active_conns"].(float64); got != 1 {
+		t.Errorf("active_conns = %v, want 1", got)
+	}
+
+	rules := out["rules"].([]interface{})
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rule entries, got %d", len(rules))
+	}
+	first := rules[0].(map[string]interface{})
+	if first["rule_id"] != "rule-1" || first["bytes_in"].(float64) != 100 || first["bytes_out"].(float64) != 50 {
+		t.Errorf("rule-1 entry = %v, want bytes_in=100 bytes_out=50", first)
+	}
+	second := rules[1].(map[string]interface{})
+	if second["rule_id"] != "rule-2" || second["bytes_in"].(float64) != 30 {
+		t.Errorf("rule-2 entry = %v, want bytes_in=30", second)
+	}
+}
+
+func TestMetricsEndpoint_RejectsNonLoopbackAddr(t *testing.T) {
+	c := New(&ClientConfig{})
+	if err := c.startMetricsServer("0.0.0.0:0"); err == nil {
+		c.metricsSrv.Close()
+		t.Fatal("expected error for non-loopback metrics addr")
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// mirrorQueueSize 单连接镜像队列的帧数上限, 写满后新副本直接丢弃
+const mirrorQueueSize = 256
+
+// mirrorDialTimeout 镜像 tap 的拨号超时
+const mirrorDialTimeout = 5 * time.Second
+
+// trafficMirror 把转发载荷的副本以尽力而为的方式送往 tap 地址 (用于调试/IDS)。
+// 副本经有界队列异步写出, 慢速或不可达的 tap 绝不回压主链路:
+// 拨号失败只告警一次并静默丢弃, 队列满时丢弃新副本。
+// 实现 io.Writer, 供 io.MultiWriter 挂到主复制路径上; Write 永不阻塞也永不报错
+type trafficMirror struct {
+	addr    string
+	ch      chan []byte
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+// newTrafficMirror 创建镜像并启动后台写出协程
+func newTrafficMirror(addr string) *trafficMirror {
+	m := &trafficMirror{
+		addr:   addr,
+		ch:     make(chan []byte, mirrorQueueSize),
+		stopCh: make(chan struct{}),
+	}
+	m.wg.Add(1)
+	go m.run()
+	return m
+}
+
+// Write 把载荷副本排入镜像队列; 队列满时丢弃, 返回值恒为成功以免干扰主路径
+func (m *trafficMirror) Write(p []byte) (int, error) {
+	data := make([]byte, len(p))
+	copy(data, p)
+	select {
+	case m.ch <- data:
+	default:
+		atomic.AddInt64(&m.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Close 停止镜像并等待后台协程退出, 队列中未写出的副本尽力冲刷后丢弃
+func (m *trafficMirror) Close() {
+	close(m.stopCh)
+	m.wg.Wait()
+	if n := atomic.LoadInt64(&m.dropped); n > 0 {
+		log.Debug().Str("tap", m.addr).Int64("dropped", n).Msg("Mirror dropped frames")
+	}
+}
+
+// DroppedFrames 返回因队列满被丢弃的副本帧数
+func (m *trafficMirror) DroppedFrames() int64 {
+	return atomic.LoadInt64(&m.dropped)
+}
+
+func (m *trafficMirror) run() {
+	defer m.wg.Done()
+
+	conn, err := net.DialTimeout("tcp", m.addr, mirrorDialTimeout)
+	if err != nil {
+		log.Warn().Err(err).Str("tap", m.addr).Msg("Mirror tap unreachable, discarding copies")
+		m.discardUntilClosed()
+		return
+	}
+	defer conn.Close()
+
+	for {
+		select {
+		case data := <-m.ch:
+			if _, err := conn.Write(data); err != nil {
+				log.Warn().Err(err).Str("tap", m.addr).Msg("Mirror tap write failed, discarding copies")
+				m.discardUntilClosed()
+				return
+			}
+		case <-m.stopCh:
+			// 停止前把已入队的副本尽力写完
+			for {
+				select {
+				case data := <-m.ch:
+					if _, err := conn.Write(data); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// discardUntilClosed 在 tap 不可用后持续清空队列, 保持 Write 不阻塞
+func (m *trafficMirror) discardUntilClosed() {
+	for {
+		select {
+		case <-m.ch:
+			atomic.AddInt64(&m.dropped, 1)
+		case <-m.stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTapServer 启动镜像 tap 收集器, 返回地址与读取已收字节的函数
+func startTapServer(t *testing.T) (string, func() []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	var received []byte
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				buf := make([]byte, 4096)
+				for {
+					n, err := c.Read(buf)
+					if n > 0 {
+						mu.Lock()
+						received = append(received, buf[:n]...)
+						mu.Unlock()
+					}
+					if err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		out := make([]byte, len(received))
+		copy(out, received)
+		return out
+	}
+}
+
+// startMirrorForwarder 在空闲端口上启动带镜像的 direct 转发器, 返回监听地址
+func startMirrorForwarder(t *testing.T, targetAddr, mirrorAddr string) string {
+	t.Helper()
+	tempListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to allocate port: %v", err)
+	}
+	listenAddr := tempListener.Addr().String()
+	tempListener.Close()
+
+	ready := make(chan struct{}, 1)
+	callback := func(ruleID, status, errMsg string) {
+		if status == "running" {
+			select {
+			case ready <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	f := NewForwarder("mirror-rule", listenAddr, targetAddr, ForwarderSection{ConnectTimeout: 5}, nil, callback)
+	f.SetMirrorAddr(mirrorAddr)
+	go f.Start()
+	t.Cleanup(f.Stop)
+
+	select {
+	case <-ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("forwarder did not start in time")
+	}
+	return listenAddr
+}
+
+func TestForwarder_MirrorsTrafficToTap(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	tapAddr, tapBytes := startTapServer(t)
+	listenAddr := startMirrorForwarder(t, echoAddr, tapAddr)
+
+	conn, err := net.Dial("tcp", listenAddr)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	payload := []byte("mirror me")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	// 主链路不受镜像影响: 回显正常
+	buf := make([]byte, len(payload))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read echo: %v", err)
+	}
+	if string(buf) != string(payload) {
+		t.Errorf("echo = %q, want %q", buf, payload)
+	}
+
+	// tap 应收到两个方向的副本 (请求 + 回显)
+	want := len(payload) * 2
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if len(tapBytes()) >= want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tap received %d bytes, want %d", len(tapBytes()), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwarder_MirrorTapUnreachable_PrimaryUnaffected(t *testing.T) {
+	echoAddr := startEchoServer(t)
+	listenAddr := startMirrorForwarder(t, echoAddr, closedPortAddr(t))
+
+	conn, err := net.Dial("tcp", listenAddr)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	payload := []byte("still works")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	buf := make([]byte, len(payload))
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read echo with unreachable tap: %v", err)
+	}
+	if string(buf) != string(payload) {
+		t.Errorf("echo = %q, want %q", buf, payload)
+	}
+}
+
+func TestTrafficMirror_WriteNeverBlocks(t *testing.T) {
+	// tap 不可达: 副本入队后被后台丢弃, Write 始终立即返回
+	m := newTrafficMirror(closedPortAddr(t))
+	defer m.Close()
+
+	start := time.Now()
+	for i := 0; i < mirrorQueueSize*4; i++ {
+		if n, err := m.Write([]byte(fmt.Sprintf("frame-%d", i))); err != nil || n == 0 {
+			t.Fatalf("mirror write returned n=%d err=%v, want success", n, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("writes took %v, mirror must not block the caller", elapsed)
+	}
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// startPortCheckHarness 搭建端口检查测试环境: 真实 WSServer + 已连接的客户端,
+// 客户端侧只驱动端口检查消息
+func startPortCheckHarness(t *testing.T) (*Client, *relay.WSServer, func()) {
+	t.Helper()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+
+	c := New(&ClientConfig{})
+
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "pc-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+
+	go func() {
+		for {
+			msg := ws.Recv()
+			if msg == nil {
+				return
+			}
+			if msg.Type == relay.MsgTypeCheckPort {
+				c.handleCheckPort(ws, msg)
+			}
+		}
+	}()
+
+	// 等待服务端完成注册, 避免 CheckPort 先于注册执行而直接判为离线
+	deadline := time.Now().Add(2 * time.Second)
+	for !wsServer.IsClientOnline("pc-client") {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not register in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cleanup := func() {
+		ws.Close()
+		wsSrv.Close()
+	}
+	return c, wsServer, cleanup
+}
+
+func TestCheckPort_HeldByAnotherRule_ReportsOwner(t *testing.T) {
+	c, wsServer, cleanup := startPortCheckHarness(t)
+	defer cleanup()
+
+	c.mu.Lock()
+	c.forwarders["owner-rule"] = &mockForwarder{listenAddr: "127.0.0.1:7777"}
+	c.mu.Unlock()
+
+	result := wsServer.CheckPort("pc-client", "127.0.0.1:7777", "other-rule", 3*time.Second)
+	if result.Available {
+		t.Error("expected port held by another rule to be unavailable")
+	}
+	if result.Status != relay.PortStatusUsedByRule {
+		t.Errorf("status = %q, want %q", result.Status, relay.PortStatusUsedByRule)
+	}
+	if result.OwnerRuleID != "owner-rule" {
+		t.Errorf("owner rule = %q, want %q", result.OwnerRuleID, "owner-rule")
+	}
+}
+
+func TestCheckPort_UsedByCurrentRule_Available(t *testing.T) {
+	c, wsServer, cleanup := startPortCheckHarness(t)
+	defer cleanup()
+
+	c.mu.Lock()
+	c.forwarders["owner-rule"] = &mockForwarder{listenAddr: "127.0.0.1:7778"}
+	c.mu.Unlock()
+
+	// 当前规则自己占用的端口在更新时会重启, 应判为可用
+	result := wsServer.CheckPort("pc-client", "127.0.0.1:7778", "owner-rule", 3*time.Second)
+	if !result.Available {
+		t.Errorf("expected port used by current rule to be available, got error %q", result.Error)
+	}
+	if result.Status != relay.PortStatusFree {
+		t.Errorf("status = %q, want %q", result.Status, relay.PortStatusFree)
+	}
+}
+
+func TestCheckPort_FreePort(t *testing.T) {
+	_, wsServer, cleanup := startPortCheckHarness(t)
+	defer cleanup()
+
+	result := wsServer.CheckPort("pc-client", "127.0.0.1:0", "", 3*time.Second)
+	if !result.Available {
+		t.Errorf("expected free port to be available, got error %q", result.Error)
+	}
+	if result.Status != relay.PortStatusFree {
+		t.Errorf("status = %q, want %q", result.Status, relay.PortStatusFree)
+	}
+	if result.OwnerRuleID != "" {
+		t.Errorf("owner rule = %q, want empty", result.OwnerRuleID)
+	}
+}
+
+func TestPortCheckConcurrency_Config(t *testing.T) {
+	if n := portCheckConcurrency(&ClientConfig{}); n != 1 {
+		t.Errorf("default concurrency = %d, want 1 (serialized)", n)
+	}
+	if n := portCheckConcurrency(&ClientConfig{Forwarder: ForwarderSection{PortCheckConcurrency: 4}}); n != 4 {
+		t.Errorf("configured concurrency = %d, want 4", n)
+	}
+	if n := portCheckConcurrency(&ClientConfig{Forwarder: ForwarderSection{PortCheckConcurrency: -1}}); n != 1 {
+		t.Errorf("negative concurrency = %d, want 1", n)
+	}
+}
+
+// TestWithPortCheckSlot_BoundsConcurrency 验证并发端口检查被限制在配置的上限内
+func TestWithPortCheckSlot_BoundsConcurrency(t *testing.T) {
+	c := New(&ClientConfig{Forwarder: ForwarderSection{PortCheckConcurrency: 2}})
+
+	var current, maxSeen int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.withPortCheckSlot(func() {
+				n := atomic.AddInt32(&current, 1)
+				for {
+					m := atomic.LoadInt32(&maxSeen)
+					if n <= m || atomic.CompareAndSwapInt32(&maxSeen, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&current, -1)
+			})
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxSeen); got > 2 {
+		t.Errorf("max concurrent checks = %d, want <= 2", got)
+	}
+	if atomic.LoadInt32(&maxSeen) == 0 {
+		t.Error("no check ever ran")
+	}
+}
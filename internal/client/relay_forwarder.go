@@ -25,11 +25,28 @@ type RelayForwarder struct {
 	// wsConnProvider 每次调用返回最新的 wsConn，避免持有过期引用
 	wsConnProvider WSConnProvider
 	listener       net.Listener
+	pool           *acceptPool
 	listenerMu     sync.Mutex
 	stopCh         chan struct{}
 	wg             sync.WaitGroup
 	trafficCounter *TrafficCounter
 	statusCallback StatusCallback
+
+	// 每规则 TCP 调优参数
+	tcpNoDelay   bool
+	tcpKeepAlive time.Duration
+
+	// poolConns 规则允许出口复用目标连接
+	poolConns bool
+
+	// drainOnClose 规则要求出口关闭前半关排空 (保证尾部数据送达目标)
+	drainOnClose bool
+
+	// verboseLogging 规则开启连接级详细日志 (逐连接 Debug, 默认关闭以降低大规模部署的日志开销)
+	verboseLogging bool
+
+	// fallbackGroup 备用代理组引用 (形如 "@groupID"), 首跳离线时服务端改走该组
+	fallbackGroup string
 }
 
 // NewRelayForwarder 创建中继转发器
@@ -44,12 +61,40 @@ func NewRelayForwarder(id, listenAddr, exitAddr string, relayChain []string, cfg
 		stopCh:         make(chan struct{}),
 		trafficCounter: tc,
 		statusCallback: cb,
+		tcpNoDelay:     true,
+		tcpKeepAlive:   defaultKeepAlivePeriod,
 	}
 }
 
+// SetTCPOptions 设置本规则的 TCP 调优参数 (应在 Start 前调用)
+func (f *RelayForwarder) SetTCPOptions(noDelay bool, keepAlive time.Duration) {
+	f.tcpNoDelay = noDelay
+	f.tcpKeepAlive = keepAlive
+}
+
+// SetPoolConns 标记本规则允许出口复用目标连接 (应在 Start 前调用)
+func (f *RelayForwarder) SetPoolConns(enabled bool) {
+	f.poolConns = enabled
+}
+
+// SetDrainOnClose 标记本规则要求出口关闭前半关排空 (应在 Start 前调用)
+func (f *RelayForwarder) SetDrainOnClose(enabled bool) {
+	f.drainOnClose = enabled
+}
+
+// SetVerboseLogging 标记本规则开启连接级详细日志 (应在 Start 前调用)
+func (f *RelayForwarder) SetVerboseLogging(enabled bool) {
+	f.verboseLogging = enabled
+}
+
+// SetFallbackGroup 设置备用代理组引用 (应在 Start 前调用)
+func (f *RelayForwarder) SetFallbackGroup(group string) {
+	f.fallbackGroup = group
+}
+
 // Start 启动转发器
 func (f *RelayForwarder) Start() error {
-	listener, err := net.Listen("tcp", f.listenAddr)
+	listener, err := listenTCP(f.listenAddr, f.cfg.ListenBacklog)
 	if err != nil {
 		// 上报错误状态
 		if f.statusCallback != nil {
@@ -57,8 +102,13 @@ func (f *RelayForwarder) Start() error {
 		}
 		return err
 	}
+	pool := newAcceptPoolFromConfig(f.cfg, func(conn net.Conn) {
+		f.wg.Add(1)
+		f.handleConnection(conn)
+	})
 	f.listenerMu.Lock()
 	f.listener = listener
+	f.pool = pool
 	f.listenerMu.Unlock()
 
 	// 上报运行状态
@@ -73,6 +123,7 @@ func (f *RelayForwarder) Start() error {
 		Strs("relay_chain", f.relayChain).
 		Msg("Relay forwarder started")
 
+	var backoff acceptBackoff
 	for {
 		select {
 		case <-f.stopCh:
@@ -86,9 +137,31 @@ func (f *RelayForwarder) Start() error {
 			case <-f.stopCh:
 				return nil
 			default:
-				log.Warn().Err(err).Msg("Accept error")
-				continue
 			}
+			// 临时错误退避重试, 致命错误 (监听器被关闭等) 退出
+			if !isTemporaryAcceptError(err) {
+				log.Error().Err(err).Str("id", f.id).Msg("Fatal accept error, stopping relay forwarder")
+				if f.statusCallback != nil {
+					f.statusCallback(f.id, "error", err.Error())
+				}
+				return err
+			}
+			d := backoff.next()
+			log.Warn().Err(err).Dur("backoff", d).Str("id", f.id).Msg("Temporary accept error, backing off")
+			select {
+			case <-f.stopCh:
+				return nil
+			case <-time.After(d):
+			}
+			continue
+		}
+		backoff.reset()
+
+		if pool != nil {
+			if !pool.Submit(conn) {
+				log.Warn().Str("id", f.id).Msg("Accept queue full, connection dropped")
+			}
+			continue
 		}
 
 		f.wg.Add(1)
@@ -103,7 +176,11 @@ func (f *RelayForwarder) Stop() {
 	if f.listener != nil {
 		f.listener.Close()
 	}
+	pool := f.pool
 	f.listenerMu.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
 	f.wg.Wait()
 }
 
@@ -126,7 +203,7 @@ func (f *RelayForwarder) handleConnection(clientConn net.Conn) {
 	defer f.wg.Done()
 	defer clientConn.Close()
 
-	tuneTCPConn(clientConn)
+	tuneTCPConnOpts(clientConn, f.tcpNoDelay, f.tcpKeepAlive)
 
 	// 统计连接数
 	if f.trafficCounter != nil {
@@ -145,10 +222,19 @@ func (f *RelayForwarder) handleConnection(clientConn net.Conn) {
 	stream := ws.GetStreams().NewStream(f.exitAddr)
 	defer ws.GetStreams().RemoveStream(stream.ID)
 
-	log.Debug().
-		Uint32("stream_id", stream.ID).
-		Str("exit", f.exitAddr).
-		Msg("Creating relay tunnel")
+	if f.verboseLogging {
+		log.Debug().
+			Uint32("stream_id", stream.ID).
+			Str("exit", f.exitAddr).
+			Msg("Creating relay tunnel")
+	}
+
+	// fast open: 短暂等待首包随 Connect 一起送往出口, 省掉首字节前的一个完整往返。
+	// 等不到首包 (如服务端先发话的协议) 则正常走 Connect→ConnAck 流程
+	var earlyData []byte
+	if f.cfg.FastOpen {
+		earlyData = readEarlyData(clientConn)
+	}
 
 	// 发送 Connect 请求
 	connectMsg := &relay.TunnelMessage{
@@ -157,10 +243,43 @@ func (f *RelayForwarder) handleConnection(clientConn net.Conn) {
 		Target:   f.exitAddr,
 		RuleID:   f.id, // 用于服务端流量统计
 	}
+	if f.poolConns {
+		connectMsg.Flags |= relay.ConnFlagPoolable
+	}
+	if f.drainOnClose {
+		connectMsg.Flags |= relay.ConnFlagDrainClose
+	}
+	if f.verboseLogging {
+		connectMsg.Flags |= relay.ConnFlagVerbose
+	}
 
 	if len(f.relayChain) > 0 {
-		// 如果有中继链，payload 中携带下一跳信息
-		connectMsg.Payload = []byte(f.relayChain[0])
+		// 如果有中继链，payload 中携带下一跳信息；"|" 后附加可选的备用组引用
+		nextHop := f.relayChain[0]
+		if f.fallbackGroup != "" {
+			nextHop += "|" + f.fallbackGroup
+		}
+		connectMsg.Payload = []byte(nextHop)
+	}
+
+	if len(earlyData) > 0 {
+		connectMsg.Flags |= relay.ConnFlagEarlyData
+		connectMsg.Payload = relay.PackConnectRefs(string(connectMsg.Payload), earlyData)
+		if f.trafficCounter != nil {
+			f.trafficCounter.AddBytesOut(f.id, int64(len(earlyData)))
+		}
+		if f.verboseLogging {
+			log.Debug().
+				Uint32("stream_id", stream.ID).
+				Int("bytes", len(earlyData)).
+				Msg("Sending early data with connect (fast open)")
+		}
+	}
+
+	// 统计标签包在 payload 最外层, 服务端解出后按 (规则, 标签) 细分流量
+	if f.cfg.TrafficLabel != "" {
+		connectMsg.Flags |= relay.ConnFlagLabeled
+		connectMsg.Payload = relay.PackConnectLabel(f.cfg.TrafficLabel, connectMsg.Payload)
 	}
 
 	if err := ws.Send(connectMsg); err != nil {
@@ -173,7 +292,9 @@ func (f *RelayForwarder) handleConnection(clientConn net.Conn) {
 		return
 	}
 
-	log.Debug().Uint32("stream_id", stream.ID).Msg("Relay tunnel established")
+	if f.verboseLogging {
+		log.Debug().Uint32("stream_id", stream.ID).Msg("Relay tunnel established")
+	}
 
 	// 双向转发：任一方向结束都关闭对端并等待两侧都退出
 	var wg sync.WaitGroup
@@ -210,11 +331,17 @@ func (f *RelayForwarder) waitForConnAck(stream *relay.Stream) bool {
 			// 这里收到的是来自 handleTunnelMessage 的信号
 			if len(data) == 1 && data[0] == relay.MsgTypeConnAck {
 				return true
-			} else if len(data) == 1 && data[0] == relay.MsgTypeError {
+			} else if len(data) >= 1 && data[0] == relay.MsgTypeError {
+				// 第二个字节为错误类别 (超时/拒绝/一般), 旧格式单字节按一般错误处理
+				category := relay.ErrCategoryGeneral
+				if len(data) >= 2 {
+					category = data[1]
+				}
 				log.Warn().
 					Uint32("stream_id", stream.ID).
 					Str("exit_addr", f.exitAddr).
 					Strs("relay_chain", f.relayChain).
+					Str("category", relay.ErrCategoryName(category)).
 					Msg("Connect rejected")
 				return false
 			}
@@ -278,13 +405,25 @@ func (f *RelayForwarder) forwardFromTunnel(clientConn net.Conn, stream *relay.St
 	}
 }
 
-// tuneTCPConn 调校 TCP 连接：开启 KeepAlive（感知半开连接）和 NoDelay（关闭 Nagle，降低小包延迟）
+// defaultKeepAlivePeriod 默认 TCP KeepAlive 间隔
+const defaultKeepAlivePeriod = 30 * time.Second
+
+// tuneTCPConn 按默认参数调校 TCP 连接：开启 KeepAlive（感知半开连接）和 NoDelay（关闭 Nagle，降低小包延迟）
 func tuneTCPConn(conn net.Conn) {
+	tuneTCPConnOpts(conn, true, defaultKeepAlivePeriod)
+}
+
+// tuneTCPConnOpts 按规则参数调校 TCP 连接。keepAlive <= 0 时关闭 KeepAlive。
+func tuneTCPConnOpts(conn net.Conn, noDelay bool, keepAlive time.Duration) {
 	tc, ok := conn.(*net.TCPConn)
 	if !ok {
 		return
 	}
-	_ = tc.SetKeepAlive(true)
-	_ = tc.SetKeepAlivePeriod(30 * time.Second)
-	_ = tc.SetNoDelay(true)
+	if keepAlive > 0 {
+		_ = tc.SetKeepAlive(true)
+		_ = tc.SetKeepAlivePeriod(keepAlive)
+	} else {
+		_ = tc.SetKeepAlive(false)
+	}
+	_ = tc.SetNoDelay(noDelay)
 }
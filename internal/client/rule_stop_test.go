@@ -0,0 +1,146 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// startRuleStopHarness 搭建规则迁移测试环境: 真实 WSServer + 已连接并
+// 处理隧道消息的 Client (扮演迁移前持有规则的旧客户端)
+func startRuleStopHarness(t *testing.T) (*Client, *relay.WSServer, func()) {
+	t.Helper()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+
+	c := New(&ClientConfig{
+		Forwarder: ForwarderSection{ConnectTimeout: 3},
+	})
+	c.clientID = "old-client"
+
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "old-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+	c.wsConn.Store(ws)
+	go c.handleTunnelMessages()
+
+	deadline := time.Now().Add(time.Second)
+	for !wsServer.IsClientOnline("old-client") {
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered on ws server")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cleanup := func() {
+		ws.Close()
+		wsSrv.Close()
+	}
+	return c, wsServer, cleanup
+}
+
+func TestStopRuleAndWait_ActiveConnectionClosedDuringMove(t *testing.T) {
+	c, wsServer, cleanup := startRuleStopHarness(t)
+	defer cleanup()
+
+	// 目标服务: 接受连接后一直持有, 模拟长连接
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen target: %v", err)
+	}
+	defer target.Close()
+	established := make(chan net.Conn, 1)
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		buf := make([]byte, 1)
+		conn.Read(buf)
+		established <- conn
+	}()
+
+	// 旧客户端上运行待迁移的规则
+	f := NewForwarder("moved-rule", "127.0.0.1:0", target.Addr().String(),
+		ForwarderSection{ConnectTimeout: 3}, nil, nil)
+	go f.Start()
+	var listenAddr string
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.listenerMu.Lock()
+		if f.listener != nil {
+			listenAddr = f.listener.Addr().String()
+		}
+		f.listenerMu.Unlock()
+		if listenAddr != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("forwarder never started listening")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	c.mu.Lock()
+	c.forwarders["moved-rule"] = f
+	c.mu.Unlock()
+
+	// 通过规则建立一条活跃连接
+	conn, err := net.Dial("tcp", listenAddr)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Fatalf("failed to write through forwarder: %v", err)
+	}
+	select {
+	case <-established:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection never reached target through forwarder")
+	}
+
+	// 迁移: 服务端要求旧客户端停止规则并等待确认
+	if err := wsServer.StopRuleAndWait("old-client", "moved-rule", 3*time.Second); err != nil {
+		t.Fatalf("StopRuleAndWait failed: %v", err)
+	}
+
+	// 活跃连接应在停止过程中被关闭
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err == nil {
+		t.Error("active connection should be closed during rule move")
+	}
+
+	// 规则已从旧客户端移除, 监听端口已释放
+	c.mu.RLock()
+	_, running := c.forwarders["moved-rule"]
+	c.mu.RUnlock()
+	if running {
+		t.Error("forwarder should be removed from old client")
+	}
+	ln, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		t.Errorf("listen port should be released after rule stop: %v", err)
+	} else {
+		ln.Close()
+	}
+}
+
+func TestStopRuleAndWait_RuleNotRunningStillConfirms(t *testing.T) {
+	_, wsServer, cleanup := startRuleStopHarness(t)
+	defer cleanup()
+
+	// 旧客户端本地没有该规则 (比如从未启动成功) 时也应回报确认而不是超时
+	if err := wsServer.StopRuleAndWait("old-client", "no-such-rule", 2*time.Second); err != nil {
+		t.Fatalf("StopRuleAndWait for absent rule should confirm, got: %v", err)
+	}
+}
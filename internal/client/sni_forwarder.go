@@ -0,0 +1,386 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sniHelloTimeout 读取 ClientHello 的超时，避免慢客户端占住 goroutine
+const sniHelloTimeout = 5 * time.Second
+
+// maxTLSRecordLen TLS 记录体的最大长度 (RFC 8446: 2^14 + 冗余)
+const maxTLSRecordLen = 16384 + 256
+
+// SNIForwarder 单端口多后端转发器:
+// 窥探 TLS ClientHello 中的 SNI (不解密), 按 SNI -> 目标映射选择后端后原样拼接转发
+type SNIForwarder struct {
+	id         string
+	listenAddr string
+	sniMap     map[string]string
+	cfg        ForwarderSection
+
+	listener       net.Listener
+	pool           *acceptPool
+	listenerMu     sync.Mutex
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+	trafficCounter *TrafficCounter
+	statusCallback StatusCallback
+
+	// 每规则 TCP 调优参数
+	tcpNoDelay   bool
+	tcpKeepAlive time.Duration
+}
+
+func NewSNIForwarder(id, listenAddr string, sniMap map[string]string, cfg ForwarderSection, tc *TrafficCounter, cb StatusCallback) *SNIForwarder {
+	return &SNIForwarder{
+		id:             id,
+		listenAddr:     listenAddr,
+		sniMap:         sniMap,
+		cfg:            cfg,
+		stopCh:         make(chan struct{}),
+		trafficCounter: tc,
+		statusCallback: cb,
+		tcpNoDelay:     true,
+		tcpKeepAlive:   defaultKeepAlivePeriod,
+	}
+}
+
+// SetTCPOptions 设置本规则的 TCP 调优参数 (应在 Start 前调用)
+func (f *SNIForwarder) SetTCPOptions(noDelay bool, keepAlive time.Duration) {
+	f.tcpNoDelay = noDelay
+	f.tcpKeepAlive = keepAlive
+}
+
+func (f *SNIForwarder) Start() error {
+	listener, err := listenTCP(f.listenAddr, f.cfg.ListenBacklog)
+	if err != nil {
+		if f.statusCallback != nil {
+			f.statusCallback(f.id, "error", err.Error())
+		}
+		return err
+	}
+	pool := newAcceptPoolFromConfig(f.cfg, func(conn net.Conn) {
+		f.wg.Add(1)
+		f.handleConnection(conn)
+	})
+	f.listenerMu.Lock()
+	f.listener = listener
+	f.pool = pool
+	f.listenerMu.Unlock()
+
+	if f.statusCallback != nil {
+		f.statusCallback(f.id, "running", "")
+	}
+
+	log.Info().
+		Str("id", f.id).
+		Str("listen", f.listenAddr).
+		Int("sni_entries", len(f.sniMap)).
+		Msg("SNI forwarder started")
+
+	var backoff acceptBackoff
+	for {
+		select {
+		case <-f.stopCh:
+			return nil
+		default:
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-f.stopCh:
+				return nil
+			default:
+			}
+			if !isTemporaryAcceptError(err) {
+				log.Error().Err(err).Str("id", f.id).Msg("Fatal accept error, stopping SNI forwarder")
+				if f.statusCallback != nil {
+					f.statusCallback(f.id, "error", err.Error())
+				}
+				return err
+			}
+			d := backoff.next()
+			log.Warn().Err(err).Dur("backoff", d).Str("id", f.id).Msg("Temporary accept error, backing off")
+			select {
+			case <-f.stopCh:
+				return nil
+			case <-time.After(d):
+			}
+			continue
+		}
+		backoff.reset()
+
+		if pool != nil {
+			if !pool.Submit(conn) {
+				log.Warn().Str("id", f.id).Msg("Accept queue full, connection dropped")
+			}
+			continue
+		}
+
+		f.wg.Add(1)
+		go f.handleConnection(conn)
+	}
+}
+
+func (f *SNIForwarder) Stop() {
+	close(f.stopCh)
+	f.listenerMu.Lock()
+	if f.listener != nil {
+		f.listener.Close()
+	}
+	pool := f.pool
+	f.listenerMu.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
+	f.wg.Wait()
+}
+
+// GetConfigHash 返回配置的哈希值，用于比较配置是否变化
+func (f *SNIForwarder) GetConfigHash() string {
+	keys := make([]string, 0, len(f.sniMap))
+	for k := range f.sniMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("sni:")
+	sb.WriteString(f.listenAddr)
+	for _, k := range keys {
+		sb.WriteString(":")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(f.sniMap[k])
+	}
+	return sb.String()
+}
+
+// GetListenAddr 返回监听地址
+func (f *SNIForwarder) GetListenAddr() string {
+	return f.listenAddr
+}
+
+func (f *SNIForwarder) handleConnection(clientConn net.Conn) {
+	defer f.wg.Done()
+	defer clientConn.Close()
+
+	tuneTCPConnOpts(clientConn, f.tcpNoDelay, f.tcpKeepAlive)
+
+	// 统计连接数
+	if f.trafficCounter != nil {
+		f.trafficCounter.IncrementConn(f.id)
+		defer f.trafficCounter.DecrementConn(f.id)
+	}
+
+	// 读取并缓存 ClientHello，解析 SNI (不解密)
+	clientConn.SetReadDeadline(time.Now().Add(sniHelloTimeout))
+	hello, sni, err := readClientHelloSNI(clientConn)
+	if err != nil {
+		log.Warn().Err(err).Str("rule_id", f.id).Msg("Failed to read ClientHello")
+		return
+	}
+	clientConn.SetReadDeadline(time.Time{})
+
+	targetAddr, ok := matchSNITarget(f.sniMap, sni)
+	if !ok {
+		log.Warn().Str("rule_id", f.id).Str("sni", sni).Msg("No target for SNI")
+		return
+	}
+
+	// 连接目标
+	targetConn, err := net.DialTimeout("tcp", targetAddr, time.Duration(f.cfg.ConnectTimeout)*time.Second)
+	if err != nil {
+		log.Warn().Err(err).Str("target", targetAddr).Msg("Failed to connect to target")
+		return
+	}
+	defer targetConn.Close()
+	tuneTCPConnOpts(targetConn, f.tcpNoDelay, f.tcpKeepAlive)
+
+	var stat *RuleTraffic
+	if f.trafficCounter != nil {
+		stat = f.trafficCounter.GetOrCreateStat(f.id)
+	}
+
+	// 先把缓存的 ClientHello 回放给目标，再进入双向拼接
+	if _, err := targetConn.Write(hello); err != nil {
+		return
+	}
+	if stat != nil {
+		atomic.AddInt64(&stat.BytesOut, int64(len(hello)))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// 客户端 -> 目标 (出站流量)
+	go func() {
+		defer wg.Done()
+		defer targetConn.Close()
+		defer clientConn.Close()
+		copyAndCount(targetConn, clientConn, stat, false)
+	}()
+
+	// 目标 -> 客户端 (入站流量)
+	go func() {
+		defer wg.Done()
+		defer clientConn.Close()
+		defer targetConn.Close()
+		copyAndCount(clientConn, targetConn, stat, true)
+	}()
+
+	wg.Wait()
+}
+
+// matchSNITarget 按优先级匹配: 精确 > *.suffix 通配 > * 兜底
+func matchSNITarget(sniMap map[string]string, sni string) (string, bool) {
+	if target, ok := sniMap[sni]; ok {
+		return target, true
+	}
+	// *.example.com 匹配一级子域 (与 x509 通配证书语义一致)
+	if i := strings.Index(sni, "."); i > 0 {
+		if target, ok := sniMap["*"+sni[i:]]; ok {
+			return target, true
+		}
+	}
+	if target, ok := sniMap["*"]; ok {
+		return target, true
+	}
+	return "", false
+}
+
+// readClientHelloSNI 从 conn 读取第一条 TLS 握手记录并解析 SNI。
+// 返回已读取的原始字节 (用于回放给目标) 和 SNI 主机名。
+func readClientHelloSNI(conn net.Conn) ([]byte, string, error) {
+	header := make([]byte, 5)
+	if _, err := readFull(conn, header); err != nil {
+		return nil, "", fmt.Errorf("read record header: %w", err)
+	}
+
+	// ContentType 0x16 = handshake
+	if header[0] != 0x16 {
+		return nil, "", fmt.Errorf("not a TLS handshake record (type 0x%02x)", header[0])
+	}
+
+	recLen := int(binary.BigEndian.Uint16(header[3:5]))
+	if recLen == 0 || recLen > maxTLSRecordLen {
+		return nil, "", fmt.Errorf("invalid TLS record length %d", recLen)
+	}
+
+	body := make([]byte, recLen)
+	if _, err := readFull(conn, body); err != nil {
+		return nil, "", fmt.Errorf("read record body: %w", err)
+	}
+
+	sni, err := parseClientHelloSNI(body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return append(header, body...), sni, nil
+}
+
+func readFull(conn net.Conn, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := conn.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// parseClientHelloSNI 在握手消息体中定位 server_name 扩展
+func parseClientHelloSNI(data []byte) (string, error) {
+	// HandshakeType(1) + Length(3)
+	if len(data) < 4 || data[0] != 0x01 {
+		return "", fmt.Errorf("not a ClientHello")
+	}
+	data = data[4:]
+
+	// client_version(2) + random(32)
+	if len(data) < 34 {
+		return "", fmt.Errorf("ClientHello too short")
+	}
+	data = data[34:]
+
+	// session_id
+	if len(data) < 1 {
+		return "", fmt.Errorf("truncated session_id")
+	}
+	sessLen := int(data[0])
+	if len(data) < 1+sessLen {
+		return "", fmt.Errorf("truncated session_id")
+	}
+	data = data[1+sessLen:]
+
+	// cipher_suites
+	if len(data) < 2 {
+		return "", fmt.Errorf("truncated cipher_suites")
+	}
+	csLen := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+csLen {
+		return "", fmt.Errorf("truncated cipher_suites")
+	}
+	data = data[2+csLen:]
+
+	// compression_methods
+	if len(data) < 1 {
+		return "", fmt.Errorf("truncated compression_methods")
+	}
+	compLen := int(data[0])
+	if len(data) < 1+compLen {
+		return "", fmt.Errorf("truncated compression_methods")
+	}
+	data = data[1+compLen:]
+
+	// extensions
+	if len(data) < 2 {
+		return "", fmt.Errorf("no extensions")
+	}
+	extLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if len(data) < extLen {
+		return "", fmt.Errorf("truncated extensions")
+	}
+	data = data[:extLen]
+
+	for len(data) >= 4 {
+		extType := binary.BigEndian.Uint16(data)
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		data = data[4:]
+		if len(data) < length {
+			return "", fmt.Errorf("truncated extension")
+		}
+
+		// extension 0 = server_name
+		if extType == 0 {
+			ext := data[:length]
+			// ServerNameList length(2) + type(1) + name length(2)
+			if len(ext) < 5 || ext[2] != 0 {
+				return "", fmt.Errorf("malformed server_name extension")
+			}
+			nameLen := int(binary.BigEndian.Uint16(ext[3:5]))
+			if len(ext) < 5+nameLen {
+				return "", fmt.Errorf("truncated server_name")
+			}
+			return string(ext[5 : 5+nameLen]), nil
+		}
+
+		data = data[length:]
+	}
+
+	return "", fmt.Errorf("no SNI in ClientHello")
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+)
+
+// captureClientHello 通过 net.Pipe 捕获带指定 SNI 的真实 ClientHello 字节
+func captureClientHello(t *testing.T, sni string) []byte {
+	t.Helper()
+
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+	defer serverSide.Close()
+
+	tlsConn := tls.Client(clientSide, &tls.Config{
+		ServerName:         sni,
+		InsecureSkipVerify: true,
+	})
+	go tlsConn.Handshake()
+
+	serverSide.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 8192)
+	n, err := serverSide.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to capture ClientHello: %v", err)
+	}
+	return buf[:n]
+}
+
+// startEchoBackend 启动一个回显后端，并把收到的第一段数据上报到 channel
+func startEchoBackend(t *testing.T) (string, chan []byte, func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start backend: %v", err)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				buf := make([]byte, 8192)
+				n, err := c.Read(buf)
+				if err != nil {
+					return
+				}
+				select {
+				case received <- buf[:n]:
+				default:
+				}
+				c.Write(buf[:n])
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), received, func() { ln.Close() }
+}
+
+func TestSNIForwarder_RoutesBySNI(t *testing.T) {
+	backendA, receivedA, cleanupA := startEchoBackend(t)
+	defer cleanupA()
+	backendB, receivedB, cleanupB := startEchoBackend(t)
+	defer cleanupB()
+
+	// 获取一个可用端口
+	tempListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create temp listener: %v", err)
+	}
+	listenAddr := tempListener.Addr().String()
+	tempListener.Close()
+
+	f := NewSNIForwarder("sni-rule", listenAddr, map[string]string{
+		"a.example.com": backendA,
+		"b.example.com": backendB,
+	}, ForwarderSection{ConnectTimeout: 5}, nil, nil)
+
+	go f.Start()
+	defer f.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	cases := []struct {
+		sni      string
+		received chan []byte
+	}{
+		{"a.example.com", receivedA},
+		{"b.example.com", receivedB},
+	}
+
+	for _, tc := range cases {
+		hello := captureClientHello(t, tc.sni)
+
+		conn, err := net.Dial("tcp", listenAddr)
+		if err != nil {
+			t.Fatalf("failed to dial forwarder: %v", err)
+		}
+		if _, err := conn.Write(hello); err != nil {
+			t.Fatalf("failed to send hello: %v", err)
+		}
+
+		// 后端应该收到原始 ClientHello
+		select {
+		case got := <-tc.received:
+			if !bytes.Equal(got, hello) {
+				t.Errorf("SNI %s: backend received different bytes", tc.sni)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("SNI %s: backend did not receive data", tc.sni)
+		}
+
+		// 回显数据应该原路返回
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		echoed := make([]byte, len(hello))
+		if _, err := readFull(conn, echoed); err != nil {
+			t.Fatalf("SNI %s: failed to read echo: %v", tc.sni, err)
+		}
+		if !bytes.Equal(echoed, hello) {
+			t.Errorf("SNI %s: echoed bytes mismatch", tc.sni)
+		}
+		conn.Close()
+	}
+}
+
+func TestSNIForwarder_UnknownSNIDropped(t *testing.T) {
+	backendA, receivedA, cleanupA := startEchoBackend(t)
+	defer cleanupA()
+
+	tempListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create temp listener: %v", err)
+	}
+	listenAddr := tempListener.Addr().String()
+	tempListener.Close()
+
+	f := NewSNIForwarder("sni-rule", listenAddr, map[string]string{
+		"a.example.com": backendA,
+	}, ForwarderSection{ConnectTimeout: 5}, nil, nil)
+
+	go f.Start()
+	defer f.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	hello := captureClientHello(t, "unknown.example.org")
+
+	conn, err := net.Dial("tcp", listenAddr)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+	conn.Write(hello)
+
+	// 连接应被关闭，后端不应收到任何数据
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("expected connection to be closed for unknown SNI")
+	}
+	select {
+	case <-receivedA:
+		t.Error("backend should not receive data for unknown SNI")
+	default:
+	}
+}
+
+func TestParseClientHelloSNI(t *testing.T) {
+	raw := captureClientHello(t, "example.com")
+
+	// 跳过 5 字节记录头，剩下的是握手消息体
+	sni, err := parseClientHelloSNI(raw[5:])
+	if err != nil {
+		t.Fatalf("parseClientHelloSNI failed: %v", err)
+	}
+	if sni != "example.com" {
+		t.Errorf("expected SNI 'example.com', got '%s'", sni)
+	}
+}
+
+func TestParseClientHelloSNI_NotTLS(t *testing.T) {
+	if _, _, err := readClientHelloSNI(newStaticConn([]byte("GET / HTTP/1.1\r\n"))); err == nil {
+		t.Error("expected error for non-TLS data")
+	}
+}
+
+// staticConn 提供固定字节流的 net.Conn 桩
+type staticConn struct {
+	net.Conn
+	buf *bytes.Reader
+}
+
+func newStaticConn(data []byte) *staticConn {
+	return &staticConn{buf: bytes.NewReader(data)}
+}
+
+func (c *staticConn) Read(p []byte) (int, error)        { return c.buf.Read(p) }
+func (c *staticConn) SetReadDeadline(t time.Time) error { return nil }
+
+func TestMatchSNITarget(t *testing.T) {
+	sniMap := map[string]string{
+		"exact.example.com": "10.0.0.1:443",
+		"*.example.com":     "10.0.0.2:443",
+		"*":                 "10.0.0.3:443",
+	}
+
+	tests := []struct {
+		sni    string
+		target string
+	}{
+		{"exact.example.com", "10.0.0.1:443"},  // 精确优先
+		{"other.example.com", "10.0.0.2:443"},  // 通配
+		{"something.else.org", "10.0.0.3:443"}, // 兜底
+	}
+	for _, tc := range tests {
+		got, ok := matchSNITarget(sniMap, tc.sni)
+		if !ok || got != tc.target {
+			t.Errorf("matchSNITarget(%s) = %s, want %s", tc.sni, got, tc.target)
+		}
+	}
+
+	// 无兜底时未知 SNI 应失配
+	delete(sniMap, "*")
+	if _, ok := matchSNITarget(sniMap, "no.match.org"); ok {
+		t.Error("expected no match without catch-all")
+	}
+}
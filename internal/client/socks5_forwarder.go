@@ -0,0 +1,486 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// socksHandshakeTimeout 完成 SOCKS5 握手的超时, 避免慢客户端占住 goroutine
+const socksHandshakeTimeout = 10 * time.Second
+
+// SOCKS5 协议常量 (RFC 1928)
+const (
+	socksVersion5     = 0x05
+	socksCmdConnect   = 0x01
+	socksAuthNone     = 0x00
+	socksAtypIPv4     = 0x01
+	socksAtypDomain   = 0x03
+	socksAtypIPv6     = 0x04
+	socksRepSuccess   = 0x00
+	socksRepFailure   = 0x01
+	socksRepRefused   = 0x05
+	socksRepCmdNotSup = 0x07
+)
+
+// Socks5Forwarder SOCKS5 监听转发器:
+// 监听端口讲 SOCKS5 协议, 目标地址由本地应用在 CONNECT 请求中动态指定,
+// 出口由规则决定 — 无中继链时监听客户端直接拨号, 有中继链时经隧道由链上出口拨号
+type Socks5Forwarder struct {
+	id         string
+	listenAddr string
+	relayChain []string
+	cfg        ForwarderSection
+
+	// wsConnProvider 每次调用返回最新的 wsConn (仅中继出口使用)
+	wsConnProvider WSConnProvider
+	listener       net.Listener
+	pool           *acceptPool
+	listenerMu     sync.Mutex
+	stopCh         chan struct{}
+	wg             sync.WaitGroup
+	trafficCounter *TrafficCounter
+	statusCallback StatusCallback
+
+	// 每规则 TCP 调优参数
+	tcpNoDelay   bool
+	tcpKeepAlive time.Duration
+
+	// verboseLogging 规则开启连接级详细日志
+	verboseLogging bool
+
+	// fallbackGroup 备用代理组引用 (形如 "@groupID"), 首跳离线时服务端改走该组
+	fallbackGroup string
+}
+
+func NewSocks5Forwarder(id, listenAddr string, relayChain []string, cfg ForwarderSection, provider WSConnProvider, tc *TrafficCounter, cb StatusCallback) *Socks5Forwarder {
+	return &Socks5Forwarder{
+		id:             id,
+		listenAddr:     listenAddr,
+		relayChain:     relayChain,
+		cfg:            cfg,
+		wsConnProvider: provider,
+		stopCh:         make(chan struct{}),
+		trafficCounter: tc,
+		statusCallback: cb,
+		tcpNoDelay:     true,
+		tcpKeepAlive:   defaultKeepAlivePeriod,
+	}
+}
+
+// SetTCPOptions 设置本规则的 TCP 调优参数 (应在 Start 前调用)
+func (f *Socks5Forwarder) SetTCPOptions(noDelay bool, keepAlive time.Duration) {
+	f.tcpNoDelay = noDelay
+	f.tcpKeepAlive = keepAlive
+}
+
+// SetVerboseLogging 标记本规则开启连接级详细日志 (应在 Start 前调用)
+func (f *Socks5Forwarder) SetVerboseLogging(enabled bool) {
+	f.verboseLogging = enabled
+}
+
+// SetFallbackGroup 设置备用代理组引用 (应在 Start 前调用)
+func (f *Socks5Forwarder) SetFallbackGroup(group string) {
+	f.fallbackGroup = group
+}
+
+func (f *Socks5Forwarder) Start() error {
+	listener, err := listenTCP(f.listenAddr, f.cfg.ListenBacklog)
+	if err != nil {
+		if f.statusCallback != nil {
+			f.statusCallback(f.id, "error", err.Error())
+		}
+		return err
+	}
+	pool := newAcceptPoolFromConfig(f.cfg, func(conn net.Conn) {
+		f.wg.Add(1)
+		f.handleConnection(conn)
+	})
+	f.listenerMu.Lock()
+	f.listener = listener
+	f.pool = pool
+	f.listenerMu.Unlock()
+
+	if f.statusCallback != nil {
+		f.statusCallback(f.id, "running", "")
+	}
+
+	log.Info().
+		Str("id", f.id).
+		Str("listen", f.listenAddr).
+		Strs("relay_chain", f.relayChain).
+		Msg("SOCKS5 forwarder started")
+
+	var backoff acceptBackoff
+	for {
+		select {
+		case <-f.stopCh:
+			return nil
+		default:
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-f.stopCh:
+				return nil
+			default:
+			}
+			if !isTemporaryAcceptError(err) {
+				log.Error().Err(err).Str("id", f.id).Msg("Fatal accept error, stopping SOCKS5 forwarder")
+				if f.statusCallback != nil {
+					f.statusCallback(f.id, "error", err.Error())
+				}
+				return err
+			}
+			d := backoff.next()
+			log.Warn().Err(err).Dur("backoff", d).Str("id", f.id).Msg("Temporary accept error, backing off")
+			select {
+			case <-f.stopCh:
+				return nil
+			case <-time.After(d):
+			}
+			continue
+		}
+		backoff.reset()
+
+		if pool != nil {
+			if !pool.Submit(conn) {
+				log.Warn().Str("id", f.id).Msg("Accept queue full, connection dropped")
+			}
+			continue
+		}
+
+		f.wg.Add(1)
+		go f.handleConnection(conn)
+	}
+}
+
+func (f *Socks5Forwarder) Stop() {
+	close(f.stopCh)
+	f.listenerMu.Lock()
+	if f.listener != nil {
+		f.listener.Close()
+	}
+	pool := f.pool
+	f.listenerMu.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
+	f.wg.Wait()
+}
+
+// GetConfigHash 返回配置的哈希值，用于比较配置是否变化
+func (f *Socks5Forwarder) GetConfigHash() string {
+	return "socks5:" + f.listenAddr + ":" + strings.Join(f.relayChain, ",")
+}
+
+// GetListenAddr 返回监听地址
+func (f *Socks5Forwarder) GetListenAddr() string {
+	return f.listenAddr
+}
+
+func (f *Socks5Forwarder) handleConnection(clientConn net.Conn) {
+	defer f.wg.Done()
+	defer clientConn.Close()
+
+	tuneTCPConnOpts(clientConn, f.tcpNoDelay, f.tcpKeepAlive)
+
+	// 统计连接数
+	if f.trafficCounter != nil {
+		f.trafficCounter.IncrementConn(f.id)
+		defer f.trafficCounter.DecrementConn(f.id)
+	}
+
+	// SOCKS5 握手与请求解析 (带超时, 解析出本连接的动态目标)
+	clientConn.SetReadDeadline(time.Now().Add(socksHandshakeTimeout))
+	target, err := readSocksConnect(clientConn)
+	if err != nil {
+		log.Warn().Err(err).Str("rule_id", f.id).Msg("SOCKS5 handshake failed")
+		return
+	}
+	clientConn.SetReadDeadline(time.Time{})
+
+	if f.verboseLogging {
+		log.Debug().
+			Str("rule_id", f.id).
+			Str("target", target).
+			Msg("SOCKS5 connect request")
+	}
+
+	if len(f.relayChain) > 0 {
+		f.handleViaTunnel(clientConn, target)
+		return
+	}
+	f.handleDirect(clientConn, target)
+}
+
+// handleDirect 监听客户端直接拨号目标
+func (f *Socks5Forwarder) handleDirect(clientConn net.Conn, target string) {
+	targetConn, err := net.DialTimeout("tcp", target, time.Duration(f.cfg.ConnectTimeout)*time.Second)
+	if err != nil {
+		log.Warn().Err(err).Str("target", target).Msg("Failed to connect to SOCKS5 target")
+		writeSocksReply(clientConn, socksRepRefused)
+		return
+	}
+	defer targetConn.Close()
+	tuneTCPConnOpts(targetConn, f.tcpNoDelay, f.tcpKeepAlive)
+
+	if err := writeSocksReply(clientConn, socksRepSuccess); err != nil {
+		return
+	}
+
+	var stat *RuleTraffic
+	if f.trafficCounter != nil {
+		stat = f.trafficCounter.GetOrCreateStat(f.id)
+	}
+
+	// 双向转发：任一方向结束时 close 双端触发对端退出，两侧都退出后才返回
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	// 客户端 -> 目标 (出站流量)
+	go func() {
+		defer wg.Done()
+		defer targetConn.Close()
+		defer clientConn.Close()
+		copyAndCount(targetConn, clientConn, stat, false)
+	}()
+
+	// 目标 -> 客户端 (入站流量)
+	go func() {
+		defer wg.Done()
+		defer clientConn.Close()
+		defer targetConn.Close()
+		copyAndCount(clientConn, targetConn, stat, true)
+	}()
+
+	wg.Wait()
+}
+
+// handleViaTunnel 经 WebSocket 隧道由中继链上的出口拨号目标
+func (f *Socks5Forwarder) handleViaTunnel(clientConn net.Conn, target string) {
+	ws := f.wsConnProvider()
+	if ws == nil {
+		log.Warn().Str("rule_id", f.id).Msg("SOCKS5 forwarder dropping connection: wsConn unavailable")
+		writeSocksReply(clientConn, socksRepFailure)
+		return
+	}
+
+	stream := ws.GetStreams().NewStream(target)
+	defer ws.GetStreams().RemoveStream(stream.ID)
+
+	connectMsg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: stream.ID,
+		Target:   target,
+		RuleID:   f.id, // 用于服务端流量统计
+	}
+	if f.verboseLogging {
+		connectMsg.Flags |= relay.ConnFlagVerbose
+	}
+	nextHop := f.relayChain[0]
+	if f.fallbackGroup != "" {
+		nextHop += "|" + f.fallbackGroup
+	}
+	connectMsg.Payload = []byte(nextHop)
+
+	if err := ws.Send(connectMsg); err != nil {
+		log.Warn().Err(err).Msg("Failed to send connect message")
+		writeSocksReply(clientConn, socksRepFailure)
+		return
+	}
+
+	if !f.waitForConnAck(stream, target) {
+		writeSocksReply(clientConn, socksRepRefused)
+		return
+	}
+
+	if err := writeSocksReply(clientConn, socksRepSuccess); err != nil {
+		return
+	}
+
+	// 双向转发：任一方向结束都关闭对端并等待两侧都退出
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go f.forwardToTunnel(ws, clientConn, stream, &wg)
+	go f.forwardFromTunnel(clientConn, stream, &wg)
+	wg.Wait()
+
+	ws.Send(&relay.TunnelMessage{
+		Type:     relay.MsgTypeClose,
+		StreamID: stream.ID,
+	})
+}
+
+// waitForConnAck 等待连接确认
+func (f *Socks5Forwarder) waitForConnAck(stream *relay.Stream, target string) bool {
+	timeout := time.After(time.Duration(f.cfg.ConnectTimeout) * time.Second)
+
+	for {
+		select {
+		case <-timeout:
+			log.Warn().
+				Uint32("stream_id", stream.ID).
+				Str("target", target).
+				Strs("relay_chain", f.relayChain).
+				Msg("SOCKS5 tunnel connect timeout")
+			return false
+		case <-stream.CloseCh:
+			return false
+		case data := <-stream.DataCh:
+			// 这里收到的是来自 handleTunnelMessage 的信号
+			if len(data) == 1 && data[0] == relay.MsgTypeConnAck {
+				return true
+			} else if len(data) >= 1 && data[0] == relay.MsgTypeError {
+				category := relay.ErrCategoryGeneral
+				if len(data) >= 2 {
+					category = data[1]
+				}
+				log.Warn().
+					Uint32("stream_id", stream.ID).
+					Str("target", target).
+					Str("category", relay.ErrCategoryName(category)).
+					Msg("SOCKS5 tunnel connect rejected")
+				return false
+			}
+		}
+	}
+}
+
+// forwardToTunnel 从客户端转发到隧道
+func (f *Socks5Forwarder) forwardToTunnel(ws *relay.WSClientConn, clientConn net.Conn, stream *relay.Stream, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer stream.Close()
+	defer clientConn.Close()
+
+	buf := relay.GetBuffer()
+	defer relay.PutBuffer(buf)
+
+	for {
+		n, err := clientConn.Read((*buf)[relay.HeaderSize:])
+		if err != nil {
+			return
+		}
+
+		if f.trafficCounter != nil {
+			f.trafficCounter.AddBytesOut(f.id, int64(n))
+		}
+
+		msg := &relay.TunnelMessage{
+			Type:     relay.MsgTypeData,
+			StreamID: stream.ID,
+			Payload:  (*buf)[relay.HeaderSize : relay.HeaderSize+n],
+		}
+
+		if err := ws.Send(msg); err != nil {
+			return
+		}
+	}
+}
+
+// forwardFromTunnel 从隧道转发到客户端
+func (f *Socks5Forwarder) forwardFromTunnel(clientConn net.Conn, stream *relay.Stream, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer stream.Close()
+	defer clientConn.Close()
+
+	for {
+		select {
+		case data, ok := <-stream.DataCh:
+			if !ok {
+				return
+			}
+			if f.trafficCounter != nil {
+				f.trafficCounter.AddBytesIn(f.id, int64(len(data)))
+			}
+			if _, err := clientConn.Write(data); err != nil {
+				return
+			}
+		case <-stream.CloseCh:
+			return
+		}
+	}
+}
+
+// readSocksConnect 完成 SOCKS5 协商 (仅支持无认证) 并解析 CONNECT 请求中的目标地址
+func readSocksConnect(conn net.Conn) (string, error) {
+	// 方法协商: VER NMETHODS METHODS...
+	head := make([]byte, 2)
+	if _, err := readFull(conn, head); err != nil {
+		return "", fmt.Errorf("read greeting: %w", err)
+	}
+	if head[0] != socksVersion5 {
+		return "", fmt.Errorf("unsupported SOCKS version 0x%02x", head[0])
+	}
+	methods := make([]byte, int(head[1]))
+	if _, err := readFull(conn, methods); err != nil {
+		return "", fmt.Errorf("read methods: %w", err)
+	}
+	if _, err := conn.Write([]byte{socksVersion5, socksAuthNone}); err != nil {
+		return "", fmt.Errorf("write method selection: %w", err)
+	}
+
+	// 请求: VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := make([]byte, 4)
+	if _, err := readFull(conn, req); err != nil {
+		return "", fmt.Errorf("read request: %w", err)
+	}
+	if req[0] != socksVersion5 {
+		return "", fmt.Errorf("unsupported SOCKS version 0x%02x", req[0])
+	}
+	if req[1] != socksCmdConnect {
+		writeSocksReply(conn, socksRepCmdNotSup)
+		return "", fmt.Errorf("unsupported SOCKS command 0x%02x", req[1])
+	}
+
+	var host string
+	switch req[3] {
+	case socksAtypIPv4:
+		addr := make([]byte, 4)
+		if _, err := readFull(conn, addr); err != nil {
+			return "", fmt.Errorf("read IPv4 address: %w", err)
+		}
+		host = net.IP(addr).String()
+	case socksAtypDomain:
+		lenBuf := make([]byte, 1)
+		if _, err := readFull(conn, lenBuf); err != nil {
+			return "", fmt.Errorf("read domain length: %w", err)
+		}
+		domain := make([]byte, int(lenBuf[0]))
+		if _, err := readFull(conn, domain); err != nil {
+			return "", fmt.Errorf("read domain: %w", err)
+		}
+		host = string(domain)
+	case socksAtypIPv6:
+		addr := make([]byte, 16)
+		if _, err := readFull(conn, addr); err != nil {
+			return "", fmt.Errorf("read IPv6 address: %w", err)
+		}
+		host = net.IP(addr).String()
+	default:
+		return "", fmt.Errorf("unsupported address type 0x%02x", req[3])
+	}
+
+	portBuf := make([]byte, 2)
+	if _, err := readFull(conn, portBuf); err != nil {
+		return "", fmt.Errorf("read port: %w", err)
+	}
+	port := binary.BigEndian.Uint16(portBuf)
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// writeSocksReply 发送 SOCKS5 应答, 绑定地址统一回 0.0.0.0:0 (客户端不使用)
+func writeSocksReply(conn net.Conn, rep byte) error {
+	_, err := conn.Write([]byte{socksVersion5, rep, 0x00, socksAtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
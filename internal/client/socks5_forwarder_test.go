@@ -0,0 +1,238 @@
+package client
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// startEchoServer 启动一个 echo 服务并返回其地址
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				io.Copy(c, c)
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// waitSocks5Listen 等待转发器监听就绪并返回实际地址
+func waitSocks5Listen(t *testing.T, f *Socks5Forwarder) string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.listenerMu.Lock()
+		var addr string
+		if f.listener != nil {
+			addr = f.listener.Addr().String()
+		}
+		f.listenerMu.Unlock()
+		if addr != "" {
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("forwarder never started listening")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// socksConnect 以 SOCKS5 客户端身份完成协商和 CONNECT 请求 (域名地址类型),
+// 返回服务器应答的 REP 字节
+func socksConnect(t *testing.T, conn net.Conn, target string) byte {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		t.Fatalf("invalid target %q: %v", target, err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	// 方法协商: 只声明无认证
+	if _, err := conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		t.Fatalf("failed to write greeting: %v", err)
+	}
+	sel := make([]byte, 2)
+	if _, err := io.ReadFull(conn, sel); err != nil {
+		t.Fatalf("failed to read method selection: %v", err)
+	}
+	if sel[0] != 0x05 || sel[1] != 0x00 {
+		t.Fatalf("method selection = %v, want [5 0]", sel)
+	}
+
+	// CONNECT 请求, 域名地址类型
+	req := []byte{0x05, 0x01, 0x00, 0x03, byte(len(host))}
+	req = append(req, host...)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("failed to write connect request: %v", err)
+	}
+
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("failed to read connect reply: %v", err)
+	}
+	if reply[0] != 0x05 {
+		t.Fatalf("reply version = 0x%02x, want 0x05", reply[0])
+	}
+	return reply[1]
+}
+
+// TestSocks5Forwarder_DirectEgress 验证无中继链时监听客户端直接拨号:
+// SOCKS5 客户端经转发器连到 echo 服务并完整往返
+func TestSocks5Forwarder_DirectEgress(t *testing.T) {
+	echoAddr := startEchoServer(t)
+
+	cfg := ForwarderSection{ConnectTimeout: 3}
+	f := NewSocks5Forwarder("socks-rule", "127.0.0.1:0", nil, cfg, nil, nil, nil)
+	go f.Start()
+	defer f.Stop()
+	listenAddr := waitSocks5Listen(t, f)
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	if rep := socksConnect(t, conn, echoAddr); rep != 0x00 {
+		t.Fatalf("connect reply = 0x%02x, want success", rep)
+	}
+
+	payload := []byte("hello socks direct")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read echo back: %v", err)
+	}
+	if string(buf) != string(payload) {
+		t.Errorf("echoed data = %q, want %q", buf, payload)
+	}
+}
+
+// TestSocks5Forwarder_TunnelEgress 验证配置中继链时目标由链上出口拨号:
+// SOCKS5 客户端 -> 入口转发器 -> 隧道 -> 出口客户端 -> echo 服务
+func TestSocks5Forwarder_TunnelEgress(t *testing.T) {
+	echoAddr := startEchoServer(t)
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	cfg := ForwarderSection{ConnectTimeout: 3}
+
+	// 出口客户端: 接收 Connect 后拨号 SOCKS5 请求中指定的目标
+	exitClient := New(&ClientConfig{Forwarder: cfg})
+	exitClient.clientID = "exit-client"
+	wsExit, err := relay.NewWSClientConn(wsSrv.URL, "exit-client", "")
+	if err != nil {
+		t.Fatalf("failed to create exit ws client: %v", err)
+	}
+	if err := wsExit.Connect(); err != nil {
+		t.Fatalf("failed to connect exit ws: %v", err)
+	}
+	defer wsExit.Close()
+	go runTunnelDispatch(exitClient, wsExit)
+
+	// 入口客户端: SOCKS5 监听, 经隧道出口拨号
+	entryClient := New(&ClientConfig{Forwarder: cfg})
+	entryClient.clientID = "entry-client"
+	wsEntry, err := relay.NewWSClientConn(wsSrv.URL, "entry-client", "")
+	if err != nil {
+		t.Fatalf("failed to create entry ws client: %v", err)
+	}
+	if err := wsEntry.Connect(); err != nil {
+		t.Fatalf("failed to connect entry ws: %v", err)
+	}
+	defer wsEntry.Close()
+	go runTunnelDispatch(entryClient, wsEntry)
+
+	f := NewSocks5Forwarder("socks-tunnel-rule", "127.0.0.1:0", []string{"exit-client"}, cfg,
+		func() *relay.WSClientConn { return wsEntry }, nil, nil)
+	go f.Start()
+	defer f.Stop()
+	listenAddr := waitSocks5Listen(t, f)
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	if rep := socksConnect(t, conn, echoAddr); rep != 0x00 {
+		t.Fatalf("connect reply = 0x%02x, want success", rep)
+	}
+
+	payload := []byte("hello socks tunnel")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read echo back: %v", err)
+	}
+	if string(buf) != string(payload) {
+		t.Errorf("echoed data = %q, want %q", buf, payload)
+	}
+}
+
+// TestSocks5Forwarder_RejectsNonConnectCommand 验证 BIND 等不支持的命令被拒绝
+func TestSocks5Forwarder_RejectsNonConnectCommand(t *testing.T) {
+	cfg := ForwarderSection{ConnectTimeout: 3}
+	f := NewSocks5Forwarder("socks-rule", "127.0.0.1:0", nil, cfg, nil, nil, nil)
+	go f.Start()
+	defer f.Stop()
+	listenAddr := waitSocks5Listen(t, f)
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		t.Fatalf("failed to write greeting: %v", err)
+	}
+	sel := make([]byte, 2)
+	if _, err := io.ReadFull(conn, sel); err != nil {
+		t.Fatalf("failed to read method selection: %v", err)
+	}
+
+	// BIND 命令 (0x02)
+	req := []byte{0x05, 0x02, 0x00, 0x01, 127, 0, 0, 1, 0x00, 0x50}
+	if _, err := conn.Write(req); err != nil {
+		t.Fatalf("failed to write bind request: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	if reply[1] != 0x07 {
+		t.Errorf("reply = 0x%02x, want 0x07 (command not supported)", reply[1])
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// startBackpressureTestHarness 与排空测试环境相同, 但使用很小的流缓冲以便快速触发背压
+func startBackpressureTestHarness(t *testing.T) (*Client, *relay.WSClientConn, func()) {
+	t.Helper()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+
+	c := New(&ClientConfig{
+		Forwarder: ForwarderSection{ConnectTimeout: 3, CloseDrainTimeout: 2, StreamBufferSize: 2},
+	})
+	c.clientID = "backpressure-client"
+
+	ws, err := relay.NewWSClientConn(wsSrv.URL, "backpressure-client", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect ws: %v", err)
+	}
+
+	cleanup := func() {
+		ws.Close()
+		wsSrv.Close()
+	}
+	return c, ws, cleanup
+}
+
+func TestHandleIncomingConnect_SlowTargetNoDataLoss(t *testing.T) {
+	c, ws, cleanup := startBackpressureTestHarness(t)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// 慢目标: 小读缓冲 + 每次读后停顿, 迫使隧道->目标方向的写入阻塞
+	received := make(chan int, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.SetReadBuffer(4096)
+		}
+
+		total := 0
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			total += n
+			if err != nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		received <- total
+	}()
+
+	// 开启排空, 关闭时把缓冲中的尾部数据一并送达
+	msg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: 88,
+		Target:   ln.Addr().String(),
+		Flags:    relay.ConnFlagDrainClose,
+	}
+	done := make(chan struct{})
+	go func() {
+		c.handleIncomingConnect(ws, msg)
+		close(done)
+	}()
+
+	stream := waitForStream(t, ws, 88)
+	if cap(stream.DataCh) != 2 {
+		t.Errorf("expected configured stream buffer size 2, got %d", cap(stream.DataCh))
+	}
+
+	// 写入远超缓冲容量的数据量, 阻塞写入应全部送达而不是丢弃
+	const chunks = 25
+	chunk := bytes.Repeat([]byte("x"), 4096)
+	for i := 0; i < chunks; i++ {
+		if !stream.WriteBlocking(chunk) {
+			t.Fatalf("WriteBlocking failed on chunk %d", i)
+		}
+	}
+	stream.Close()
+
+	select {
+	case total := <-received:
+		if total != chunks*len(chunk) {
+			t.Errorf("expected target to receive %d bytes, got %d", chunks*len(chunk), total)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("target never finished reading")
+	}
+
+	if stream.DroppedMessages() != 0 {
+		t.Errorf("expected no dropped messages with backpressure, got %d", stream.DroppedMessages())
+	}
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("handleIncomingConnect did not return")
+	}
+}
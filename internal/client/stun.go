@@ -0,0 +1,148 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// 最小 STUN Binding 客户端 (RFC 5389), 用于 NAT 后的客户端发现公网 IP。
+// 只实现注册上报所需的子集: 发送 Binding Request, 解析响应中的
+// XOR-MAPPED-ADDRESS (旧服务器回退到 MAPPED-ADDRESS)
+
+const (
+	stunMagicCookie   = 0x2112A442
+	stunHeaderSize    = 20
+	stunBindingReq    = 0x0001
+	stunBindingResp   = 0x0101
+	stunAttrMapped    = 0x0001
+	stunAttrXorMapped = 0x0020
+)
+
+// discoverPublicIP 向 STUN 服务器发送 Binding Request 并返回映射的公网 IP。
+// server 形如 host:port (省略端口默认 3478)
+func discoverPublicIP(server string, timeout time.Duration) (string, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "3478")
+	}
+
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return "", fmt.Errorf("failed to dial stun server: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Binding Request: 类型(2) + 长度(2) + magic cookie(4) + 事务 ID(12)
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:2], stunBindingReq)
+	binary.BigEndian.PutUint32(req[4:8], stunMagicCookie)
+	var txID [12]byte
+	if _, err := rand.Read(txID[:]); err != nil {
+		return "", err
+	}
+	copy(req[8:], txID[:])
+
+	if _, err := conn.Write(req); err != nil {
+		return "", fmt.Errorf("failed to send stun request: %w", err)
+	}
+
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stun response: %w", err)
+	}
+
+	return parseStunResponse(buf[:n], txID)
+}
+
+// parseStunResponse 校验响应头并提取映射地址的 IP
+func parseStunResponse(resp []byte, txID [12]byte) (string, error) {
+	if len(resp) < stunHeaderSize {
+		return "", errors.New("stun response too short")
+	}
+	if binary.BigEndian.Uint16(resp[0:2]) != stunBindingResp {
+		return "", fmt.Errorf("unexpected stun message type 0x%04x", binary.BigEndian.Uint16(resp[0:2]))
+	}
+	if binary.BigEndian.Uint32(resp[4:8]) != stunMagicCookie {
+		return "", errors.New("stun response missing magic cookie")
+	}
+	if [12]byte(resp[8:20]) != txID {
+		return "", errors.New("stun transaction id mismatch")
+	}
+
+	// 遍历属性 (TLV, 值按 4 字节对齐)
+	attrs := resp[stunHeaderSize:]
+	var mappedIP net.IP
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if len(attrs) < 4+attrLen {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXorMapped:
+			if ip := parseMappedAddress(value, true, txID); ip != nil {
+				return ip.String(), nil
+			}
+		case stunAttrMapped:
+			if ip := parseMappedAddress(value, false, txID); ip != nil {
+				mappedIP = ip
+			}
+		}
+
+		// 下一个属性 (长度向上取整到 4 的倍数)
+		next := 4 + (attrLen+3)/4*4
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if mappedIP != nil {
+		return mappedIP.String(), nil
+	}
+	return "", errors.New("stun response has no mapped address")
+}
+
+// parseMappedAddress 解析 (XOR-)MAPPED-ADDRESS 属性值: 保留(1) + 族(1) + 端口(2) + 地址
+func parseMappedAddress(value []byte, xored bool, txID [12]byte) net.IP {
+	if len(value) < 8 {
+		return nil
+	}
+	family := value[1]
+
+	var ip net.IP
+	switch family {
+	case 0x01: // IPv4
+		ip = net.IP(append([]byte{}, value[4:8]...))
+		if xored {
+			var cookie [4]byte
+			binary.BigEndian.PutUint32(cookie[:], stunMagicCookie)
+			for i := range ip {
+				ip[i] ^= cookie[i]
+			}
+		}
+	case 0x02: // IPv6, 异或掩码为 cookie + 事务 ID
+		if len(value) < 20 {
+			return nil
+		}
+		ip = net.IP(append([]byte{}, value[4:20]...))
+		if xored {
+			var mask [16]byte
+			binary.BigEndian.PutUint32(mask[:4], stunMagicCookie)
+			copy(mask[4:], txID[:])
+			for i := range ip {
+				ip[i] ^= mask[i]
+			}
+		}
+	default:
+		return nil
+	}
+	return ip
+}
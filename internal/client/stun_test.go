@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// startStubSTUNServer 起一个只回 XOR-MAPPED-ADDRESS 的 STUN 桩服务器
+func startStubSTUNServer(t *testing.T, publicIP string, port uint16) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen udp: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderSize || binary.BigEndian.Uint16(buf[0:2]) != stunBindingReq {
+				continue
+			}
+
+			// XOR-MAPPED-ADDRESS: 保留(1) + 族(1) + 端口(2) + IPv4(4), 均与 cookie 异或
+			attr := make([]byte, 8)
+			attr[1] = 0x01
+			binary.BigEndian.PutUint16(attr[2:4], port^uint16(stunMagicCookie>>16))
+			ip := net.ParseIP(publicIP).To4()
+			var cookie [4]byte
+			binary.BigEndian.PutUint32(cookie[:], stunMagicCookie)
+			for i := 0; i < 4; i++ {
+				attr[4+i] = ip[i] ^ cookie[i]
+			}
+
+			resp := make([]byte, stunHeaderSize+4+len(attr))
+			binary.BigEndian.PutUint16(resp[0:2], stunBindingResp)
+			binary.BigEndian.PutUint16(resp[2:4], uint16(4+len(attr)))
+			copy(resp[4:20], buf[4:20]) // cookie + 事务 ID 原样回传
+			binary.BigEndian.PutUint16(resp[20:22], stunAttrXorMapped)
+			binary.BigEndian.PutUint16(resp[22:24], uint16(len(attr)))
+			copy(resp[24:], attr)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestDiscoverPublicIP(t *testing.T) {
+	stunAddr := startStubSTUNServer(t, "203.0.113.9", 4321)
+
+	ip, err := discoverPublicIP(stunAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("discoverPublicIP failed: %v", err)
+	}
+	if ip != "203.0.113.9" {
+		t.Errorf("discovered IP = %q, want %q", ip, "203.0.113.9")
+	}
+}
+
+func TestRegister_ReportsSTUNDiscoveredIP(t *testing.T) {
+	stunAddr := startStubSTUNServer(t, "198.51.100.42", 9999)
+
+	// 捕获注册参数的 RPC 桩服务器
+	gotReportIP := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		params, _ := req["params"].(map[string]interface{})
+		reportIP, _ := params["report_ip"].(string)
+		gotReportIP <- reportIP
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      "register",
+			"result": map[string]interface{}{
+				"client_id":   "c1",
+				"secret_key":  "s1",
+				"ws_endpoint": "ws://example.com/ws",
+			},
+		})
+	}))
+	defer srv.Close()
+
+	c := New(&ClientConfig{
+		Client: ClientSection{
+			ServerURL:  srv.URL,
+			Token:      "t",
+			ReportIP:   "10.0.0.1", // STUN 成功时应被忽略
+			STUNServer: stunAddr,
+		},
+	})
+
+	if err := c.register(); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	select {
+	case reportIP := <-gotReportIP:
+		if reportIP != "198.51.100.42" {
+			t.Errorf("report_ip = %q, want STUN discovered %q", reportIP, "198.51.100.42")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("register request never reached server")
+	}
+}
+
+func TestResolveReportIP_FallbackToConfigured(t *testing.T) {
+	// STUN 服务器不可达: 回退到手动配置的 ReportIP
+	c := New(&ClientConfig{
+		Client: ClientSection{
+			ReportIP:   "10.0.0.1",
+			STUNServer: "127.0.0.1:1", // 无人监听
+		},
+	})
+
+	if got := c.resolveReportIP(); got != "10.0.0.1" {
+		t.Errorf("resolveReportIP() = %q, want fallback %q", got, "10.0.0.1")
+	}
+}
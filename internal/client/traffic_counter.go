@@ -98,6 +98,24 @@ func (tc *TrafficCounter) GetAndReset() []TrafficReport {
 	return reports
 }
 
+// Snapshot 返回各规则当前计数的快照 (不重置)。
+// 字节数是自上次上报以来的增量 (GetAndReset 定期清零), 供本机调试端点使用
+func (tc *TrafficCounter) Snapshot() []TrafficReport {
+	var reports []TrafficReport
+	tc.stats.Range(func(key, value any) bool {
+		stat := value.(*RuleTraffic)
+		reports = append(reports, TrafficReport{
+			RuleID:      key.(string),
+			BytesIn:     atomic.LoadInt64(&stat.BytesIn),
+			BytesOut:    atomic.LoadInt64(&stat.BytesOut),
+			Connections: atomic.LoadInt64(&stat.Connections),
+			ActiveConns: atomic.LoadInt32(&stat.ActiveConns),
+		})
+		return true
+	})
+	return reports
+}
+
 // GetOrCreateStat 暴露给热路径使用，避免每次读写都走 map lookup
 func (tc *TrafficCounter) GetOrCreateStat(ruleID string) *RuleTraffic {
 	return tc.getOrCreate(ruleID)
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// syncLogBuffer 线程安全的日志捕获缓冲 (出口侧多个 goroutine 并发写日志)
+type syncLogBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncLogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncLogBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureDebugLogs 把全局 logger 重定向到缓冲并开启 Debug 级别, 测试结束后还原
+func captureDebugLogs(t *testing.T) *syncLogBuffer {
+	t.Helper()
+	buf := &syncLogBuffer{}
+	oldLogger := log.Logger
+	oldLevel := zerolog.GlobalLevel()
+	log.Logger = zerolog.New(buf)
+	zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	t.Cleanup(func() {
+		log.Logger = oldLogger
+		zerolog.SetGlobalLevel(oldLevel)
+	})
+	return buf
+}
+
+// runIncomingConnect 对本地真实目标跑一次完整的 handleIncomingConnect 生命周期
+func runIncomingConnect(t *testing.T, flags byte, streamID uint32) {
+	t.Helper()
+
+	c, ws, cleanup := startDrainTestHarness(t)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	// 目标端保持连接直到测试收尾, 避免 EOF 抢在断言前拆掉 stream
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	msg := &relay.TunnelMessage{
+		Type:     relay.MsgTypeConnect,
+		StreamID: streamID,
+		Target:   ln.Addr().String(),
+		Flags:    flags,
+	}
+	done := make(chan struct{})
+	go func() {
+		c.handleIncomingConnect(ws, msg)
+		close(done)
+	}()
+
+	stream := waitForStream(t, ws, streamID)
+	stream.Close()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("handleIncomingConnect did not return")
+	}
+}
+
+func TestHandleIncomingConnect_NonVerboseRuleEmitsNoDebugLogs(t *testing.T) {
+	buf := captureDebugLogs(t)
+
+	runIncomingConnect(t, 0, 91)
+
+	out := buf.String()
+	if strings.Contains(out, "Handling incoming connect request") ||
+		strings.Contains(out, "ConnAck sent") {
+		t.Errorf("non-verbose rule emitted per-connection debug logs:\n%s", out)
+	}
+}
+
+func TestHandleIncomingConnect_VerboseRuleEmitsDebugLogs(t *testing.T) {
+	buf := captureDebugLogs(t)
+
+	runIncomingConnect(t, relay.ConnFlagVerbose, 92)
+
+	out := buf.String()
+	if !strings.Contains(out, "Handling incoming connect request") {
+		t.Errorf("verbose rule should emit per-connection debug logs, got:\n%s", out)
+	}
+}
+
+func TestRuleVerboseLogging(t *testing.T) {
+	rule, err := parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1"})
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if rule.VerboseLogging {
+		t.Error("verbose_logging should default to false")
+	}
+
+	rule, err = parseRule(map[string]interface{}{"id": "r", "type": "relay", "listen_addr": ":1", "verbose_logging": true})
+	if err != nil {
+		t.Fatalf("parseRule failed: %v", err)
+	}
+	if !rule.VerboseLogging {
+		t.Error("verbose_logging should be true when configured")
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/internal/relay"
+)
+
+// runTunnelDispatch 复刻 handleTunnelMessages 的分发逻辑, 驱动指定的 ws 连接
+// (测试中一个进程里同时扮演入口和出口两个客户端, 无法复用 Client 内部的单连接循环)
+func runTunnelDispatch(c *Client, ws *relay.WSClientConn) {
+	for {
+		msg := ws.Recv()
+		if msg == nil {
+			return
+		}
+		switch msg.Type {
+		case relay.MsgTypeConnect:
+			go c.handleIncomingConnect(ws, msg)
+		case relay.MsgTypeConnAck:
+			if stream := ws.GetStreams().GetStream(msg.StreamID); stream != nil {
+				stream.Write([]byte{relay.MsgTypeConnAck})
+			}
+		case relay.MsgTypeData:
+			if stream := ws.GetStreams().GetStream(msg.StreamID); stream != nil {
+				if !stream.WriteBlocking(msg.Payload) {
+					ws.GetStreams().RemoveStream(msg.StreamID)
+				}
+			}
+		case relay.MsgTypeClose:
+			ws.GetStreams().RemoveStream(msg.StreamID)
+		case relay.MsgTypeError:
+			if stream := ws.GetStreams().GetStream(msg.StreamID); stream != nil {
+				category := relay.ErrCategoryGeneral
+				if len(msg.Payload) > 0 {
+					category = msg.Payload[0]
+				}
+				stream.Write([]byte{relay.MsgTypeError, category})
+				stream.Close()
+			}
+		}
+	}
+}
+
+// TestViaRule_TrafficFlowsThroughViaClient 验证 direct+via 规则下发为单跳 relay 后,
+// 流量按 监听客户端 -> via 客户端 -> 目标 的路径完整往返
+func TestViaRule_TrafficFlowsThroughViaClient(t *testing.T) {
+	// 目标 echo 服务
+	echoLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer echoLn.Close()
+	go func() {
+		for {
+			conn, err := echoLn.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	wsServer := relay.NewWSServer()
+	wsSrv := httptest.NewServer(http.HandlerFunc(wsServer.HandleConnection))
+	defer wsSrv.Close()
+
+	cfg := ForwarderSection{ConnectTimeout: 3}
+
+	// via (出口) 客户端: 接收 Connect 后拨号目标
+	viaClient := New(&ClientConfig{Forwarder: cfg})
+	viaClient.clientID = "via-client"
+	wsVia, err := relay.NewWSClientConn(wsSrv.URL, "via-client", "")
+	if err != nil {
+		t.Fatalf("failed to create via ws client: %v", err)
+	}
+	if err := wsVia.Connect(); err != nil {
+		t.Fatalf("failed to connect via ws: %v", err)
+	}
+	defer wsVia.Close()
+	go runTunnelDispatch(viaClient, wsVia)
+
+	// 监听 (入口) 客户端: 服务端将 direct+via 规则转换为 relay_chain=[via-client]
+	entryClient := New(&ClientConfig{Forwarder: cfg})
+	entryClient.clientID = "entry-client"
+	wsEntry, err := relay.NewWSClientConn(wsSrv.URL, "entry-client", "")
+	if err != nil {
+		t.Fatalf("failed to create entry ws client: %v", err)
+	}
+	if err := wsEntry.Connect(); err != nil {
+		t.Fatalf("failed to connect entry ws: %v", err)
+	}
+	defer wsEntry.Close()
+	go runTunnelDispatch(entryClient, wsEntry)
+
+	f := NewRelayForwarder("via-rule", "127.0.0.1:0", echoLn.Addr().String(), []string{"via-client"}, cfg,
+		func() *relay.WSClientConn { return wsEntry }, nil, nil)
+	go f.Start()
+	defer f.Stop()
+
+	// 等待监听端口就绪并取实际地址
+	var listenAddr string
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.listenerMu.Lock()
+		if f.listener != nil {
+			listenAddr = f.listener.Addr().String()
+		}
+		f.listenerMu.Unlock()
+		if listenAddr != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("forwarder never started listening")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, err := net.DialTimeout("tcp", listenAddr, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to dial forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	payload := []byte("hello via relay")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, len(payload))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("failed to read echo back: %v", err)
+	}
+	if string(buf) != string(payload) {
+		t.Errorf("echoed data = %q, want %q", buf, payload)
+	}
+}
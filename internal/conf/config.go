@@ -2,6 +2,8 @@ package conf
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -22,6 +24,75 @@ type ServerConfig struct {
 	Debug            bool   `mapstructure:"Debug"`
 	ExternalURL      string `mapstructure:"ExternalURL"`
 	InstallScriptURL string `mapstructure:"InstallScriptURL"`
+
+	// StaticDir 仪表盘构建产物目录 (为空不提供静态资源)。
+	// 未匹配的非 API 路径回退 index.html, 带哈希文件名的资源长期缓存
+	StaticDir string `mapstructure:"StaticDir"`
+
+	// RPCAllowlist 无认证 RPC 方法的来源网段白名单 (method -> CIDR 列表)
+	// 未列出的方法不受限制
+	RPCAllowlist map[string][]string `mapstructure:"RPCAllowlist"`
+
+	// PayloadHistogram 开启中继载荷大小直方图统计 (用于评估缓冲区尺寸)
+	PayloadHistogram bool `mapstructure:"PayloadHistogram"`
+
+	// MaxBodySize RPC 请求体大小上限 (字节, <=0 不限制), 超限返回 413
+	MaxBodySize int64 `mapstructure:"MaxBodySize"`
+
+	// RequireOnlineForRules 仅向已建立 WebSocket 隧道的 client 下发规则
+	RequireOnlineForRules bool `mapstructure:"RequireOnlineForRules"`
+
+	// RejectSelfLoopRules 创建/更新时拒绝中继链绕回监听 client 自身的规则
+	// (这种自环只会空转资源或挂起连接), 组引用按当前成员解析判定
+	RejectSelfLoopRules bool `mapstructure:"RejectSelfLoopRules"`
+
+	// HealthCheckInterval 代理组节点健康检查间隔 (秒), 支持 SIGHUP 热更
+	HealthCheckInterval int `mapstructure:"HealthCheckInterval"`
+
+	// BandwidthSmoothing 带宽 EMA 平滑系数 alpha (0 < alpha <= 1, 越小曲线越平滑)
+	BandwidthSmoothing float64 `mapstructure:"BandwidthSmoothing"`
+
+	// RouteIdleTimeout 中继路由空闲超时 (秒, <=0 不回收)。
+	// 超时无数据往来的路由被回收, 节点连接计数与流量连接数同步递减
+	RouteIdleTimeout int `mapstructure:"RouteIdleTimeout"`
+
+	// DuplicateClientPolicy 同 ID 客户端重复连接策略: replace (默认, 新连接顶掉旧连接)
+	// 或 reject-new (保留旧连接, 拒绝新连接, 防止共用令牌的主机互相顶掉)
+	DuplicateClientPolicy string `mapstructure:"DuplicateClientPolicy"`
+
+	// TrafficRetentionDays 流量明细保留天数 (<=0 不清理)。
+	// 超过保留期的按日统计行被定期删除, 避免 traffic_stats 表无限增长
+	TrafficRetentionDays int `mapstructure:"TrafficRetentionDays"`
+
+	// 统计行的时间分桶粒度 ("daily" 默认 / "hourly" 保留日内分辨率, 行数约为按日的 24 倍)
+	TrafficPeriodGranularity string `mapstructure:"TrafficPeriodGranularity"`
+
+	// 按来源 IP 的请求限流 (令牌桶): RateLimitPerIP 为每秒请求数上限 (<=0 关闭),
+	// RateLimitBurst 为突发容量; 作用于 /api/rpc 和 WebSocket 升级, clientRegister 豁免
+	RateLimitPerIP float64 `mapstructure:"RateLimitPerIP"`
+	RateLimitBurst int     `mapstructure:"RateLimitBurst"`
+
+	// 客户端密钥轮换: SecretRotationInterval 为密钥最长使用期 (秒, <=0 关闭自动轮换),
+	// SecretRotationGrace 为轮换后旧密钥仍被接受的宽限期 (秒), 客户端需在此期间重新注册
+	SecretRotationInterval int `mapstructure:"SecretRotationInterval"`
+	SecretRotationGrace    int `mapstructure:"SecretRotationGrace"`
+
+	// 重连风暴抑制: 服务端重启后大量客户端同时重连时, 把 WebSocket 升级放行速率
+	// 压平到 AdmissionRatePerSec (每秒放行数, <=0 关闭); 超出的排队等待,
+	// 预计等待超过 AdmissionMaxWait (秒) 的连接以可重试的 503 剪除
+	AdmissionRatePerSec int `mapstructure:"AdmissionRatePerSec"`
+	AdmissionMaxWait    int `mapstructure:"AdmissionMaxWait"`
+
+	// MaxPendingPortChecks 等待客户端响应的端口检查全局上限 (<=0 不限制)。
+	// 客户端无响应时等待队列只增不减, 超限的新检查直接返回忙错误
+	MaxPendingPortChecks int `mapstructure:"MaxPendingPortChecks"`
+
+	// TLS 直出配置: 证书和私钥都配置时直接以 HTTPS/WSS 提供服务, 否则走明文 (由反代终止 TLS)
+	// TLSMinVersion 最低版本 ("1.2" 默认 / "1.3"), TLSCipherSuites 为密码套件白名单 (IANA 名称)
+	TLSCertFile     string   `mapstructure:"TLSCertFile"`
+	TLSKeyFile      string   `mapstructure:"TLSKeyFile"`
+	TLSMinVersion   string   `mapstructure:"TLSMinVersion"`
+	TLSCipherSuites []string `mapstructure:"TLSCipherSuites"`
 }
 
 type DatabaseConfig struct {
@@ -48,10 +119,15 @@ type AdminConfig struct {
 }
 
 type WebSocketConfig struct {
-	Endpoint         string `mapstructure:"Endpoint"`
-	PingInterval     int    `mapstructure:"PingInterval"`
-	PongTimeout      int    `mapstructure:"PongTimeout"`
-	OfflineThreshold int    `mapstructure:"OfflineThreshold"`
+	Endpoint string `mapstructure:"Endpoint"`
+
+	// ListenAddr 独立监听地址 (host:port)。非空时 WebSocket 隧道绑定独立端口,
+	// 与管理 API 分离, 便于控制面/数据面配置不同的防火墙规则; 为空时共用 API 端口
+	ListenAddr string `mapstructure:"ListenAddr"`
+
+	PingInterval     int `mapstructure:"PingInterval"`
+	PongTimeout      int `mapstructure:"PongTimeout"`
+	OfflineThreshold int `mapstructure:"OfflineThreshold"`
 }
 
 type LoggingConfig struct {
@@ -84,9 +160,71 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// applyEnvOverrides 应用环境变量覆盖 (容器部署无需挂载配置文件即可调整关键项)。
+// 变量名为 MB_<节>_<键> 的蛇形大写, 如 MB_SERVER_EXTERNAL_URL、MB_DATABASE_PASSWORD。
+// 优先级: 环境变量 > 配置文件 > 默认值
+func applyEnvOverrides(cfg *Config) error {
+	setString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	setInt := func(name string, dst *int) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		*dst = n
+		return nil
+	}
+	setBool := func(name string, dst *bool) error {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		*dst = b
+		return nil
+	}
+
+	setString("MB_SERVER_HOST", &cfg.Server.Host)
+	setString("MB_SERVER_EXTERNAL_URL", &cfg.Server.ExternalURL)
+	setString("MB_SERVER_STATIC_DIR", &cfg.Server.StaticDir)
+	setString("MB_DATABASE_HOST", &cfg.Database.Host)
+	setString("MB_DATABASE_USER", &cfg.Database.User)
+	setString("MB_DATABASE_PASSWORD", &cfg.Database.Password)
+	setString("MB_DATABASE_DBNAME", &cfg.Database.DBName)
+	setString("MB_DATABASE_SSLMODE", &cfg.Database.SSLMode)
+	setString("MB_JWT_SECRET_KEY", &cfg.JWT.SecretKey)
+	setString("MB_ADMIN_USERNAME", &cfg.Admin.Username)
+	setString("MB_ADMIN_PASSWORD", &cfg.Admin.Password)
+	setString("MB_WEBSOCKET_ENDPOINT", &cfg.WebSocket.Endpoint)
+	setString("MB_WEBSOCKET_LISTEN_ADDR", &cfg.WebSocket.ListenAddr)
+	setString("MB_LOGGING_LEVEL", &cfg.Logging.Level)
+
+	if err := setInt("MB_SERVER_PORT", &cfg.Server.Port); err != nil {
+		return err
+	}
+	if err := setInt("MB_DATABASE_PORT", &cfg.Database.Port); err != nil {
+		return err
+	}
+	return setBool("MB_SERVER_DEBUG", &cfg.Server.Debug)
+}
+
 func setDefaults(v *viper.Viper) {
 	// Server defaults
 	v.SetDefault("Server.Host", "0.0.0.0")
@@ -94,6 +232,25 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("Server.Debug", false)
 	v.SetDefault("Server.ExternalURL", "http://localhost:8080")
 	v.SetDefault("Server.InstallScriptURL", "https://fileoss.hacksnews.top/install.sh")
+	v.SetDefault("Server.StaticDir", "")
+	v.SetDefault("Server.PayloadHistogram", false)
+	v.SetDefault("Server.MaxBodySize", 1<<20)
+	v.SetDefault("Server.RequireOnlineForRules", false)
+	v.SetDefault("Server.RejectSelfLoopRules", true)
+	v.SetDefault("Server.HealthCheckInterval", 10)
+	v.SetDefault("Server.BandwidthSmoothing", 0.3)
+	v.SetDefault("Server.RouteIdleTimeout", 0)
+	v.SetDefault("Server.DuplicateClientPolicy", "replace")
+	v.SetDefault("Server.TrafficRetentionDays", 0)
+	v.SetDefault("Server.TrafficPeriodGranularity", "daily")
+	v.SetDefault("Server.RateLimitPerIP", 0)
+	v.SetDefault("Server.RateLimitBurst", 20)
+	v.SetDefault("Server.SecretRotationInterval", 0)
+	v.SetDefault("Server.AdmissionRatePerSec", 0)
+	v.SetDefault("Server.AdmissionMaxWait", 2)
+	v.SetDefault("Server.MaxPendingPortChecks", 256)
+	v.SetDefault("Server.SecretRotationGrace", 300)
+	v.SetDefault("Server.TLSMinVersion", "1.2")
 
 	// Database defaults
 	v.SetDefault("Database.Host", "localhost")
@@ -117,6 +274,7 @@ func setDefaults(v *viper.Viper) {
 
 	// WebSocket defaults
 	v.SetDefault("WebSocket.Endpoint", "/ws")
+	v.SetDefault("WebSocket.ListenAddr", "")
 	v.SetDefault("WebSocket.PingInterval", 30)
 	v.SetDefault("WebSocket.PongTimeout", 60)
 	v.SetDefault("WebSocket.OfflineThreshold", 90)
@@ -0,0 +1,69 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "server.toml")
+	if err := os.WriteFile(cfgPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return cfgPath
+}
+
+func TestLoadConfig_EnvOverridesFile(t *testing.T) {
+	cfgPath := writeTestConfig(t, `
+[Server]
+ExternalURL = "http://file.example.com"
+
+[Database]
+Password = "file-password"
+`)
+
+	t.Setenv("MB_SERVER_EXTERNAL_URL", "http://env.example.com")
+	t.Setenv("MB_DATABASE_PASSWORD", "env-password")
+
+	cfg, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+
+	if cfg.Server.ExternalURL != "http://env.example.com" {
+		t.Errorf("ExternalURL = %q, want env override", cfg.Server.ExternalURL)
+	}
+	if cfg.Database.Password != "env-password" {
+		t.Errorf("Database.Password = %q, want env override", cfg.Database.Password)
+	}
+}
+
+func TestLoadConfig_FileValueWithoutEnv(t *testing.T) {
+	cfgPath := writeTestConfig(t, `
+[Server]
+ExternalURL = "http://file.example.com"
+`)
+
+	cfg, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig error: %v", err)
+	}
+	if cfg.Server.ExternalURL != "http://file.example.com" {
+		t.Errorf("ExternalURL = %q, want file value", cfg.Server.ExternalURL)
+	}
+}
+
+func TestLoadConfig_InvalidEnvInt(t *testing.T) {
+	cfgPath := writeTestConfig(t, `
+[Server]
+Port = 8080
+`)
+
+	t.Setenv("MB_SERVER_PORT", "not-a-number")
+
+	if _, err := LoadConfig(cfgPath); err == nil {
+		t.Error("expected error for non-numeric MB_SERVER_PORT")
+	}
+}
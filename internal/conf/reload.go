@@ -0,0 +1,63 @@
+package conf
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ReloadHooks 各子系统的热更入口, 由 main 在启动时装配。
+// 未装配的钩子对应字段不参与热更
+type ReloadHooks struct {
+	SetLogLevel            func(level string)
+	SetHealthCheckInterval func(interval time.Duration)
+	SetMaxBodySize         func(limit int64)
+}
+
+// ApplyReload 将新配置中可热更的字段应用到运行中的进程 (SIGHUP 路径),
+// 并把已生效的值同步回 current, 返回实际生效的字段名列表。
+// 监听地址、TLS 证书、数据库连接等需要重建连接或重绑监听的字段不热更,
+// 检测到变更时仅记录告警, 保持旧值继续运行
+func ApplyReload(current, next *Config, hooks ReloadHooks) []string {
+	var applied []string
+
+	if next.Logging.Level != current.Logging.Level && hooks.SetLogLevel != nil {
+		hooks.SetLogLevel(next.Logging.Level)
+		current.Logging.Level = next.Logging.Level
+		applied = append(applied, "Logging.Level")
+	}
+
+	if next.Server.HealthCheckInterval != current.Server.HealthCheckInterval &&
+		next.Server.HealthCheckInterval > 0 && hooks.SetHealthCheckInterval != nil {
+		hooks.SetHealthCheckInterval(time.Duration(next.Server.HealthCheckInterval) * time.Second)
+		current.Server.HealthCheckInterval = next.Server.HealthCheckInterval
+		applied = append(applied, "Server.HealthCheckInterval")
+	}
+
+	if next.Server.MaxBodySize != current.Server.MaxBodySize && hooks.SetMaxBodySize != nil {
+		hooks.SetMaxBodySize(next.Server.MaxBodySize)
+		current.Server.MaxBodySize = next.Server.MaxBodySize
+		applied = append(applied, "Server.MaxBodySize")
+	}
+
+	// 不可热更字段: 变更只告警, 重启后才生效
+	notReloadable := []struct {
+		changed bool
+		field   string
+	}{
+		{next.Database != current.Database, "Database"},
+		{next.Server.Host != current.Server.Host || next.Server.Port != current.Server.Port, "Server.Host/Port"},
+		{next.WebSocket.ListenAddr != current.WebSocket.ListenAddr, "WebSocket.ListenAddr"},
+		{next.Server.TLSCertFile != current.Server.TLSCertFile || next.Server.TLSKeyFile != current.Server.TLSKeyFile, "Server.TLSCertFile/TLSKeyFile"},
+	}
+	for _, f := range notReloadable {
+		if f.changed {
+			log.Warn().Str("field", f.field).Msg("Config field is not reloadable, restart required to apply")
+		}
+	}
+
+	if len(applied) > 0 {
+		log.Info().Strs("fields", applied).Msg("Config reloaded")
+	}
+	return applied
+}
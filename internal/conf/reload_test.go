@@ -0,0 +1,94 @@
+package conf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyReload_LogLevel(t *testing.T) {
+	current := &Config{Logging: LoggingConfig{Level: "info"}}
+	next := &Config{Logging: LoggingConfig{Level: "debug"}}
+
+	var gotLevel string
+	applied := ApplyReload(current, next, ReloadHooks{
+		SetLogLevel: func(level string) { gotLevel = level },
+	})
+
+	if gotLevel != "debug" {
+		t.Errorf("SetLogLevel called with %q, want %q", gotLevel, "debug")
+	}
+	if len(applied) != 1 || applied[0] != "Logging.Level" {
+		t.Errorf("applied = %v, want [Logging.Level]", applied)
+	}
+	if current.Logging.Level != "debug" {
+		t.Errorf("current.Logging.Level = %q, want %q (已生效的值应同步回 current)", current.Logging.Level, "debug")
+	}
+
+	// 再次 reload 相同配置: 不应重复触发
+	gotLevel = ""
+	applied = ApplyReload(current, next, ReloadHooks{
+		SetLogLevel: func(level string) { gotLevel = level },
+	})
+	if gotLevel != "" || len(applied) != 0 {
+		t.Errorf("unchanged config should not re-apply, got level=%q applied=%v", gotLevel, applied)
+	}
+}
+
+func TestApplyReload_HealthCheckIntervalAndMaxBodySize(t *testing.T) {
+	current := &Config{Server: ServerConfig{HealthCheckInterval: 10, MaxBodySize: 1 << 20}}
+	next := &Config{Server: ServerConfig{HealthCheckInterval: 30, MaxBodySize: 2 << 20}}
+
+	var gotInterval time.Duration
+	var gotLimit int64
+	applied := ApplyReload(current, next, ReloadHooks{
+		SetHealthCheckInterval: func(d time.Duration) { gotInterval = d },
+		SetMaxBodySize:         func(limit int64) { gotLimit = limit },
+	})
+
+	if gotInterval != 30*time.Second {
+		t.Errorf("SetHealthCheckInterval called with %v, want 30s", gotInterval)
+	}
+	if gotLimit != 2<<20 {
+		t.Errorf("SetMaxBodySize called with %d, want %d", gotLimit, 2<<20)
+	}
+	if len(applied) != 2 {
+		t.Errorf("applied = %v, want 2 fields", applied)
+	}
+}
+
+func TestApplyReload_NonReloadableFieldsKeepOldValues(t *testing.T) {
+	current := &Config{
+		Server:   ServerConfig{Host: "0.0.0.0", Port: 8080},
+		Database: DatabaseConfig{Host: "localhost", DBName: "messageboy"},
+	}
+	next := &Config{
+		Server:   ServerConfig{Host: "0.0.0.0", Port: 9090},
+		Database: DatabaseConfig{Host: "db.internal", DBName: "messageboy"},
+	}
+
+	applied := ApplyReload(current, next, ReloadHooks{})
+
+	if len(applied) != 0 {
+		t.Errorf("non-reloadable changes should not be applied, got %v", applied)
+	}
+	if current.Server.Port != 8080 {
+		t.Errorf("current.Server.Port = %d, want 8080 (不可热更字段保持旧值)", current.Server.Port)
+	}
+	if current.Database.Host != "localhost" {
+		t.Errorf("current.Database.Host = %q, want localhost", current.Database.Host)
+	}
+}
+
+func TestApplyReload_NilHooksSkipped(t *testing.T) {
+	current := &Config{Logging: LoggingConfig{Level: "info"}}
+	next := &Config{Logging: LoggingConfig{Level: "debug"}}
+
+	// 未装配钩子时不 panic, 也不同步值 (下次装配后仍可应用)
+	applied := ApplyReload(current, next, ReloadHooks{})
+	if len(applied) != 0 {
+		t.Errorf("applied = %v, want empty without hooks", applied)
+	}
+	if current.Logging.Level != "info" {
+		t.Errorf("current.Logging.Level = %q, want info", current.Logging.Level)
+	}
+}
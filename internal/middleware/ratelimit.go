@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// bucketIdleTTL 空闲令牌桶的回收阈值 (超过该时长无请求的 IP 条目被清理)
+	bucketIdleTTL = 10 * time.Minute
+
+	// bucketGCInterval 清理扫描的最小间隔 (在请求路径上惰性触发, 无后台协程)
+	bucketGCInterval = time.Minute
+)
+
+// RateLimiter 按来源 IP 的令牌桶限流器。
+// 每个 IP 独立计桶: 以固定速率补充令牌, 突发最多消耗 burst 个
+type RateLimiter struct {
+	rate  float64 // 每秒补充的令牌数
+	burst float64 // 桶容量 (允许的突发请求数)
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	lastGC  time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建限流器。ratePerSec 为每秒请求数上限, burst 为突发容量 (最小 1)
+func NewRateLimiter(ratePerSec float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    ratePerSec,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		lastGC:  time.Now(),
+	}
+}
+
+// Allow 尝试为 key (通常是来源 IP) 取一个令牌, 桶空时返回 false
+func (l *RateLimiter) Allow(key string) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastGC) > bucketGCInterval {
+		l.gcLocked(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// gcLocked 清理长时间空闲的桶, 防止 map 随来源 IP 无限增长 (需持有 mu)
+func (l *RateLimiter) gcLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastGC = now
+}
+
+// Middleware 返回按来源 IP 限流的 gin 中间件, 超限返回 429
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !l.Allow(c.ClientIP()) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "rate limit exceeded",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -17,8 +17,8 @@ type ProxyGroupStore interface {
 	GetNodesByGroupID(groupID string) ([]model.ProxyGroupNode, error)
 	GetNode(id string) (*model.ProxyGroupNode, error)
 	GetByID(id string) (*model.ProxyGroup, error)
-	UpdateNodeHealth(nodeID string, healthy bool) error
-	MarkNodeUnhealthy(nodeID string) error
+	UpdateNodeHealth(nodeID string, healthy bool, checkError string) error
+	MarkNodeUnhealthy(nodeID string, reason string) error
 }
 
 // ClientChecker abstracts online-status checking for testability.
@@ -26,14 +26,46 @@ type ClientChecker interface {
 	IsClientOnline(clientID string) bool
 }
 
+// 翻转检测参数
+const (
+	checkHistorySize = 20              // 每个节点保留的检查结果数
+	flapWindow       = 5 * time.Minute // 翻转统计窗口
+	flapThreshold    = 6               // 窗口内状态翻转次数阈值
+	flapDampingTime  = 2 * time.Minute // 翻转后的抑制期
+)
+
+// CheckResult 单次健康检查结果
+type CheckResult struct {
+	Healthy   bool      `json:"healthy"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// nodeHistory 节点的检查历史与翻转状态
+type nodeHistory struct {
+	results     []CheckResult
+	flapping    bool
+	dampedUntil time.Time
+}
+
 // HealthChecker 健康检查器
 type HealthChecker struct {
 	proxyStore  ProxyGroupStore
 	clientCheck ClientChecker
 
-	stopCh   chan struct{}
-	wg       sync.WaitGroup
-	interval time.Duration
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+	interval   time.Duration
+	intervalMu sync.Mutex
+	intervalCh chan time.Duration
+
+	// 检查历史 (内存, 按节点 ID)
+	history   map[string]*nodeHistory
+	historyMu sync.RWMutex
+
+	// inflight 正在探测中的节点; 定时检查与按需检查并发到达同一节点时
+	// 后到者直接跳过, 避免重复累计失败
+	inflight   map[string]struct{}
+	inflightMu sync.Mutex
 }
 
 func NewHealthChecker(s *storage.Storage, ws *relay.WSServer) *HealthChecker {
@@ -42,9 +74,34 @@ func NewHealthChecker(s *storage.Storage, ws *relay.WSServer) *HealthChecker {
 		clientCheck: ws,
 		stopCh:      make(chan struct{}),
 		interval:    10 * time.Second, // 默认检查间隔
+		intervalCh:  make(chan time.Duration, 1),
+		history:     make(map[string]*nodeHistory),
+		inflight:    make(map[string]struct{}),
 	}
 }
 
+// SetInterval 调整定时检查间隔, 运行中的检查循环会在下次调度时切换到新间隔。
+// 非正值忽略; 配置热加载时由 SIGHUP 路径调用
+func (h *HealthChecker) SetInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	h.intervalMu.Lock()
+	h.interval = d
+	h.intervalMu.Unlock()
+	select {
+	case h.intervalCh <- d:
+	default:
+	}
+}
+
+// Interval 返回当前检查间隔
+func (h *HealthChecker) Interval() time.Duration {
+	h.intervalMu.Lock()
+	defer h.intervalMu.Unlock()
+	return h.interval
+}
+
 func (h *HealthChecker) Start() {
 	h.wg.Add(1)
 	go h.run()
@@ -60,7 +117,7 @@ func (h *HealthChecker) Stop() {
 func (h *HealthChecker) run() {
 	defer h.wg.Done()
 
-	ticker := time.NewTicker(h.interval)
+	ticker := time.NewTicker(h.Interval())
 	defer ticker.Stop()
 
 	// 启动时立即执行一次
@@ -70,6 +127,8 @@ func (h *HealthChecker) run() {
 		select {
 		case <-h.stopCh:
 			return
+		case d := <-h.intervalCh:
+			ticker.Reset(d)
 		case <-ticker.C:
 			h.checkAllGroups()
 		}
@@ -106,21 +165,71 @@ func (h *HealthChecker) checkGroup(group *model.ProxyGroup) {
 	}
 }
 
+// beginCheck 登记节点探测开始, 已有探测在进行时返回 false
+func (h *HealthChecker) beginCheck(nodeID string) bool {
+	h.inflightMu.Lock()
+	defer h.inflightMu.Unlock()
+
+	if h.inflight == nil {
+		h.inflight = make(map[string]struct{})
+	}
+	if _, ok := h.inflight[nodeID]; ok {
+		return false
+	}
+	h.inflight[nodeID] = struct{}{}
+	return true
+}
+
+// endCheck 登记节点探测结束
+func (h *HealthChecker) endCheck(nodeID string) {
+	h.inflightMu.Lock()
+	defer h.inflightMu.Unlock()
+	delete(h.inflight, nodeID)
+}
+
 func (h *HealthChecker) checkNode(group *model.ProxyGroup, node *model.ProxyGroupNode) {
+	if !h.beginCheck(node.ID) {
+		return
+	}
+	defer h.endCheck(node.ID)
+
 	// 检查 client 是否在线 (通过 WebSocket 连接状态)
 	isOnline := h.clientCheck.IsClientOnline(node.ClientID)
 
+	// 探测失败原因，随健康状态一起落库供运维排查
+	checkErr := ""
+	if !isOnline {
+		checkErr = "client offline: no active websocket connection"
+	}
+
+	// 记录检查历史并做翻转检测；抑制期内强制按不健康处理
+	damped := h.recordCheck(node.ID, isOnline)
+	if damped {
+		isOnline = false
+		checkErr = "flapping: held unhealthy for damping period"
+	}
+
 	// 更新健康状态
-	if err := h.proxyStore.UpdateNodeHealth(node.ID, isOnline); err != nil {
+	if err := h.proxyStore.UpdateNodeHealth(node.ID, isOnline, checkErr); err != nil {
 		log.Warn().Err(err).Str("node_id", node.ID).Msg("Failed to update node health")
 		return
 	}
 
+	// 翻转抑制期内直接压成不健康，不等失败计数
+	if damped {
+		h.proxyStore.MarkNodeUnhealthy(node.ID, checkErr)
+		log.Warn().
+			Str("node_id", node.ID).
+			Str("client_id", node.ClientID).
+			Msg("Node is flapping, held unhealthy for damping period")
+		return
+	}
+
 	// 如果连续失败次数超过阈值，标记为不健康
 	if !isOnline {
 		newNode, _ := h.proxyStore.GetNode(node.ID)
 		if newNode != nil && newNode.FailCount >= group.HealthCheckRetries {
-			h.proxyStore.MarkNodeUnhealthy(node.ID)
+			h.proxyStore.MarkNodeUnhealthy(node.ID, checkErr)
 			log.Warn().
 				Str("node_id", node.ID).
 				Str("client_id", node.ClientID).
@@ -135,6 +244,79 @@ func (h *HealthChecker) checkNode(group *model.ProxyGroup, node *model.ProxyGrou
 	}
 }
 
+// recordCheck 记录一次检查结果，返回该节点是否处于翻转抑制期
+func (h *HealthChecker) recordCheck(nodeID string, healthy bool) bool {
+	now := time.Now()
+
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+
+	if h.history == nil {
+		h.history = make(map[string]*nodeHistory)
+	}
+	nh := h.history[nodeID]
+	if nh == nil {
+		nh = &nodeHistory{}
+		h.history[nodeID] = nh
+	}
+
+	nh.results = append(nh.results, CheckResult{Healthy: healthy, CheckedAt: now})
+	if len(nh.results) > checkHistorySize {
+		nh.results = nh.results[len(nh.results)-checkHistorySize:]
+	}
+
+	// 仍在抑制期内
+	if now.Before(nh.dampedUntil) {
+		return true
+	}
+	nh.flapping = false
+
+	// 统计窗口内的状态翻转次数
+	transitions := 0
+	first := true
+	var prev bool
+	for _, r := range nh.results {
+		if now.Sub(r.CheckedAt) > flapWindow {
+			continue
+		}
+		if !first && prev != r.Healthy {
+			transitions++
+		}
+		prev = r.Healthy
+		first = false
+	}
+
+	if transitions >= flapThreshold {
+		nh.flapping = true
+		nh.dampedUntil = now.Add(flapDampingTime)
+		return true
+	}
+	return false
+}
+
+// NodeCheckHistory 返回节点最近的检查结果 (副本)
+func (h *HealthChecker) NodeCheckHistory(nodeID string) []CheckResult {
+	h.historyMu.RLock()
+	defer h.historyMu.RUnlock()
+
+	nh := h.history[nodeID]
+	if nh == nil {
+		return nil
+	}
+	results := make([]CheckResult, len(nh.results))
+	copy(results, nh.results)
+	return results
+}
+
+// IsNodeFlapping 返回节点当前是否处于翻转抑制期
+func (h *HealthChecker) IsNodeFlapping(nodeID string) bool {
+	h.historyMu.RLock()
+	defer h.historyMu.RUnlock()
+
+	nh := h.history[nodeID]
+	return nh != nil && nh.flapping && time.Now().Before(nh.dampedUntil)
+}
+
 // CheckNodeHealth 手动检查单个节点健康状态
 func (h *HealthChecker) CheckNodeHealth(nodeID string) error {
 	node, err := h.proxyStore.GetNode(nodeID)
@@ -150,3 +332,14 @@ func (h *HealthChecker) CheckNodeHealth(nodeID string) error {
 	h.checkNode(group, node)
 	return nil
 }
+
+// CheckGroupHealth 手动检查整组节点健康状态
+func (h *HealthChecker) CheckGroupHealth(groupID string) error {
+	group, err := h.proxyStore.GetByID(groupID)
+	if err != nil {
+		return err
+	}
+
+	h.checkGroup(group)
+	return nil
+}
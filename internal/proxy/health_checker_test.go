@@ -20,6 +20,7 @@ type mockProxyGroupStore struct {
 	groupByID       map[string]*model.ProxyGroup
 	healthUpdates   []struct{ nodeID string; healthy bool }
 	markedUnhealthy []string
+	lastCheckErrors map[string]string // nodeID -> 最近一次上报的探测错误
 }
 
 func (m *mockProxyGroupStore) List(params storage.ProxyGroupListParams) ([]model.ProxyGroup, int64, error) {
@@ -58,17 +59,27 @@ func (m *mockProxyGroupStore) GetByID(id string) (*model.ProxyGroup, error) {
 	return group, nil
 }
 
-func (m *mockProxyGroupStore) UpdateNodeHealth(nodeID string, healthy bool) error {
+func (m *mockProxyGroupStore) UpdateNodeHealth(nodeID string, healthy bool, checkError string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.healthUpdates = append(m.healthUpdates, struct{ nodeID string; healthy bool }{nodeID, healthy})
+	if m.lastCheckErrors == nil {
+		m.lastCheckErrors = make(map[string]string)
+	}
+	m.lastCheckErrors[nodeID] = checkError
 	return nil
 }
 
-func (m *mockProxyGroupStore) MarkNodeUnhealthy(nodeID string) error {
+func (m *mockProxyGroupStore) MarkNodeUnhealthy(nodeID string, reason string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.markedUnhealthy = append(m.markedUnhealthy, nodeID)
+	if m.lastCheckErrors == nil {
+		m.lastCheckErrors = make(map[string]string)
+	}
+	if reason != "" {
+		m.lastCheckErrors[nodeID] = reason
+	}
 	return nil
 }
 
@@ -282,3 +293,154 @@ func (c *countingProxyGroupStore) List(params storage.ProxyGroupListParams) ([]m
 	atomic.AddInt64(c.listCount, 1)
 	return c.mockProxyGroupStore.List(params)
 }
+
+// ===== Flap detection =====
+
+func TestHealthChecker_FlapDetection_AlternatingResults(t *testing.T) {
+	store := &mockProxyGroupStore{
+		nodeByID: map[string]*model.ProxyGroupNode{},
+	}
+	checker := &mockClientChecker{online: map[string]bool{}}
+
+	hc := &HealthChecker{
+		proxyStore:  store,
+		clientCheck: checker,
+		stopCh:      make(chan struct{}),
+	}
+
+	group := &model.ProxyGroup{ID: "group-1", HealthCheckRetries: 3}
+	node := &model.ProxyGroupNode{ID: "node-1", ClientID: "client-1"}
+
+	// 交替喂 online/offline，直到翻转次数超过阈值
+	for i := 0; i < flapThreshold+2; i++ {
+		checker.online["client-1"] = i%2 == 0
+		hc.checkNode(group, node)
+	}
+
+	if !hc.IsNodeFlapping("node-1") {
+		t.Fatal("expected node to be flagged as flapping")
+	}
+
+	// 抑制期内即使客户端在线也应保持不健康
+	checker.online["client-1"] = true
+	hc.checkNode(group, node)
+
+	store.mu.Lock()
+	last := store.healthUpdates[len(store.healthUpdates)-1]
+	marked := len(store.markedUnhealthy)
+	store.mu.Unlock()
+
+	if last.healthy {
+		t.Error("expected damped node to be recorded unhealthy even when online")
+	}
+	if marked == 0 {
+		t.Error("expected MarkNodeUnhealthy to be called during damping period")
+	}
+}
+
+func TestHealthChecker_NodeCheckHistory(t *testing.T) {
+	hc := &HealthChecker{}
+
+	for i := 0; i < checkHistorySize+5; i++ {
+		hc.recordCheck("node-1", true)
+	}
+
+	history := hc.NodeCheckHistory("node-1")
+	if len(history) != checkHistorySize {
+		t.Errorf("expected history capped at %d, got %d", checkHistorySize, len(history))
+	}
+	for _, r := range history {
+		if !r.Healthy {
+			t.Error("expected all recorded results to be healthy")
+		}
+	}
+
+	if hc.IsNodeFlapping("node-1") {
+		t.Error("stable node should not be flagged as flapping")
+	}
+	if hc.NodeCheckHistory("unknown") != nil {
+		t.Error("unknown node should have no history")
+	}
+}
+
+func TestHealthChecker_StableNode_NoDamping(t *testing.T) {
+	hc := &HealthChecker{}
+
+	// 单次状态切换不应触发抑制
+	hc.recordCheck("node-1", true)
+	hc.recordCheck("node-1", true)
+	if hc.recordCheck("node-1", false) {
+		t.Error("single transition should not engage damping")
+	}
+}
+
+func TestHealthChecker_CheckNode_RecordsProbeError(t *testing.T) {
+	store := &mockProxyGroupStore{
+		nodeByID: map[string]*model.ProxyGroupNode{
+			"n1": {ID: "n1", ClientID: "client-1"},
+		},
+	}
+	checker := &mockClientChecker{online: map[string]bool{"client-1": false}}
+
+	hc := &HealthChecker{proxyStore: store, clientCheck: checker, stopCh: make(chan struct{})}
+
+	group := &model.ProxyGroup{ID: "g1", HealthCheckRetries: 3}
+	node := &model.ProxyGroupNode{ID: "n1", ClientID: "client-1"}
+
+	hc.checkNode(group, node)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.lastCheckErrors["n1"] == "" {
+		t.Error("expected probe error detail to be reported for offline node")
+	}
+
+	// 节点恢复后错误应被清空
+	checker.online["client-1"] = true
+	store.mu.Unlock()
+	hc.checkNode(group, node)
+	store.mu.Lock()
+	if store.lastCheckErrors["n1"] != "" {
+		t.Errorf("expected probe error to be cleared on recovery, got %q", store.lastCheckErrors["n1"])
+	}
+}
+
+func TestHealthChecker_InflightGuard(t *testing.T) {
+	hc := &HealthChecker{}
+
+	if !hc.beginCheck("n1") {
+		t.Fatal("expected first beginCheck to succeed")
+	}
+	// 同一节点已有探测在进行, 后到者应被拒绝
+	if hc.beginCheck("n1") {
+		t.Error("expected concurrent beginCheck on same node to be rejected")
+	}
+	// 不同节点互不影响
+	if !hc.beginCheck("n2") {
+		t.Error("expected beginCheck on different node to succeed")
+	}
+
+	hc.endCheck("n1")
+	if !hc.beginCheck("n1") {
+		t.Error("expected beginCheck to succeed after endCheck")
+	}
+}
+
+func TestHealthChecker_SetInterval(t *testing.T) {
+	h := NewHealthChecker(&storage.Storage{}, nil)
+
+	if h.Interval() != 10*time.Second {
+		t.Errorf("default interval = %v, want 10s", h.Interval())
+	}
+
+	h.SetInterval(30 * time.Second)
+	if h.Interval() != 30*time.Second {
+		t.Errorf("interval = %v, want 30s", h.Interval())
+	}
+
+	// 非正值忽略
+	h.SetInterval(0)
+	if h.Interval() != 30*time.Second {
+		t.Errorf("interval = %v after SetInterval(0), want 30s", h.Interval())
+	}
+}
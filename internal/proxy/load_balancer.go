@@ -4,16 +4,20 @@ import (
 	"errors"
 	"hash/fnv"
 	"math/rand"
+	"strings"
 	"sync"
 	"sync/atomic"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/dollarkillerx/MessageBoy/internal/storage"
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 )
 
 var (
-	ErrNoHealthyNodes = errors.New("no healthy nodes available")
-	ErrGroupNotFound  = errors.New("proxy group not found")
+	ErrNoHealthyNodes      = errors.New("no healthy nodes available")
+	ErrGroupNotFound       = errors.New("proxy group not found")
+	ErrInvalidTagReference = errors.New("invalid tag reference, expected @tag:key=value")
 )
 
 // ProxyGroupReader abstracts proxy group read operations for testability.
@@ -25,20 +29,74 @@ type ProxyGroupReader interface {
 	DecrementNodeConns(nodeID string) error
 }
 
+// ClientTagReader abstracts tagged-client lookup for testability.
+type ClientTagReader interface {
+	ListOnlineByTag(key, value string) ([]model.Client, error)
+}
+
+// RuleReader abstracts forward-rule lookup (用于钉选节点查询).
+type RuleReader interface {
+	GetByID(id string) (*model.ForwardRule, error)
+}
+
+// ClientOnlineChecker abstracts WebSocket onliness lookup (由 relay.WSServer 实现).
+type ClientOnlineChecker interface {
+	IsClientOnline(clientID string) bool
+}
+
 // LoadBalancer 负载均衡器
 type LoadBalancer struct {
-	proxyStore ProxyGroupReader
+	proxyStore  ProxyGroupReader
+	clientStore ClientTagReader
+	ruleStore   RuleReader
+
+	// onlineChecker 客户端 WebSocket 在线状态 (可选注入)。
+	// 健康检查结果落库存在滞后, DB 健康但连接已断开的节点选出来也会在
+	// handleConnect 阶段报 "target client not online", 选择前直接过滤掉
+	onlineChecker ClientOnlineChecker
 
 	// 轮询计数器 (按组ID)
 	rrCounters map[string]*uint64
 	mu         sync.RWMutex
+
+	// selectionCounts 节点被选中的累计次数 (按节点ID, 进程内计数)。
+	// 用于观察选择分布是否倾斜 (如 ip_hash 热点)
+	selectionCounts map[string]*uint64
 }
 
 func NewLoadBalancer(s *storage.Storage) *LoadBalancer {
 	return &LoadBalancer{
-		proxyStore: s.ProxyGroup,
-		rrCounters: make(map[string]*uint64),
+		proxyStore:      s.ProxyGroup,
+		clientStore:     s.Client,
+		ruleStore:       s.Forward,
+		rrCounters:      make(map[string]*uint64),
+		selectionCounts: make(map[string]*uint64),
+	}
+}
+
+// SetOnlineChecker 注入客户端在线状态查询, 选择节点时跳过连接已断开的客户端
+func (lb *LoadBalancer) SetOnlineChecker(oc ClientOnlineChecker) {
+	lb.onlineChecker = oc
+}
+
+// filterOnlineNodes 过滤掉客户端当前没有 WebSocket 连接的节点。
+// 未注入 onlineChecker 时不过滤, 返回原切片
+func (lb *LoadBalancer) filterOnlineNodes(nodes []model.ProxyGroupNode) []model.ProxyGroupNode {
+	if lb.onlineChecker == nil {
+		return nodes
 	}
+	online := make([]model.ProxyGroupNode, 0, len(nodes))
+	for i := range nodes {
+		if lb.onlineChecker.IsClientOnline(nodes[i].ClientID) {
+			online = append(online, nodes[i])
+			continue
+		}
+		log.Debug().
+			Str("node_id", nodes[i].ID).
+			Str("client_id", nodes[i].ClientID).
+			Msg("Skipping healthy node: client not connected")
+	}
+	return online
 }
 
 // SelectNode 根据负载均衡策略选择节点
@@ -53,18 +111,68 @@ func (lb *LoadBalancer) SelectNode(groupID string, clientIP string) (*model.Prox
 		return nil, ErrNoHealthyNodes
 	}
 
+	// DB 健康但客户端已断开的节点不参与选择
+	nodes = lb.filterOnlineNodes(nodes)
+	if len(nodes) == 0 {
+		return nil, ErrNoHealthyNodes
+	}
+
+	var node *model.ProxyGroupNode
 	switch group.LoadBalanceMethod {
 	case model.LoadBalanceRoundRobin:
-		return lb.selectRoundRobin(groupID, nodes), nil
+		node = lb.selectRoundRobin(groupID, nodes)
 	case model.LoadBalanceRandom:
-		return lb.selectRandom(nodes), nil
+		node = lb.selectRandom(nodes)
 	case model.LoadBalanceLeastConn:
-		return lb.selectLeastConn(nodes), nil
+		node = lb.selectLeastConn(nodes)
 	case model.LoadBalanceIPHash:
-		return lb.selectIPHash(nodes, clientIP), nil
+		node = lb.selectIPHash(nodes, clientIP)
 	default:
-		return lb.selectRoundRobin(groupID, nodes), nil
+		node = lb.selectRoundRobin(groupID, nodes)
+	}
+
+	lb.recordSelection(node.ID)
+	return node, nil
+}
+
+// recordSelection 累计节点被选中次数 (所有选择策略共用)
+func (lb *LoadBalancer) recordSelection(nodeID string) {
+	lb.mu.Lock()
+	if lb.selectionCounts == nil {
+		lb.selectionCounts = make(map[string]*uint64)
+	}
+	counter, ok := lb.selectionCounts[nodeID]
+	if !ok {
+		var c uint64
+		counter = &c
+		lb.selectionCounts[nodeID] = counter
+	}
+	lb.mu.Unlock()
+
+	atomic.AddUint64(counter, 1)
+}
+
+// SelectionCount 返回单个节点被选中的累计次数
+func (lb *LoadBalancer) SelectionCount(nodeID string) uint64 {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	counter, ok := lb.selectionCounts[nodeID]
+	if !ok {
+		return 0
+	}
+	return atomic.LoadUint64(counter)
+}
+
+// SelectionCountsSnapshot 返回各节点被选中次数的快照 (节点ID -> 次数)
+func (lb *LoadBalancer) SelectionCountsSnapshot() map[string]uint64 {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	snapshot := make(map[string]uint64, len(lb.selectionCounts))
+	for nodeID, counter := range lb.selectionCounts {
+		snapshot[nodeID] = atomic.LoadUint64(counter)
 	}
+	return snapshot
 }
 
 // SelectNodeByGroupName 通过组名选择节点
@@ -130,9 +238,16 @@ func (lb *LoadBalancer) GetGroupIDByName(name string) (string, error) {
 	return group.ID, nil
 }
 
-// ResolveTarget 解析目标，支持 @group_name 格式
+// tagReferencePrefix 标签引用前缀 (如 @tag:region=eu)
+const tagReferencePrefix = "@tag:"
+
+// ResolveTarget 解析目标，支持 @group_name 和 @tag:key=value 格式
 // 返回: clientID, nodeID, error
 func (lb *LoadBalancer) ResolveTarget(target string, clientIP string) (string, string, error) {
+	if strings.HasPrefix(target, tagReferencePrefix) {
+		return lb.resolveTagTarget(strings.TrimPrefix(target, tagReferencePrefix))
+	}
+
 	if !storage.IsGroupReference(target) {
 		// 不是组引用，直接返回 (视为 client ID)
 		return target, "", nil
@@ -153,3 +268,82 @@ func (lb *LoadBalancer) ResolveTarget(target string, clientIP string) (string, s
 
 	return node.ClientID, node.ID, nil
 }
+
+// ResolveTargetForRule 带规则上下文的目标解析。
+// 规则配置了 PinnedNodeID 且目标是代理组引用时绕过负载均衡, 流量固定走钉选节点
+// (仅节点仍在组内且健康时生效, 否则告警后回退正常均衡); 其余情况等同 ResolveTarget
+func (lb *LoadBalancer) ResolveTargetForRule(target, clientIP, ruleID string) (string, string, error) {
+	if ruleID != "" && lb.ruleStore != nil &&
+		storage.IsGroupReference(target) && !strings.HasPrefix(target, tagReferencePrefix) {
+		if rule, err := lb.ruleStore.GetByID(ruleID); err == nil && rule.PinnedNodeID != "" {
+			if node := lb.healthyPinnedNode(storage.ParseGroupReference(target), rule.PinnedNodeID); node != nil {
+				log.Info().
+					Str("rule_id", ruleID).
+					Str("pinned_node", node.ID).
+					Str("client_id", node.ClientID).
+					Msg("Rule pinned to node, bypassing load balancing")
+				lb.recordSelection(node.ID)
+				return node.ClientID, node.ID, nil
+			}
+			log.Warn().
+				Str("rule_id", ruleID).
+				Str("pinned_node", rule.PinnedNodeID).
+				Msg("Pinned node not healthy or not in group, falling back to load balancing")
+		}
+	}
+	return lb.ResolveTarget(target, clientIP)
+}
+
+// healthyPinnedNode 在组的健康节点中查找钉选节点, 未找到返回 nil
+func (lb *LoadBalancer) healthyPinnedNode(groupRef, nodeID string) *model.ProxyGroupNode {
+	groupID := groupRef
+	if _, err := lb.proxyStore.GetByID(groupRef); err != nil {
+		group, err := lb.proxyStore.GetByName(groupRef)
+		if err != nil {
+			return nil
+		}
+		groupID = group.ID
+	}
+
+	nodes, err := lb.proxyStore.GetHealthyNodesByGroupID(groupID)
+	if err != nil {
+		return nil
+	}
+	for i := range nodes {
+		if nodes[i].ID == nodeID {
+			// 钉选节点的客户端连接已断开时视同不健康, 回退正常均衡
+			if lb.onlineChecker != nil && !lb.onlineChecker.IsClientOnline(nodes[i].ClientID) {
+				return nil
+			}
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+// resolveTagTarget 在携带指定标签的在线客户端中轮询选择出口。
+// 标签引用无需预建代理组, 也没有对应的组节点, 返回的 nodeID 为空。
+func (lb *LoadBalancer) resolveTagTarget(ref string) (string, string, error) {
+	key, value, ok := strings.Cut(ref, "=")
+	if !ok || key == "" || value == "" {
+		return "", "", ErrInvalidTagReference
+	}
+
+	clients, err := lb.clientStore.ListOnlineByTag(key, value)
+	if err != nil || len(clients) == 0 {
+		return "", "", ErrNoHealthyNodes
+	}
+
+	lb.mu.Lock()
+	counterKey := tagReferencePrefix + ref
+	counter, ok := lb.rrCounters[counterKey]
+	if !ok {
+		var c uint64
+		counter = &c
+		lb.rrCounters[counterKey] = counter
+	}
+	lb.mu.Unlock()
+
+	idx := atomic.AddUint64(counter, 1) % uint64(len(clients))
+	return clients[idx].ID, "", nil
+}
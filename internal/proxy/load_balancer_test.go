@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 
@@ -276,6 +277,64 @@ func TestLoadBalancer_SelectNode_GroupNotFound(t *testing.T) {
 	}
 }
 
+// mockOnlineChecker 按集合回答客户端是否在线
+type mockOnlineChecker struct {
+	online map[string]bool
+}
+
+func (m *mockOnlineChecker) IsClientOnline(clientID string) bool {
+	return m.online[clientID]
+}
+
+func TestLoadBalancer_SelectNode_SkipsDisconnectedClients(t *testing.T) {
+	mock := &mockProxyGroupReader{
+		groups: map[string]*model.ProxyGroup{
+			"g1": {ID: "g1", LoadBalanceMethod: model.LoadBalanceRoundRobin},
+		},
+		healthyNodes: map[string][]model.ProxyGroupNode{
+			"g1": {
+				{ID: "n1", ClientID: "c1"},
+				{ID: "n2", ClientID: "c2"},
+			},
+		},
+	}
+
+	lb := &LoadBalancer{proxyStore: mock, rrCounters: make(map[string]*uint64)}
+	// c1 在 DB 中健康但 WebSocket 已断开
+	lb.SetOnlineChecker(&mockOnlineChecker{online: map[string]bool{"c2": true}})
+
+	for i := 0; i < 4; i++ {
+		node, err := lb.SelectNode("g1", "")
+		if err != nil {
+			t.Fatalf("SelectNode error: %v", err)
+		}
+		if node.ID != "n2" {
+			t.Errorf("selection %d: expected n2 (only online client), got %s", i, node.ID)
+		}
+	}
+}
+
+func TestLoadBalancer_SelectNode_AllClientsDisconnected(t *testing.T) {
+	mock := &mockProxyGroupReader{
+		groups: map[string]*model.ProxyGroup{
+			"g1": {ID: "g1"},
+		},
+		healthyNodes: map[string][]model.ProxyGroupNode{
+			"g1": {
+				{ID: "n1", ClientID: "c1"},
+			},
+		},
+	}
+
+	lb := &LoadBalancer{proxyStore: mock, rrCounters: make(map[string]*uint64)}
+	lb.SetOnlineChecker(&mockOnlineChecker{online: map[string]bool{}})
+
+	_, err := lb.SelectNode("g1", "")
+	if !errors.Is(err, ErrNoHealthyNodes) {
+		t.Errorf("expected ErrNoHealthyNodes when all clients are disconnected, got %v", err)
+	}
+}
+
 func TestLoadBalancer_SelectNodeByGroupName(t *testing.T) {
 	mock := &mockProxyGroupReader{
 		groups: map[string]*model.ProxyGroup{
@@ -399,3 +458,222 @@ func TestLoadBalancer_DecrementConnections(t *testing.T) {
 		t.Errorf("expected DecrementNodeConns(n1), got %v", mock.decrCalls)
 	}
 }
+
+// mockClientTagReader 按标签过滤固定的在线客户端集合
+type mockClientTagReader struct {
+	clients []model.Client
+}
+
+func (m *mockClientTagReader) ListOnlineByTag(key, value string) ([]model.Client, error) {
+	matched := make([]model.Client, 0)
+	for _, c := range m.clients {
+		if c.Tags[key] == value {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
+
+func TestLoadBalancer_ResolveTarget_TagReference(t *testing.T) {
+	mock := &mockClientTagReader{clients: []model.Client{
+		{ID: "eu-1", Tags: model.StringMap{"region": "eu"}},
+		{ID: "eu-2", Tags: model.StringMap{"region": "eu"}},
+		{ID: "us-1", Tags: model.StringMap{"region": "us"}},
+	}}
+	lb := &LoadBalancer{clientStore: mock, rrCounters: make(map[string]*uint64)}
+
+	counts := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		clientID, nodeID, err := lb.ResolveTarget("@tag:region=eu", "")
+		if err != nil {
+			t.Fatalf("ResolveTarget error: %v", err)
+		}
+		if nodeID != "" {
+			t.Errorf("expected empty nodeID for tag reference, got %s", nodeID)
+		}
+		if clientID == "us-1" {
+			t.Fatal("selection escaped the tagged set")
+		}
+		counts[clientID]++
+	}
+
+	// 轮询: 两个 eu 客户端各命中三次
+	for _, id := range []string{"eu-1", "eu-2"} {
+		if counts[id] != 3 {
+			t.Errorf("expected client %s selected 3 times, got %d", id, counts[id])
+		}
+	}
+}
+
+func TestLoadBalancer_ResolveTarget_TagNoMatch(t *testing.T) {
+	mock := &mockClientTagReader{clients: []model.Client{
+		{ID: "us-1", Tags: model.StringMap{"region": "us"}},
+	}}
+	lb := &LoadBalancer{clientStore: mock, rrCounters: make(map[string]*uint64)}
+
+	_, _, err := lb.ResolveTarget("@tag:region=eu", "")
+	if !errors.Is(err, ErrNoHealthyNodes) {
+		t.Errorf("expected ErrNoHealthyNodes, got %v", err)
+	}
+}
+
+func TestLoadBalancer_ResolveTarget_InvalidTagReference(t *testing.T) {
+	lb := &LoadBalancer{clientStore: &mockClientTagReader{}, rrCounters: make(map[string]*uint64)}
+
+	for _, ref := range []string{"@tag:region", "@tag:=eu", "@tag:region="} {
+		if _, _, err := lb.ResolveTarget(ref, ""); !errors.Is(err, ErrInvalidTagReference) {
+			t.Errorf("expected ErrInvalidTagReference for %q, got %v", ref, err)
+		}
+	}
+}
+
+func TestLoadBalancer_SelectionCounts_SumMatchesSelections(t *testing.T) {
+	mock := &mockProxyGroupReader{
+		groups: map[string]*model.ProxyGroup{
+			"rr":   {ID: "rr", LoadBalanceMethod: model.LoadBalanceRoundRobin},
+			"hash": {ID: "hash", LoadBalanceMethod: model.LoadBalanceIPHash},
+		},
+		healthyNodes: map[string][]model.ProxyGroupNode{
+			"rr":   {{ID: "n1"}, {ID: "n2"}, {ID: "n3"}},
+			"hash": {{ID: "h1"}, {ID: "h2"}},
+		},
+	}
+
+	lb := &LoadBalancer{proxyStore: mock, rrCounters: make(map[string]*uint64)}
+
+	const selections = 300
+	for i := 0; i < selections; i++ {
+		group, ip := "rr", ""
+		if i%2 == 1 {
+			group, ip = "hash", fmt.Sprintf("10.0.0.%d", i%7)
+		}
+		if _, err := lb.SelectNode(group, ip); err != nil {
+			t.Fatalf("SelectNode error: %v", err)
+		}
+	}
+
+	var sum uint64
+	for _, count := range lb.SelectionCountsSnapshot() {
+		sum += count
+	}
+	if sum != selections {
+		t.Errorf("selection counts sum = %d, want %d", sum, selections)
+	}
+
+	// 轮询组的三个节点被均分
+	for _, id := range []string{"n1", "n2", "n3"} {
+		if got := lb.SelectionCount(id); got != 50 {
+			t.Errorf("node %s selection count = %d, want 50", id, got)
+		}
+	}
+}
+
+func TestLoadBalancer_SelectionCount_UnknownNodeZero(t *testing.T) {
+	lb := &LoadBalancer{rrCounters: make(map[string]*uint64)}
+	if got := lb.SelectionCount("missing"); got != 0 {
+		t.Errorf("selection count for unknown node = %d, want 0", got)
+	}
+}
+
+// --- mockRuleReader implements RuleReader for testing ---
+
+type mockRuleReader struct {
+	rules map[string]*model.ForwardRule
+}
+
+func (m *mockRuleReader) GetByID(id string) (*model.ForwardRule, error) {
+	r, ok := m.rules[id]
+	if !ok {
+		return nil, errors.New("rule not found")
+	}
+	return r, nil
+}
+
+func TestResolveTargetForRule_PinnedNodeAlwaysSelected(t *testing.T) {
+	store := &mockProxyGroupReader{
+		groups: map[string]*model.ProxyGroup{
+			"g1": {ID: "g1", LoadBalanceMethod: model.LoadBalanceRoundRobin},
+		},
+		healthyNodes: map[string][]model.ProxyGroupNode{
+			"g1": {
+				{ID: "n1", ClientID: "c1"},
+				{ID: "n2", ClientID: "c2"},
+				{ID: "n3", ClientID: "c3"},
+			},
+		},
+	}
+	rule := &model.ForwardRule{ID: "rule-1", PinnedNodeID: "n2"}
+	lb := &LoadBalancer{
+		proxyStore: store,
+		ruleStore:  &mockRuleReader{rules: map[string]*model.ForwardRule{"rule-1": rule}},
+		rrCounters: make(map[string]*uint64),
+	}
+
+	for i := 0; i < 10; i++ {
+		clientID, nodeID, err := lb.ResolveTargetForRule("@g1", "10.0.0.1", "rule-1")
+		if err != nil {
+			t.Fatalf("resolve %d failed: %v", i, err)
+		}
+		if nodeID != "n2" || clientID != "c2" {
+			t.Fatalf("resolve %d = (%s, %s), want pinned (c2, n2)", i, clientID, nodeID)
+		}
+	}
+	if got := lb.SelectionCount("n2"); got != 10 {
+		t.Errorf("pinned node selection count = %d, want 10", got)
+	}
+
+	// 解除钉选后恢复正常轮询
+	rule.PinnedNodeID = ""
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		_, nodeID, err := lb.ResolveTargetForRule("@g1", "10.0.0.1", "rule-1")
+		if err != nil {
+			t.Fatalf("resolve after unpin failed: %v", err)
+		}
+		seen[nodeID] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("after clearing pin, round robin should cycle all 3 nodes, got %v", seen)
+	}
+}
+
+func TestResolveTargetForRule_UnhealthyPinFallsBack(t *testing.T) {
+	store := &mockProxyGroupReader{
+		groups: map[string]*model.ProxyGroup{
+			"g1": {ID: "g1", LoadBalanceMethod: model.LoadBalanceRoundRobin},
+		},
+		healthyNodes: map[string][]model.ProxyGroupNode{
+			"g1": {{ID: "n1", ClientID: "c1"}},
+		},
+	}
+	// 钉选节点不在健康节点列表中
+	rule := &model.ForwardRule{ID: "rule-1", PinnedNodeID: "gone"}
+	lb := &LoadBalancer{
+		proxyStore: store,
+		ruleStore:  &mockRuleReader{rules: map[string]*model.ForwardRule{"rule-1": rule}},
+		rrCounters: make(map[string]*uint64),
+	}
+
+	clientID, nodeID, err := lb.ResolveTargetForRule("@g1", "10.0.0.1", "rule-1")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if nodeID != "n1" || clientID != "c1" {
+		t.Errorf("resolve = (%s, %s), want fallback to healthy node (c1, n1)", clientID, nodeID)
+	}
+}
+
+func TestResolveTargetForRule_NoRuleBehavesLikeResolveTarget(t *testing.T) {
+	lb := &LoadBalancer{
+		proxyStore: &mockProxyGroupReader{},
+		rrCounters: make(map[string]*uint64),
+	}
+
+	clientID, nodeID, err := lb.ResolveTargetForRule("plain-client", "10.0.0.1", "")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if clientID != "plain-client" || nodeID != "" {
+		t.Errorf("resolve = (%s, %s), want direct client passthrough", clientID, nodeID)
+	}
+}
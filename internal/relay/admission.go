@@ -0,0 +1,48 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// admissionController 重连风暴抑制器。
+// 服务端冷启动后大量客户端同时重连, 注册与路由重建会瞬时挤占 CPU 和数据库;
+// 控制器把 WebSocket 升级的放行速率压平到固定水平: 超出速率的连接按到达顺序
+// 排队等待自己的放行时刻, 预计等待超过上限的直接剪除 (调用方返回可重试错误,
+// 由客户端退避后重连)
+type admissionController struct {
+	mu       sync.Mutex
+	interval time.Duration // 相邻两次放行的最小间隔
+	maxWait  time.Duration // 排队等待上限, 预计等待超过该值的连接被剪除
+	nextSlot time.Time     // 下一个放行时刻
+}
+
+func newAdmissionController(ratePerSec int, maxWait time.Duration) *admissionController {
+	return &admissionController{
+		interval: time.Second / time.Duration(ratePerSec),
+		maxWait:  maxWait,
+	}
+}
+
+// admit 申请一个放行额度: 领到最近的空闲时刻后睡到该时刻再返回 true (平滑突发),
+// 预计等待超过上限时立即返回 false (剪除)。额度分配在锁内完成, 等待在锁外进行
+func (a *admissionController) admit() bool {
+	a.mu.Lock()
+	now := time.Now()
+	slot := a.nextSlot
+	if slot.Before(now) {
+		slot = now
+	}
+	wait := slot.Sub(now)
+	if wait > a.maxWait {
+		a.mu.Unlock()
+		return false
+	}
+	a.nextSlot = slot.Add(a.interval)
+	a.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAdmissionController_BurstAdmittedAtBoundedRate 验证突发到达的申请
+// 按固定速率放行, 排队超限的被剪除
+func TestAdmissionController_BurstAdmittedAtBoundedRate(t *testing.T) {
+	// 每秒 10 个 (间隔 100ms), 排队上限 250ms: 突发下放行时刻为 0/100/200ms,
+	// 第 4 个起预计等待 300ms 超限被剪除
+	ac := newAdmissionController(10, 250*time.Millisecond)
+
+	const burst = 10
+	start := time.Now()
+	var mu sync.Mutex
+	var admittedAt []time.Duration
+	var shed int
+
+	var wg sync.WaitGroup
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok := ac.admit()
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				admittedAt = append(admittedAt, time.Since(start))
+			} else {
+				shed++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(admittedAt) != 3 {
+		t.Fatalf("admitted = %d, want 3 (shed = %d)", len(admittedAt), shed)
+	}
+	if shed != burst-3 {
+		t.Errorf("shed = %d, want %d", shed, burst-3)
+	}
+
+	// 最后一个放行时刻应不早于 2 个间隔 (速率被压平而不是一次性放完)
+	var last time.Duration
+	for _, at := range admittedAt {
+		if at > last {
+			last = at
+		}
+	}
+	if last < 180*time.Millisecond {
+		t.Errorf("last admission at %v, want >= ~200ms (rate not bounded)", last)
+	}
+}
+
+// TestAdmissionController_RecoversAfterBurst 验证突发过后速率额度恢复
+func TestAdmissionController_RecoversAfterBurst(t *testing.T) {
+	// 排队上限 0: 没有空闲额度时立即剪除
+	ac := newAdmissionController(100, 0)
+
+	if !ac.admit() {
+		t.Fatal("first admit should succeed")
+	}
+	if ac.admit() {
+		t.Error("immediate second admit should be shed")
+	}
+
+	// 静默一段时间后应重新放行
+	time.Sleep(50 * time.Millisecond)
+	if !ac.admit() {
+		t.Error("admit after quiet period should succeed")
+	}
+}
+
+// TestWSServer_AdmissionShedsWithRetryableClose 验证升级入口接入抑制器:
+// 超出速率的连接收到 503 + Retry-After, 放行的正常进入升级流程
+func TestWSServer_AdmissionShedsWithRetryableClose(t *testing.T) {
+	server := NewWSServer()
+	// 每秒 5 个 (间隔 200ms), 排队上限 250ms: 突发下只放行 2 个
+	server.SetAdmissionLimits(5, 250*time.Millisecond)
+
+	srv := httptest.NewServer(http.HandlerFunc(server.HandleConnection))
+	defer srv.Close()
+
+	// 普通 GET 不是合法的升级请求: 放行的在 Upgrade 阶段拿到 400,
+	// 被剪除的拿到 503, 以此区分两种结局
+	const burst = 8
+	type outcome struct {
+		status     int
+		retryAfter string
+	}
+	results := make(chan outcome, burst)
+	var wg sync.WaitGroup
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			resp, err := http.Get(fmt.Sprintf("%s?client_id=burst-%d", srv.URL, n))
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+			results <- outcome{status: resp.StatusCode, retryAfter: resp.Header.Get("Retry-After")}
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+
+	var admitted, shed int
+	for r := range results {
+		switch r.status {
+		case http.StatusBadRequest:
+			admitted++
+		case http.StatusServiceUnavailable:
+			shed++
+			if r.retryAfter == "" {
+				t.Error("shed connection missing Retry-After header")
+			}
+		default:
+			t.Errorf("unexpected status %d", r.status)
+		}
+	}
+
+	if admitted != 2 {
+		t.Errorf("admitted = %d, want 2", admitted)
+	}
+	if shed != burst-2 {
+		t.Errorf("shed = %d, want %d", shed, burst-2)
+	}
+}
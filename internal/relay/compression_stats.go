@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sort"
+	"sync"
+)
+
+// CompressionStats 按规则累计压缩前后字节数, 用于观察载荷压缩的实际收益。
+// CompressPayload/DecompressPayload 是 marshal 路径的压缩步骤,
+// 压缩处调用 CompressPayload 即会自动累计统计
+type CompressionStats struct {
+	mu    sync.RWMutex
+	rules map[string]*ruleCompression
+}
+
+type ruleCompression struct {
+	rawBytes        int64
+	compressedBytes int64
+}
+
+// CompressionSnapshot 单条规则的压缩统计快照
+type CompressionSnapshot struct {
+	RuleID          string  `json:"rule_id"`
+	RawBytes        int64   `json:"raw_bytes"`
+	CompressedBytes int64   `json:"compressed_bytes"`
+	Ratio           float64 `json:"ratio"` // 压缩前/压缩后, 大于 1 表示有收益
+}
+
+func NewCompressionStats() *CompressionStats {
+	return &CompressionStats{
+		rules: make(map[string]*ruleCompression),
+	}
+}
+
+// Record 累计一次压缩的前后字节数
+func (s *CompressionStats) Record(ruleID string, rawBytes, compressedBytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rc, ok := s.rules[ruleID]
+	if !ok {
+		rc = &ruleCompression{}
+		s.rules[ruleID] = rc
+	}
+	rc.rawBytes += int64(rawBytes)
+	rc.compressedBytes += int64(compressedBytes)
+}
+
+// CompressPayload 压缩载荷并记录统计。压缩采用 flate BestSpeed, 偏向转发吞吐
+func (s *CompressionStats) CompressPayload(ruleID string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	compressed := buf.Bytes()
+	s.Record(ruleID, len(data), len(compressed))
+	return compressed, nil
+}
+
+// DecompressPayload 解压 CompressPayload 的输出
+func DecompressPayload(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// RuleSnapshot 返回单条规则的压缩统计, 无记录时 ok 为 false
+func (s *CompressionStats) RuleSnapshot(ruleID string) (CompressionSnapshot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rc, ok := s.rules[ruleID]
+	if !ok {
+		return CompressionSnapshot{}, false
+	}
+	return rc.snapshot(ruleID), true
+}
+
+// Snapshot 返回全部规则的压缩统计 (按规则 ID 排序)
+func (s *CompressionStats) Snapshot() []CompressionSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]CompressionSnapshot, 0, len(s.rules))
+	for ruleID, rc := range s.rules {
+		result = append(result, rc.snapshot(ruleID))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].RuleID < result[j].RuleID })
+	return result
+}
+
+func (rc *ruleCompression) snapshot(ruleID string) CompressionSnapshot {
+	snap := CompressionSnapshot{
+		RuleID:          ruleID,
+		RawBytes:        rc.rawBytes,
+		CompressedBytes: rc.compressedBytes,
+	}
+	if rc.compressedBytes > 0 {
+		snap.Ratio = float64(rc.rawBytes) / float64(rc.compressedBytes)
+	}
+	return snap
+}
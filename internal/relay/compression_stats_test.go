@@ -0,0 +1,70 @@
+package relay
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressionStats_CompressPayload_RatioGreaterThanOne(t *testing.T) {
+	stats := NewCompressionStats()
+
+	// 高度可压缩的载荷
+	raw := bytes.Repeat([]byte("abcdefgh"), 4096)
+	compressed, err := stats.CompressPayload("rule-1", raw)
+	if err != nil {
+		t.Fatalf("CompressPayload failed: %v", err)
+	}
+	if len(compressed) >= len(raw) {
+		t.Fatalf("compressed size %d >= raw size %d", len(compressed), len(raw))
+	}
+
+	snap, ok := stats.RuleSnapshot("rule-1")
+	if !ok {
+		t.Fatal("expected snapshot for rule-1")
+	}
+	if snap.RawBytes != int64(len(raw)) {
+		t.Errorf("RawBytes = %d, want %d", snap.RawBytes, len(raw))
+	}
+	if snap.CompressedBytes != int64(len(compressed)) {
+		t.Errorf("CompressedBytes = %d, want %d", snap.CompressedBytes, len(compressed))
+	}
+	if snap.Ratio <= 1 {
+		t.Errorf("Ratio = %f, want > 1 for compressible payload", snap.Ratio)
+	}
+
+	// 解压还原
+	restored, err := DecompressPayload(compressed)
+	if err != nil {
+		t.Fatalf("DecompressPayload failed: %v", err)
+	}
+	if !bytes.Equal(restored, raw) {
+		t.Error("decompressed payload does not match original")
+	}
+}
+
+func TestCompressionStats_SnapshotAccumulates(t *testing.T) {
+	stats := NewCompressionStats()
+	stats.Record("rule-b", 1000, 400)
+	stats.Record("rule-b", 1000, 600)
+	stats.Record("rule-a", 500, 500)
+
+	snaps := stats.Snapshot()
+	if len(snaps) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snaps))
+	}
+
+	// 按规则 ID 排序
+	if snaps[0].RuleID != "rule-a" || snaps[1].RuleID != "rule-b" {
+		t.Errorf("unexpected order: %s, %s", snaps[0].RuleID, snaps[1].RuleID)
+	}
+	if snaps[1].RawBytes != 2000 || snaps[1].CompressedBytes != 1000 {
+		t.Errorf("rule-b accumulation = %d/%d, want 2000/1000", snaps[1].RawBytes, snaps[1].CompressedBytes)
+	}
+	if snaps[1].Ratio != 2.0 {
+		t.Errorf("rule-b ratio = %f, want 2.0", snaps[1].Ratio)
+	}
+
+	if _, ok := stats.RuleSnapshot("missing"); ok {
+		t.Error("expected no snapshot for unknown rule")
+	}
+}
@@ -0,0 +1,86 @@
+package relay
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 规则建连并发限制: 限制同一规则同时处于 Connect→ConnAck 建连阶段的连接数,
+// 入口突发大量新建连接时避免出口被成批的同时拨号打垮。
+// 只约束建连阶段, 已确认 (ConnAck 后) 的存量连接不受影响。
+
+// connectQueueWait 槽位占满时新建连的最长排队时长, 超时才拒绝
+const connectQueueWait = 2 * time.Second
+
+// connectLimiter 单条规则的建连并发限制器 (信号量语义)
+type connectLimiter struct {
+	limit int
+	sem   chan struct{}
+}
+
+// tryAcquire 非阻塞占用一个建连槽位
+func (l *connectLimiter) tryAcquire() bool {
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire 占用一个建连槽位, 最多排队 connectQueueWait, 返回 false 表示排队超时
+func (l *connectLimiter) acquire() bool {
+	timer := time.NewTimer(connectQueueWait)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// release 归还建连槽位
+func (l *connectLimiter) release() {
+	<-l.sem
+}
+
+// SetConnectConcurrencyLookup 注入规则建连并发上限查询 (<=0 表示不限制)。
+// 应在开始服务前调用
+func (s *WSServer) SetConnectConcurrencyLookup(fn func(ruleID string) int) {
+	s.connectConcurrency = fn
+}
+
+// connectLimiterFor 返回规则对应的建连限制器, 未配置上限时为 nil。
+// 上限变化时换用新限制器, 旧限制器的存量槽位随各自路由结束释放后被回收
+func (s *WSServer) connectLimiterFor(ruleID string) *connectLimiter {
+	if s.connectConcurrency == nil || ruleID == "" {
+		return nil
+	}
+	limit := s.connectConcurrency(ruleID)
+	if limit <= 0 {
+		s.connectLimiters.Delete(ruleID)
+		return nil
+	}
+	if v, ok := s.connectLimiters.Load(ruleID); ok {
+		if l := v.(*connectLimiter); l.limit == limit {
+			return l
+		}
+	}
+	l := &connectLimiter{limit: limit, sem: make(chan struct{}, limit)}
+	s.connectLimiters.Store(ruleID, l)
+	return l
+}
+
+// finishConnecting 标记路由建连阶段结束并归还限制器槽位。
+// connecting 的 1→0 转换统一经此处, CAS 保证槽位只归还一次;
+// 返回 true 表示本次调用完成了该转换
+func (s *WSServer) finishConnecting(route *RouteInfo) bool {
+	if !atomic.CompareAndSwapInt32(&route.connecting, 1, 0) {
+		return false
+	}
+	if route.limiter != nil {
+		route.limiter.release()
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+// drainConnects 在 timeout 内持续收取 Connect 帧, 返回收到的 StreamID
+func drainConnects(t *testing.T, ch chan *sendItem, want int, timeout time.Duration) []uint32 {
+	t.Helper()
+	var ids []uint32
+	deadline := time.After(timeout)
+	for len(ids) < want {
+		select {
+		case item := <-ch:
+			msg := unmarshalItem(t, item)
+			if msg.Type != MsgTypeConnect {
+				t.Fatalf("expected MsgTypeConnect, got %d", msg.Type)
+			}
+			ids = append(ids, msg.StreamID)
+		case <-deadline:
+			t.Fatalf("received %d connects within %v, want %d", len(ids), timeout, want)
+		}
+	}
+	return ids
+}
+
+// assertNoItem 断言窗口内没有新帧送达
+func assertNoItem(t *testing.T, ch chan *sendItem, window time.Duration) {
+	t.Helper()
+	select {
+	case item := <-ch:
+		msg := unmarshalItem(t, item)
+		t.Fatalf("unexpected message type %d (stream %d) while limit saturated", msg.Type, msg.StreamID)
+	case <-time.After(window):
+	}
+}
+
+func TestHandleConnect_EstablishmentConcurrencyBounded(t *testing.T) {
+	s := NewWSServer()
+	s.SetConnectConcurrencyLookup(func(ruleID string) int {
+		if ruleID == "limited-rule" {
+			return 2
+		}
+		return 0
+	})
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	// 突发 5 个新建连接, 只有 2 个应立即转发到出口
+	for id := uint32(1); id <= 5; id++ {
+		s.handleConnect("sender", &TunnelMessage{
+			Type:     MsgTypeConnect,
+			StreamID: id,
+			Target:   "127.0.0.1:8080",
+			RuleID:   "limited-rule",
+			Payload:  []byte("target"),
+		})
+	}
+
+	first := drainConnects(t, target.SendCh, 2, time.Second)
+	assertNoItem(t, target.SendCh, 200*time.Millisecond)
+
+	// ConnAck 释放槽位后, 排队中的建连继续转发
+	for _, id := range first {
+		s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: id})
+	}
+	second := drainConnects(t, target.SendCh, 2, time.Second)
+	assertNoItem(t, target.SendCh, 200*time.Millisecond)
+
+	for _, id := range second {
+		s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: id})
+	}
+	drainConnects(t, target.SendCh, 1, time.Second)
+
+	// 不设上限的规则不受影响, 全部立即转发
+	for id := uint32(10); id < 14; id++ {
+		s.handleConnect("sender", &TunnelMessage{
+			Type:     MsgTypeConnect,
+			StreamID: id,
+			Target:   "127.0.0.1:8080",
+			RuleID:   "open-rule",
+			Payload:  []byte("target"),
+		})
+	}
+	drainConnects(t, target.SendCh, 4, time.Second)
+}
+
+func TestHandleConnect_SteadyStateUnaffectedByLimit(t *testing.T) {
+	s := NewWSServer()
+	s.SetConnectConcurrencyLookup(func(string) int { return 1 })
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	// 建立一条连接并确认, 进入稳态
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "127.0.0.1:8080",
+		RuleID:   "rule-1",
+		Payload:  []byte("target"),
+	})
+	drainConnects(t, target.SendCh, 1, time.Second)
+	s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: 1})
+	drainOneItem(t, sender.SendCh, time.Second) // 转回 sender 的 ConnAck
+
+	// 占满建连槽位 (stream 2 在建, stream 3 排队)
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 2,
+		Target:   "127.0.0.1:8080",
+		RuleID:   "rule-1",
+		Payload:  []byte("target"),
+	})
+	drainConnects(t, target.SendCh, 1, time.Second)
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 3,
+		Target:   "127.0.0.1:8080",
+		RuleID:   "rule-1",
+		Payload:  []byte("target"),
+	})
+
+	// 建连限流不影响稳态连接: stream 1 的数据照常转发
+	s.handleData("sender", &TunnelMessage{
+		Type:     MsgTypeData,
+		StreamID: 1,
+		Payload:  []byte("steady-state data"),
+	})
+	item := drainOneItem(t, target.SendCh, time.Second)
+	msg := unmarshalItem(t, item)
+	if msg.Type != MsgTypeData {
+		t.Fatalf("expected MsgTypeData, got %d (stream %d)", msg.Type, msg.StreamID)
+	}
+	if msg.StreamID != 1 {
+		t.Errorf("data StreamID = %d, want 1", msg.StreamID)
+	}
+
+	// stream 2 确认后, 排队的 stream 3 才转发
+	s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: 2})
+	ids := drainConnects(t, target.SendCh, 1, time.Second)
+	if ids[0] != 3 {
+		t.Errorf("queued connect StreamID = %d, want 3", ids[0])
+	}
+}
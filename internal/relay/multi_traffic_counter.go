@@ -0,0 +1,72 @@
+package relay
+
+// MultiTrafficCounter 把每次统计调用扇出到全部已注册的 sink
+// (如 DB 仓库 + Prometheus 导出 + 审计), 新增观测目的地无需改动中继本身。
+// sink 实现 TrafficLabelCounter 时按标签细分的调用也会转发, 其余 sink
+// 对带标签的流量仍收到按规则汇总的普通调用
+type MultiTrafficCounter struct {
+	sinks []TrafficCounterInterface
+}
+
+// NewMultiTrafficCounter 创建扇出计数器
+func NewMultiTrafficCounter(sinks ...TrafficCounterInterface) *MultiTrafficCounter {
+	return &MultiTrafficCounter{sinks: sinks}
+}
+
+// AddSink 追加一个 sink (应在开始服务前调用)
+func (m *MultiTrafficCounter) AddSink(sink TrafficCounterInterface) {
+	m.sinks = append(m.sinks, sink)
+}
+
+func (m *MultiTrafficCounter) AddBytesIn(ruleID, clientID string, bytes int64) {
+	for _, sink := range m.sinks {
+		sink.AddBytesIn(ruleID, clientID, bytes)
+	}
+}
+
+func (m *MultiTrafficCounter) AddBytesOut(ruleID, clientID string, bytes int64) {
+	for _, sink := range m.sinks {
+		sink.AddBytesOut(ruleID, clientID, bytes)
+	}
+}
+
+func (m *MultiTrafficCounter) AddDroppedBytes(ruleID, clientID string, bytes int64) {
+	for _, sink := range m.sinks {
+		sink.AddDroppedBytes(ruleID, clientID, bytes)
+	}
+}
+
+func (m *MultiTrafficCounter) IncrementConn(ruleID, clientID string) {
+	for _, sink := range m.sinks {
+		sink.IncrementConn(ruleID, clientID)
+	}
+}
+
+func (m *MultiTrafficCounter) DecrementConn(ruleID, clientID string) {
+	for _, sink := range m.sinks {
+		sink.DecrementConn(ruleID, clientID)
+	}
+}
+
+// AddBytesInLabeled 实现 TrafficLabelCounter: 支持标签的 sink 收到细分调用,
+// 不支持的退回按规则汇总, 保证每个 sink 的总量口径一致
+func (m *MultiTrafficCounter) AddBytesInLabeled(ruleID, clientID, label string, bytes int64) {
+	for _, sink := range m.sinks {
+		if lc, ok := sink.(TrafficLabelCounter); ok {
+			lc.AddBytesInLabeled(ruleID, clientID, label, bytes)
+		} else {
+			sink.AddBytesIn(ruleID, clientID, bytes)
+		}
+	}
+}
+
+// AddBytesOutLabeled 实现 TrafficLabelCounter (语义同 AddBytesInLabeled)
+func (m *MultiTrafficCounter) AddBytesOutLabeled(ruleID, clientID, label string, bytes int64) {
+	for _, sink := range m.sinks {
+		if lc, ok := sink.(TrafficLabelCounter); ok {
+			lc.AddBytesOutLabeled(ruleID, clientID, label, bytes)
+		} else {
+			sink.AddBytesOut(ruleID, clientID, bytes)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package relay
+
+import (
+	"testing"
+)
+
+func TestMultiTrafficCounter_FansOutToAllSinks(t *testing.T) {
+	a := &mockTrafficCounter{}
+	b := &mockTrafficCounter{}
+	multi := NewMultiTrafficCounter(a, b)
+
+	multi.AddBytesIn("rule-1", "client-1", 100)
+	multi.IncrementConn("rule-1", "client-1")
+
+	for i, sink := range []*mockTrafficCounter{a, b} {
+		if sink.bytesInRule != "rule-1" || sink.bytesIn != 100 {
+			t.Errorf("sink %d: AddBytesIn not forwarded (rule=%q bytes=%d)", i, sink.bytesInRule, sink.bytesIn)
+		}
+		if sink.incrementedRule != "rule-1" || sink.incrementedClient != "client-1" {
+			t.Errorf("sink %d: IncrementConn not forwarded (rule=%q client=%q)", i, sink.incrementedRule, sink.incrementedClient)
+		}
+	}
+
+	multi.AddBytesOut("rule-1", "client-1", 50)
+	multi.AddDroppedBytes("rule-1", "client-1", 7)
+	multi.DecrementConn("rule-1", "client-1")
+	for i, sink := range []*mockTrafficCounter{a, b} {
+		if sink.bytesOut != 50 || sink.droppedBytes != 7 || sink.decrementedRule != "rule-1" {
+			t.Errorf("sink %d: remaining calls not forwarded (out=%d dropped=%d decremented=%q)",
+				i, sink.bytesOut, sink.droppedBytes, sink.decrementedRule)
+		}
+	}
+}
+
+func TestMultiTrafficCounter_AddSink(t *testing.T) {
+	multi := NewMultiTrafficCounter()
+	a := &mockTrafficCounter{}
+	multi.AddSink(a)
+
+	multi.AddBytesIn("rule-2", "client-2", 9)
+	if a.bytesInRule != "rule-2" || a.bytesIn != 9 {
+		t.Errorf("appended sink did not receive call (rule=%q bytes=%d)", a.bytesInRule, a.bytesIn)
+	}
+}
+
+func TestMultiTrafficCounter_LabeledFallsBackForPlainSinks(t *testing.T) {
+	labeled := &labeledTrafficCounter{}
+	plain := &mockTrafficCounter{}
+	multi := NewMultiTrafficCounter(labeled, plain)
+
+	// 扇出计数器自身暴露标签扩展, 中继的类型断言应命中
+	var _ TrafficLabelCounter = multi
+
+	multi.AddBytesInLabeled("rule-3", "client-3", "tenant-a", 11)
+	if labeled.labeledRule != "rule-3" || labeled.label != "tenant-a" || labeled.labeledIn != 11 {
+		t.Errorf("labeled sink missed labeled call (rule=%q label=%q in=%d)",
+			labeled.labeledRule, labeled.label, labeled.labeledIn)
+	}
+	// 不支持标签的 sink 退回按规则汇总, 总量口径不变
+	if plain.bytesInRule != "rule-3" || plain.bytesIn != 11 {
+		t.Errorf("plain sink missed fallback call (rule=%q bytes=%d)", plain.bytesInRule, plain.bytesIn)
+	}
+
+	multi.AddBytesOutLabeled("rule-3", "client-3", "tenant-a", 13)
+	if labeled.labeledOut != 13 {
+		t.Errorf("labeled sink missed labeled out call (out=%d)", labeled.labeledOut)
+	}
+	if plain.bytesOut != 13 {
+		t.Errorf("plain sink missed fallback out call (out=%d)", plain.bytesOut)
+	}
+}
@@ -0,0 +1,51 @@
+package relay
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// payloadHistogramBuckets 桶数量: 0 字节桶 + 2 的幂次桶 (上界至 2^21) + 溢出桶
+const payloadHistogramBuckets = 23
+
+// PayloadHistogram 载荷大小直方图 (powers-of-two 桶)
+// 更新完全基于 atomic, 可以安全地在 handleData 热路径调用
+type PayloadHistogram struct {
+	buckets [payloadHistogramBuckets]int64
+}
+
+// Observe 记录一次载荷大小
+// 桶划分: buckets[0] = 0 字节, buckets[k] = [2^(k-1), 2^k), 最后一桶收纳更大的载荷
+func (h *PayloadHistogram) Observe(size int) {
+	if size < 0 {
+		return
+	}
+	idx := bits.Len(uint(size))
+	if idx >= payloadHistogramBuckets {
+		idx = payloadHistogramBuckets - 1
+	}
+	atomic.AddInt64(&h.buckets[idx], 1)
+}
+
+// HistogramBucket 直方图桶快照
+type HistogramBucket struct {
+	UpperBound int64 `json:"upper_bound"` // 桶上界 (字节, 不含); 溢出桶为 -1
+	Count      int64 `json:"count"`
+}
+
+// Snapshot 返回所有非空桶的快照
+func (h *PayloadHistogram) Snapshot() []HistogramBucket {
+	snapshot := make([]HistogramBucket, 0, payloadHistogramBuckets)
+	for i := 0; i < payloadHistogramBuckets; i++ {
+		count := atomic.LoadInt64(&h.buckets[i])
+		if count == 0 {
+			continue
+		}
+		upper := int64(1) << i
+		if i == payloadHistogramBuckets-1 {
+			upper = -1
+		}
+		snapshot = append(snapshot, HistogramBucket{UpperBound: upper, Count: count})
+	}
+	return snapshot
+}
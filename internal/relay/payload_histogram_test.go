@@ -0,0 +1,111 @@
+package relay
+
+import (
+	"sync"
+	"testing"
+)
+
+func bucketCount(buckets []HistogramBucket, upperBound int64) int64 {
+	for _, b := range buckets {
+		if b.UpperBound == upperBound {
+			return b.Count
+		}
+	}
+	return 0
+}
+
+func TestPayloadHistogram_BucketBoundaries(t *testing.T) {
+	h := &PayloadHistogram{}
+
+	h.Observe(0)                // [0,1)
+	h.Observe(1)                // [1,2)
+	h.Observe(2)                // [2,4)
+	h.Observe(3)                // [2,4)
+	h.Observe(4)                // [4,8)
+	h.Observe(1023)             // [512,1024)
+	h.Observe(1024)             // [1024,2048)
+	h.Observe(16 * 1024 * 1024) // 溢出桶
+
+	buckets := h.Snapshot()
+
+	cases := []struct {
+		upperBound int64
+		want       int64
+	}{
+		{1, 1},
+		{2, 1},
+		{4, 2},
+		{8, 1},
+		{1024, 1},
+		{2048, 1},
+		{-1, 1},
+	}
+	for _, c := range cases {
+		if got := bucketCount(buckets, c.upperBound); got != c.want {
+			t.Errorf("bucket upper_bound=%d: expected %d, got %d", c.upperBound, c.want, got)
+		}
+	}
+}
+
+func TestPayloadHistogram_ConcurrentObserve(t *testing.T) {
+	h := &PayloadHistogram{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h.Observe(100)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := bucketCount(h.Snapshot(), 128); got != 8000 {
+		t.Errorf("expected 8000 observations in [64,128), got %d", got)
+	}
+}
+
+func TestWSServer_HandleData_PopulatesHistogram(t *testing.T) {
+	s := NewWSServer()
+	s.EnablePayloadHistogram()
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "10.0.0.1:80",
+		Payload:  []byte("target"),
+	})
+
+	// 几种不同量级的载荷
+	s.handleData("sender", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: make([]byte, 4)})
+	s.handleData("sender", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: make([]byte, 1000)})
+	s.handleData("target", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: make([]byte, 1024)})
+
+	buckets := s.PayloadHistogramSnapshot()
+	if buckets == nil {
+		t.Fatal("histogram should be enabled")
+	}
+	if got := bucketCount(buckets, 8); got != 1 {
+		t.Errorf("expected 1 payload in [4,8), got %d", got)
+	}
+	if got := bucketCount(buckets, 1024); got != 1 {
+		t.Errorf("expected 1 payload in [512,1024), got %d", got)
+	}
+	if got := bucketCount(buckets, 2048); got != 1 {
+		t.Errorf("expected 1 payload in [1024,2048), got %d", got)
+	}
+}
+
+func TestWSServer_PayloadHistogramSnapshot_DisabledReturnsNil(t *testing.T) {
+	s := NewWSServer()
+	if s.PayloadHistogramSnapshot() != nil {
+		t.Error("expected nil snapshot when histogram disabled")
+	}
+}
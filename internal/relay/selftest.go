@@ -0,0 +1,144 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/common/crypto"
+)
+
+// SelfTest 对二进制协议与加密做一轮代表性自检 (--selftest 启动参数调用)。
+// 覆盖各消息类型的序列化往返与 AES-GCM 加解密, 用于部署后快速确认二进制协议未被意外破坏
+func SelfTest() error {
+	if err := selfTestMessages(); err != nil {
+		return fmt.Errorf("protocol self-test: %w", err)
+	}
+	if err := selfTestCrypto(); err != nil {
+		return fmt.Errorf("crypto self-test: %w", err)
+	}
+	return nil
+}
+
+// selfTestMessages 对代表性消息集做序列化往返校验 (与 tunnel_test.go 的覆盖面一致)
+func selfTestMessages() error {
+	msgs := []*TunnelMessage{
+		{Type: MsgTypeConnect, StreamID: 1, Target: "192.168.1.1:80", RuleID: "rule-1",
+			Flags: ConnFlagPoolable | ConnFlagVerbose, Payload: []byte("next-hop|@fallback")},
+		{Type: MsgTypeConnect, StreamID: 2, Target: "10.0.0.1:443", RuleID: "rule-2",
+			Flags: ConnFlagEarlyData, Payload: PackConnectRefs("exit-client", []byte("early-bytes"))},
+		{Type: MsgTypeConnAck, StreamID: 3},
+		{Type: MsgTypeData, StreamID: 4, Payload: []byte("hello world")},
+		{Type: MsgTypeData, StreamID: 5, Payload: bytes.Repeat([]byte{0xab}, 32*1024)},
+		{Type: MsgTypeClose, StreamID: 6},
+		{Type: MsgTypeError, StreamID: 7, Error: "connection refused"},
+		{Type: MsgTypeRuleUpdate, StreamID: 8},
+		{Type: MsgTypeCheckPort, StreamID: 9, Target: "0.0.0.0:8080", RuleID: "rule-3"},
+		{Type: MsgTypeCheckPortResult, StreamID: 10, Error: "port in use", RuleID: "rule-4"},
+		{Type: MsgTypeCommand, StreamID: 11, Target: "restart", RuleID: "rule-5"},
+		{Type: MsgTypeCommandResult, StreamID: 12, Error: "", Payload: []byte("command output")},
+	}
+
+	for _, msg := range msgs {
+		if err := roundTripCheck(msg, nil); err != nil {
+			return fmt.Errorf("message type 0x%02x: %w", msg.Type, err)
+		}
+	}
+	return nil
+}
+
+// roundTripCheck 序列化消息并反序列化比对各字段; corrupt 非 nil 时在两步之间注入破坏
+func roundTripCheck(want *TunnelMessage, corrupt func([]byte)) error {
+	data, err := want.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	if corrupt != nil {
+		corrupt(data)
+	}
+	got, err := UnmarshalBinary(data)
+	if err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+	return messagesEqual(want, got)
+}
+
+// messagesEqual 逐字段比较序列化往返前后的消息
+func messagesEqual(want, got *TunnelMessage) error {
+	if got.Type != want.Type {
+		return fmt.Errorf("type = 0x%02x, want 0x%02x", got.Type, want.Type)
+	}
+	if got.StreamID != want.StreamID {
+		return fmt.Errorf("stream_id = %d, want %d", got.StreamID, want.StreamID)
+	}
+	if got.Target != want.Target {
+		return fmt.Errorf("target = %q, want %q", got.Target, want.Target)
+	}
+	if got.RuleID != want.RuleID {
+		return fmt.Errorf("rule_id = %q, want %q", got.RuleID, want.RuleID)
+	}
+	if got.Error != want.Error {
+		return fmt.Errorf("error = %q, want %q", got.Error, want.Error)
+	}
+	if got.Flags != want.Flags {
+		return fmt.Errorf("flags = 0x%02x, want 0x%02x", got.Flags, want.Flags)
+	}
+	if !bytes.Equal(got.Payload, want.Payload) {
+		return fmt.Errorf("payload mismatch: %d bytes, want %d bytes", len(got.Payload), len(want.Payload))
+	}
+	return nil
+}
+
+// selfTestCrypto 校验 AES-GCM 加解密往返与篡改检测
+func selfTestCrypto() error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	c, err := crypto.NewAESCrypto(key)
+	if err != nil {
+		return fmt.Errorf("init aes: %w", err)
+	}
+
+	plaintext := []byte("messageboy protocol self-test")
+	ciphertext, nonce, err := c.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+	decrypted, err := c.Decrypt(ciphertext, nonce)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		return errors.New("decrypted plaintext mismatch")
+	}
+
+	// GCM 必须拒绝被篡改的密文
+	ciphertext[0] ^= 0xff
+	if _, err := c.Decrypt(ciphertext, nonce); err == nil {
+		return errors.New("tampered ciphertext accepted")
+	}
+
+	// 流式加密 (每帧递增 nonce)
+	sc, err := crypto.NewStreamCrypto(c)
+	if err != nil {
+		return fmt.Errorf("init stream crypto: %w", err)
+	}
+	sct, snonce, err := sc.EncryptNext(plaintext)
+	if err != nil {
+		return fmt.Errorf("stream encrypt: %w", err)
+	}
+	sdec, err := sc.DecryptNext(sct, snonce)
+	if err != nil {
+		return fmt.Errorf("stream decrypt: %w", err)
+	}
+	if !bytes.Equal(sdec, plaintext) {
+		return errors.New("stream decrypted plaintext mismatch")
+	}
+	// 重放同一帧必须被拒绝
+	if _, err := sc.DecryptNext(sct, snonce); err == nil {
+		return errors.New("replayed stream frame accepted")
+	}
+	return nil
+}
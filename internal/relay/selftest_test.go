@@ -0,0 +1,43 @@
+package relay
+
+import (
+	"testing"
+)
+
+func TestSelfTest_Passes(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Errorf("SelfTest failed on valid code: %v", err)
+	}
+}
+
+func TestRoundTripCheck_DetectsCorruption(t *testing.T) {
+	msg := &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 42,
+		Target:   "192.168.1.1:80",
+		RuleID:   "rule-1",
+		Flags:    ConnFlagPoolable,
+		Payload:  []byte("next-hop"),
+	}
+
+	// 无破坏时通过
+	if err := roundTripCheck(msg, nil); err != nil {
+		t.Fatalf("clean round trip failed: %v", err)
+	}
+
+	// 破坏 payload 中的一个字节 (header 之后) 必须被检出
+	err := roundTripCheck(msg, func(data []byte) {
+		data[HeaderSize+2] ^= 0xff
+	})
+	if err == nil {
+		t.Error("corrupted message passed round trip check")
+	}
+
+	// 破坏 StreamID 同样必须被检出
+	err = roundTripCheck(msg, func(data []byte) {
+		data[1] ^= 0xff
+	})
+	if err == nil {
+		t.Error("corrupted stream id passed round trip check")
+	}
+}
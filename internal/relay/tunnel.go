@@ -3,8 +3,10 @@ package relay
 import (
 	"encoding/binary"
 	"errors"
+	"net"
 	"sync"
 	"sync/atomic"
+	"syscall"
 )
 
 // 消息类型
@@ -17,8 +19,53 @@ const (
 	MsgTypeRuleUpdate      byte = 0x06 // 规则更新通知
 	MsgTypeCheckPort       byte = 0x07 // 端口检查请求
 	MsgTypeCheckPortResult byte = 0x08 // 端口检查结果
+	MsgTypeCommand         byte = 0x09 // 远程命令请求
+	MsgTypeCommandResult   byte = 0x0A // 远程命令结果
+	MsgTypeRuleStop        byte = 0x0B // 规则停止请求 (规则迁移时要求旧 client 停止并排空)
+	MsgTypeRuleStopResult  byte = 0x0C // 规则停止确认 (旧 client 排空完成后回报)
 )
 
+// Connect 消息标志位
+const (
+	ConnFlagPoolable   byte = 0x01 // 出口可复用目标连接 (放入连接池)
+	ConnFlagDrainClose byte = 0x02 // 出口关闭前半关排空, 保证尾部数据送达目标
+	ConnFlagVerbose    byte = 0x04 // 规则开启 verbose_logging, 中继/出口逐连接打 Debug 日志
+	ConnFlagEarlyData  byte = 0x08 // fast open: Connect payload 携带首包数据, 出口拨号后先写入再回 ConnAck
+	ConnFlagLabeled    byte = 0x10 // Connect payload 前部携带统计标签 (如租户 ID), 服务端按 (规则, 标签) 细分流量
+)
+
+// 错误类别 (MsgTypeError 的 Payload 首字节携带),
+// 入口可据此区分超时与拒绝, 向源应用回报正确语义
+const (
+	ErrCategoryGeneral byte = 0x00 // 其他错误 (目标离线、维护模式等)
+	ErrCategoryTimeout byte = 0x01 // 连接超时
+	ErrCategoryRefused byte = 0x02 // 连接被拒绝
+)
+
+// ClassifyDialError 把出口拨号错误归类为 ErrCategory*
+func ClassifyDialError(err error) byte {
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return ErrCategoryTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrCategoryRefused
+	}
+	return ErrCategoryGeneral
+}
+
+// ErrCategoryName 返回错误类别的可读名称 (日志用)
+func ErrCategoryName(category byte) string {
+	switch category {
+	case ErrCategoryTimeout:
+		return "timeout"
+	case ErrCategoryRefused:
+		return "refused"
+	default:
+		return "general"
+	}
+}
+
 // 协议常量
 const (
 	HeaderSize     = 9         // Type(1) + StreamID(4) + PayloadLen(4)
@@ -104,7 +151,8 @@ type TunnelMessage struct {
 	Target   string // 用于 Connect, CheckPort
 	Payload  []byte // 用于 Data, Connect(携带下一跳)
 	Error    string // 用于 Error, CheckPortResult
-	RuleID   string // 用于流量统计, CheckPort
+	RuleID   string // 用于流量统计, CheckPort; CheckPortResult 中为占用端口的规则
+	Flags    byte   // 用于 Connect (ConnFlag* 标志位)
 }
 
 // MarshalBinary 二进制序列化
@@ -145,17 +193,35 @@ func (m *TunnelMessage) MarshalTo(buf []byte) (int, error) {
 		// Data: 直接是 payload
 		copy(buf[offset:], m.Payload)
 
-	case MsgTypeConnect, MsgTypeCheckPort:
-		// Connect/CheckPort: Target + RuleID + Payload(下一跳)
+	case MsgTypeConnect, MsgTypeCheckPort, MsgTypeCommand, MsgTypeRuleStop:
+		// Connect/CheckPort/Command/RuleStop: Target + RuleID + Flags + Payload(下一跳)
+		// Command 复用 Target 字段携带命令动词, RuleStop 只用 RuleID
 		offset += writeString(buf[offset:], m.Target)
 		offset += writeString(buf[offset:], m.RuleID)
+		buf[offset] = m.Flags
+		offset++
+		if len(m.Payload) > 0 {
+			copy(buf[offset:], m.Payload)
+		}
+
+	case MsgTypeError:
+		// Error: Error string + Payload(错误类别, 可为空)
+		offset += writeString(buf[offset:], m.Error)
 		if len(m.Payload) > 0 {
 			copy(buf[offset:], m.Payload)
 		}
 
-	case MsgTypeError, MsgTypeCheckPortResult:
-		// Error: Error string
-		writeString(buf[offset:], m.Error)
+	case MsgTypeCheckPortResult, MsgTypeRuleStopResult:
+		// CheckPortResult/RuleStopResult: Error + RuleID
+		offset += writeString(buf[offset:], m.Error)
+		writeString(buf[offset:], m.RuleID)
+
+	case MsgTypeCommandResult:
+		// CommandResult: Error string + Payload(命令输出)
+		offset += writeString(buf[offset:], m.Error)
+		if len(m.Payload) > 0 {
+			copy(buf[offset:], m.Payload)
+		}
 
 	case MsgTypeConnAck, MsgTypeClose, MsgTypeRuleUpdate:
 		// 无 payload
@@ -170,19 +236,30 @@ func (m *TunnelMessage) calcPayloadSize() int {
 	case MsgTypeData:
 		return len(m.Payload)
 
-	case MsgTypeConnect, MsgTypeCheckPort:
-		// Target(2+len) + RuleID(2+len) + Payload
-		return 2 + len(m.Target) + 2 + len(m.RuleID) + len(m.Payload)
+	case MsgTypeConnect, MsgTypeCheckPort, MsgTypeCommand, MsgTypeRuleStop:
+		// Target(2+len) + RuleID(2+len) + Flags(1) + Payload
+		return 2 + len(m.Target) + 2 + len(m.RuleID) + 1 + len(m.Payload)
+
+	case MsgTypeError:
+		return 2 + len(m.Error) + len(m.Payload)
+
+	case MsgTypeCheckPortResult, MsgTypeRuleStopResult:
+		return 2 + len(m.Error) + 2 + len(m.RuleID)
 
-	case MsgTypeError, MsgTypeCheckPortResult:
-		return 2 + len(m.Error)
+	case MsgTypeCommandResult:
+		return 2 + len(m.Error) + len(m.Payload)
 
 	default:
 		return 0
 	}
 }
 
-// UnmarshalBinary 二进制反序列化
+// UnmarshalBinary 二进制反序列化。
+//
+// 所有权约定: 返回消息的 Payload 直接切片引用传入的 data, 不做拷贝
+// (Data 消息为整个载荷, Connect/Error/CommandResult 为尾部字段)。
+// 调用方在消息仍被持有期间不得复用或归还 data 缓冲, 否则 payload 会被
+// 后续帧覆盖; 需要在帧生命周期之外保留 Payload 的一方应使用 UnmarshalBinaryCopy
 func UnmarshalBinary(data []byte) (*TunnelMessage, error) {
 	if len(data) < HeaderSize {
 		return nil, ErrInvalidHeader
@@ -209,16 +286,40 @@ func UnmarshalBinary(data []byte) (*TunnelMessage, error) {
 		// 直接引用，避免拷贝（调用方需要注意生命周期）
 		msg.Payload = payload
 
-	case MsgTypeConnect, MsgTypeCheckPort:
+	case MsgTypeConnect, MsgTypeCheckPort, MsgTypeCommand, MsgTypeRuleStop:
 		offset := 0
 		msg.Target, offset = readString(payload, offset)
 		msg.RuleID, offset = readString(payload, offset)
+		if offset < len(payload) {
+			msg.Flags = payload[offset]
+			offset++
+		}
+		if offset < len(payload) {
+			msg.Payload = payload[offset:]
+		}
+
+	case MsgTypeError:
+		offset := 0
+		msg.Error, offset = readString(payload, offset)
+		// 兼容旧格式: 没有类别字节时 Payload 留空
 		if offset < len(payload) {
 			msg.Payload = payload[offset:]
 		}
 
-	case MsgTypeError, MsgTypeCheckPortResult:
-		msg.Error, _ = readString(payload, 0)
+	case MsgTypeCheckPortResult, MsgTypeRuleStopResult:
+		offset := 0
+		msg.Error, offset = readString(payload, offset)
+		// 兼容旧客户端: 没有 RuleID 字段时留空
+		if offset < len(payload) {
+			msg.RuleID, _ = readString(payload, offset)
+		}
+
+	case MsgTypeCommandResult:
+		offset := 0
+		msg.Error, offset = readString(payload, offset)
+		if offset < len(payload) {
+			msg.Payload = payload[offset:]
+		}
 
 	case MsgTypeConnAck, MsgTypeClose, MsgTypeRuleUpdate:
 		// 无 payload
@@ -227,6 +328,56 @@ func UnmarshalBinary(data []byte) (*TunnelMessage, error) {
 	return msg, nil
 }
 
+// UnmarshalBinaryCopy 与 UnmarshalBinary 相同, 但把 Payload 拷贝到独立缓冲,
+// 返回的消息不再引用 data。供在帧缓冲复用之后仍保留 payload 的处理方使用;
+// 转发热路径不跨帧持有消息时应继续用零拷贝的 UnmarshalBinary
+func UnmarshalBinaryCopy(data []byte) (*TunnelMessage, error) {
+	msg, err := UnmarshalBinary(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Payload) > 0 {
+		msg.Payload = append([]byte(nil), msg.Payload...)
+	}
+	return msg, nil
+}
+
+// PackConnectRefs 打包携带首包数据的 Connect payload: 下一跳引用 (2字节长度前缀) + 首包数据。
+// 仅在 ConnFlagEarlyData 置位时使用; 不带首包的 Connect payload 仍为裸引用字符串
+func PackConnectRefs(refs string, earlyData []byte) []byte {
+	buf := make([]byte, 2+len(refs)+len(earlyData))
+	n := writeString(buf, refs)
+	copy(buf[n:], earlyData)
+	return buf
+}
+
+// UnpackConnectRefs 解包携带首包数据的 Connect payload, 返回下一跳引用与首包数据
+func UnpackConnectRefs(payload []byte) (string, []byte) {
+	refs, offset := readString(payload, 0)
+	if offset >= len(payload) {
+		return refs, nil
+	}
+	return refs, payload[offset:]
+}
+
+// PackConnectLabel 把统计标签打包到 Connect payload 前部。
+// 仅在 ConnFlagLabeled 置位时使用; 标签在引用/首包打包之外, 服务端拆出后不再转发
+func PackConnectLabel(label string, rest []byte) []byte {
+	buf := make([]byte, 2+len(label)+len(rest))
+	n := writeString(buf, label)
+	copy(buf[n:], rest)
+	return buf
+}
+
+// UnpackConnectLabel 解包携带统计标签的 Connect payload, 返回标签与其余部分
+func UnpackConnectLabel(payload []byte) (string, []byte) {
+	label, offset := readString(payload, 0)
+	if offset >= len(payload) {
+		return label, nil
+	}
+	return label, payload[offset:]
+}
+
 // writeString 写入字符串 (2字节长度 + 数据)
 func writeString(buf []byte, s string) int {
 	l := len(s)
@@ -278,6 +429,9 @@ type StreamManager struct {
 	streams map[uint32]*Stream
 	mu      sync.RWMutex
 	nextID  uint32
+
+	// maxStreams 并发流上限 (<=0 不限制), TryAddStream 超限时拒绝
+	maxStreams int
 }
 
 // Stream 表示一个多路复用流
@@ -319,6 +473,31 @@ func (sm *StreamManager) NewStream(target string) *Stream {
 	return stream
 }
 
+// SetMaxStreams 设置并发流上限 (<=0 不限制)
+func (sm *StreamManager) SetMaxStreams(n int) {
+	sm.mu.Lock()
+	sm.maxStreams = n
+	sm.mu.Unlock()
+}
+
+// Count 当前流数量
+func (sm *StreamManager) Count() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return len(sm.streams)
+}
+
+// TryAddStream 在并发流上限内添加流, 超限时拒绝并返回 false
+func (sm *StreamManager) TryAddStream(stream *Stream) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.maxStreams > 0 && len(sm.streams) >= sm.maxStreams {
+		return false
+	}
+	sm.streams[stream.ID] = stream
+	return true
+}
+
 // GetStream 获取流
 func (sm *StreamManager) GetStream(id uint32) *Stream {
 	sm.mu.RLock()
@@ -380,3 +559,17 @@ func (s *Stream) Write(data []byte) bool {
 		return false
 	}
 }
+
+// WriteBlocking 阻塞写入数据到流: 缓冲满时等待消费端取走 (背压) 而不是丢弃。
+// 仅在流关闭时返回 false
+func (s *Stream) WriteBlocking(data []byte) bool {
+	if s.IsClosed() {
+		return false
+	}
+	select {
+	case s.DataCh <- data:
+		return true
+	case <-s.CloseCh:
+		return false
+	}
+}
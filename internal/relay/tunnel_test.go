@@ -595,6 +595,73 @@ func TestStream_Write_ClosedChannelDrop(t *testing.T) {
 	}
 }
 
+func TestStream_WriteBlocking_WaitsForConsumer(t *testing.T) {
+	s := &Stream{
+		ID:      1,
+		DataCh:  make(chan []byte, 1),
+		CloseCh: make(chan struct{}),
+	}
+
+	// Fill the channel
+	if !s.WriteBlocking([]byte("1")) {
+		t.Fatal("WriteBlocking should succeed on empty channel")
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- s.WriteBlocking([]byte("2"))
+	}()
+
+	// Writer must block while the buffer is full
+	select {
+	case <-done:
+		t.Fatal("WriteBlocking should block when channel is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Consuming one message unblocks the writer
+	<-s.DataCh
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("WriteBlocking should return true once consumer drains")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WriteBlocking did not complete after consumer drained")
+	}
+
+	if s.DroppedMessages() != 0 {
+		t.Errorf("DroppedMessages = %d, want 0 (blocking write must not drop)", s.DroppedMessages())
+	}
+}
+
+func TestStream_WriteBlocking_UnblocksOnClose(t *testing.T) {
+	s := &Stream{
+		ID:      1,
+		DataCh:  make(chan []byte, 1),
+		CloseCh: make(chan struct{}),
+	}
+
+	s.WriteBlocking([]byte("fill"))
+
+	done := make(chan bool, 1)
+	go func() {
+		done <- s.WriteBlocking([]byte("blocked"))
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	s.Close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("WriteBlocking should return false when stream closes while blocked")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WriteBlocking did not return after Close")
+	}
+}
+
 // ===== Benchmarks =====
 
 func BenchmarkTunnelMessageMarshal(b *testing.B) {
@@ -659,3 +726,161 @@ func BenchmarkBufferPool(b *testing.B) {
 		PutBuffer(buf)
 	}
 }
+
+// ============================================================
+// Connect payload 首包打包 (fast open)
+// ============================================================
+
+func TestPackUnpackConnectRefs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		refs      string
+		earlyData []byte
+	}{
+		{name: "refs_and_data", refs: "exit-client|@fallback", earlyData: []byte("GET / HTTP/1.1\r\n")},
+		{name: "refs_only", refs: "exit-client", earlyData: nil},
+		{name: "data_only", refs: "", earlyData: []byte{0x00, 0x01, 0xff}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			packed := PackConnectRefs(tc.refs, tc.earlyData)
+			refs, data := UnpackConnectRefs(packed)
+			if refs != tc.refs {
+				t.Errorf("refs = %q, want %q", refs, tc.refs)
+			}
+			if string(data) != string(tc.earlyData) {
+				t.Errorf("earlyData = %q, want %q", data, tc.earlyData)
+			}
+		})
+	}
+}
+
+func TestPackConnectRefs_SurvivesConnectMarshal(t *testing.T) {
+	msg := &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 99,
+		Target:   "10.0.0.1:80",
+		RuleID:   "rule-1",
+		Flags:    ConnFlagEarlyData,
+		Payload:  PackConnectRefs("exit-client", []byte("early-bytes")),
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	decoded, err := UnmarshalBinary(data)
+	if err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	if decoded.Flags&ConnFlagEarlyData == 0 {
+		t.Error("ConnFlagEarlyData lost in marshal roundtrip")
+	}
+	refs, early := UnpackConnectRefs(decoded.Payload)
+	if refs != "exit-client" {
+		t.Errorf("refs = %q, want %q", refs, "exit-client")
+	}
+	if string(early) != "early-bytes" {
+		t.Errorf("earlyData = %q, want %q", early, "early-bytes")
+	}
+}
+
+func TestPackUnpackConnectLabel(t *testing.T) {
+	testCases := []struct {
+		name  string
+		label string
+		rest  []byte
+	}{
+		{name: "label_and_rest", label: "tenant-a", rest: []byte("exit-client|@fallback")},
+		{name: "label_only", label: "tenant-a", rest: nil},
+		{name: "label_wraps_refs", label: "t1", rest: PackConnectRefs("exit-client", []byte("early"))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			packed := PackConnectLabel(tc.label, tc.rest)
+			label, rest := UnpackConnectLabel(packed)
+			if label != tc.label {
+				t.Errorf("label = %q, want %q", label, tc.label)
+			}
+			if string(rest) != string(tc.rest) {
+				t.Errorf("rest = %q, want %q", rest, tc.rest)
+			}
+		})
+	}
+}
+
+func TestStreamManager_MaxStreams(t *testing.T) {
+	sm := NewStreamManager()
+	sm.SetMaxStreams(2)
+
+	mkStream := func(id uint32) *Stream {
+		return &Stream{ID: id, DataCh: make(chan []byte, 1), CloseCh: make(chan struct{})}
+	}
+
+	if !sm.TryAddStream(mkStream(1)) || !sm.TryAddStream(mkStream(2)) {
+		t.Fatal("streams within the cap should be accepted")
+	}
+	if sm.TryAddStream(mkStream(3)) {
+		t.Error("stream beyond the cap should be rejected")
+	}
+	if sm.Count() != 2 {
+		t.Errorf("count = %d, want 2", sm.Count())
+	}
+
+	// 释放后恢复可用
+	sm.RemoveStream(1)
+	if !sm.TryAddStream(mkStream(3)) {
+		t.Error("stream should be accepted after a slot is freed")
+	}
+
+	// 不设上限时不受限制
+	unlimited := NewStreamManager()
+	for i := uint32(1); i <= 100; i++ {
+		if !unlimited.TryAddStream(mkStream(i)) {
+			t.Fatalf("unlimited manager rejected stream %d", i)
+		}
+	}
+}
+
+func TestUnmarshalBinary_DataPayloadAliasingAndCopy(t *testing.T) {
+	mkFrame := func() []byte {
+		msg := &TunnelMessage{Type: MsgTypeData, StreamID: 7, Payload: []byte("hello")}
+		frame, err := msg.Marshal()
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		return frame
+	}
+
+	// 零拷贝路径: payload 与帧缓冲共享存储, 缓冲复用后数据被覆盖 (文档化的风险)
+	frame := mkFrame()
+	zero, err := UnmarshalBinary(frame)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if string(zero.Payload) != "hello" {
+		t.Fatalf("payload = %q, want hello", zero.Payload)
+	}
+	for i := range frame {
+		frame[i] = 0xFF
+	}
+	if string(zero.Payload) == "hello" {
+		t.Error("zero-copy payload should alias the frame buffer and see the overwrite")
+	}
+
+	// 拷贝变体: 缓冲复用不影响已解析的 payload
+	frame = mkFrame()
+	copied, err := UnmarshalBinaryCopy(frame)
+	if err != nil {
+		t.Fatalf("unmarshal copy failed: %v", err)
+	}
+	for i := range frame {
+		frame[i] = 0xFF
+	}
+	if string(copied.Payload) != "hello" {
+		t.Errorf("copied payload = %q, want hello after frame reuse", copied.Payload)
+	}
+}
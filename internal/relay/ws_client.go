@@ -1,6 +1,8 @@
 package relay
 
 import (
+	"crypto/tls"
+	"net/http"
 	"net/url"
 	"sync"
 	"sync/atomic"
@@ -26,6 +28,13 @@ type WSClientConn struct {
 	clientID  string
 	secretKey string
 
+	// 拨号自定义 header 与子协议 (用于经过 LB/WAF 等中间层)
+	dialHeaders http.Header
+	subprotocol string
+
+	// tlsConfig wss 拨号的 TLS 策略 (nil 时用 Go 默认)
+	tlsConfig *tls.Config
+
 	conn    *websocket.Conn
 	sendCh  chan *sendItem
 	recvCh  chan *TunnelMessage
@@ -33,6 +42,13 @@ type WSClientConn struct {
 	closed  bool
 	mu      sync.Mutex
 
+	// closeDrainTimeout 优雅关闭时排空发送队列的最长等待 (0 = 不排空, 立即关闭)。
+	// 仅对本端主动 Close 生效; 对端踢断等异常断开时连接已不可写, 不排空
+	closeDrainTimeout time.Duration
+
+	// writeDone 当前 writePump 的退出信号, 每次建连重建; Close 借此等待排空完成
+	writeDone chan struct{}
+
 	streams   *StreamManager
 	reconnect bool
 
@@ -72,6 +88,28 @@ func NewWSClientConn(endpoint, clientID, secretKey string) (*WSClientConn, error
 	}, nil
 }
 
+// SetDialHeaders 设置拨号时附带的自定义 header (如 Authorization、路由 header)
+func (c *WSClientConn) SetDialHeaders(headers http.Header) {
+	c.dialHeaders = headers
+}
+
+// SetSubprotocol 设置拨号时请求的 WebSocket 子协议
+func (c *WSClientConn) SetSubprotocol(proto string) {
+	c.subprotocol = proto
+}
+
+// SetTLSConfig 设置 wss 拨号的 TLS 配置 (应在 Connect 前调用)
+func (c *WSClientConn) SetTLSConfig(cfg *tls.Config) {
+	c.tlsConfig = cfg
+}
+
+// SetCloseDrainTimeout 设置优雅关闭时排空发送队列的最长等待 (0 关闭排空)
+func (c *WSClientConn) SetCloseDrainTimeout(d time.Duration) {
+	c.mu.Lock()
+	c.closeDrainTimeout = d
+	c.mu.Unlock()
+}
+
 // Connect 连接到 WebSocket 服务器。若当前已有活跃连接，会返回 ErrAlreadyConnected。
 func (c *WSClientConn) Connect() error {
 	c.mu.Lock()
@@ -104,7 +142,15 @@ func (c *WSClientConn) Connect() error {
 
 	log.Info().Str("url", u.String()).Msg("Connecting to WebSocket server")
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	dialer := *websocket.DefaultDialer
+	if c.subprotocol != "" {
+		dialer.Subprotocols = []string{c.subprotocol}
+	}
+	if c.tlsConfig != nil {
+		dialer.TLSClientConfig = c.tlsConfig
+	}
+
+	conn, _, err := dialer.Dial(u.String(), c.dialHeaders)
 	if err != nil {
 		return err
 	}
@@ -122,6 +168,7 @@ func (c *WSClientConn) Connect() error {
 		return ErrAlreadyConnected
 	}
 	c.conn = conn
+	c.writeDone = make(chan struct{})
 	c.mu.Unlock()
 
 	go c.readPump()
@@ -144,6 +191,8 @@ func (c *WSClientConn) readPump() {
 			return
 		}
 
+		// ReadMessage 每帧返回独立缓冲, 消息经 RecvCh 移交后缓冲不再复用,
+		// 零拷贝解析是安全的; 持有方不得把 payload 寿命延长到缓冲复用之后
 		msg, err := UnmarshalBinary(message)
 		if err != nil {
 			log.Warn().Err(err).Msg("Failed to unmarshal tunnel message")
@@ -172,11 +221,20 @@ func (c *WSClientConn) readPump() {
 
 // writePump 发送消息循环
 func (c *WSClientConn) writePump() {
+	c.mu.Lock()
+	done := c.writeDone
+	c.mu.Unlock()
+	if done != nil {
+		defer close(done)
+	}
+
 	for {
 		select {
 		case item := <-c.sendCh:
 			c.mu.Lock()
-			if c.conn == nil || c.closed {
+			// 优雅关闭 (closed 且配置了排空) 期间继续写出已入队的帧,
+			// 底层连接由 Close 在超时后关闭兜底
+			if c.conn == nil || (c.closed && c.closeDrainTimeout <= 0) {
 				c.mu.Unlock()
 				PutBuffer(item.buf)
 				return
@@ -192,6 +250,43 @@ func (c *WSClientConn) writePump() {
 				return
 			}
 		case <-c.closeCh:
+			c.drainOnGracefulClose()
+			return
+		}
+	}
+}
+
+// drainOnGracefulClose 本端主动关闭时限时排空发送队列, 把已入队的帧写出后
+// 发送正常关闭帧告知对端; 异常断开 (连接已失效) 时直接返回, 与优雅关闭区分
+func (c *WSClientConn) drainOnGracefulClose() {
+	c.mu.Lock()
+	conn := c.conn
+	timeout := c.closeDrainTimeout
+	graceful := c.closed && conn != nil
+	c.mu.Unlock()
+
+	if !graceful || timeout <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(timeout)
+	conn.SetWriteDeadline(deadline)
+
+	for {
+		if time.Now().After(deadline) {
+			return
+		}
+		select {
+		case item := <-c.sendCh:
+			err := conn.WriteMessage(websocket.BinaryMessage, (*item.buf)[:item.size])
+			PutBuffer(item.buf)
+			if err != nil {
+				return
+			}
+		default:
+			// 队列已空: 发送正常关闭帧
+			conn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 			return
 		}
 	}
@@ -321,20 +416,36 @@ func (c *WSClientConn) Recv() *TunnelMessage {
 	}
 }
 
-// Close 关闭连接
+// Close 关闭连接。配置了排空超时时, 先等 writePump 把发送队列中剩余的帧
+// 写出并发送关闭帧 (上限为超时时长), 再关闭底层连接
 func (c *WSClientConn) Close() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.reconnect = false
+	conn := c.conn
+	done := c.writeDone
+	drain := c.closeDrainTimeout
+	close(c.closeCh)
+	c.mu.Unlock()
 
-	if !c.closed {
-		c.closed = true
-		c.reconnect = false
-		close(c.closeCh)
-		if c.conn != nil {
-			c.conn.Close()
+	// 等待 writePump 排空完成 (writePump 已退出时 done 立即就绪)
+	if conn != nil && drain > 0 && done != nil {
+		select {
+		case <-done:
+		case <-time.After(drain):
 		}
-		c.streams.CloseAll()
 	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.streams.CloseAll()
+	c.mu.Unlock()
 }
 
 // GetStreams 获取流管理器
@@ -0,0 +1,111 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startCaptureWSServer 启动一个捕获二进制帧的裸 WebSocket 服务,
+// 返回服务地址、收到的二进制帧计数和收到正常关闭帧的信号
+func startCaptureWSServer(t *testing.T) (string, *int32, chan struct{}) {
+	t.Helper()
+
+	var frames int32
+	normalClose := make(chan struct{}, 1)
+	upgrader := websocket.Upgrader{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, _, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+					normalClose <- struct{}{}
+				}
+				return
+			}
+			if mt == websocket.BinaryMessage {
+				atomic.AddInt32(&frames, 1)
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &frames, normalClose
+}
+
+func TestClose_DrainsQueuedFramesBeforeClose(t *testing.T) {
+	url, frames, normalClose := startCaptureWSServer(t)
+
+	ws, err := NewWSClientConn(url, "drain-ws", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	ws.SetCloseDrainTimeout(2 * time.Second)
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	const queued = 200
+	for i := 0; i < queued; i++ {
+		msg := &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("queued payload")}
+		if err := ws.Send(msg); err != nil {
+			t.Fatalf("send %d failed: %v", i, err)
+		}
+	}
+
+	ws.Close()
+
+	// Close 返回时排空已完成; 等服务端把写出的帧全部读完
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(frames) < queued && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := atomic.LoadInt32(frames); got != queued {
+		t.Errorf("server received %d frames, want %d", got, queued)
+	}
+
+	// 优雅关闭以正常关闭帧收尾, 与异常断开区分
+	select {
+	case <-normalClose:
+	case <-time.After(time.Second):
+		t.Error("server never received normal close frame")
+	}
+}
+
+func TestClose_WithoutDrainTimeoutClosesImmediately(t *testing.T) {
+	url, _, normalClose := startCaptureWSServer(t)
+
+	ws, err := NewWSClientConn(url, "abrupt-ws", "")
+	if err != nil {
+		t.Fatalf("failed to create ws client: %v", err)
+	}
+	if err := ws.Connect(); err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		ws.Send(&TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("x")})
+	}
+
+	start := time.Now()
+	ws.Close()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Close took %v, want immediate return without drain", elapsed)
+	}
+
+	// 未配置排空时不发送正常关闭帧
+	select {
+	case <-normalClose:
+		t.Error("unexpected normal close frame without drain timeout")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -2,7 +2,12 @@ package relay
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestSharedCryptoInit(t *testing.T) {
@@ -283,3 +288,68 @@ func BenchmarkFullMessageEncryptDecrypt(b *testing.B) {
 		}
 	}
 }
+
+// ============================================================
+// Dial headers and subprotocol
+// ============================================================
+
+func TestWSClientConn_DialHeadersReachServer(t *testing.T) {
+	var gotHeader string
+	var gotProto string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Routing-Key")
+		gotProto = r.Header.Get("Sec-WebSocket-Protocol")
+
+		var respHeader http.Header
+		if gotProto != "" {
+			respHeader = http.Header{"Sec-WebSocket-Protocol": []string{gotProto}}
+		}
+		conn, err := upgrader.Upgrade(w, r, respHeader)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	c, err := NewWSClientConn(srv.URL, "client-1", "secret")
+	if err != nil {
+		t.Fatalf("NewWSClientConn: %v", err)
+	}
+	defer c.Close()
+
+	c.SetDialHeaders(http.Header{"X-Routing-Key": []string{"edge-7"}})
+	c.SetSubprotocol("messageboy.v1")
+
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	if gotHeader != "edge-7" {
+		t.Errorf("X-Routing-Key = %q, want %q", gotHeader, "edge-7")
+	}
+	if gotProto != "messageboy.v1" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want %q", gotProto, "messageboy.v1")
+	}
+}
+
+func TestWSServer_EchoesSubprotocolOnUpgrade(t *testing.T) {
+	s := NewWSServer()
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleConnection))
+	defer srv.Close()
+
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{"messageboy.v1"}
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "?client_id=proto-client"
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "messageboy.v1" {
+		t.Errorf("negotiated subprotocol = %q, want %q", got, "messageboy.v1")
+	}
+}
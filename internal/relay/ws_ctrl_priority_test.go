@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startWritePumpPeer 建立一对真实 websocket 连接, 返回 writePump 写入的本端连接
+// 与对端按序收到的帧类型通道
+func startWritePumpPeer(t *testing.T) (*websocket.Conn, <-chan byte) {
+	t.Helper()
+	frames := make(chan byte, 64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var up websocket.Upgrader
+		peer, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		go func() {
+			defer peer.Close()
+			for {
+				_, data, err := peer.ReadMessage()
+				if err != nil {
+					return
+				}
+				if len(data) > 0 {
+					frames <- data[0]
+				}
+			}
+		}()
+	}))
+	t.Cleanup(srv.Close)
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, frames
+}
+
+func TestWritePump_ControlFrameJumpsDataBacklog(t *testing.T) {
+	conn, frames := startWritePumpPeer(t)
+
+	c := &WSClient{
+		ID:      "ctrl-prio",
+		Conn:    conn,
+		SendCh:  make(chan *sendItem, 4),
+		CtrlCh:  make(chan *sendItem, ctrlQueueSize),
+		CloseCh: make(chan struct{}),
+	}
+
+	// 先灌满数据帧制造积压, 控制帧此时入队应转入优先队列而不是被丢弃
+	for i := 0; i < 4; i++ {
+		if !c.SendMsg(&TunnelMessage{Type: MsgTypeData, StreamID: uint32(i + 1), Payload: []byte("bulk")}) {
+			t.Fatalf("data frame %d rejected before channel full", i)
+		}
+	}
+	if !c.SendMsg(&TunnelMessage{Type: MsgTypeConnAck, StreamID: 99}) {
+		t.Fatal("control frame dropped while data channel backlogged")
+	}
+
+	go c.writePump()
+	defer c.Close()
+
+	// 控制帧应先于更早入队的数据帧送达
+	select {
+	case typ := <-frames:
+		if typ != MsgTypeConnAck {
+			t.Fatalf("first delivered frame type = %#x, want ConnAck", typ)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("control frame not delivered promptly")
+	}
+
+	// 积压的数据帧随后照常送达
+	for i := 0; i < 4; i++ {
+		select {
+		case typ := <-frames:
+			if typ != MsgTypeData {
+				t.Fatalf("frame %d type = %#x, want Data", i, typ)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("backlogged data frame %d not delivered", i)
+		}
+	}
+}
+
+func TestSendMsg_DataFrameStillDroppedWhenBacklogged(t *testing.T) {
+	c := &WSClient{
+		ID:      "data-drop",
+		SendCh:  make(chan *sendItem, 1),
+		CtrlCh:  make(chan *sendItem, ctrlQueueSize),
+		CloseCh: make(chan struct{}),
+	}
+
+	if !c.SendMsg(&TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("x")}) {
+		t.Fatal("first data frame rejected")
+	}
+	// 数据帧不享受优先队列, 积压时仍按原语义丢弃并计数
+	if c.SendMsg(&TunnelMessage{Type: MsgTypeData, StreamID: 2, Payload: []byte("x")}) {
+		t.Fatal("data frame should be dropped when SendCh is full")
+	}
+	if got := c.DroppedMessages(); got != 1 {
+		t.Errorf("dropped messages = %d, want 1", got)
+	}
+	if len(c.CtrlCh) != 0 {
+		t.Error("data frame must not spill into the control queue")
+	}
+}
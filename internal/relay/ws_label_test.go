@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+// labeledTrafficCounter 同时实现 TrafficCounterInterface 与 TrafficLabelCounter 的测试桩
+type labeledTrafficCounter struct {
+	mockTrafficCounter
+
+	labeledRule   string
+	labeledClient string
+	label         string
+	labeledOut    int64
+	labeledIn     int64
+}
+
+func (m *labeledTrafficCounter) AddBytesInLabeled(ruleID, clientID, label string, bytes int64) {
+	m.labeledRule = ruleID
+	m.labeledClient = clientID
+	m.label = label
+	m.labeledIn += bytes
+}
+
+func (m *labeledTrafficCounter) AddBytesOutLabeled(ruleID, clientID, label string, bytes int64) {
+	m.labeledRule = ruleID
+	m.labeledClient = clientID
+	m.label = label
+	m.labeledOut += bytes
+}
+
+func TestWSServer_ConnectLabel_CountedAndStripped(t *testing.T) {
+	s := NewWSServer()
+	counter := &labeledTrafficCounter{}
+	s.SetTrafficCounter(counter)
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "127.0.0.1:8080",
+		RuleID:   "rule-1",
+		Flags:    ConnFlagLabeled,
+		Payload:  PackConnectLabel("tenant-a", []byte("target")),
+	})
+
+	// 标签在服务端拆出, 不随 Connect 转发给出口
+	item := drainOneItem(t, target.SendCh, time.Second)
+	fwd := unmarshalItem(t, item)
+	if fwd.Type != MsgTypeConnect {
+		t.Fatalf("expected MsgTypeConnect, got %d", fwd.Type)
+	}
+	if fwd.Flags&ConnFlagLabeled != 0 {
+		t.Error("ConnFlagLabeled should be cleared on the forwarded Connect")
+	}
+	if len(fwd.Payload) != 0 {
+		t.Errorf("forwarded payload should be empty, got %q", fwd.Payload)
+	}
+
+	s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: 1})
+	drainOneItem(t, sender.SendCh, time.Second)
+
+	// 源到目标的数据按 (规则, 标签) 计入出站流量
+	s.handleData("sender", &TunnelMessage{
+		Type:     MsgTypeData,
+		StreamID: 1,
+		Payload:  []byte("hello"),
+	})
+
+	if counter.label != "tenant-a" {
+		t.Errorf("label = %q, want %q", counter.label, "tenant-a")
+	}
+	if counter.labeledRule != "rule-1" || counter.labeledClient != "sender" {
+		t.Errorf("labeled rule/client = %q/%q, want rule-1/sender", counter.labeledRule, counter.labeledClient)
+	}
+	if counter.labeledOut != 5 {
+		t.Errorf("labeled bytes_out = %d, want 5", counter.labeledOut)
+	}
+	if counter.bytesOut != 0 {
+		t.Errorf("plain bytes_out = %d, want 0 when label path is taken", counter.bytesOut)
+	}
+}
+
+func TestWSServer_ConnectLabel_FallsBackWithoutLabelCounter(t *testing.T) {
+	s := NewWSServer()
+	counter := &mockTrafficCounter{}
+	s.SetTrafficCounter(counter)
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "127.0.0.1:8080",
+		RuleID:   "rule-1",
+		Flags:    ConnFlagLabeled,
+		Payload:  PackConnectLabel("tenant-a", []byte("target")),
+	})
+
+	drainOneItem(t, target.SendCh, time.Second)
+	s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: 1})
+	drainOneItem(t, sender.SendCh, time.Second)
+
+	s.handleData("sender", &TunnelMessage{
+		Type:     MsgTypeData,
+		StreamID: 1,
+		Payload:  []byte("hello"),
+	})
+
+	// 计数器不支持标签时退回按规则统计, 流量不丢失
+	if counter.bytesOutRule != "rule-1" || counter.bytesOut != 5 {
+		t.Errorf("fallback bytes_out rule=%q bytes=%d, want rule-1/5", counter.bytesOutRule, counter.bytesOut)
+	}
+}
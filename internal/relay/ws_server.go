@@ -1,8 +1,10 @@
 package relay
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +21,8 @@ func routeKey(clientID string, streamID uint32) string {
 // LoadBalancerInterface 负载均衡器接口 (避免循环依赖)
 type LoadBalancerInterface interface {
 	ResolveTarget(target string, clientIP string) (clientID string, nodeID string, err error)
+	// ResolveTargetForRule 带规则上下文的解析, 支持规则级钉选节点
+	ResolveTargetForRule(target, clientIP, ruleID string) (clientID string, nodeID string, err error)
 	IncrementConnections(nodeID string) error
 	DecrementConnections(nodeID string) error
 }
@@ -27,20 +31,51 @@ type LoadBalancerInterface interface {
 type TrafficCounterInterface interface {
 	AddBytesIn(ruleID, clientID string, bytes int64)
 	AddBytesOut(ruleID, clientID string, bytes int64)
+	AddDroppedBytes(ruleID, clientID string, bytes int64)
 	IncrementConn(ruleID, clientID string)
 	DecrementConn(ruleID, clientID string)
 }
 
+// TrafficLabelCounter 按 (规则, 标签) 细分统计的可选扩展。
+// 计数器实现该接口且路由带标签时, 字节数走细分方法; 否则退回按规则统计
+type TrafficLabelCounter interface {
+	AddBytesInLabeled(ruleID, clientID, label string, bytes int64)
+	AddBytesOutLabeled(ruleID, clientID, label string, bytes int64)
+}
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
+// 端口检查结果状态
+const (
+	PortStatusFree       = "free"         // 端口空闲 (或被待更新的当前规则占用)
+	PortStatusUsedByRule = "used_by_rule" // 被同一客户端的另一条规则占用
+	PortStatusForeign    = "foreign"      // 被客户端之外的进程占用
+)
+
 // PortCheckResult 端口检查结果
 type PortCheckResult struct {
 	Available bool
 	Error     string
+	// Status 结构化状态 (PortStatus*); 离线、超时等无法判定时为空
+	Status string
+	// OwnerRuleID Status 为 used_by_rule 时占用端口的规则 ID
+	OwnerRuleID string
+}
+
+// portCheckFlight 一次进行中的端口检查, done 关闭后 result 可读
+type portCheckFlight struct {
+	done   chan struct{}
+	result *PortCheckResult
+}
+
+// CommandResult 远程命令执行结果
+type CommandResult struct {
+	Error  string
+	Output string
 }
 
 type WSServer struct {
@@ -60,16 +95,194 @@ type WSServer struct {
 	pendingPortChecks   map[uint32]chan *PortCheckResult
 	pendingPortChecksMu sync.RWMutex
 	portCheckNextID     uint32
+
+	// maxPendingPortChecks 等待队列的全局上限 (<=0 不限制)。
+	// 客户端停止响应而服务端持续下发检查时队列只增不减, 超限的新检查直接回忙错误
+	maxPendingPortChecks int
+
+	// 进行中的端口检查合并表: "clientID|addr|ruleID" -> 共享结果
+	// 相同检查并发到达时只向 client 发一次请求, 其余调用等待同一结果
+	portCheckFlights   map[string]*portCheckFlight
+	portCheckFlightsMu sync.Mutex
+
+	// 远程命令等待队列: streamID -> result channel
+	pendingCommands   map[uint32]chan *CommandResult
+	pendingCommandsMu sync.RWMutex
+	commandNextID     uint32
+
+	// 规则停止等待队列: streamID -> 结果 channel (携带错误信息, 空串为成功)。
+	// 规则迁移时等待旧 client 排空确认
+	pendingRuleStops   map[uint32]chan string
+	pendingRuleStopsMu sync.RWMutex
+	ruleStopNextID     uint32
+
+	// 消息类型处理器表: 内置类型在构造时注册, RegisterHandler 可追加自定义类型
+	handlers   map[byte]MsgHandler
+	handlersMu sync.RWMutex
+
+	// ConnAck 超时时间：路由建立后目标在该窗口内未确认则清理
+	connAckTimeout time.Duration
+
+	// duplicatePolicy 同 ID 连接重复到达时的处理策略 (空值等同 replace)
+	duplicatePolicy DuplicatePolicy
+
+	// maintenanceMode 非 0 时拒绝新的 Connect, 已建立的路由继续中继 (atomic)
+	maintenanceMode int32
+
+	// admission 重连风暴抑制器 (可选, 服务启动前经 SetAdmissionLimits 开启)
+	admission *admissionController
+
+	// 路由空闲超时: 超过该时长无数据往来的路由被回收 (0 = 不回收)
+	routeIdleTimeout time.Duration
+	idleReaperStopCh chan struct{}
+	idleReaperWg     sync.WaitGroup
+
+	// 载荷大小直方图 (可选, 服务启动前经 EnablePayloadHistogram 开启)
+	payloadHistogram *PayloadHistogram
+
+	// 压缩统计 (按规则累计压缩前后字节数)
+	compressionStats *CompressionStats
+
+	// 规则建连并发上限查询 (可选, 经 SetConnectConcurrencyLookup 注入, <=0 不限制)
+	connectConcurrency func(ruleID string) int
+	// 规则建连限制器表: ruleID -> *connectLimiter
+	connectLimiters sync.Map
+}
+
+// defaultConnAckTimeout 默认 ConnAck 等待窗口
+const defaultConnAckTimeout = 10 * time.Second
+
+// SetConnAckTimeout 设置 ConnAck 超时时间
+func (s *WSServer) SetConnAckTimeout(d time.Duration) {
+	s.connAckTimeout = d
+}
+
+// DuplicatePolicy 同 ID 连接重复到达时的处理策略
+type DuplicatePolicy string
+
+const (
+	// DuplicateReplace 默认策略: 关闭旧连接, 新连接接管 (正常重连场景)
+	DuplicateReplace DuplicatePolicy = "replace"
+	// DuplicateRejectNew 保留旧连接, 拒绝新连接。
+	// 两台主机误用同一令牌 (脑裂) 时避免互相顶掉形成重连战
+	DuplicateRejectNew DuplicatePolicy = "reject-new"
+)
+
+// SetDuplicatePolicy 设置同 ID 重复连接策略。
+// 空值与未知值 (含尚未实现的 allow-both 多路复用) 回退 replace
+func (s *WSServer) SetDuplicatePolicy(p DuplicatePolicy) {
+	switch p {
+	case DuplicateReplace, DuplicateRejectNew:
+		s.duplicatePolicy = p
+	case "":
+		s.duplicatePolicy = DuplicateReplace
+	default:
+		log.Warn().
+			Str("policy", string(p)).
+			Msg("Unsupported duplicate client policy, falling back to replace")
+		s.duplicatePolicy = DuplicateReplace
+	}
+}
+
+// SetMaintenanceMode 设置维护模式: 开启后新 Connect 一律拒绝, 存量路由不受影响。
+// 用于维护窗口前逐步排空连接而不强断在途会话
+func (s *WSServer) SetMaintenanceMode(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.maintenanceMode, v)
+	log.Info().Bool("enabled", on).Msg("Maintenance mode changed")
+}
+
+// SetAdmissionLimits 开启重连风暴抑制 (应在开始服务前调用)。
+// WebSocket 升级按 ratePerSec 的速率放行, 超出的排队等待, 预计等待超过 maxWait
+// 的连接以 503 剪除并带 Retry-After, 客户端退避后重连; ratePerSec <= 0 关闭
+func (s *WSServer) SetAdmissionLimits(ratePerSec int, maxWait time.Duration) {
+	if ratePerSec <= 0 {
+		s.admission = nil
+		return
+	}
+	s.admission = newAdmissionController(ratePerSec, maxWait)
+	log.Info().
+		Int("rate_per_sec", ratePerSec).
+		Dur("max_wait", maxWait).
+		Msg("Connection admission control enabled")
+}
+
+// defaultMaxPendingPortChecks 端口检查等待队列的默认全局上限
+const defaultMaxPendingPortChecks = 256
+
+// SetMaxPendingPortChecks 设置端口检查等待队列的全局上限 (<=0 不限制)。
+// 达到上限后新的检查不再下发, 直接返回忙错误, 调用方稍后重试
+func (s *WSServer) SetMaxPendingPortChecks(n int) {
+	s.pendingPortChecksMu.Lock()
+	s.maxPendingPortChecks = n
+	s.pendingPortChecksMu.Unlock()
+}
+
+// PendingPortCheckCount 当前等待响应的端口检查数量 (用于监控队列增长)
+func (s *WSServer) PendingPortCheckCount() int {
+	s.pendingPortChecksMu.RLock()
+	defer s.pendingPortChecksMu.RUnlock()
+	return len(s.pendingPortChecks)
+}
+
+// InMaintenanceMode 返回当前是否处于维护模式
+func (s *WSServer) InMaintenanceMode() bool {
+	return atomic.LoadInt32(&s.maintenanceMode) != 0
+}
+
+// OnlineClientCount 当前在线客户端数量
+func (s *WSServer) OnlineClientCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clients)
+}
+
+// ActiveRouteCount 当前活跃路由数量 (源/目标两个键指向同一路由, 按路由去重)
+func (s *WSServer) ActiveRouteCount() int {
+	seen := make(map[*RouteInfo]struct{})
+	s.routes.Range(func(_, v interface{}) bool {
+		seen[v.(*RouteInfo)] = struct{}{}
+		return true
+	})
+	return len(seen)
 }
 
 // RouteInfo 中继路由信息
 type RouteInfo struct {
-	SourceClientID string // 源 Client ID
-	TargetClientID string // 目标 Client ID (下一跳或出口)
-	StreamID       uint32 // 流 ID
-	ExitAddr       string // 最终目标地址
-	NodeID         string // 代理组节点 ID (用于连接统计)
-	RuleID         string // 转发规则 ID (用于流量统计)
+	SourceClientID string    // 源 Client ID
+	TargetClientID string    // 目标 Client ID (下一跳或出口)
+	StreamID       uint32    // 流 ID
+	ExitAddr       string    // 最终目标地址
+	NodeID         string    // 代理组节点 ID (用于连接统计)
+	RuleID         string    // 转发规则 ID (用于流量统计)
+	Label          string    // 入口附带的统计标签 (如租户 ID, 可为空)
+	CreatedAt      time.Time // 路由建立时刻 (用于连接快照展示时长)
+
+	// 经过本路由的字节数 (atomic, 方向与流量统计一致: out = 源到目标)
+	bytesIn  int64
+	bytesOut int64
+
+	// connecting: 1 = 已转发 Connect 但尚未收到 ConnAck (atomic)
+	connecting int32
+
+	// limiter 本路由占用的规则建连限制器槽位 (无限制时为 nil),
+	// 建连阶段结束 (connecting 1→0) 时经 finishConnecting 归还
+	limiter *connectLimiter
+
+	// lastActive 最近一次数据往来的时刻 (atomic, unix 纳秒, 用于空闲回收)
+	lastActive int64
+
+	// cleaned: 1 = 已经过 cleanupRoute (atomic)。
+	// 空闲回收与显式 Close 可能并发触发清理, CAS 保证连接计数只减一次
+	cleaned int32
+}
+
+// touch 更新路由活跃时刻
+func (r *RouteInfo) touch() {
+	atomic.StoreInt64(&r.lastActive, time.Now().UnixNano())
 }
 
 // SetLoadBalancer 设置负载均衡器
@@ -82,10 +295,44 @@ func (s *WSServer) SetTrafficCounter(tc TrafficCounterInterface) {
 	s.trafficCounter = tc
 }
 
+// EnablePayloadHistogram 开启载荷大小直方图统计 (应在开始服务前调用)
+func (s *WSServer) EnablePayloadHistogram() {
+	s.payloadHistogram = &PayloadHistogram{}
+}
+
+// PayloadHistogramSnapshot 返回载荷大小直方图快照, 未开启时返回 nil
+func (s *WSServer) PayloadHistogramSnapshot() []HistogramBucket {
+	if s.payloadHistogram == nil {
+		return nil
+	}
+	return s.payloadHistogram.Snapshot()
+}
+
+// CompressionStats 返回压缩统计器 (marshal 路径的压缩步骤向其记录)
+func (s *WSServer) CompressionStats() *CompressionStats {
+	return s.compressionStats
+}
+
+// CompressionStatsSnapshot 返回全部规则的压缩统计快照
+func (s *WSServer) CompressionStatsSnapshot() []CompressionSnapshot {
+	return s.compressionStats.Snapshot()
+}
+
+// CompressionRuleSnapshot 返回单条规则的压缩统计, 无记录时 ok 为 false
+func (s *WSServer) CompressionRuleSnapshot(ruleID string) (CompressionSnapshot, bool) {
+	return s.compressionStats.RuleSnapshot(ruleID)
+}
+
+// ctrlQueueSize 控制帧备用队列长度: SendCh 被数据帧灌满时非 Data 帧转入此队列,
+// writePump 优先发送, 避免 ConnAck/Close 被积压的数据帧饿死或丢弃
+const ctrlQueueSize = 256
+
 type WSClient struct {
-	ID              string
-	Conn            *websocket.Conn
-	SendCh          chan *sendItem
+	ID     string
+	Conn   *websocket.Conn
+	SendCh chan *sendItem
+	// CtrlCh 控制帧优先队列, 仅在 SendCh 已满时使用 (见 ctrlQueueSize)
+	CtrlCh          chan *sendItem
 	CloseCh         chan struct{}
 	closed          bool
 	mu              sync.Mutex
@@ -97,11 +344,56 @@ func (c *WSClient) DroppedMessages() int64 {
 	return atomic.LoadInt64(&c.droppedMessages)
 }
 
+// MsgHandler 消息类型处理函数, clientID 为消息来源连接
+type MsgHandler func(clientID string, msg *TunnelMessage)
+
 func NewWSServer() *WSServer {
-	return &WSServer{
-		clients:           make(map[string]*WSClient),
-		pendingPortChecks: make(map[uint32]chan *PortCheckResult),
+	s := &WSServer{
+		clients:              make(map[string]*WSClient),
+		pendingPortChecks:    make(map[uint32]chan *PortCheckResult),
+		portCheckFlights:     make(map[string]*portCheckFlight),
+		pendingCommands:      make(map[uint32]chan *CommandResult),
+		pendingRuleStops:     make(map[uint32]chan string),
+		handlers:             make(map[byte]MsgHandler),
+		connAckTimeout:       defaultConnAckTimeout,
+		maxPendingPortChecks: defaultMaxPendingPortChecks,
+		compressionStats:     NewCompressionStats(),
 	}
+	s.registerBuiltinHandlers()
+	return s
+}
+
+// registerBuiltinHandlers 注册内置消息类型处理器
+func (s *WSServer) registerBuiltinHandlers() {
+	s.RegisterHandler(MsgTypeConnect, s.handleConnect)
+	s.RegisterHandler(MsgTypeConnAck, s.handleConnAck)
+	s.RegisterHandler(MsgTypeData, s.handleData)
+	s.RegisterHandler(MsgTypeClose, s.handleClose)
+	s.RegisterHandler(MsgTypeError, s.handleError)
+	s.RegisterHandler(MsgTypeCheckPortResult, func(_ string, msg *TunnelMessage) {
+		s.HandlePortCheckResult(msg)
+	})
+	s.RegisterHandler(MsgTypeCommandResult, func(_ string, msg *TunnelMessage) {
+		s.HandleCommandResult(msg)
+	})
+	s.RegisterHandler(MsgTypeRuleStopResult, func(_ string, msg *TunnelMessage) {
+		s.handleRuleStopResult(msg)
+	})
+}
+
+// RegisterHandler 注册消息类型处理器, 同类型重复注册时覆盖
+// 新增消息类型无需改动 readPump 的分发逻辑
+func (s *WSServer) RegisterHandler(msgType byte, fn MsgHandler) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.handlers[msgType] = fn
+}
+
+// getHandler 查找消息类型处理器
+func (s *WSServer) getHandler(msgType byte) MsgHandler {
+	s.handlersMu.RLock()
+	defer s.handlersMu.RUnlock()
+	return s.handlers[msgType]
 }
 
 func (s *WSServer) HandleConnection(w http.ResponseWriter, r *http.Request) {
@@ -111,7 +403,29 @@ func (s *WSServer) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	// 重连风暴抑制: 超出放行速率的连接短暂排队, 排不进队列的剪除并提示重试
+	if s.admission != nil && !s.admission.admit() {
+		log.Warn().Str("client_id", clientID).Msg("Connection shed by admission controller")
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "server busy, retry later", http.StatusServiceUnavailable)
+		return
+	}
+
+	// reject-new 策略下已有同 ID 连接时直接拒绝, 不打断现有连接
+	if s.duplicatePolicy == DuplicateRejectNew && s.GetClient(clientID) != nil {
+		log.Warn().Str("client_id", clientID).Msg("Duplicate connection rejected, keeping existing connection")
+		http.Error(w, "client already connected", http.StatusConflict)
+		return
+	}
+
+	// 回显客户端请求的第一个子协议，满足按子协议路由的中间层
+	var respHeader http.Header
+	if proto := r.Header.Get("Sec-WebSocket-Protocol"); proto != "" {
+		first := strings.TrimSpace(strings.Split(proto, ",")[0])
+		respHeader = http.Header{"Sec-WebSocket-Protocol": []string{first}}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, respHeader)
 	if err != nil {
 		log.Error().Err(err).Msg("WebSocket upgrade failed")
 		return
@@ -121,12 +435,20 @@ func (s *WSServer) HandleConnection(w http.ResponseWriter, r *http.Request) {
 		ID:      clientID,
 		Conn:    conn,
 		SendCh:  make(chan *sendItem, 2048),
+		CtrlCh:  make(chan *sendItem, ctrlQueueSize),
 		CloseCh: make(chan struct{}),
 	}
 
 	s.mu.Lock()
-	// 关闭旧连接
 	if old, ok := s.clients[clientID]; ok {
+		// 升级窗口内出现的同 ID 竞争连接: reject-new 下保留已注册连接
+		if s.duplicatePolicy == DuplicateRejectNew {
+			s.mu.Unlock()
+			log.Warn().Str("client_id", clientID).Msg("Duplicate connection rejected after upgrade, keeping existing connection")
+			conn.Close()
+			return
+		}
+		// 关闭旧连接
 		old.Close()
 	}
 	s.clients[clientID] = client
@@ -137,17 +459,25 @@ func (s *WSServer) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	go client.writePump()
 	client.readPump(s)
 
+	s.removeClient(client)
+
+	log.Info().Str("client_id", clientID).Msg("WebSocket client disconnected")
+}
+
+// removeClient 注销连接并清理其路由
+// 仅当传入实例仍是注册实例时生效: 已被同 ID 新连接替换时注册表和路由都归新实例所有,
+// 旧连接的退出不能碰它们 (快速重连场景下的竞态)
+func (s *WSServer) removeClient(client *WSClient) {
 	s.mu.Lock()
-	// Only delete if this is still our client (not replaced by a new connection)
-	if s.clients[clientID] == client {
-		delete(s.clients, clientID)
+	stillRegistered := s.clients[client.ID] == client
+	if stillRegistered {
+		delete(s.clients, client.ID)
 	}
 	s.mu.Unlock()
 
-	// 清理该 client 相关的路由
-	s.cleanupRoutesForClient(clientID)
-
-	log.Info().Str("client_id", clientID).Msg("WebSocket client disconnected")
+	if stillRegistered {
+		s.cleanupRoutesForClient(client.ID)
+	}
 }
 
 func (s *WSServer) GetClient(clientID string) *WSClient {
@@ -199,47 +529,116 @@ func (c *WSClient) readPump(server *WSServer) {
 			return
 		}
 
-		msg, err := UnmarshalTunnelMessage(message)
+		// ReadMessage 每帧返回独立缓冲且处理器同步执行, 这里刻意选零拷贝解析;
+		// 若处理器改为跨帧持有 payload, 需换用 UnmarshalBinaryCopy
+		msg, err := UnmarshalBinary(message)
 		if err != nil {
 			log.Warn().Err(err).Msg("Failed to unmarshal tunnel message")
 			continue
 		}
 
-		// 根据消息类型处理
-		switch msg.Type {
-		case MsgTypeConnect:
-			server.handleConnect(c.ID, msg)
+		// 根据消息类型分发到注册的处理器
+		handler := server.getHandler(msg.Type)
+		if handler == nil {
+			log.Warn().
+				Uint8("msg_type", msg.Type).
+				Str("client_id", c.ID).
+				Msg("No handler registered for message type")
+			continue
+		}
+		handler(c.ID, msg)
+	}
+}
 
-		case MsgTypeConnAck:
-			server.handleConnAck(c.ID, msg)
+// handleConnect 处理连接请求 - 先过规则建连并发限制, 再路由到目标 Client
+func (s *WSServer) handleConnect(sourceClientID string, msg *TunnelMessage) {
+	limiter := s.connectLimiterFor(msg.RuleID)
+	if limiter == nil {
+		s.forwardConnect(sourceClientID, msg, nil)
+		return
+	}
+	if limiter.tryAcquire() {
+		s.forwardConnect(sourceClientID, msg, limiter)
+		return
+	}
 
-		case MsgTypeData:
-			server.handleData(c.ID, msg)
+	// 槽位已满: 排队不能阻塞读循环 (会停摆同一来源的存量 Data),
+	// 复制消息 (readPump 的 payload 是零拷贝, 跨帧持有需复制) 后转入后台短暂等待
+	queued := *msg
+	if len(msg.Payload) > 0 {
+		queued.Payload = append([]byte(nil), msg.Payload...)
+	}
+	go func() {
+		if !limiter.acquire() {
+			log.Warn().
+				Str("source", sourceClientID).
+				Str("rule_id", queued.RuleID).
+				Uint32("stream_id", queued.StreamID).
+				Int("limit", limiter.limit).
+				Msg("Connect rejected: rule connect concurrency limit reached")
+			s.sendError(sourceClientID, queued.StreamID, "rule connect concurrency limit reached")
+			return
+		}
+		s.forwardConnect(sourceClientID, &queued, limiter)
+	}()
+}
 
-		case MsgTypeClose:
-			server.handleClose(c.ID, msg)
+// forwardConnect 解析 Connect 并转发到目标 Client。
+// limiter 非 nil 时表示已占用一个建连槽位, 路由建立前的失败路径在此归还,
+// 路由建立后由 finishConnecting 随建连阶段结束归还
+func (s *WSServer) forwardConnect(sourceClientID string, msg *TunnelMessage, limiter *connectLimiter) {
+	// 路由尚未接管槽位前, 任何提前返回都要归还
+	routed := false
+	if limiter != nil {
+		defer func() {
+			if !routed {
+				limiter.release()
+			}
+		}()
+	}
 
-		case MsgTypeError:
-			server.handleError(c.ID, msg)
+	// 连接级 Debug 日志只对开启 verbose_logging 的规则输出, 降低大规模部署的日志开销
+	verbose := msg.Flags&ConnFlagVerbose != 0
+	if verbose {
+		log.Debug().
+			Str("source", sourceClientID).
+			Uint32("stream_id", msg.StreamID).
+			Str("target", msg.Target).
+			Msg("Handling connect request")
+	}
 
-		case MsgTypeCheckPortResult:
-			server.HandlePortCheckResult(msg)
-		}
+	// 维护模式只拦截新建连接, 存量路由的 Data/Close 不经过此处
+	if s.InMaintenanceMode() {
+		log.Debug().
+			Str("source", sourceClientID).
+			Uint32("stream_id", msg.StreamID).
+			Msg("Connect rejected: server in maintenance mode")
+		s.sendError(sourceClientID, msg.StreamID, "server in maintenance mode")
+		return
 	}
-}
 
-// handleConnect 处理连接请求 - 路由到目标 Client
-func (s *WSServer) handleConnect(sourceClientID string, msg *TunnelMessage) {
-	log.Debug().
-		Str("source", sourceClientID).
-		Uint32("stream_id", msg.StreamID).
-		Str("target", msg.Target).
-		Msg("Handling connect request")
+	// 统计标签 (可选): 入口在 payload 前部附带, 拆出后不再随 Connect 转发
+	var label string
+	payloadRest := msg.Payload
+	if msg.Flags&ConnFlagLabeled != 0 {
+		label, payloadRest = UnpackConnectLabel(msg.Payload)
+	}
 
-	// Payload 中携带下一跳 Client ID 或代理组引用 (@group_name)
-	var targetRef string
-	if len(msg.Payload) > 0 {
-		targetRef = string(msg.Payload)
+	// Payload 中携带下一跳 Client ID 或代理组引用 (@group_name),
+	// "|" 之后为可选的备用代理组引用 (首跳离线时启用);
+	// ConnFlagEarlyData 置位时 payload 为 [引用段][首包数据], 首包随 Connect 原样转给出口
+	var targetRef, fallbackRef string
+	var earlyData []byte
+	refs := string(payloadRest)
+	if msg.Flags&ConnFlagEarlyData != 0 {
+		refs, earlyData = UnpackConnectRefs(payloadRest)
+	}
+	if refs != "" {
+		parts := strings.SplitN(refs, "|", 2)
+		targetRef = parts[0]
+		if len(parts) == 2 {
+			fallbackRef = parts[1]
+		}
 	}
 
 	if targetRef == "" {
@@ -253,25 +652,41 @@ func (s *WSServer) handleConnect(sourceClientID string, msg *TunnelMessage) {
 	var err error
 
 	if s.loadBalancer != nil && len(targetRef) > 0 && targetRef[0] == '@' {
-		// 代理组引用，使用负载均衡器选择节点
-		targetClientID, nodeID, err = s.loadBalancer.ResolveTarget(targetRef, sourceClientID)
+		// 代理组引用，使用负载均衡器选择节点 (带规则上下文, 规则钉选节点时绕过均衡)
+		targetClientID, nodeID, err = s.loadBalancer.ResolveTargetForRule(targetRef, sourceClientID, msg.RuleID)
 		if err != nil {
 			log.Warn().Err(err).Str("target_ref", targetRef).Msg("Failed to resolve proxy group")
 			s.sendError(sourceClientID, msg.StreamID, "proxy group resolution failed: "+err.Error())
 			return
 		}
-		log.Debug().
-			Str("group_ref", targetRef).
-			Str("selected_client", targetClientID).
-			Str("node_id", nodeID).
-			Msg("Resolved proxy group to client")
+		if verbose {
+			log.Debug().
+				Str("group_ref", targetRef).
+				Str("selected_client", targetClientID).
+				Str("node_id", nodeID).
+				Msg("Resolved proxy group to client")
+		}
 	} else {
 		// 直接是 client ID
 		targetClientID = targetRef
 	}
 
-	// 检查目标 Client 是否在线
+	// 检查目标 Client 是否在线; 离线且带备用组引用时改走备用组
 	targetClient := s.GetClient(targetClientID)
+	if targetClient == nil && fallbackRef != "" && s.loadBalancer != nil {
+		fbClientID, fbNodeID, fbErr := s.loadBalancer.ResolveTarget(fallbackRef, sourceClientID)
+		if fbErr != nil {
+			log.Warn().Err(fbErr).Str("fallback_ref", fallbackRef).Msg("Failed to resolve fallback proxy group")
+		} else if fb := s.GetClient(fbClientID); fb != nil {
+			log.Info().
+				Str("primary_target", targetClientID).
+				Str("fallback_ref", fallbackRef).
+				Str("fallback_client", fbClientID).
+				Msg("Primary target offline, using fallback proxy group")
+			targetClientID, nodeID = fbClientID, fbNodeID
+			targetClient = fb
+		}
+	}
 	if targetClient == nil {
 		log.Warn().Str("target_client", targetClientID).Msg("Target client not online")
 		s.sendError(sourceClientID, msg.StreamID, "target client not online")
@@ -291,7 +706,13 @@ func (s *WSServer) handleConnect(sourceClientID string, msg *TunnelMessage) {
 		ExitAddr:       msg.Target,
 		NodeID:         nodeID,
 		RuleID:         msg.RuleID,
+		Label:          label,
+		CreatedAt:      time.Now(),
+		connecting:     1, // 等待目标 ConnAck
+		limiter:        limiter,
 	}
+	routed = true // 槽位随路由走, 此后由 finishConnecting 归还
+	route.touch()
 	s.routes.Store(routeKey(sourceClientID, msg.StreamID), route)
 	s.routes.Store(routeKey(targetClientID, msg.StreamID), route)
 
@@ -300,12 +721,20 @@ func (s *WSServer) handleConnect(sourceClientID string, msg *TunnelMessage) {
 		s.trafficCounter.IncrementConn(msg.RuleID, sourceClientID)
 	}
 
+	// fast open 首包随 Connect 转发, 不经过 handleData, 在此计入流量
+	if n := int64(len(earlyData)); n > 0 {
+		atomic.AddInt64(&route.bytesOut, n)
+		s.addRouteBytes(route, false, n)
+	}
+
 	// 转发 Connect 消息到目标 Client（零拷贝）
-	// 清除 payload 中的下一跳信息，保留 target 地址
+	// 清除 payload 中的下一跳信息和统计标签，保留 target 地址; 首包数据 (fast open) 原样带给出口
 	forwardMsg := &TunnelMessage{
 		Type:     MsgTypeConnect,
 		StreamID: msg.StreamID,
 		Target:   msg.Target,
+		Flags:    msg.Flags &^ ConnFlagLabeled,
+		Payload:  earlyData,
 	}
 
 	if !targetClient.SendMsg(forwardMsg) {
@@ -313,17 +742,64 @@ func (s *WSServer) handleConnect(sourceClientID string, msg *TunnelMessage) {
 		s.sendError(sourceClientID, msg.StreamID, "failed to send to target")
 		s.cleanupRoute(route)
 	} else {
-		log.Debug().
-			Str("source", sourceClientID).
-			Str("target", targetClientID).
-			Str("exit_addr", msg.Target).
-			Uint32("stream_id", msg.StreamID).
-			Msg("Connect forwarded to target client")
+		// 启动 ConnAck 超时定时器：目标一直不确认时清理路由并通知源端
+		if s.connAckTimeout > 0 {
+			time.AfterFunc(s.connAckTimeout, func() {
+				if s.finishConnecting(route) {
+					log.Warn().
+						Str("source", route.SourceClientID).
+						Str("target", route.TargetClientID).
+						Uint32("stream_id", route.StreamID).
+						Msg("ConnAck timeout, cleaning route")
+					s.sendError(route.SourceClientID, route.StreamID, "connect timeout: target did not acknowledge")
+					s.cleanupRoute(route)
+				}
+			})
+		}
+		if verbose {
+			log.Debug().
+				Str("source", sourceClientID).
+				Str("target", targetClientID).
+				Str("exit_addr", msg.Target).
+				Uint32("stream_id", msg.StreamID).
+				Msg("Connect forwarded to target client")
+		}
+	}
+}
+
+// addRouteBytes 把路由上的字节数计入流量统计。
+// 路由带标签且计数器支持按标签细分时走 (规则, 标签) 维度, 否则退回按规则统计
+func (s *WSServer) addRouteBytes(route *RouteInfo, isIn bool, n int64) {
+	if s.trafficCounter == nil || route.RuleID == "" {
+		return
+	}
+	if route.Label != "" {
+		if lc, ok := s.trafficCounter.(TrafficLabelCounter); ok {
+			if isIn {
+				lc.AddBytesInLabeled(route.RuleID, route.SourceClientID, route.Label, n)
+			} else {
+				lc.AddBytesOutLabeled(route.RuleID, route.SourceClientID, route.Label, n)
+			}
+			return
+		}
+	}
+	if isIn {
+		s.trafficCounter.AddBytesIn(route.RuleID, route.SourceClientID, n)
+	} else {
+		s.trafficCounter.AddBytesOut(route.RuleID, route.SourceClientID, n)
 	}
 }
 
 // cleanupRoute 清理路由并减少节点连接计数
+// 幂等: 同一路由的重复清理 (如空闲回收后又收到迟到的 Close) 只执行一次
 func (s *WSServer) cleanupRoute(route *RouteInfo) {
+	if !atomic.CompareAndSwapInt32(&route.cleaned, 0, 1) {
+		return
+	}
+
+	// 路由已清理，取消待决的 ConnAck 超时 (建连阶段未结束时一并归还限制器槽位)
+	s.finishConnecting(route)
+
 	s.routes.Delete(routeKey(route.SourceClientID, route.StreamID))
 	s.routes.Delete(routeKey(route.TargetClientID, route.StreamID))
 
@@ -338,6 +814,95 @@ func (s *WSServer) cleanupRoute(route *RouteInfo) {
 	}
 }
 
+// SetRouteIdleTimeout 设置路由空闲超时 (应在 StartIdleReaper 前调用)
+func (s *WSServer) SetRouteIdleTimeout(d time.Duration) {
+	s.routeIdleTimeout = d
+}
+
+// StartIdleReaper 启动空闲路由回收器
+// 周期扫描路由表, 超过空闲超时无数据往来的路由向两端发送 Close 后经 cleanupRoute 回收,
+// 保证节点连接计数与流量连接数正确递减; 未配置超时时不启动
+func (s *WSServer) StartIdleReaper() {
+	if s.routeIdleTimeout <= 0 {
+		return
+	}
+	s.idleReaperStopCh = make(chan struct{})
+	s.idleReaperWg.Add(1)
+	go s.runIdleReaper()
+	log.Info().Dur("idle_timeout", s.routeIdleTimeout).Msg("Route idle reaper started")
+}
+
+// StopIdleReaper 停止空闲路由回收器
+func (s *WSServer) StopIdleReaper() {
+	if s.idleReaperStopCh == nil {
+		return
+	}
+	close(s.idleReaperStopCh)
+	s.idleReaperWg.Wait()
+	s.idleReaperStopCh = nil
+	log.Info().Msg("Route idle reaper stopped")
+}
+
+func (s *WSServer) runIdleReaper() {
+	defer s.idleReaperWg.Done()
+
+	// 扫描间隔取超时的四分之一, 下限 1 秒
+	interval := s.routeIdleTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.idleReaperStopCh:
+			return
+		case <-ticker.C:
+			s.reapIdleRoutes(time.Now())
+		}
+	}
+}
+
+// reapIdleRoutes 回收空闲超时的路由
+func (s *WSServer) reapIdleRoutes(now time.Time) {
+	deadline := now.Add(-s.routeIdleTimeout).UnixNano()
+
+	// 每条路由以两个 key 存储, 用指针去重
+	seen := make(map[*RouteInfo]bool)
+	var toReap []*RouteInfo
+	s.routes.Range(func(_, v any) bool {
+		route := v.(*RouteInfo)
+		if seen[route] {
+			return true
+		}
+		seen[route] = true
+		// 等待 ConnAck 的路由由 ConnAck 超时负责, 不在这里回收
+		if atomic.LoadInt32(&route.connecting) == 1 {
+			return true
+		}
+		if atomic.LoadInt64(&route.lastActive) < deadline {
+			toReap = append(toReap, route)
+		}
+		return true
+	})
+
+	for _, route := range toReap {
+		log.Info().
+			Str("source", route.SourceClientID).
+			Str("target", route.TargetClientID).
+			Uint32("stream_id", route.StreamID).
+			Msg("Reaping idle route")
+
+		// 通知两端释放本地流
+		closeMsg := &TunnelMessage{Type: MsgTypeClose, StreamID: route.StreamID}
+		s.SendMsgToClient(route.SourceClientID, closeMsg)
+		s.SendMsgToClient(route.TargetClientID, closeMsg)
+
+		s.cleanupRoute(route)
+	}
+}
+
 // handleConnAck 处理连接确认 - 路由回源 Client
 func (s *WSServer) handleConnAck(fromClientID string, msg *TunnelMessage) {
 	v, ok := s.routes.Load(routeKey(fromClientID, msg.StreamID))
@@ -356,6 +921,10 @@ func (s *WSServer) handleConnAck(fromClientID string, msg *TunnelMessage) {
 		return
 	}
 
+	// 目标已确认，取消 ConnAck 超时 (建连阶段结束, 归还限制器槽位)
+	s.finishConnecting(route)
+	route.touch()
+
 	// 转发到源 Client（零拷贝）
 	if !s.SendMsgToClient(route.SourceClientID, msg) {
 		log.Warn().
@@ -379,6 +948,7 @@ func (s *WSServer) handleData(fromClientID string, msg *TunnelMessage) {
 		return
 	}
 	route := v.(*RouteInfo)
+	route.touch()
 
 	// 确定转发目标和流量方向
 	var targetClientID string
@@ -398,17 +968,24 @@ func (s *WSServer) handleData(fromClientID string, msg *TunnelMessage) {
 	}
 
 	// 统计流量
-	if s.trafficCounter != nil && route.RuleID != "" {
-		dataLen := int64(len(msg.Payload))
-		if isInbound {
-			s.trafficCounter.AddBytesOut(route.RuleID, route.SourceClientID, dataLen)
-		} else {
-			s.trafficCounter.AddBytesIn(route.RuleID, route.SourceClientID, dataLen)
-		}
+	if s.payloadHistogram != nil {
+		s.payloadHistogram.Observe(len(msg.Payload))
+	}
+	dataLen := int64(len(msg.Payload))
+	if isInbound {
+		atomic.AddInt64(&route.bytesOut, dataLen)
+	} else {
+		atomic.AddInt64(&route.bytesIn, dataLen)
 	}
+	// isInbound 表示从源到目标, 计为规则的出站流量
+	s.addRouteBytes(route, !isInbound, dataLen)
 
 	// 转发数据（零拷贝）
 	if !s.SendMsgToClient(targetClientID, msg) {
+		// 目标发送缓冲已满或目标已离线, 该帧被丢弃; 按规则归因丢弃字节数
+		if s.trafficCounter != nil && route.RuleID != "" {
+			s.trafficCounter.AddDroppedBytes(route.RuleID, route.SourceClientID, dataLen)
+		}
 		log.Debug().
 			Str("target", targetClientID).
 			Uint32("stream_id", msg.StreamID).
@@ -518,6 +1095,50 @@ func (s *WSServer) NotifyRuleUpdateToAll() {
 	}
 }
 
+// ConnectionInfo 活跃路由的连接快照
+type ConnectionInfo struct {
+	StreamID       uint32  `json:"stream_id"`
+	SourceClientID string  `json:"source_client_id"`
+	TargetClientID string  `json:"target_client_id"`
+	ExitAddr       string  `json:"exit_addr"`
+	RuleID         string  `json:"rule_id"`
+	BytesIn        int64   `json:"bytes_in"`
+	BytesOut       int64   `json:"bytes_out"`
+	AgeSeconds     float64 `json:"age_seconds"`
+}
+
+// GetClientConnections 返回某 client 作为源或目标的所有活跃路由快照
+func (s *WSServer) GetClientConnections(clientID string) []ConnectionInfo {
+	now := time.Now()
+	// 每条路由以两个 key 存储 (源和目标), 用指针去重
+	seen := make(map[*RouteInfo]bool)
+	conns := make([]ConnectionInfo, 0)
+
+	s.routes.Range(func(_, v interface{}) bool {
+		route := v.(*RouteInfo)
+		if seen[route] {
+			return true
+		}
+		seen[route] = true
+		if route.SourceClientID != clientID && route.TargetClientID != clientID {
+			return true
+		}
+		conns = append(conns, ConnectionInfo{
+			StreamID:       route.StreamID,
+			SourceClientID: route.SourceClientID,
+			TargetClientID: route.TargetClientID,
+			ExitAddr:       route.ExitAddr,
+			RuleID:         route.RuleID,
+			BytesIn:        atomic.LoadInt64(&route.bytesIn),
+			BytesOut:       atomic.LoadInt64(&route.bytesOut),
+			AgeSeconds:     now.Sub(route.CreatedAt).Seconds(),
+		})
+		return true
+	})
+
+	return conns
+}
+
 // IsClientOnline 检查 Client 是否在线
 func (s *WSServer) IsClientOnline(clientID string) bool {
 	return s.GetClient(clientID) != nil
@@ -526,6 +1147,38 @@ func (s *WSServer) IsClientOnline(clientID string) bool {
 // CheckPortAvailable 检查 Client 上的端口是否可用
 // 返回 (是否可用, 错误信息)
 func (s *WSServer) CheckPortAvailable(clientID string, addr string, currentRuleID string, timeout time.Duration) (bool, string) {
+	result := s.CheckPort(clientID, addr, currentRuleID, timeout)
+	return result.Available, result.Error
+}
+
+// CheckPort 检查 Client 上的端口是否可用, 返回含占用方信息的结构化结果
+// 相同 (client, addr, rule) 的并发检查会被合并: 只有第一个调用真正下发请求, 其余等待同一结果
+func (s *WSServer) CheckPort(clientID string, addr string, currentRuleID string, timeout time.Duration) *PortCheckResult {
+	key := clientID + "|" + addr + "|" + currentRuleID
+
+	s.portCheckFlightsMu.Lock()
+	if flight, ok := s.portCheckFlights[key]; ok {
+		s.portCheckFlightsMu.Unlock()
+		<-flight.done
+		return flight.result
+	}
+	flight := &portCheckFlight{done: make(chan struct{})}
+	s.portCheckFlights[key] = flight
+	s.portCheckFlightsMu.Unlock()
+
+	result := s.doCheckPort(clientID, addr, currentRuleID, timeout)
+
+	flight.result = result
+	s.portCheckFlightsMu.Lock()
+	delete(s.portCheckFlights, key)
+	s.portCheckFlightsMu.Unlock()
+	close(flight.done)
+
+	return result
+}
+
+// doCheckPort 实际执行一次端口检查
+func (s *WSServer) doCheckPort(clientID string, addr string, currentRuleID string, timeout time.Duration) *PortCheckResult {
 	log.Info().
 		Str("client_id", clientID).
 		Str("addr", addr).
@@ -534,11 +1187,20 @@ func (s *WSServer) CheckPortAvailable(clientID string, addr string, currentRuleI
 
 	// 检查 client 是否在线
 	if !s.IsClientOnline(clientID) {
-		return false, "客户端不在线"
+		return &PortCheckResult{Error: "客户端不在线"}
 	}
 
-	// 生成唯一的请求 ID
+	// 生成唯一的请求 ID; 等待队列达到全局上限时直接回忙, 不再下发
 	s.pendingPortChecksMu.Lock()
+	if s.maxPendingPortChecks > 0 && len(s.pendingPortChecks) >= s.maxPendingPortChecks {
+		pending := len(s.pendingPortChecks)
+		s.pendingPortChecksMu.Unlock()
+		log.Warn().
+			Int("pending", pending).
+			Int("max", s.maxPendingPortChecks).
+			Msg("Port check rejected: too many pending checks")
+		return &PortCheckResult{Error: "端口检查队列已满，请稍后重试"}
+	}
 	s.portCheckNextID++
 	requestID := s.portCheckNextID
 	resultCh := make(chan *PortCheckResult, 1)
@@ -561,7 +1223,7 @@ func (s *WSServer) CheckPortAvailable(clientID string, addr string, currentRuleI
 	}
 
 	if !s.SendMsgToClient(clientID, msg) {
-		return false, "无法发送请求到客户端"
+		return &PortCheckResult{Error: "无法发送请求到客户端"}
 	}
 
 	log.Info().Uint32("request_id", requestID).Str("addr", addr).Msg("Port check request sent, waiting for response")
@@ -572,12 +1234,14 @@ func (s *WSServer) CheckPortAvailable(clientID string, addr string, currentRuleI
 		log.Info().
 			Uint32("request_id", requestID).
 			Bool("available", result.Available).
+			Str("status", result.Status).
+			Str("owner_rule_id", result.OwnerRuleID).
 			Str("error", result.Error).
 			Msg("Port check result received")
-		return result.Available, result.Error
+		return result
 	case <-time.After(timeout):
 		log.Warn().Uint32("request_id", requestID).Msg("Port check timeout")
-		return false, "检查超时，客户端可能无响应"
+		return &PortCheckResult{Error: "检查超时，客户端可能无响应"}
 	}
 }
 
@@ -592,9 +1256,20 @@ func (s *WSServer) HandlePortCheckResult(msg *TunnelMessage) {
 		return
 	}
 
+	// 根据错误与占用规则推导结构化状态: 可用 -> free, 带规则 ID -> used_by_rule, 其余 -> foreign
+	status := PortStatusFree
+	if msg.Error != "" {
+		if msg.RuleID != "" {
+			status = PortStatusUsedByRule
+		} else {
+			status = PortStatusForeign
+		}
+	}
 	result := &PortCheckResult{
-		Available: msg.Error == "",
-		Error:     msg.Error,
+		Available:   msg.Error == "",
+		Error:       msg.Error,
+		Status:      status,
+		OwnerRuleID: msg.RuleID,
 	}
 
 	select {
@@ -604,21 +1279,175 @@ func (s *WSServer) HandlePortCheckResult(msg *TunnelMessage) {
 	}
 }
 
+// SendCommand 向 Client 下发远程命令并等待执行结果
+// 返回 (命令输出, 错误)
+func (s *WSServer) SendCommand(clientID string, command string, timeout time.Duration) (string, error) {
+	if !s.IsClientOnline(clientID) {
+		return "", errors.New("客户端不在线")
+	}
+
+	// 生成唯一的请求 ID
+	s.pendingCommandsMu.Lock()
+	s.commandNextID++
+	requestID := s.commandNextID
+	resultCh := make(chan *CommandResult, 1)
+	s.pendingCommands[requestID] = resultCh
+	s.pendingCommandsMu.Unlock()
+
+	defer func() {
+		s.pendingCommandsMu.Lock()
+		delete(s.pendingCommands, requestID)
+		s.pendingCommandsMu.Unlock()
+	}()
+
+	// Target 字段携带命令动词
+	msg := &TunnelMessage{
+		Type:     MsgTypeCommand,
+		StreamID: requestID,
+		Target:   command,
+	}
+
+	if !s.SendMsgToClient(clientID, msg) {
+		return "", errors.New("无法发送请求到客户端")
+	}
+
+	log.Info().
+		Uint32("request_id", requestID).
+		Str("client_id", clientID).
+		Str("command", command).
+		Msg("Command sent, waiting for result")
+
+	select {
+	case result := <-resultCh:
+		if result.Error != "" {
+			return result.Output, errors.New(result.Error)
+		}
+		return result.Output, nil
+	case <-time.After(timeout):
+		log.Warn().Uint32("request_id", requestID).Str("command", command).Msg("Command timeout")
+		return "", errors.New("命令执行超时，客户端可能无响应")
+	}
+}
+
+// HandleCommandResult 处理远程命令执行结果
+func (s *WSServer) HandleCommandResult(msg *TunnelMessage) {
+	s.pendingCommandsMu.RLock()
+	resultCh, exists := s.pendingCommands[msg.StreamID]
+	s.pendingCommandsMu.RUnlock()
+
+	if !exists {
+		log.Warn().Uint32("request_id", msg.StreamID).Msg("Received command result for unknown request")
+		return
+	}
+
+	result := &CommandResult{
+		Error:  msg.Error,
+		Output: string(msg.Payload),
+	}
+
+	select {
+	case resultCh <- result:
+	default:
+		log.Warn().Uint32("request_id", msg.StreamID).Msg("Command result channel full")
+	}
+}
+
+// StopRuleAndWait 要求 client 停止指定规则并等待确认。
+// 规则迁移时旧 client 先停止监听、关闭活跃连接, 排空完成后回报,
+// 服务端据此再通知新 client 启动, 取代固定时长的盲等。
+// client 不在线或超时未确认时返回错误, 由调用方决定如何继续
+func (s *WSServer) StopRuleAndWait(clientID, ruleID string, timeout time.Duration) error {
+	if !s.IsClientOnline(clientID) {
+		return errors.New("客户端不在线")
+	}
+
+	s.pendingRuleStopsMu.Lock()
+	s.ruleStopNextID++
+	requestID := s.ruleStopNextID
+	resultCh := make(chan string, 1)
+	s.pendingRuleStops[requestID] = resultCh
+	s.pendingRuleStopsMu.Unlock()
+
+	defer func() {
+		s.pendingRuleStopsMu.Lock()
+		delete(s.pendingRuleStops, requestID)
+		s.pendingRuleStopsMu.Unlock()
+	}()
+
+	msg := &TunnelMessage{
+		Type:     MsgTypeRuleStop,
+		StreamID: requestID,
+		RuleID:   ruleID,
+	}
+	if !s.SendMsgToClient(clientID, msg) {
+		return errors.New("无法发送请求到客户端")
+	}
+
+	log.Info().
+		Uint32("request_id", requestID).
+		Str("client_id", clientID).
+		Str("rule_id", ruleID).
+		Msg("Rule stop sent, waiting for confirmation")
+
+	select {
+	case errMsg := <-resultCh:
+		if errMsg != "" {
+			return errors.New(errMsg)
+		}
+		return nil
+	case <-time.After(timeout):
+		log.Warn().
+			Uint32("request_id", requestID).
+			Str("client_id", clientID).
+			Str("rule_id", ruleID).
+			Msg("Rule stop confirmation timeout")
+		return errors.New("规则停止确认超时，客户端可能无响应")
+	}
+}
+
+// handleRuleStopResult 处理规则停止确认
+func (s *WSServer) handleRuleStopResult(msg *TunnelMessage) {
+	s.pendingRuleStopsMu.RLock()
+	resultCh, exists := s.pendingRuleStops[msg.StreamID]
+	s.pendingRuleStopsMu.RUnlock()
+
+	if !exists {
+		log.Warn().Uint32("request_id", msg.StreamID).Msg("Received rule stop result for unknown request")
+		return
+	}
+
+	select {
+	case resultCh <- msg.Error:
+	default:
+		log.Warn().Uint32("request_id", msg.StreamID).Msg("Rule stop result channel full")
+	}
+}
+
 func (c *WSClient) writePump() {
 	defer c.Close()
 
 	for {
+		// 优先清空控制帧队列, 数据帧积压时 ConnAck/Close 仍能及时送达
+		select {
+		case item := <-c.CtrlCh:
+			if !c.writeItem(item) {
+				return
+			}
+			continue
+		default:
+		}
+
 		select {
+		case item := <-c.CtrlCh:
+			if !c.writeItem(item) {
+				return
+			}
 		case item, ok := <-c.SendCh:
 			if !ok {
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			err := c.Conn.WriteMessage(websocket.BinaryMessage, (*item.buf)[:item.size])
-			// 归还 buffer
-			PutBuffer(item.buf)
-			if err != nil {
-				log.Warn().Err(err).Str("client_id", c.ID).Msg("WebSocket write error")
+			if !c.writeItem(item) {
 				return
 			}
 		case <-c.CloseCh:
@@ -627,6 +1456,17 @@ func (c *WSClient) writePump() {
 	}
 }
 
+// writeItem 发送单帧并归还 buffer, 返回 false 表示写失败应退出
+func (c *WSClient) writeItem(item *sendItem) bool {
+	err := c.Conn.WriteMessage(websocket.BinaryMessage, (*item.buf)[:item.size])
+	PutBuffer(item.buf)
+	if err != nil {
+		log.Warn().Err(err).Str("client_id", c.ID).Msg("WebSocket write error")
+		return false
+	}
+	return true
+}
+
 // Send 发送原始数据（兼容旧接口，内部会复制数据到 pool buffer）
 func (c *WSClient) Send(data []byte) bool {
 	c.mu.Lock()
@@ -643,14 +1483,7 @@ func (c *WSClient) Send(data []byte) bool {
 	}
 	n := copy(*buf, data)
 
-	select {
-	case c.SendCh <- &sendItem{buf: buf, size: n}:
-		return true
-	default:
-		PutBuffer(buf)
-		atomic.AddInt64(&c.droppedMessages, 1)
-		return false
-	}
+	return c.enqueue(&sendItem{buf: buf, size: n}, len(data) > 0 && data[0] != MsgTypeData)
 }
 
 // SendMsg 发送消息（零拷贝，使用 buffer pool）
@@ -669,14 +1502,27 @@ func (c *WSClient) SendMsg(msg *TunnelMessage) bool {
 		return false
 	}
 
+	return c.enqueue(&sendItem{buf: buf, size: n}, msg.Type != MsgTypeData)
+}
+
+// enqueue 帧入队。正常情况下所有帧走 SendCh 保持先后顺序;
+// SendCh 被数据帧灌满时控制帧转入优先队列而不是丢弃, 队列也满才计入丢帧
+func (c *WSClient) enqueue(item *sendItem, isCtrl bool) bool {
 	select {
-	case c.SendCh <- &sendItem{buf: buf, size: n}:
+	case c.SendCh <- item:
 		return true
 	default:
-		PutBuffer(buf)
-		atomic.AddInt64(&c.droppedMessages, 1)
-		return false
 	}
+	if isCtrl {
+		select {
+		case c.CtrlCh <- item:
+			return true
+		default:
+		}
+	}
+	PutBuffer(item.buf)
+	atomic.AddInt64(&c.droppedMessages, 1)
+	return false
 }
 
 func (c *WSClient) Close() {
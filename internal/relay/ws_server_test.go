@@ -1,6 +1,7 @@
 package relay
 
 import (
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -679,6 +680,165 @@ func TestWSServer_HandleConnect_WithLoadBalancer(t *testing.T) {
 	}
 }
 
+func TestWSServer_HandleConnect_FallbackGroupWhenTargetOffline(t *testing.T) {
+	s := NewWSServer()
+	lb := &mockLoadBalancer{
+		resolveClientID: "fallback-exit",
+		resolveNodeID:   "node-7",
+	}
+	s.SetLoadBalancer(lb)
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	fallbackExit := registerTestWSClient(t, s, "fallback-exit")
+	defer fallbackExit.Close()
+
+	// 首跳直连 offline-client 不在线，"|" 后的备用组应接管
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "10.0.0.1:80",
+		Payload:  []byte("offline-client|@backup-group"),
+	})
+
+	// 备用出口应收到 Connect
+	item := drainOneItem(t, fallbackExit.SendCh, time.Second)
+	msg := unmarshalItem(t, item)
+	if msg.Type != MsgTypeConnect {
+		t.Errorf("expected MsgTypeConnect, got %d", msg.Type)
+	}
+
+	// 路由应指向备用出口
+	v, ok := s.routes.Load(routeKey("sender", 1))
+	if !ok {
+		t.Fatal("route should be stored under source key")
+	}
+	route := v.(*RouteInfo)
+	if route.TargetClientID != "fallback-exit" {
+		t.Errorf("route target = %q, want %q", route.TargetClientID, "fallback-exit")
+	}
+
+	// 备用组节点计数应增加
+	if lb.incremented != "node-7" {
+		t.Errorf("IncrementConnections not called with node-7, got %q", lb.incremented)
+	}
+}
+
+func TestWSServer_HandleConnect_FallbackGroupAlsoOffline(t *testing.T) {
+	s := NewWSServer()
+	lb := &mockLoadBalancer{
+		resolveClientID: "also-offline",
+	}
+	s.SetLoadBalancer(lb)
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "10.0.0.1:80",
+		Payload:  []byte("offline-client|@backup-group"),
+	})
+
+	// 备用组选出的 client 也不在线，源端应收到错误
+	item := drainOneItem(t, sender.SendCh, time.Second)
+	msg := unmarshalItem(t, item)
+	if msg.Type != MsgTypeError {
+		t.Errorf("expected MsgTypeError, got %d", msg.Type)
+	}
+}
+
+func TestWSServer_GetClientConnections(t *testing.T) {
+	s := NewWSServer()
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	// 经同一个 target 建立两条路由
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "10.0.0.1:80",
+		Payload:  []byte("target"),
+		RuleID:   "rule-1",
+	})
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 2,
+		Target:   "10.0.0.2:443",
+		Payload:  []byte("target"),
+		RuleID:   "rule-2",
+	})
+
+	conns := s.GetClientConnections("target")
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+
+	byStream := make(map[uint32]ConnectionInfo, len(conns))
+	for _, c := range conns {
+		byStream[c.StreamID] = c
+	}
+	if c, ok := byStream[1]; !ok || c.ExitAddr != "10.0.0.1:80" || c.RuleID != "rule-1" {
+		t.Errorf("stream 1 snapshot mismatch: %+v", byStream[1])
+	}
+	if c, ok := byStream[2]; !ok || c.ExitAddr != "10.0.0.2:443" || c.RuleID != "rule-2" {
+		t.Errorf("stream 2 snapshot mismatch: %+v", byStream[2])
+	}
+	for _, c := range conns {
+		if c.SourceClientID != "sender" || c.TargetClientID != "target" {
+			t.Errorf("unexpected endpoints: %+v", c)
+		}
+		if c.AgeSeconds < 0 {
+			t.Errorf("negative age: %+v", c)
+		}
+	}
+
+	// 源端视角也能看到同样的两条
+	if got := len(s.GetClientConnections("sender")); got != 2 {
+		t.Errorf("expected 2 connections for sender, got %d", got)
+	}
+
+	// 无关 client 看不到任何连接
+	if got := len(s.GetClientConnections("bystander")); got != 0 {
+		t.Errorf("expected 0 connections for bystander, got %d", got)
+	}
+}
+
+func TestWSServer_GetClientConnections_CountsBytes(t *testing.T) {
+	s := NewWSServer()
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "10.0.0.1:80",
+		Payload:  []byte("target"),
+	})
+
+	// 源到目标 4 字节, 目标回源 9 字节
+	s.handleData("sender", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("ping")})
+	s.handleData("target", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("pong pong")})
+
+	conns := s.GetClientConnections("sender")
+	if len(conns) != 1 {
+		t.Fatalf("expected 1 connection, got %d", len(conns))
+	}
+	if conns[0].BytesOut != 4 {
+		t.Errorf("expected bytes_out 4, got %d", conns[0].BytesOut)
+	}
+	if conns[0].BytesIn != 9 {
+		t.Errorf("expected bytes_in 9, got %d", conns[0].BytesIn)
+	}
+}
+
 // ============================================================
 // handleConnAck logic
 // ============================================================
@@ -1067,6 +1227,96 @@ func TestWSServer_HandleConnection_ReplacesOldClient(t *testing.T) {
 	}
 }
 
+func TestWSServer_HandleConnection_RapidReconnectKeepsNewest(t *testing.T) {
+	s := NewWSServer()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.HandleConnection(w, r)
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "?client_id=flappy"
+
+	// 快速重连: 每条新连接都立即替换上一条
+	var conns []*websocket.Conn
+	for i := 0; i < 5; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		conns = append(conns, conn)
+	}
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	// 等旧连接的 readPump 全部退出并跑完清理逻辑
+	time.Sleep(300 * time.Millisecond)
+
+	if !s.IsClientOnline("flappy") {
+		t.Fatal("newest connection should stay registered after rapid reconnects")
+	}
+
+	// 注册的实例必须是最后一条连接: 服务端发出的消息应到达它
+	if !s.NotifyRuleUpdate("flappy") {
+		t.Fatal("failed to send to registered client")
+	}
+	newest := conns[len(conns)-1]
+	newest.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := newest.ReadMessage()
+	if err != nil {
+		t.Fatalf("newest connection should receive server messages: %v", err)
+	}
+	msg, err := UnmarshalTunnelMessage(data)
+	if err != nil {
+		t.Fatalf("failed to unmarshal message: %v", err)
+	}
+	if msg.Type != MsgTypeRuleUpdate {
+		t.Errorf("expected MsgTypeRuleUpdate, got %d", msg.Type)
+	}
+}
+
+func TestWSServer_RemoveClient_SkipsReplacedInstance(t *testing.T) {
+	s := NewWSServer()
+
+	// old 已被同 ID 的 replacement 接管
+	old := registerTestWSClient(t, s, "roamer")
+	defer old.Close()
+	replacement := registerTestWSClient(t, s, "roamer")
+	defer replacement.Close()
+
+	// 新实例上建立的路由
+	target := registerTestWSClient(t, s, "exit")
+	defer target.Close()
+	s.handleConnect("roamer", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "10.0.0.1:80",
+		Payload:  []byte("exit"),
+	})
+
+	// 旧实例退出清理: 注册表和路由都必须留给新实例
+	s.removeClient(old)
+
+	if s.GetClient("roamer") != replacement {
+		t.Error("replacement client should stay registered after old instance cleanup")
+	}
+	if _, ok := s.routes.Load(routeKey("roamer", 1)); !ok {
+		t.Error("route on replacement connection should survive old instance cleanup")
+	}
+
+	// 新实例自己退出时才真正清理
+	s.removeClient(replacement)
+	if s.GetClient("roamer") != nil {
+		t.Error("client should be unregistered after current instance cleanup")
+	}
+	if _, ok := s.routes.Load(routeKey("roamer", 1)); ok {
+		t.Error("routes should be cleaned after current instance cleanup")
+	}
+}
+
 // ============================================================
 // SetLoadBalancer / SetTrafficCounter
 // ============================================================
@@ -1172,12 +1422,17 @@ type mockLoadBalancer struct {
 	resolveNodeID   string
 	incremented     string
 	decremented     string
+	decrementCount  int32
 }
 
 func (m *mockLoadBalancer) ResolveTarget(target string, clientIP string) (string, string, error) {
 	return m.resolveClientID, m.resolveNodeID, nil
 }
 
+func (m *mockLoadBalancer) ResolveTargetForRule(target, clientIP, ruleID string) (string, string, error) {
+	return m.ResolveTarget(target, clientIP)
+}
+
 func (m *mockLoadBalancer) IncrementConnections(nodeID string) error {
 	m.incremented = nodeID
 	return nil
@@ -1185,6 +1440,7 @@ func (m *mockLoadBalancer) IncrementConnections(nodeID string) error {
 
 func (m *mockLoadBalancer) DecrementConnections(nodeID string) error {
 	m.decremented = nodeID
+	atomic.AddInt32(&m.decrementCount, 1)
 	return nil
 }
 
@@ -1197,6 +1453,8 @@ type mockTrafficCounter struct {
 	incrementedClient  string
 	decrementedRule    string
 	decrementedClient  string
+	droppedRule        string
+	droppedBytes       int64
 }
 
 func (m *mockTrafficCounter) AddBytesIn(ruleID, clientID string, bytes int64) {
@@ -1209,6 +1467,11 @@ func (m *mockTrafficCounter) AddBytesOut(ruleID, clientID string, bytes int64) {
 	m.bytesOut = bytes
 }
 
+func (m *mockTrafficCounter) AddDroppedBytes(ruleID, clientID string, bytes int64) {
+	m.droppedRule = ruleID
+	atomic.AddInt64(&m.droppedBytes, bytes)
+}
+
 func (m *mockTrafficCounter) IncrementConn(ruleID, clientID string) {
 	m.incrementedRule = ruleID
 	m.incrementedClient = clientID
@@ -1218,3 +1481,573 @@ func (m *mockTrafficCounter) DecrementConn(ruleID, clientID string) {
 	m.decrementedRule = ruleID
 	m.decrementedClient = clientID
 }
+
+// ============================================================
+// ConnAck timeout
+// ============================================================
+
+func TestWSServer_ConnAckTimeout_CleansRouteAndNotifiesSource(t *testing.T) {
+	s := NewWSServer()
+	s.SetConnAckTimeout(100 * time.Millisecond)
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "127.0.0.1:8080",
+		Payload:  []byte("target"),
+	})
+
+	// Target receives Connect but never acks
+	item := drainOneItem(t, target.SendCh, time.Second)
+	msg := unmarshalItem(t, item)
+	if msg.Type != MsgTypeConnect {
+		t.Fatalf("expected MsgTypeConnect, got %d", msg.Type)
+	}
+
+	// Source should receive an error after the timeout window
+	item = drainOneItem(t, sender.SendCh, time.Second)
+	msg = unmarshalItem(t, item)
+	if msg.Type != MsgTypeError {
+		t.Fatalf("expected MsgTypeError, got %d", msg.Type)
+	}
+	if msg.StreamID != 1 {
+		t.Errorf("StreamID = %d, want 1", msg.StreamID)
+	}
+
+	// Route should be cleaned under both keys
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, okSrc := s.routes.Load(routeKey("sender", 1))
+		_, okDst := s.routes.Load(routeKey("target", 1))
+		if !okSrc && !okDst {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("route not cleaned after ConnAck timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWSServer_ConnAckTimeout_AckCancelsTimer(t *testing.T) {
+	s := NewWSServer()
+	s.SetConnAckTimeout(100 * time.Millisecond)
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "127.0.0.1:8080",
+		Payload:  []byte("target"),
+	})
+
+	// Drain forwarded Connect
+	item := drainOneItem(t, target.SendCh, time.Second)
+	unmarshalItem(t, item)
+
+	// Target acks in time
+	s.handleConnAck("target", &TunnelMessage{Type: MsgTypeConnAck, StreamID: 1})
+
+	// Sender gets the ConnAck
+	item = drainOneItem(t, sender.SendCh, time.Second)
+	msg := unmarshalItem(t, item)
+	if msg.Type != MsgTypeConnAck {
+		t.Fatalf("expected MsgTypeConnAck, got %d", msg.Type)
+	}
+
+	// Wait past the timeout window: route must survive, no error sent
+	time.Sleep(250 * time.Millisecond)
+	if _, ok := s.routes.Load(routeKey("sender", 1)); !ok {
+		t.Error("route should survive when ConnAck arrives in time")
+	}
+	select {
+	case item := <-sender.SendCh:
+		msg := unmarshalItem(t, item)
+		t.Errorf("unexpected message to sender after ack: type %d", msg.Type)
+	default:
+	}
+}
+
+// ============================================================
+// Port check dedup
+// ============================================================
+
+// servePortChecks 消费 client 发送队列中的端口检查请求, 延迟 delay 后回成功, 返回请求计数
+func servePortChecks(s *WSServer, client *WSClient, delay time.Duration, stop chan struct{}) *int64 {
+	var served int64
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case item := <-client.SendCh:
+				msg, err := UnmarshalBinary((*item.buf)[:item.size])
+				PutBuffer(item.buf)
+				if err != nil || msg.Type != MsgTypeCheckPort {
+					continue
+				}
+				atomic.AddInt64(&served, 1)
+				go func(streamID uint32) {
+					time.Sleep(delay)
+					s.HandlePortCheckResult(&TunnelMessage{
+						Type:     MsgTypeCheckPortResult,
+						StreamID: streamID,
+					})
+				}(msg.StreamID)
+			}
+		}
+	}()
+	return &served
+}
+
+func TestWSServer_CheckPortAvailable_DedupsConcurrentIdenticalChecks(t *testing.T) {
+	s := NewWSServer()
+	client := registerTestWSClient(t, s, "pc-client")
+	defer client.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	served := servePortChecks(s, client, 200*time.Millisecond, stop)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]bool, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			available, _ := s.CheckPortAvailable("pc-client", "0.0.0.0:8080", "rule-1", 2*time.Second)
+			results[i] = available
+		}(i)
+	}
+	wg.Wait()
+
+	for i, ok := range results {
+		if !ok {
+			t.Errorf("caller %d: expected port available", i)
+		}
+	}
+	if got := atomic.LoadInt64(served); got != 1 {
+		t.Errorf("expected exactly 1 check request to reach the client, got %d", got)
+	}
+}
+
+func TestWSServer_CheckPortAvailable_DistinctChecksNotDeduped(t *testing.T) {
+	s := NewWSServer()
+	client := registerTestWSClient(t, s, "pc-client")
+	defer client.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	served := servePortChecks(s, client, 50*time.Millisecond, stop)
+
+	var wg sync.WaitGroup
+	for _, addr := range []string{"0.0.0.0:8080", "0.0.0.0:8081"} {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			s.CheckPortAvailable("pc-client", addr, "rule-1", 2*time.Second)
+		}(addr)
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt64(served); got != 2 {
+		t.Errorf("expected 2 check requests for distinct addrs, got %d", got)
+	}
+}
+
+func TestWSServer_CheckPort_RejectsWhenPendingCapReached(t *testing.T) {
+	s := NewWSServer()
+	s.SetMaxPendingPortChecks(2)
+	client := registerTestWSClient(t, s, "pc-client")
+	defer client.Close()
+
+	// 预填等待队列至上限 (模拟客户端不响应形成的积压)
+	s.pendingPortChecksMu.Lock()
+	s.pendingPortChecks[1] = make(chan *PortCheckResult, 1)
+	s.pendingPortChecks[2] = make(chan *PortCheckResult, 1)
+	s.pendingPortChecksMu.Unlock()
+
+	start := time.Now()
+	result := s.CheckPort("pc-client", "0.0.0.0:8080", "rule-1", 2*time.Second)
+	if result.Available {
+		t.Error("check should not succeed when pending queue is full")
+	}
+	if result.Error == "" {
+		t.Error("rejected check should carry a busy error")
+	}
+	// 拒绝应立即返回, 不占用超时窗口
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("rejection took %v, should return immediately", elapsed)
+	}
+	// 被拒绝的检查不在队列中留下条目, 也不下发给客户端
+	if got := s.PendingPortCheckCount(); got != 2 {
+		t.Errorf("pending count = %d, want 2", got)
+	}
+	select {
+	case <-client.SendCh:
+		t.Error("rejected check should not be sent to the client")
+	default:
+	}
+
+	// 积压清除后新检查恢复正常
+	s.pendingPortChecksMu.Lock()
+	delete(s.pendingPortChecks, 1)
+	delete(s.pendingPortChecks, 2)
+	s.pendingPortChecksMu.Unlock()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	servePortChecks(s, client, 0, stop)
+
+	available, errMsg := s.CheckPortAvailable("pc-client", "0.0.0.0:8080", "rule-1", 2*time.Second)
+	if !available || errMsg != "" {
+		t.Errorf("check after backlog cleared: available=%v err=%q, want true/empty", available, errMsg)
+	}
+	if got := s.PendingPortCheckCount(); got != 0 {
+		t.Errorf("pending count after completion = %d, want 0", got)
+	}
+}
+
+// ============================================================
+// Message handler registration
+// ============================================================
+
+func TestWSServer_RegisterHandler_CustomTypeInvoked(t *testing.T) {
+	s := NewWSServer()
+	srv := httptest.NewServer(http.HandlerFunc(s.HandleConnection))
+	defer srv.Close()
+
+	const customType byte = 0x7F
+
+	received := make(chan *TunnelMessage, 1)
+	s.RegisterHandler(customType, func(clientID string, msg *TunnelMessage) {
+		if clientID != "handler-client" {
+			t.Errorf("handler clientID = %q, want %q", clientID, "handler-client")
+		}
+		received <- msg
+	})
+
+	wsURL := "ws" + srv.URL[len("http"):] + "?client_id=handler-client"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer conn.Close()
+
+	// 自定义类型只序列化 header, 用 StreamID 验证消息内容到达
+	msg := &TunnelMessage{Type: customType, StreamID: 42}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if got.StreamID != 42 {
+			t.Errorf("StreamID = %d, want 42", got.StreamID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("custom handler was never invoked")
+	}
+}
+
+func TestWSServer_RegisterHandler_BuiltinsPresent(t *testing.T) {
+	s := NewWSServer()
+
+	builtins := []byte{
+		MsgTypeConnect, MsgTypeConnAck, MsgTypeData, MsgTypeClose,
+		MsgTypeError, MsgTypeCheckPortResult, MsgTypeCommandResult,
+	}
+	for _, msgType := range builtins {
+		if s.getHandler(msgType) == nil {
+			t.Errorf("expected builtin handler for message type 0x%02X", msgType)
+		}
+	}
+
+	// 未注册的类型查不到处理器 (readPump 对其告警而不是静默忽略)
+	if s.getHandler(0x7E) != nil {
+		t.Error("expected no handler for unregistered message type")
+	}
+}
+
+// ============================================================
+// Idle route reaping
+// ============================================================
+
+func TestWSServer_ReapIdleRoutes_DecrementsExactlyOnce(t *testing.T) {
+	s := NewWSServer()
+	s.SetRouteIdleTimeout(time.Minute)
+	lb := &mockLoadBalancer{}
+	s.SetLoadBalancer(lb)
+	tc := &mockTrafficCounter{}
+	s.SetTrafficCounter(tc)
+
+	route := &RouteInfo{
+		SourceClientID: "a",
+		TargetClientID: "b",
+		StreamID:       1,
+		NodeID:         "node-1",
+		RuleID:         "rule-1",
+	}
+	// 最近活跃在超时之前
+	atomic.StoreInt64(&route.lastActive, time.Now().Add(-2*time.Minute).UnixNano())
+	s.routes.Store(routeKey("a", 1), route)
+	s.routes.Store(routeKey("b", 1), route)
+
+	s.reapIdleRoutes(time.Now())
+
+	if _, ok := s.routes.Load(routeKey("a", 1)); ok {
+		t.Error("idle route source key should be reaped")
+	}
+	if _, ok := s.routes.Load(routeKey("b", 1)); ok {
+		t.Error("idle route target key should be reaped")
+	}
+	if lb.decremented != "node-1" {
+		t.Errorf("DecrementConnections not called with node-1, got %q", lb.decremented)
+	}
+	if got := atomic.LoadInt32(&lb.decrementCount); got != 1 {
+		t.Errorf("decrement count = %d, want 1", got)
+	}
+	if tc.decrementedRule != "rule-1" || tc.decrementedClient != "a" {
+		t.Errorf("DecrementConn not called correctly: rule=%q client=%q", tc.decrementedRule, tc.decrementedClient)
+	}
+
+	// 迟到的 Close: 路由已出表, handleClose 是 no-op; 即便拿着旧指针重复清理也不再递减
+	s.handleClose("a", &TunnelMessage{Type: MsgTypeClose, StreamID: 1})
+	s.cleanupRoute(route)
+	if got := atomic.LoadInt32(&lb.decrementCount); got != 1 {
+		t.Errorf("decrement count after late close = %d, want 1", got)
+	}
+}
+
+func TestWSServer_ReapIdleRoutes_SkipsActiveAndConnecting(t *testing.T) {
+	s := NewWSServer()
+	s.SetRouteIdleTimeout(time.Minute)
+
+	active := &RouteInfo{SourceClientID: "a", TargetClientID: "b", StreamID: 1}
+	active.touch()
+	s.routes.Store(routeKey("a", 1), active)
+	s.routes.Store(routeKey("b", 1), active)
+
+	// 等待 ConnAck 的路由由 ConnAck 超时负责, 即使超时也不被空闲回收
+	connecting := &RouteInfo{SourceClientID: "a", TargetClientID: "c", StreamID: 2, connecting: 1}
+	atomic.StoreInt64(&connecting.lastActive, time.Now().Add(-2*time.Minute).UnixNano())
+	s.routes.Store(routeKey("a", 2), connecting)
+	s.routes.Store(routeKey("c", 2), connecting)
+
+	s.reapIdleRoutes(time.Now())
+
+	if _, ok := s.routes.Load(routeKey("a", 1)); !ok {
+		t.Error("active route should not be reaped")
+	}
+	if _, ok := s.routes.Load(routeKey("a", 2)); !ok {
+		t.Error("connecting route should not be reaped")
+	}
+}
+
+func TestWSServer_IdleReaper_StartStopWithoutTimeout(t *testing.T) {
+	s := NewWSServer()
+	// 未配置超时时 Start/Stop 均为 no-op, 不应 panic
+	s.StartIdleReaper()
+	s.StopIdleReaper()
+}
+
+func TestSetDuplicatePolicy_FallbackToReplace(t *testing.T) {
+	s := NewWSServer()
+
+	s.SetDuplicatePolicy(DuplicateRejectNew)
+	if s.duplicatePolicy != DuplicateRejectNew {
+		t.Errorf("policy = %q, want %q", s.duplicatePolicy, DuplicateRejectNew)
+	}
+
+	// 未知值 (含尚未实现的 allow-both) 回退 replace
+	s.SetDuplicatePolicy("allow-both")
+	if s.duplicatePolicy != DuplicateReplace {
+		t.Errorf("policy = %q, want fallback to %q", s.duplicatePolicy, DuplicateReplace)
+	}
+
+	s.SetDuplicatePolicy("")
+	if s.duplicatePolicy != DuplicateReplace {
+		t.Errorf("empty policy = %q, want %q", s.duplicatePolicy, DuplicateReplace)
+	}
+}
+
+func TestWSServer_HandleConnection_RejectNewKeepsExisting(t *testing.T) {
+	s := NewWSServer()
+	s.SetDuplicatePolicy(DuplicateRejectNew)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.HandleConnection(w, r)
+	}))
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "?client_id=dup"
+
+	conn1, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial 1: %v", err)
+	}
+	defer conn1.Close()
+
+	waitForClientOnline(t, s, "dup", time.Second)
+
+	// 第二条同 ID 连接应在握手阶段被 409 拒绝
+	conn2, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil {
+		conn2.Close()
+		t.Fatal("second connection should be rejected under reject-new policy")
+	}
+	if resp == nil || resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected HTTP 409 rejection, got resp = %+v", resp)
+	}
+
+	// 原连接不受影响: 仍在注册表中, 且读超时 (而不是被服务端关闭)
+	if !s.IsClientOnline("dup") {
+		t.Error("existing client should stay online after rejected duplicate")
+	}
+	conn1.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, _, err := conn1.ReadMessage(); err != nil {
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			t.Errorf("existing connection should stay open (read timeout), got %v", err)
+		}
+	}
+
+	// 原连接断开后同 ID 可以重新接入
+	conn1.Close()
+	deadline := time.Now().Add(time.Second)
+	for s.IsClientOnline("dup") {
+		if time.Now().After(deadline) {
+			t.Fatal("client never deregistered after close")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	conn3, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("reconnect after close should succeed: %v", err)
+	}
+	conn3.Close()
+}
+
+func TestWSServer_HandleData_DropCountsBytesPerRule(t *testing.T) {
+	s := NewWSServer()
+	tc := &mockTrafficCounter{}
+	s.SetTrafficCounter(tc)
+
+	// 发送缓冲为 0 且无人消费的目标连接: 任何转发都会走丢弃分支
+	target := &WSClient{
+		ID:      "target",
+		SendCh:  make(chan *sendItem),
+		CloseCh: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.clients["target"] = target
+	s.mu.Unlock()
+
+	route := &RouteInfo{
+		SourceClientID: "source",
+		TargetClientID: "target",
+		StreamID:       1,
+		RuleID:         "rule-drop",
+	}
+	s.routes.Store(routeKey("source", 1), route)
+	s.routes.Store(routeKey("target", 1), route)
+
+	payload := []byte("dropped-bytes")
+	s.handleData("source", &TunnelMessage{
+		Type:     MsgTypeData,
+		StreamID: 1,
+		Payload:  payload,
+	})
+
+	if tc.droppedRule != "rule-drop" {
+		t.Errorf("dropped rule = %q, want %q", tc.droppedRule, "rule-drop")
+	}
+	if got := atomic.LoadInt64(&tc.droppedBytes); got != int64(len(payload)) {
+		t.Errorf("dropped bytes = %d, want %d", got, len(payload))
+	}
+
+	// 缓冲仍然为 0, 第二帧同样被丢弃, 计数继续累加
+	s.handleData("source", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("x")})
+	if got := atomic.LoadInt64(&tc.droppedBytes); got != int64(len(payload))+1 {
+		t.Errorf("dropped bytes after second drop = %d, want %d", got, len(payload)+1)
+	}
+}
+
+func TestWSServer_MaintenanceMode_RejectsNewConnectsKeepsExisting(t *testing.T) {
+	s := NewWSServer()
+
+	sender := registerTestWSClient(t, s, "sender")
+	defer sender.Close()
+	target := registerTestWSClient(t, s, "target")
+	defer target.Close()
+
+	// 维护模式开启前先建立一条路由
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 1,
+		Target:   "127.0.0.1:8080",
+		Payload:  []byte("target"),
+	})
+	item := drainOneItem(t, target.SendCh, time.Second)
+	if unmarshalItem(t, item).Type != MsgTypeConnect {
+		t.Fatal("initial connect should be forwarded")
+	}
+
+	s.SetMaintenanceMode(true)
+	if !s.InMaintenanceMode() {
+		t.Fatal("InMaintenanceMode should report true")
+	}
+
+	// 新 Connect 被拒绝: 源收到 Error 且不建立路由
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 2,
+		Target:   "127.0.0.1:9090",
+		Payload:  []byte("target"),
+	})
+	item = drainOneItem(t, sender.SendCh, time.Second)
+	msg := unmarshalItem(t, item)
+	if msg.Type != MsgTypeError {
+		t.Errorf("expected MsgTypeError, got %d", msg.Type)
+	}
+	if !strings.Contains(msg.Error, "maintenance") {
+		t.Errorf("error = %q, want maintenance message", msg.Error)
+	}
+	if _, ok := s.routes.Load(routeKey("sender", 2)); ok {
+		t.Error("no route should be created in maintenance mode")
+	}
+
+	// 存量路由继续中继数据
+	s.handleData("sender", &TunnelMessage{Type: MsgTypeData, StreamID: 1, Payload: []byte("still flowing")})
+	item = drainOneItem(t, target.SendCh, time.Second)
+	msg = unmarshalItem(t, item)
+	if msg.Type != MsgTypeData || string(msg.Payload) != "still flowing" {
+		t.Errorf("existing route should keep relaying, got type=%d payload=%q", msg.Type, msg.Payload)
+	}
+
+	// 关闭维护模式后恢复接受新连接
+	s.SetMaintenanceMode(false)
+	s.handleConnect("sender", &TunnelMessage{
+		Type:     MsgTypeConnect,
+		StreamID: 3,
+		Target:   "127.0.0.1:8080",
+		Payload:  []byte("target"),
+	})
+	item = drainOneItem(t, target.SendCh, time.Second)
+	if unmarshalItem(t, item).Type != MsgTypeConnect {
+		t.Error("connect should be forwarded again after maintenance mode is lifted")
+	}
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(entry *model.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+type AuditListParams struct {
+	Page   int
+	Limit  int
+	Method string
+	Actor  string
+}
+
+func (r *AuditRepository) List(params AuditListParams) ([]model.AuditLog, int64, error) {
+	var entries []model.AuditLog
+	var total int64
+
+	query := r.db.Model(&model.AuditLog{})
+
+	if params.Method != "" {
+		query = query.Where("method = ?", params.Method)
+	}
+	if params.Actor != "" {
+		query = query.Where("actor = ?", params.Actor)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((params.Page - 1) * params.Limit).
+		Limit(params.Limit).
+		Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
@@ -48,6 +48,9 @@ type ClientListParams struct {
 	Limit  int
 	Search string
 	Status string
+	// 键集分页游标 (AfterID 非空时生效, 此时忽略 Page)
+	AfterCreatedAt time.Time
+	AfterID        string
 }
 
 func (r *ClientRepository) List(params ClientListParams) ([]model.Client, int64, error) {
@@ -69,14 +72,39 @@ func (r *ClientRepository) List(params ClientListParams) ([]model.Client, int64,
 		return nil, 0, err
 	}
 
-	offset := (params.Page - 1) * params.Limit
-	if err := query.Order("created_at DESC").Offset(offset).Limit(params.Limit).Find(&clients).Error; err != nil {
+	// 键集分页: 在 Count 之后过滤, total 始终为全量
+	if params.AfterID != "" {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)",
+			params.AfterCreatedAt, params.AfterCreatedAt, params.AfterID)
+	} else {
+		query = query.Offset((params.Page - 1) * params.Limit)
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Limit(params.Limit).Find(&clients).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return clients, total, nil
 }
 
+// ListOnlineByTag 返回携带指定标签 (key=value) 的在线客户端。
+// 标签以 JSON 文本落库无法下推过滤, 在线客户端数量有限, 在内存中筛选。
+func (r *ClientRepository) ListOnlineByTag(key, value string) ([]model.Client, error) {
+	var clients []model.Client
+	if err := r.db.Where("status = ?", model.ClientStatusOnline).
+		Order("created_at ASC, id ASC").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+
+	matched := make([]model.Client, 0, len(clients))
+	for _, c := range clients {
+		if c.Tags[key] == value {
+			matched = append(matched, c)
+		}
+	}
+	return matched, nil
+}
+
 func (r *ClientRepository) UpdateStatus(id string, status model.ClientStatus, ip string) error {
 	now := time.Now()
 	return r.db.Model(&model.Client{}).Where("id = ?", id).Updates(map[string]interface{}{
@@ -86,6 +114,37 @@ func (r *ClientRepository) UpdateStatus(id string, status model.ClientStatus, ip
 	}).Error
 }
 
+// MarkStaleOffline 将 LastSeen 早于阈值的在线客户端标记为离线
+// 返回受影响的客户端数量
+func (r *ClientRepository) MarkStaleOffline(threshold time.Duration) (int64, error) {
+	cutoff := time.Now().Add(-threshold)
+	result := r.db.Model(&model.Client{}).
+		Where("status = ? AND (last_seen IS NULL OR last_seen < ?)", model.ClientStatusOnline, cutoff).
+		Update("status", model.ClientStatusOffline)
+	return result.RowsAffected, result.Error
+}
+
 func (r *ClientRepository) UpdateToken(id string, token string) error {
 	return r.db.Model(&model.Client{}).Where("id = ?", id).Update("token", token).Error
 }
+
+// RotateSecret 轮换密钥: 当前密钥转为旧密钥并记录轮换时间, 供宽限期内双密钥校验
+func (r *ClientRepository) RotateSecret(id string, newKey string) error {
+	now := time.Now()
+	return r.db.Model(&model.Client{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"prev_secret_key":   gorm.Expr("secret_key"),
+		"secret_key":        newKey,
+		"secret_rotated_at": &now,
+	}).Error
+}
+
+// ListWithStaleSecrets 返回密钥使用时长超过 maxAge 的客户端
+// (从未轮换过的按创建时间计)
+func (r *ClientRepository) ListWithStaleSecrets(maxAge time.Duration) ([]model.Client, error) {
+	cutoff := time.Now().Add(-maxAge)
+	var clients []model.Client
+	err := r.db.
+		Where("(secret_rotated_at IS NULL AND created_at < ?) OR secret_rotated_at < ?", cutoff, cutoff).
+		Find(&clients).Error
+	return clients, err
+}
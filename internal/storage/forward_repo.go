@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 	"gorm.io/gorm"
 )
@@ -39,6 +41,9 @@ type ForwardListParams struct {
 	ClientID string
 	Type     string
 	Enabled  *bool
+	// 键集分页游标 (AfterID 非空时生效, 此时忽略 Page)
+	AfterCreatedAt time.Time
+	AfterID        string
 }
 
 func (r *ForwardRepository) List(params ForwardListParams) ([]model.ForwardRule, int64, error) {
@@ -63,8 +68,15 @@ func (r *ForwardRepository) List(params ForwardListParams) ([]model.ForwardRule,
 		return nil, 0, err
 	}
 
-	offset := (params.Page - 1) * params.Limit
-	if err := query.Order("created_at DESC").Offset(offset).Limit(params.Limit).Find(&rules).Error; err != nil {
+	// 键集分页: 在 Count 之后过滤, total 始终为全量
+	if params.AfterID != "" {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)",
+			params.AfterCreatedAt, params.AfterCreatedAt, params.AfterID)
+	} else {
+		query = query.Offset((params.Page - 1) * params.Limit)
+	}
+
+	if err := query.Order("created_at DESC, id DESC").Limit(params.Limit).Find(&rules).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -83,6 +95,73 @@ func (r *ForwardRepository) ToggleEnabled(id string, enabled bool) error {
 	return r.db.Model(&model.ForwardRule{}).Where("id = ?", id).Update("enabled", enabled).Error
 }
 
+// HasEnabledListenConflict 检查同一 client 上是否已有占用该监听地址的启用规则
+// excludeID 非空时排除指定规则 (用于更新场景排除自身)
+func (r *ForwardRepository) HasEnabledListenConflict(clientID, listenAddr, excludeID string) (bool, error) {
+	var count int64
+	query := r.db.Model(&model.ForwardRule{}).
+		Where("listen_client = ? AND listen_addr = ? AND enabled = ?", clientID, listenAddr, true)
+	if excludeID != "" {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListScheduledActivations 查询已进入启用时间窗但尚未启用的规则
+func (r *ForwardRepository) ListScheduledActivations(now time.Time) ([]model.ForwardRule, error) {
+	var rules []model.ForwardRule
+	err := r.db.
+		Where("enabled = ? AND enabled_from IS NOT NULL AND enabled_from <= ?", false, now).
+		Where("enabled_until IS NULL OR enabled_until > ?", now).
+		Find(&rules).Error
+	return rules, err
+}
+
+// ListScheduledDeactivations 查询已超出启用时间窗但仍启用的规则
+func (r *ForwardRepository) ListScheduledDeactivations(now time.Time) ([]model.ForwardRule, error) {
+	var rules []model.ForwardRule
+	err := r.db.
+		Where("enabled = ? AND enabled_until IS NOT NULL AND enabled_until <= ?", true, now).
+		Find(&rules).Error
+	return rules, err
+}
+
+// GetRelayRules 获取全部 relay 类型规则 (用于扫描代理组引用)
+func (r *ForwardRepository) GetRelayRules() ([]model.ForwardRule, error) {
+	var rules []model.ForwardRule
+	if err := r.db.Where("type = ?", model.ForwardTypeRelay).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetAllByClientID 获取某 client 的全部规则（包含已禁用的）
+func (r *ForwardRepository) GetAllByClientID(clientID string) ([]model.ForwardRule, error) {
+	var rules []model.ForwardRule
+	if err := r.db.Where("listen_client = ?", clientID).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ToggleEnabledBulk 在单个事务中批量启用/禁用规则
+func (r *ForwardRepository) ToggleEnabledBulk(ids []string, enabled bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, id := range ids {
+			if err := tx.Model(&model.ForwardRule{}).Where("id = ?", id).Update("enabled", enabled).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (r *ForwardRepository) UpdateStatus(id string, status model.RuleStatus, lastError string) error {
 	updates := map[string]any{
 		"status":     status,
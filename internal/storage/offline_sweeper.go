@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// OfflineSweeper 定期扫描 LastSeen 过期的客户端并标记为离线
+// 独立于 WebSocket 断开路径, 覆盖只有 HTTP 心跳停止的场景
+type OfflineSweeper struct {
+	clients   *ClientRepository
+	interval  time.Duration // 扫描间隔
+	threshold time.Duration // LastSeen 超过该时长视为离线
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewOfflineSweeper(clients *ClientRepository, interval, threshold time.Duration) *OfflineSweeper {
+	return &OfflineSweeper{
+		clients:   clients,
+		interval:  interval,
+		threshold: threshold,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (s *OfflineSweeper) Start() {
+	s.wg.Add(1)
+	go s.run()
+	log.Info().
+		Dur("interval", s.interval).
+		Dur("threshold", s.threshold).
+		Msg("Offline sweeper started")
+}
+
+func (s *OfflineSweeper) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	log.Info().Msg("Offline sweeper stopped")
+}
+
+func (s *OfflineSweeper) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.sweep()
+		}
+	}
+}
+
+func (s *OfflineSweeper) sweep() {
+	affected, err := s.clients.MarkStaleOffline(s.threshold)
+	if err != nil {
+		log.Error().Err(err).Msg("Offline sweep failed")
+		return
+	}
+	if affected > 0 {
+		log.Info().Int64("count", affected).Msg("Marked stale clients offline")
+	}
+}
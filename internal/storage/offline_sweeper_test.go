@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupClientTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+
+	err = db.AutoMigrate(
+		&model.Client{},
+	)
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	return db
+}
+
+func seedClient(t *testing.T, repo *ClientRepository, id string, status model.ClientStatus, lastSeen time.Time) {
+	t.Helper()
+	client := &model.Client{
+		ID:       id,
+		Name:     id,
+		Token:    "token-" + id,
+		Status:   status,
+		LastSeen: &lastSeen,
+	}
+	if err := repo.Create(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+}
+
+func TestOfflineSweeper_MarksStaleClientOffline(t *testing.T) {
+	db := setupClientTestDB(t)
+	repo := NewClientRepository(db)
+
+	// LastSeen 已经超过阈值
+	seedClient(t, repo, "stale", model.ClientStatusOnline, time.Now().Add(-5*time.Minute))
+	// 刚刚心跳过
+	seedClient(t, repo, "fresh", model.ClientStatusOnline, time.Now())
+
+	sweeper := NewOfflineSweeper(repo, time.Second, 90*time.Second)
+	sweeper.sweep()
+
+	stale, err := repo.GetByID("stale")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+	if stale.Status != model.ClientStatusOffline {
+		t.Errorf("expected stale client offline, got %s", stale.Status)
+	}
+
+	fresh, err := repo.GetByID("fresh")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+	if fresh.Status != model.ClientStatusOnline {
+		t.Errorf("expected fresh client to stay online, got %s", fresh.Status)
+	}
+}
+
+func TestMarkStaleOffline_NullLastSeen(t *testing.T) {
+	db := setupClientTestDB(t)
+	repo := NewClientRepository(db)
+
+	client := &model.Client{
+		ID:     "no-seen",
+		Name:   "no-seen",
+		Token:  "token-no-seen",
+		Status: model.ClientStatusOnline,
+	}
+	if err := repo.Create(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	affected, err := repo.MarkStaleOffline(90 * time.Second)
+	if err != nil {
+		t.Fatalf("MarkStaleOffline failed: %v", err)
+	}
+	if affected != 1 {
+		t.Errorf("expected 1 affected, got %d", affected)
+	}
+
+	got, err := repo.GetByID("no-seen")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+	if got.Status != model.ClientStatusOffline {
+		t.Errorf("expected offline, got %s", got.Status)
+	}
+}
+
+func TestMarkStaleOffline_SkipsAlreadyOffline(t *testing.T) {
+	db := setupClientTestDB(t)
+	repo := NewClientRepository(db)
+
+	seedClient(t, repo, "off", model.ClientStatusOffline, time.Now().Add(-time.Hour))
+
+	affected, err := repo.MarkStaleOffline(90 * time.Second)
+	if err != nil {
+		t.Fatalf("MarkStaleOffline failed: %v", err)
+	}
+	if affected != 0 {
+		t.Errorf("expected 0 affected, got %d", affected)
+	}
+}
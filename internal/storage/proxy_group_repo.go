@@ -38,6 +38,9 @@ type ProxyGroupListParams struct {
 	Page   int
 	Limit  int
 	Search string
+	// 键集分页游标 (AfterID 非空时生效, 此时忽略 Page)
+	AfterCreatedAt time.Time
+	AfterID        string
 }
 
 func (r *ProxyGroupRepository) List(params ProxyGroupListParams) ([]model.ProxyGroup, int64, error) {
@@ -55,8 +58,15 @@ func (r *ProxyGroupRepository) List(params ProxyGroupListParams) ([]model.ProxyG
 		return nil, 0, err
 	}
 
-	offset := (params.Page - 1) * params.Limit
-	if err := query.Offset(offset).Limit(params.Limit).Order("created_at DESC").Find(&groups).Error; err != nil {
+	// 键集分页: 在 Count 之后过滤, total 始终为全量
+	if params.AfterID != "" {
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)",
+			params.AfterCreatedAt, params.AfterCreatedAt, params.AfterID)
+	} else {
+		query = query.Offset((params.Page - 1) * params.Limit)
+	}
+
+	if err := query.Limit(params.Limit).Order("created_at DESC, id DESC").Find(&groups).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -163,7 +173,7 @@ func (r *ProxyGroupRepository) RemoveNodeByClientID(groupID, clientID string) er
 
 // 健康检查相关
 
-func (r *ProxyGroupRepository) UpdateNodeHealth(nodeID string, healthy bool) error {
+func (r *ProxyGroupRepository) UpdateNodeHealth(nodeID string, healthy bool, checkError string) error {
 	now := time.Now()
 	updates := map[string]interface{}{
 		"last_check_at": now,
@@ -174,19 +184,24 @@ func (r *ProxyGroupRepository) UpdateNodeHealth(nodeID string, healthy bool) err
 	if healthy {
 		updates["status"] = model.NodeStatusHealthy
 		updates["fail_count"] = 0
+		updates["last_check_error"] = ""
 	} else {
 		updates["fail_count"] = gorm.Expr("fail_count + 1")
+		updates["last_check_error"] = checkError
 	}
 
 	return r.db.Model(&model.ProxyGroupNode{}).Where("id = ?", nodeID).Updates(updates).Error
 }
 
-func (r *ProxyGroupRepository) MarkNodeUnhealthy(nodeID string) error {
-	return r.db.Model(&model.ProxyGroupNode{}).Where("id = ?", nodeID).
-		Updates(map[string]interface{}{
-			"status":     model.NodeStatusUnhealthy,
-			"updated_at": time.Now(),
-		}).Error
+func (r *ProxyGroupRepository) MarkNodeUnhealthy(nodeID string, reason string) error {
+	updates := map[string]interface{}{
+		"status":     model.NodeStatusUnhealthy,
+		"updated_at": time.Now(),
+	}
+	if reason != "" {
+		updates["last_check_error"] = reason
+	}
+	return r.db.Model(&model.ProxyGroupNode{}).Where("id = ?", nodeID).Updates(updates).Error
 }
 
 // 连接统计相关
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RuleUpdateNotifier 规则变更通知接口 (由 relay.WSServer 实现)
+type RuleUpdateNotifier interface {
+	NotifyRuleUpdate(clientID string) bool
+}
+
+// RuleScheduler 定期按 EnabledFrom/EnabledUntil 时间窗切换规则的启用状态,
+// 并通知受影响的客户端重新拉取规则
+// 注意: 窗口内被手动禁用的规则会在下个周期被重新启用
+type RuleScheduler struct {
+	rules    *ForwardRepository
+	notifier RuleUpdateNotifier
+	interval time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewRuleScheduler(rules *ForwardRepository, notifier RuleUpdateNotifier, interval time.Duration) *RuleScheduler {
+	return &RuleScheduler{
+		rules:    rules,
+		notifier: notifier,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (s *RuleScheduler) Start() {
+	s.wg.Add(1)
+	go s.run()
+	log.Info().
+		Dur("interval", s.interval).
+		Msg("Rule scheduler started")
+}
+
+func (s *RuleScheduler) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	log.Info().Msg("Rule scheduler stopped")
+}
+
+func (s *RuleScheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.tick(time.Now())
+		}
+	}
+}
+
+// tick 执行一轮启停切换, 通知所有状态发生变化的规则所属客户端
+func (s *RuleScheduler) tick(now time.Time) {
+	affectedClients := make(map[string]bool)
+
+	toActivate, err := s.rules.ListScheduledActivations(now)
+	if err != nil {
+		log.Error().Err(err).Msg("Rule scheduler: failed to list scheduled activations")
+	}
+	for _, r := range toActivate {
+		if err := s.rules.ToggleEnabled(r.ID, true); err != nil {
+			log.Error().Err(err).Str("rule_id", r.ID).Msg("Rule scheduler: failed to enable rule")
+			continue
+		}
+		log.Info().Str("rule_id", r.ID).Time("enabled_from", *r.EnabledFrom).Msg("Rule activated by schedule")
+		affectedClients[r.ListenClient] = true
+	}
+
+	toDeactivate, err := s.rules.ListScheduledDeactivations(now)
+	if err != nil {
+		log.Error().Err(err).Msg("Rule scheduler: failed to list scheduled deactivations")
+	}
+	for _, r := range toDeactivate {
+		if err := s.rules.ToggleEnabled(r.ID, false); err != nil {
+			log.Error().Err(err).Str("rule_id", r.ID).Msg("Rule scheduler: failed to disable rule")
+			continue
+		}
+		log.Info().Str("rule_id", r.ID).Time("enabled_until", *r.EnabledUntil).Msg("Rule deactivated by schedule")
+		affectedClients[r.ListenClient] = true
+	}
+
+	if s.notifier != nil {
+		for clientID := range affectedClients {
+			s.notifier.NotifyRuleUpdate(clientID)
+		}
+	}
+}
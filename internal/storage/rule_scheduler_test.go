@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+type mockRuleNotifier struct {
+	notified []string
+}
+
+func (m *mockRuleNotifier) NotifyRuleUpdate(clientID string) bool {
+	m.notified = append(m.notified, clientID)
+	return true
+}
+
+func seedScheduledRule(t *testing.T, repo *ForwardRepository, id string, enabled bool, from, until *time.Time) {
+	t.Helper()
+	rule := &model.ForwardRule{
+		ID:           id,
+		Name:         id,
+		Type:         model.ForwardTypeDirect,
+		Enabled:      enabled,
+		ListenAddr:   "0.0.0.0:8080",
+		ListenClient: "client-1",
+		TargetAddr:   "127.0.0.1:80",
+		EnabledFrom:  from,
+		EnabledUntil: until,
+	}
+	if err := repo.Create(rule); err != nil {
+		t.Fatalf("failed to create rule: %v", err)
+	}
+}
+
+func TestRuleScheduler_ActivatesAtScheduledTime(t *testing.T) {
+	db := setupForwardTestDB(t)
+	repo := NewForwardRepository(db)
+	notifier := &mockRuleNotifier{}
+
+	from := time.Now().Add(time.Hour)
+	seedScheduledRule(t, repo, "scheduled", false, &from, nil)
+
+	scheduler := NewRuleScheduler(repo, notifier, time.Minute)
+
+	// 窗口开始前不动
+	scheduler.tick(from.Add(-time.Minute))
+	rule, err := repo.GetByID("scheduled")
+	if err != nil {
+		t.Fatalf("failed to get rule: %v", err)
+	}
+	if rule.Enabled {
+		t.Error("rule should stay disabled before enabled_from")
+	}
+	if len(notifier.notified) != 0 {
+		t.Errorf("expected no notifications, got %v", notifier.notified)
+	}
+
+	// 到达窗口起点后启用并通知
+	scheduler.tick(from.Add(time.Second))
+	rule, err = repo.GetByID("scheduled")
+	if err != nil {
+		t.Fatalf("failed to get rule: %v", err)
+	}
+	if !rule.Enabled {
+		t.Error("rule should be enabled after enabled_from")
+	}
+	if len(notifier.notified) != 1 || notifier.notified[0] != "client-1" {
+		t.Errorf("expected client-1 notified once, got %v", notifier.notified)
+	}
+}
+
+func TestRuleScheduler_DeactivatesAtScheduledTime(t *testing.T) {
+	db := setupForwardTestDB(t)
+	repo := NewForwardRepository(db)
+	notifier := &mockRuleNotifier{}
+
+	until := time.Now().Add(time.Hour)
+	seedScheduledRule(t, repo, "expiring", true, nil, &until)
+
+	scheduler := NewRuleScheduler(repo, notifier, time.Minute)
+
+	// 窗口结束前不动
+	scheduler.tick(until.Add(-time.Minute))
+	rule, err := repo.GetByID("expiring")
+	if err != nil {
+		t.Fatalf("failed to get rule: %v", err)
+	}
+	if !rule.Enabled {
+		t.Error("rule should stay enabled before enabled_until")
+	}
+
+	// 超过窗口终点后禁用并通知
+	scheduler.tick(until.Add(time.Second))
+	rule, err = repo.GetByID("expiring")
+	if err != nil {
+		t.Fatalf("failed to get rule: %v", err)
+	}
+	if rule.Enabled {
+		t.Error("rule should be disabled after enabled_until")
+	}
+	if len(notifier.notified) != 1 || notifier.notified[0] != "client-1" {
+		t.Errorf("expected client-1 notified once, got %v", notifier.notified)
+	}
+}
+
+func TestRuleScheduler_SkipsAlreadyExpiredWindow(t *testing.T) {
+	db := setupForwardTestDB(t)
+	repo := NewForwardRepository(db)
+	notifier := &mockRuleNotifier{}
+
+	// 整个窗口都已经过去: 不应被重新启用
+	from := time.Now().Add(-2 * time.Hour)
+	until := time.Now().Add(-time.Hour)
+	seedScheduledRule(t, repo, "expired", false, &from, &until)
+
+	scheduler := NewRuleScheduler(repo, notifier, time.Minute)
+	scheduler.tick(time.Now())
+
+	rule, err := repo.GetByID("expired")
+	if err != nil {
+		t.Fatalf("failed to get rule: %v", err)
+	}
+	if rule.Enabled {
+		t.Error("rule with fully expired window should stay disabled")
+	}
+	if len(notifier.notified) != 0 {
+		t.Errorf("expected no notifications, got %v", notifier.notified)
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SecretRotator 定期轮换使用时长超过上限的客户端密钥
+// 旧密钥由模型侧的宽限期校验兜底, 客户端重新注册后拿到新密钥
+type SecretRotator struct {
+	clients  *ClientRepository
+	interval time.Duration // 扫描间隔
+	maxAge   time.Duration // 密钥最长使用期
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewSecretRotator(clients *ClientRepository, interval, maxAge time.Duration) *SecretRotator {
+	return &SecretRotator{
+		clients:  clients,
+		interval: interval,
+		maxAge:   maxAge,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (s *SecretRotator) Start() {
+	s.wg.Add(1)
+	go s.run()
+	log.Info().
+		Dur("interval", s.interval).
+		Dur("max_age", s.maxAge).
+		Msg("Secret rotator started")
+}
+
+func (s *SecretRotator) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	log.Info().Msg("Secret rotator stopped")
+}
+
+func (s *SecretRotator) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.rotate()
+		}
+	}
+}
+
+func (s *SecretRotator) rotate() {
+	clients, err := s.clients.ListWithStaleSecrets(s.maxAge)
+	if err != nil {
+		log.Error().Err(err).Msg("Secret rotation scan failed")
+		return
+	}
+
+	rotated := 0
+	for _, client := range clients {
+		if err := s.clients.RotateSecret(client.ID, newSecretKey()); err != nil {
+			log.Error().Err(err).Str("client_id", client.ID).Msg("Failed to rotate client secret")
+			continue
+		}
+		rotated++
+	}
+	if rotated > 0 {
+		log.Info().Int("count", rotated).Msg("Rotated stale client secrets")
+	}
+}
+
+// newSecretKey 生成 64 位十六进制密钥 (与创建客户端时的格式一致)
+func newSecretKey() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func seedClientWithSecret(t *testing.T, repo *ClientRepository, id, secret string, rotatedAt *time.Time) {
+	t.Helper()
+	client := &model.Client{
+		ID:              id,
+		Name:            id,
+		Token:           "token-" + id,
+		SecretKey:       secret,
+		SecretRotatedAt: rotatedAt,
+	}
+	if err := repo.Create(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+}
+
+func TestSecretRotator_RotatesStaleSecrets(t *testing.T) {
+	db := setupClientTestDB(t)
+	repo := NewClientRepository(db)
+
+	// 上次轮换已超过使用期上限
+	old := time.Now().Add(-2 * time.Hour)
+	seedClientWithSecret(t, repo, "stale", "stale-secret", &old)
+	// 刚轮换过
+	fresh := time.Now()
+	seedClientWithSecret(t, repo, "fresh", "fresh-secret", &fresh)
+
+	rotator := NewSecretRotator(repo, time.Second, time.Hour)
+	rotator.rotate()
+
+	stale, err := repo.GetByID("stale")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+	if stale.SecretKey == "stale-secret" {
+		t.Error("expected stale secret to be rotated")
+	}
+	if stale.PrevSecretKey != "stale-secret" {
+		t.Errorf("expected previous secret to be retained, got %q", stale.PrevSecretKey)
+	}
+	if stale.SecretRotatedAt == nil || !stale.SecretRotatedAt.After(old) {
+		t.Error("expected rotation time to be updated")
+	}
+
+	freshClient, err := repo.GetByID("fresh")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+	if freshClient.SecretKey != "fresh-secret" {
+		t.Error("fresh secret should not be rotated")
+	}
+}
+
+func TestSecretRotator_NeverRotatedUsesCreatedAt(t *testing.T) {
+	db := setupClientTestDB(t)
+	repo := NewClientRepository(db)
+
+	// 从未轮换过, 创建时间很久远
+	client := &model.Client{
+		ID:        "ancient",
+		Name:      "ancient",
+		Token:     "token-ancient",
+		SecretKey: "ancient-secret",
+		CreatedAt: time.Now().Add(-48 * time.Hour),
+	}
+	if err := repo.Create(client); err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	rotator := NewSecretRotator(repo, time.Second, 24*time.Hour)
+	rotator.rotate()
+
+	rotated, err := repo.GetByID("ancient")
+	if err != nil {
+		t.Fatalf("failed to get client: %v", err)
+	}
+	if rotated.SecretKey == "ancient-secret" {
+		t.Error("expected never-rotated stale secret to be rotated based on creation time")
+	}
+}
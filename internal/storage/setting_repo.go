@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+type SettingRepository struct {
+	db *gorm.DB
+}
+
+func NewSettingRepository(db *gorm.DB) *SettingRepository {
+	return &SettingRepository{db: db}
+}
+
+// GetAll 返回全部已保存的设置 (key -> value)
+func (r *SettingRepository) GetAll() (map[string]string, error) {
+	var settings []model.Setting
+	if err := r.db.Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(settings))
+	for _, s := range settings {
+		result[s.Key] = s.Value
+	}
+	return result, nil
+}
+
+// Set 保存一条设置 (已存在时覆盖)
+func (r *SettingRepository) Set(key, value string) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&model.Setting{Key: key, Value: value}).Error
+}
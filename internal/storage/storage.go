@@ -19,6 +19,8 @@ type Storage struct {
 	Forward    *ForwardRepository
 	ProxyGroup *ProxyGroupRepository
 	Traffic    *TrafficRepository
+	Audit      *AuditRepository
+	Setting    *SettingRepository
 }
 
 func NewStorage(cfg *conf.DatabaseConfig) (*Storage, error) {
@@ -50,12 +52,21 @@ func NewStorage(cfg *conf.DatabaseConfig) (*Storage, error) {
 		&model.ProxyGroup{},
 		&model.ProxyGroupNode{},
 		&model.TrafficStats{},
+		&model.AuditLog{},
+		&model.Setting{},
 	); err != nil {
 		// panic(err)
 		log.Error().Err(err).Msg("failed to migrate database")
 		// return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// 流量唯一索引已扩展为含 label 的四列版本, 旧库上的三列索引会阻止同规则多标签落库
+	if db.Migrator().HasIndex(&model.TrafficStats{}, "idx_traffic_rule_client_period") {
+		if err := db.Migrator().DropIndex(&model.TrafficStats{}, "idx_traffic_rule_client_period"); err != nil {
+			log.Warn().Err(err).Msg("failed to drop legacy traffic stats index")
+		}
+	}
+
 	log.Info().Msg("Database connected and migrated successfully")
 
 	return &Storage{
@@ -64,6 +75,8 @@ func NewStorage(cfg *conf.DatabaseConfig) (*Storage, error) {
 		Forward:    NewForwardRepository(db),
 		ProxyGroup: NewProxyGroupRepository(db),
 		Traffic:    NewTrafficRepository(db),
+		Audit:      NewAuditRepository(db),
+		Setting:    NewSettingRepository(db),
 	}, nil
 }
 
@@ -74,3 +87,12 @@ func (s *Storage) Close() error {
 	}
 	return sqlDB.Close()
 }
+
+// Ping 检查数据库连通性 (用于就绪探针)
+func (s *Storage) Ping() error {
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TrafficFlusher 定期把内存中的流量统计刷入数据库的后台任务。
+// Stop 会同步执行最后一次刷新并等待完成, 保证进程退出前不丢最后一个周期的计数
+type TrafficFlusher struct {
+	traffic  *TrafficRepository
+	interval time.Duration
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewTrafficFlusher(traffic *TrafficRepository, interval time.Duration) *TrafficFlusher {
+	return &TrafficFlusher{
+		traffic:  traffic,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (f *TrafficFlusher) Start() {
+	f.wg.Add(1)
+	go f.run()
+	log.Info().Dur("interval", f.interval).Msg("Traffic flusher started")
+}
+
+// Stop 停止定时刷新; 返回前保证最后一次刷新已落库
+func (f *TrafficFlusher) Stop() {
+	close(f.stopCh)
+	f.wg.Wait()
+	log.Info().Msg("Traffic flusher stopped")
+}
+
+func (f *TrafficFlusher) run() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.traffic.FlushToDatabase(); err != nil {
+				log.Error().Err(err).Msg("Failed to flush traffic stats")
+			}
+		case <-f.stopCh:
+			// 最后一次刷新
+			if err := f.traffic.FlushToDatabase(); err != nil {
+				log.Error().Err(err).Msg("Failed to flush traffic stats on shutdown")
+			}
+			return
+		}
+	}
+}
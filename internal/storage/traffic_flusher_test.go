@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dollarkillerx/MessageBoy/pkg/model"
+)
+
+func TestTrafficFlusher_StopFlushesSynchronously(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	flusher := NewTrafficFlusher(repo, time.Hour) // 间隔远大于测试时长, 只有停机刷新会触发
+	flusher.Start()
+
+	// 停机前累计的计数只存在于内存
+	repo.AddBytesIn("rule-flush", "client-flush", 1234)
+	repo.AddBytesOut("rule-flush", "client-flush", 567)
+
+	var before int64
+	db.Model(&model.TrafficStats{}).Count(&before)
+	if before != 0 {
+		t.Fatalf("expected no rows before shutdown, got %d", before)
+	}
+
+	// Stop 返回时最后一次刷新必须已落库
+	flusher.Stop()
+
+	var stats []model.TrafficStats
+	if err := db.Where("rule_id = ?", "rule-flush").Find(&stats).Error; err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 flushed row, got %d", len(stats))
+	}
+	if stats[0].BytesIn != 1234 || stats[0].BytesOut != 567 {
+		t.Errorf("flushed bytes = %d/%d, want 1234/567", stats[0].BytesIn, stats[0].BytesOut)
+	}
+}
+
+func TestTrafficFlusher_PeriodicFlush(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	flusher := NewTrafficFlusher(repo, 20*time.Millisecond)
+	flusher.Start()
+	defer flusher.Stop()
+
+	repo.AddBytesIn("rule-periodic", "client-periodic", 100)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		var count int64
+		db.Model(&model.TrafficStats{}).Where("rule_id = ?", "rule-periodic").Count(&count)
+		if count > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("periodic flush did not persist stats in time")
+}
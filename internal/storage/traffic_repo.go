@@ -1,15 +1,24 @@
 package storage
 
 import (
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// flushBatchSize 单条批量 INSERT 的最大行数 (避免超出 SQLite 变量上限)
+const flushBatchSize = 100
+
+// defaultBandwidthSmoothing 带宽 EMA 平滑系数默认值 (越小曲线越平滑)
+const defaultBandwidthSmoothing = 0.3
+
 // TrafficRepository 流量统计存储
 type TrafficRepository struct {
 	db *gorm.DB
@@ -25,16 +34,45 @@ type TrafficRepository struct {
 	bandwidthIn    map[string]int64 // bytes per second
 	bandwidthOut   map[string]int64
 	bwMu           sync.RWMutex
+
+	// EMA 平滑后的带宽 (供仪表盘画稳定曲线, 原始瞬时值仍然保留)
+	bandwidthInEMA  map[string]float64
+	bandwidthOutEMA map[string]float64
+	bwSmoothing     float64 // EMA 平滑系数 alpha, (0, 1]
+
+	// 按规则聚合的带宽 (key: ruleID, 跨客户端求和), 供 top-N 查询
+	lastRuleBytesIn  map[string]int64
+	lastRuleBytesOut map[string]int64
+	ruleBandwidthIn  map[string]int64
+	ruleBandwidthOut map[string]int64
+
+	// flushIdleRules 为 true 时, 零流量但有活跃连接的规则也落库,
+	// 使其出现在 GetSummaryByRule 中。默认关闭以避免写放大。
+	flushIdleRules bool
+
+	// periodGranularity 统计行的时间分桶粒度 (空值等同按日)
+	periodGranularity TrafficPeriodGranularity
 }
 
+// maxTrafficLabelsPerRule 单条规则的统计标签基数上限,
+// 超过后新标签并入溢出桶, 防止失控标签撑爆内存和统计表
+const maxTrafficLabelsPerRule = 32
+
+// trafficLabelOverflow 标签超限后的溢出桶名
+const trafficLabelOverflow = "_other"
+
 // RealtimeTraffic 实时流量统计 (内存中)
 type RealtimeTraffic struct {
 	RuleID      string
 	ClientID    string
-	BytesIn     int64 // 待刷新到数据库的增量
+	Label       string // 入口附带的统计标签 (可为空)
+	BytesIn     int64  // 待刷新到数据库的增量
 	BytesOut    int64
 	ActiveConns int32 // 实时活跃连接数
 
+	// DroppedBytes 因发送缓冲溢出被丢弃的字节数 (累计, 不落库, 用于定位丢数据的规则)
+	DroppedBytes int64
+
 	// 用于带宽计算的累积值（不会被重置）
 	TotalBytesIn  int64
 	TotalBytesOut int64
@@ -42,18 +80,56 @@ type RealtimeTraffic struct {
 
 func NewTrafficRepository(db *gorm.DB) *TrafficRepository {
 	return &TrafficRepository{
-		db:            db,
-		realtimeStats: make(map[string]*RealtimeTraffic),
-		lastBytesIn:   make(map[string]int64),
-		lastBytesOut:  make(map[string]int64),
-		bandwidthIn:   make(map[string]int64),
-		bandwidthOut:  make(map[string]int64),
+		db:              db,
+		realtimeStats:   make(map[string]*RealtimeTraffic),
+		lastBytesIn:     make(map[string]int64),
+		lastBytesOut:    make(map[string]int64),
+		bandwidthIn:     make(map[string]int64),
+		bandwidthOut:    make(map[string]int64),
+		bandwidthInEMA:  make(map[string]float64),
+		bandwidthOutEMA: make(map[string]float64),
+		bwSmoothing:     defaultBandwidthSmoothing,
+
+		lastRuleBytesIn:  make(map[string]int64),
+		lastRuleBytesOut: make(map[string]int64),
+		ruleBandwidthIn:  make(map[string]int64),
+		ruleBandwidthOut: make(map[string]int64),
 	}
 }
 
+// SetFlushIdleRules 设置是否为空闲但有连接的规则落零值统计行
+func (r *TrafficRepository) SetFlushIdleRules(enabled bool) {
+	r.flushIdleRules = enabled
+}
+
+// SetBandwidthSmoothing 设置带宽 EMA 平滑系数 alpha (0 < alpha <= 1, 越小越平滑)。
+// 超出范围的值忽略, 保持当前系数
+func (r *TrafficRepository) SetBandwidthSmoothing(alpha float64) {
+	if alpha <= 0 || alpha > 1 {
+		return
+	}
+	r.bwMu.Lock()
+	r.bwSmoothing = alpha
+	r.bwMu.Unlock()
+}
+
 // getOrCreateStats 获取或创建实时统计
 func (r *TrafficRepository) getOrCreateStats(ruleID, clientID string) *RealtimeTraffic {
+	return r.getOrCreateLabeledStats(ruleID, clientID, "")
+}
+
+// statsKey 实时统计表的键 (无标签时保持旧格式)
+func statsKey(ruleID, clientID, label string) string {
 	key := ruleID + ":" + clientID
+	if label != "" {
+		key += ":" + label
+	}
+	return key
+}
+
+// getOrCreateLabeledStats 获取或创建按 (规则, 客户端, 标签) 细分的实时统计
+func (r *TrafficRepository) getOrCreateLabeledStats(ruleID, clientID, label string) *RealtimeTraffic {
+	key := statsKey(ruleID, clientID, label)
 	r.mu.RLock()
 	stats, ok := r.realtimeStats[key]
 	r.mu.RUnlock()
@@ -70,9 +146,27 @@ func (r *TrafficRepository) getOrCreateStats(ruleID, clientID string) *RealtimeT
 		return stats
 	}
 
+	// 标签基数上界: 同一规则的新标签超过上限时并入溢出桶
+	if label != "" && label != trafficLabelOverflow {
+		distinct := make(map[string]struct{})
+		for _, s := range r.realtimeStats {
+			if s.RuleID == ruleID && s.Label != "" {
+				distinct[s.Label] = struct{}{}
+			}
+		}
+		if _, seen := distinct[label]; !seen && len(distinct) >= maxTrafficLabelsPerRule {
+			label = trafficLabelOverflow
+			key = statsKey(ruleID, clientID, label)
+			if stats, ok = r.realtimeStats[key]; ok {
+				return stats
+			}
+		}
+	}
+
 	stats = &RealtimeTraffic{
 		RuleID:   ruleID,
 		ClientID: clientID,
+		Label:    label,
 	}
 	r.realtimeStats[key] = stats
 	return stats
@@ -92,6 +186,40 @@ func (r *TrafficRepository) AddBytesOut(ruleID, clientID string, bytes int64) {
 	atomic.AddInt64(&stats.TotalBytesOut, bytes) // 累积值用于带宽计算
 }
 
+// AddBytesInLabeled 按 (规则, 标签) 细分增加入站流量
+func (r *TrafficRepository) AddBytesInLabeled(ruleID, clientID, label string, bytes int64) {
+	stats := r.getOrCreateLabeledStats(ruleID, clientID, label)
+	atomic.AddInt64(&stats.BytesIn, bytes)
+	atomic.AddInt64(&stats.TotalBytesIn, bytes)
+}
+
+// AddBytesOutLabeled 按 (规则, 标签) 细分增加出站流量
+func (r *TrafficRepository) AddBytesOutLabeled(ruleID, clientID, label string, bytes int64) {
+	stats := r.getOrCreateLabeledStats(ruleID, clientID, label)
+	atomic.AddInt64(&stats.BytesOut, bytes)
+	atomic.AddInt64(&stats.TotalBytesOut, bytes)
+}
+
+// AddDroppedBytes 累计因发送缓冲溢出被丢弃的字节数 (按规则归因)
+func (r *TrafficRepository) AddDroppedBytes(ruleID, clientID string, bytes int64) {
+	stats := r.getOrCreateStats(ruleID, clientID)
+	atomic.AddInt64(&stats.DroppedBytes, bytes)
+}
+
+// GetRuleDroppedBytes 获取规则累计丢弃字节数 (跨客户端求和)
+func (r *TrafficRepository) GetRuleDroppedBytes(ruleID string) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int64
+	for _, stats := range r.realtimeStats {
+		if stats.RuleID == ruleID {
+			total += atomic.LoadInt64(&stats.DroppedBytes)
+		}
+	}
+	return total
+}
+
 // IncrementConn 增加活跃连接数
 func (r *TrafficRepository) IncrementConn(ruleID, clientID string) {
 	stats := r.getOrCreateStats(ruleID, clientID)
@@ -110,8 +238,57 @@ func (r *TrafficRepository) SetActiveConns(ruleID, clientID string, count int32)
 	atomic.StoreInt32(&stats.ActiveConns, count)
 }
 
-// FlushToDatabase 将内存统计刷新到数据库 (只刷新流量，连接数保留在内存)
+// flushRetryAttempts 刷库失败时的总尝试次数 (锁冲突/超时类瞬时错误)
+const flushRetryAttempts = 3
+
+// flushRetryBackoff 重试之间的起始等待时间, 每次翻倍
+const flushRetryBackoff = 100 * time.Millisecond
+
+// TrafficPeriodGranularity 流量明细行的时间分桶粒度
+type TrafficPeriodGranularity string
+
+const (
+	// PeriodDaily 默认粒度: 每规则每天一行, period_start 截断到当日零点
+	PeriodDaily TrafficPeriodGranularity = "daily"
+	// PeriodHourly 小时粒度: 每规则每小时一行, 保留日内分辨率 (行数约为按日的 24 倍)
+	PeriodHourly TrafficPeriodGranularity = "hourly"
+)
+
+// SetPeriodGranularity 设置统计行的时间分桶粒度。
+// 空值与未知值回退按日; 汇总查询按周期累加, 不受粒度影响
+func (r *TrafficRepository) SetPeriodGranularity(g TrafficPeriodGranularity) {
+	switch g {
+	case PeriodDaily, PeriodHourly:
+		r.periodGranularity = g
+	case "":
+		r.periodGranularity = PeriodDaily
+	default:
+		log.Warn().
+			Str("granularity", string(g)).
+			Msg("Unsupported traffic period granularity, falling back to daily")
+		r.periodGranularity = PeriodDaily
+	}
+}
+
+// currentPeriod 按分桶粒度计算 now 所在统计周期的起止
+func (r *TrafficRepository) currentPeriod(now time.Time) (time.Time, time.Time) {
+	if r.periodGranularity == PeriodHourly {
+		start := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+		return start, start.Add(time.Hour)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return start, start.Add(24 * time.Hour)
+}
+
+// FlushToDatabase 将内存统计刷新到数据库 (只刷新流量，连接数保留在内存)。
+// 写入失败时带退避重试, 彻底失败后把已换出的增量加回内存计数,
+// 留待下一轮刷新, 避免瞬时数据库错误造成流量静默丢失
 func (r *TrafficRepository) FlushToDatabase() error {
+	return r.flushToDatabaseAt(time.Now())
+}
+
+// flushToDatabaseAt 以指定时刻确定统计周期执行刷新 (拆出便于测试分桶逻辑)
+func (r *TrafficRepository) flushToDatabaseAt(now time.Time) error {
 	r.mu.Lock()
 	statsToFlush := make([]*RealtimeTraffic, 0, len(r.realtimeStats))
 	for _, stats := range r.realtimeStats {
@@ -119,11 +296,13 @@ func (r *TrafficRepository) FlushToDatabase() error {
 		statsCopy := &RealtimeTraffic{
 			RuleID:      stats.RuleID,
 			ClientID:    stats.ClientID,
+			Label:       stats.Label,
 			BytesIn:     atomic.SwapInt64(&stats.BytesIn, 0),
 			BytesOut:    atomic.SwapInt64(&stats.BytesOut, 0),
 			ActiveConns: atomic.LoadInt32(&stats.ActiveConns),
 		}
-		if statsCopy.BytesIn > 0 || statsCopy.BytesOut > 0 {
+		if statsCopy.BytesIn > 0 || statsCopy.BytesOut > 0 ||
+			(r.flushIdleRules && statsCopy.ActiveConns > 0) {
 			statsToFlush = append(statsToFlush, statsCopy)
 		}
 	}
@@ -133,56 +312,69 @@ func (r *TrafficRepository) FlushToDatabase() error {
 		return nil
 	}
 
-	now := time.Now()
+	periodStart, periodEnd := r.currentPeriod(now)
+
+	rows := make([]model.TrafficStats, 0, len(statsToFlush))
 	for _, stats := range statsToFlush {
 		// 只刷新流量数据，连接数只保存在内存中
-		if stats.BytesIn == 0 && stats.BytesOut == 0 {
+		// (开启 flushIdleRules 时, 有活跃连接的零流量规则也落库)
+		if stats.BytesIn == 0 && stats.BytesOut == 0 &&
+			!(r.flushIdleRules && stats.ActiveConns > 0) {
 			continue
 		}
 
-		// 查找或创建今天的统计记录
-		var existing model.TrafficStats
-		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-		err := r.db.Where("rule_id = ? AND client_id = ? AND period_start = ?",
-			stats.RuleID, stats.ClientID, today).First(&existing).Error
-
-		if err == gorm.ErrRecordNotFound {
-			// 创建新记录 (不包含 TotalConnections)
-			newStats := model.TrafficStats{
-				ID:          uuid.New().String(),
-				RuleID:      stats.RuleID,
-				ClientID:    stats.ClientID,
-				BytesIn:     stats.BytesIn,
-				BytesOut:    stats.BytesOut,
-				TotalBytes:  stats.BytesIn + stats.BytesOut,
-				ActiveConns: int(stats.ActiveConns),
-				PeriodStart: today,
-				PeriodEnd:   today.Add(24 * time.Hour),
-				CreatedAt:   now,
-				UpdatedAt:   now,
-			}
-			if err := r.db.Create(&newStats).Error; err != nil {
-				return err
-			}
-		} else if err == nil {
-			// 更新现有记录 (不更新 TotalConnections)
-			updates := map[string]interface{}{
-				"bytes_in":     gorm.Expr("bytes_in + ?", stats.BytesIn),
-				"bytes_out":    gorm.Expr("bytes_out + ?", stats.BytesOut),
-				"total_bytes":  gorm.Expr("total_bytes + ?", stats.BytesIn+stats.BytesOut),
-				"active_conns": stats.ActiveConns,
-				"updated_at":   now,
-			}
-			if err := r.db.Model(&existing).Updates(updates).Error; err != nil {
-				return err
-			}
-		} else {
-			return err
+		rows = append(rows, model.TrafficStats{
+			ID:          uuid.New().String(),
+			RuleID:      stats.RuleID,
+			ClientID:    stats.ClientID,
+			Label:       stats.Label,
+			BytesIn:     stats.BytesIn,
+			BytesOut:    stats.BytesOut,
+			TotalBytes:  stats.BytesIn + stats.BytesOut,
+			ActiveConns: int(stats.ActiveConns),
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var err error
+	for attempt := 0; attempt < flushRetryAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(flushRetryBackoff << (attempt - 1))
+		}
+		if err = r.writeStatsRows(rows, now); err == nil {
+			return nil
 		}
 	}
 
-	return nil
+	// 重试耗尽: 把已换出的增量加回内存计数, 下一轮刷新继续携带
+	for _, stats := range statsToFlush {
+		restored := r.getOrCreateLabeledStats(stats.RuleID, stats.ClientID, stats.Label)
+		atomic.AddInt64(&restored.BytesIn, stats.BytesIn)
+		atomic.AddInt64(&restored.BytesOut, stats.BytesOut)
+	}
+	return err
+}
+
+// writeStatsRows 批量 upsert: 按 (rule_id, client_id, period_start) 唯一索引冲突时累加流量,
+// PostgreSQL 与 SQLite 都支持 INSERT ... ON CONFLICT, 避免逐行读写
+func (r *TrafficRepository) writeStatsRows(rows []model.TrafficStats, now time.Time) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "rule_id"}, {Name: "client_id"}, {Name: "label"}, {Name: "period_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"bytes_in":     gorm.Expr("traffic_stats.bytes_in + excluded.bytes_in"),
+			"bytes_out":    gorm.Expr("traffic_stats.bytes_out + excluded.bytes_out"),
+			"total_bytes":  gorm.Expr("traffic_stats.total_bytes + excluded.total_bytes"),
+			"active_conns": gorm.Expr("excluded.active_conns"),
+			"updated_at":   now,
+		}),
+	}).CreateInBatches(&rows, flushBatchSize).Error
 }
 
 // GetSummaryByRule 获取按规则汇总的流量统计
@@ -192,6 +384,7 @@ func (r *TrafficRepository) GetSummaryByRule() ([]model.TrafficSummary, error) {
 		RuleName   string
 		ClientID   string
 		ClientName string
+		Label      string
 		BytesIn    int64
 		BytesOut   int64
 		TotalBytes int64
@@ -203,13 +396,14 @@ func (r *TrafficRepository) GetSummaryByRule() ([]model.TrafficSummary, error) {
 			COALESCE(forward_rules.name, '') as rule_name,
 			traffic_stats.client_id,
 			COALESCE(mb_clients.name, '') as client_name,
+			traffic_stats.label,
 			SUM(traffic_stats.bytes_in) as bytes_in,
 			SUM(traffic_stats.bytes_out) as bytes_out,
 			SUM(traffic_stats.total_bytes) as total_bytes
 		`).
 		Joins("LEFT JOIN forward_rules ON traffic_stats.rule_id = forward_rules.id").
 		Joins("LEFT JOIN mb_clients ON traffic_stats.client_id = mb_clients.id").
-		Group("traffic_stats.rule_id, traffic_stats.client_id, forward_rules.name, mb_clients.name").
+		Group("traffic_stats.rule_id, traffic_stats.client_id, traffic_stats.label, forward_rules.name, mb_clients.name").
 		Scan(&results).Error
 
 	if err != nil {
@@ -227,6 +421,7 @@ func (r *TrafficRepository) GetSummaryByRule() ([]model.TrafficSummary, error) {
 			RuleName:      res.RuleName,
 			ClientID:      res.ClientID,
 			ClientName:    res.ClientName,
+			Label:         res.Label,
 			BytesIn:       res.BytesIn,
 			BytesOut:      res.BytesOut,
 			TotalBytes:    res.TotalBytes,
@@ -236,7 +431,7 @@ func (r *TrafficRepository) GetSummaryByRule() ([]model.TrafficSummary, error) {
 		}
 
 		// 从内存获取实时活跃连接数
-		key := res.RuleID + ":" + res.ClientID
+		key := statsKey(res.RuleID, res.ClientID, res.Label)
 		if stats, ok := r.realtimeStats[key]; ok {
 			summary.ActiveConns = int(atomic.LoadInt32(&stats.ActiveConns))
 		}
@@ -256,6 +451,13 @@ func (r *TrafficRepository) GetTodayStats() ([]model.TrafficStats, error) {
 	return stats, err
 }
 
+// PurgeTrafficStatsBefore 删除统计周期在指定时刻之前结束的按日流量明细行, 返回删除行数。
+// 只清理数据库里的原始明细, 不影响内存中的实时统计
+func (r *TrafficRepository) PurgeTrafficStatsBefore(t time.Time) (int64, error) {
+	result := r.db.Where("period_end <= ?", t).Delete(&model.TrafficStats{})
+	return result.RowsAffected, result.Error
+}
+
 // GetTotalStats 获取总流量统计和实时活跃连接数
 func (r *TrafficRepository) GetTotalStats() (bytesIn, bytesOut int64, activeConns int, err error) {
 	var result struct {
@@ -289,55 +491,165 @@ func (r *TrafficRepository) GetRealtimeActiveConns() int {
 	return int(total)
 }
 
+// GetRuleActiveConns 获取单条规则的实时活跃连接数 (跨客户端求和)
+func (r *TrafficRepository) GetRuleActiveConns(ruleID string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int32
+	for _, stats := range r.realtimeStats {
+		if stats.RuleID == ruleID {
+			total += atomic.LoadInt32(&stats.ActiveConns)
+		}
+	}
+	return int(total)
+}
+
+// GetClientActiveConnsByRule 获取某客户端各规则的实时活跃连接数 (rule_id -> 连接数)
+func (r *TrafficRepository) GetClientActiveConnsByRule(clientID string) map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string]int)
+	for _, stats := range r.realtimeStats {
+		if stats.ClientID == clientID {
+			result[stats.RuleID] += int(atomic.LoadInt32(&stats.ActiveConns))
+		}
+	}
+	return result
+}
+
 // ClientBandwidth 客户端带宽统计
 type ClientBandwidth struct {
 	ClientID     string
 	ClientName   string
 	BytesIn      int64
 	BytesOut     int64
-	BandwidthIn  int64 // bytes per second
-	BandwidthOut int64 // bytes per second
+	BandwidthIn  int64 // bytes per second (瞬时值)
+	BandwidthOut int64 // bytes per second (瞬时值)
+
+	// EMA 平滑后的带宽 (bytes per second), 供仪表盘画稳定曲线
+	BandwidthInEMA  int64
+	BandwidthOutEMA int64
 }
 
 // UpdateBandwidth 更新带宽计算 (应该每秒调用一次)
 func (r *TrafficRepository) UpdateBandwidth() {
+	r.updateBandwidthAt(time.Now())
+}
+
+// updateBandwidthAt 以指定时刻计算带宽 (拆出便于测试速率逻辑)
+func (r *TrafficRepository) updateBandwidthAt(now time.Time) {
 	r.mu.RLock()
-	// 按客户端汇总当前流量（使用不会被重置的累积值）
+	// 按客户端/规则汇总当前流量（使用不会被重置的累积值）
 	currentIn := make(map[string]int64)
 	currentOut := make(map[string]int64)
+	ruleIn := make(map[string]int64)
+	ruleOut := make(map[string]int64)
 	for _, stats := range r.realtimeStats {
 		currentIn[stats.ClientID] += atomic.LoadInt64(&stats.TotalBytesIn)
 		currentOut[stats.ClientID] += atomic.LoadInt64(&stats.TotalBytesOut)
+		ruleIn[stats.RuleID] += atomic.LoadInt64(&stats.TotalBytesIn)
+		ruleOut[stats.RuleID] += atomic.LoadInt64(&stats.TotalBytesOut)
 	}
 	r.mu.RUnlock()
 
 	r.bwMu.Lock()
 	defer r.bwMu.Unlock()
 
-	now := time.Now()
 	elapsed := now.Sub(r.lastUpdateTime).Seconds()
 	if elapsed < 0.5 {
 		return // 避免频繁更新
 	}
 
-	// 计算带宽
+	// 计算带宽: 瞬时值 + EMA 平滑值 (首个样本直接作为 EMA 初值)
 	for clientID, bytesIn := range currentIn {
 		if lastIn, ok := r.lastBytesIn[clientID]; ok && elapsed > 0 {
-			r.bandwidthIn[clientID] = int64(float64(bytesIn-lastIn) / elapsed)
+			raw := float64(bytesIn-lastIn) / elapsed
+			r.bandwidthIn[clientID] = int64(raw)
+			if prev, ok := r.bandwidthInEMA[clientID]; ok {
+				r.bandwidthInEMA[clientID] = r.bwSmoothing*raw + (1-r.bwSmoothing)*prev
+			} else {
+				r.bandwidthInEMA[clientID] = raw
+			}
 		}
 		r.lastBytesIn[clientID] = bytesIn
 	}
 
 	for clientID, bytesOut := range currentOut {
 		if lastOut, ok := r.lastBytesOut[clientID]; ok && elapsed > 0 {
-			r.bandwidthOut[clientID] = int64(float64(bytesOut-lastOut) / elapsed)
+			raw := float64(bytesOut-lastOut) / elapsed
+			r.bandwidthOut[clientID] = int64(raw)
+			if prev, ok := r.bandwidthOutEMA[clientID]; ok {
+				r.bandwidthOutEMA[clientID] = r.bwSmoothing*raw + (1-r.bwSmoothing)*prev
+			} else {
+				r.bandwidthOutEMA[clientID] = raw
+			}
 		}
 		r.lastBytesOut[clientID] = bytesOut
 	}
 
+	// 按规则口径与客户端相同: 首个样本只记基线, 从第二个样本起才有速率
+	for ruleID, bytesIn := range ruleIn {
+		if last, ok := r.lastRuleBytesIn[ruleID]; ok && elapsed > 0 {
+			r.ruleBandwidthIn[ruleID] = int64(float64(bytesIn-last) / elapsed)
+		}
+		r.lastRuleBytesIn[ruleID] = bytesIn
+	}
+	for ruleID, bytesOut := range ruleOut {
+		if last, ok := r.lastRuleBytesOut[ruleID]; ok && elapsed > 0 {
+			r.ruleBandwidthOut[ruleID] = int64(float64(bytesOut-last) / elapsed)
+		}
+		r.lastRuleBytesOut[ruleID] = bytesOut
+	}
+
 	r.lastUpdateTime = now
 }
 
+// RuleBandwidth 单条规则的实时带宽 (bytes per second, 跨客户端求和)
+type RuleBandwidth struct {
+	RuleID       string
+	BandwidthIn  int64
+	BandwidthOut int64
+}
+
+// GetTopRulesByBandwidth 返回按当前带宽 (入+出) 降序排列的前 n 条规则。
+// n <= 0 时返回全部; 并列时按规则 ID 排序保证结果稳定
+func (r *TrafficRepository) GetTopRulesByBandwidth(n int) []RuleBandwidth {
+	r.bwMu.RLock()
+	ruleIDs := make(map[string]bool)
+	for ruleID := range r.lastRuleBytesIn {
+		ruleIDs[ruleID] = true
+	}
+	for ruleID := range r.lastRuleBytesOut {
+		ruleIDs[ruleID] = true
+	}
+
+	result := make([]RuleBandwidth, 0, len(ruleIDs))
+	for ruleID := range ruleIDs {
+		result = append(result, RuleBandwidth{
+			RuleID:       ruleID,
+			BandwidthIn:  r.ruleBandwidthIn[ruleID],
+			BandwidthOut: r.ruleBandwidthOut[ruleID],
+		})
+	}
+	r.bwMu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		ti := result[i].BandwidthIn + result[i].BandwidthOut
+		tj := result[j].BandwidthIn + result[j].BandwidthOut
+		if ti != tj {
+			return ti > tj
+		}
+		return result[i].RuleID < result[j].RuleID
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
 // GetClientBandwidth 获取所有客户端的带宽统计
 func (r *TrafficRepository) GetClientBandwidth() []ClientBandwidth {
 	r.bwMu.RLock()
@@ -355,11 +667,13 @@ func (r *TrafficRepository) GetClientBandwidth() []ClientBandwidth {
 	result := make([]ClientBandwidth, 0, len(clientIDs))
 	for clientID := range clientIDs {
 		bw := ClientBandwidth{
-			ClientID:     clientID,
-			BytesIn:      r.lastBytesIn[clientID],
-			BytesOut:     r.lastBytesOut[clientID],
-			BandwidthIn:  r.bandwidthIn[clientID],
-			BandwidthOut: r.bandwidthOut[clientID],
+			ClientID:        clientID,
+			BytesIn:         r.lastBytesIn[clientID],
+			BytesOut:        r.lastBytesOut[clientID],
+			BandwidthIn:     r.bandwidthIn[clientID],
+			BandwidthOut:    r.bandwidthOut[clientID],
+			BandwidthInEMA:  int64(r.bandwidthInEMA[clientID]),
+			BandwidthOutEMA: int64(r.bandwidthOutEMA[clientID]),
 		}
 		result = append(result, bw)
 	}
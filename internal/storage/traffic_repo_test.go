@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/dollarkillerx/MessageBoy/pkg/model"
 	"gorm.io/driver/sqlite"
@@ -288,3 +290,446 @@ func TestTrafficRepository_FlushEmpty(t *testing.T) {
 		t.Errorf("expected 0 records, got %d", count)
 	}
 }
+
+func TestTrafficRepository_FlushIdleRules(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+	repo.SetFlushIdleRules(true)
+
+	client := &model.Client{ID: "client1", Name: "Test Client"}
+	db.Create(client)
+	rule := &model.ForwardRule{ID: "rule1", Name: "Idle Rule", ListenClient: "client1"}
+	db.Create(rule)
+
+	// 只有活跃连接，没有流量
+	repo.IncrementConn("rule1", "client1")
+
+	if err := repo.FlushToDatabase(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	summaries, err := repo.GetSummaryByRule()
+	if err != nil {
+		t.Fatalf("failed to get summary: %v", err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("expected idle rule in summary, got %d entries", len(summaries))
+	}
+	if summaries[0].RuleID != "rule1" {
+		t.Errorf("expected rule1, got %s", summaries[0].RuleID)
+	}
+	if summaries[0].ActiveConns != 1 {
+		t.Errorf("expected 1 active conn, got %d", summaries[0].ActiveConns)
+	}
+	if summaries[0].TotalBytes != 0 {
+		t.Errorf("expected 0 bytes, got %d", summaries[0].TotalBytes)
+	}
+}
+
+func TestTrafficRepository_FlushIdleRules_DefaultSkips(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	// 默认关闭：空闲规则不落库
+	repo.IncrementConn("rule1", "client1")
+
+	if err := repo.FlushToDatabase(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	var count int64
+	db.Model(&model.TrafficStats{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected 0 records with option disabled, got %d", count)
+	}
+}
+
+func TestTrafficRepository_FlushManyStatsWithinBound(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	for i := 0; i < 2000; i++ {
+		repo.AddBytesIn(fmt.Sprintf("rule-%d", i), "client1", 100)
+		repo.AddBytesOut(fmt.Sprintf("rule-%d", i), "client1", 50)
+	}
+
+	start := time.Now()
+	if err := repo.FlushToDatabase(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("flush of 2000 stats took %v, expected under 5s", elapsed)
+	}
+
+	var count int64
+	db.Model(&model.TrafficStats{}).Count(&count)
+	if count != 2000 {
+		t.Errorf("expected 2000 records, got %d", count)
+	}
+}
+
+func BenchmarkTrafficRepository_FlushToDatabase(b *testing.B) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		b.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(&model.TrafficStats{}); err != nil {
+		b.Fatalf("failed to migrate: %v", err)
+	}
+	repo := NewTrafficRepository(db)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := 0; j < 1000; j++ {
+			repo.AddBytesIn(fmt.Sprintf("rule-%d", j), "client1", 100)
+		}
+		b.StartTimer()
+
+		if err := repo.FlushToDatabase(); err != nil {
+			b.Fatalf("flush failed: %v", err)
+		}
+	}
+}
+
+// stepBandwidth 回拨上次采样时间并触发一次带宽采样, 返回 c1 的统计
+func stepBandwidth(t *testing.T, repo *TrafficRepository) ClientBandwidth {
+	t.Helper()
+	repo.bwMu.Lock()
+	repo.lastUpdateTime = time.Now().Add(-time.Second)
+	repo.bwMu.Unlock()
+	repo.UpdateBandwidth()
+
+	for _, bw := range repo.GetClientBandwidth() {
+		if bw.ClientID == "c1" {
+			return bw
+		}
+	}
+	t.Fatal("client c1 not found in bandwidth stats")
+	return ClientBandwidth{}
+}
+
+func TestUpdateBandwidth_EMASmoothsStepChange(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+	repo.SetBandwidthSmoothing(0.5)
+
+	// 建立基线样本
+	repo.AddBytesIn("r1", "c1", 1000)
+	stepBandwidth(t, repo)
+
+	// 平稳期: ~1000 B/s, EMA 首个样本直接等于瞬时值
+	repo.AddBytesIn("r1", "c1", 1000)
+	bw := stepBandwidth(t, repo)
+	if bw.BandwidthIn < 800 || bw.BandwidthIn > 1200 {
+		t.Fatalf("steady raw bandwidth = %d, want ~1000", bw.BandwidthIn)
+	}
+	if bw.BandwidthInEMA < 800 || bw.BandwidthInEMA > 1200 {
+		t.Fatalf("steady EMA bandwidth = %d, want ~1000", bw.BandwidthInEMA)
+	}
+
+	// 流量阶跃到 ~11000 B/s: 瞬时值立即跳变, EMA 只走到中点 (alpha=0.5 -> ~6000)
+	repo.AddBytesIn("r1", "c1", 11000)
+	bw = stepBandwidth(t, repo)
+	if bw.BandwidthIn < 9000 {
+		t.Errorf("raw bandwidth after step = %d, want ~11000 (immediate jump)", bw.BandwidthIn)
+	}
+	if bw.BandwidthInEMA < 5000 || bw.BandwidthInEMA > 7000 {
+		t.Errorf("EMA after first step sample = %d, want ~6000 (gradual)", bw.BandwidthInEMA)
+	}
+	firstEMA := bw.BandwidthInEMA
+
+	// 第二个阶跃样本: EMA 继续向瞬时值收敛 (~8500) 但仍低于瞬时值
+	repo.AddBytesIn("r1", "c1", 11000)
+	bw = stepBandwidth(t, repo)
+	if bw.BandwidthInEMA <= firstEMA {
+		t.Errorf("EMA did not converge: %d -> %d", firstEMA, bw.BandwidthInEMA)
+	}
+	if bw.BandwidthInEMA >= bw.BandwidthIn {
+		t.Errorf("EMA %d should still trail raw %d", bw.BandwidthInEMA, bw.BandwidthIn)
+	}
+}
+
+func TestSetBandwidthSmoothing_RejectsOutOfRange(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	repo.SetBandwidthSmoothing(0)
+	repo.SetBandwidthSmoothing(1.5)
+	if repo.bwSmoothing != defaultBandwidthSmoothing {
+		t.Errorf("bwSmoothing = %v, want default %v", repo.bwSmoothing, defaultBandwidthSmoothing)
+	}
+
+	repo.SetBandwidthSmoothing(0.8)
+	if repo.bwSmoothing != 0.8 {
+		t.Errorf("bwSmoothing = %v, want 0.8", repo.bwSmoothing)
+	}
+}
+
+func TestTrafficRepository_PurgeTrafficStatsBefore(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	now := time.Now()
+	seed := func(id string, daysAgo int) {
+		day := now.AddDate(0, 0, -daysAgo).Truncate(24 * time.Hour)
+		err := db.Create(&model.TrafficStats{
+			ID:          id,
+			RuleID:      "rule1",
+			ClientID:    "client1",
+			BytesIn:     100,
+			BytesOut:    100,
+			TotalBytes:  200,
+			PeriodStart: day,
+			PeriodEnd:   day.Add(24 * time.Hour),
+		}).Error
+		if err != nil {
+			t.Fatalf("failed to seed stats %s: %v", id, err)
+		}
+	}
+	seed("old-1", 40)
+	seed("old-2", 31)
+	seed("recent-1", 5)
+	seed("recent-2", 0)
+
+	purged, err := repo.PurgeTrafficStatsBefore(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("expected 2 rows purged, got %d", purged)
+	}
+
+	var remaining []model.TrafficStats
+	if err := db.Find(&remaining).Error; err != nil {
+		t.Fatalf("failed to query remaining stats: %v", err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("expected 2 remaining rows, got %d", len(remaining))
+	}
+	for _, s := range remaining {
+		if s.ID != "recent-1" && s.ID != "recent-2" {
+			t.Errorf("unexpected surviving row %s", s.ID)
+		}
+	}
+}
+
+func TestTrafficRepository_DroppedBytes(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	repo.AddDroppedBytes("rule1", "client1", 100)
+	repo.AddDroppedBytes("rule1", "client2", 50)
+	repo.AddDroppedBytes("rule2", "client1", 7)
+
+	if got := repo.GetRuleDroppedBytes("rule1"); got != 150 {
+		t.Errorf("rule1 dropped bytes = %d, want 150", got)
+	}
+	if got := repo.GetRuleDroppedBytes("rule2"); got != 7 {
+		t.Errorf("rule2 dropped bytes = %d, want 7", got)
+	}
+	if got := repo.GetRuleDroppedBytes("unknown"); got != 0 {
+		t.Errorf("unknown rule dropped bytes = %d, want 0", got)
+	}
+}
+
+func TestTrafficRepository_FlushFailureRestoresDeltas(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	repo.AddBytesIn("rule1", "client1", 100)
+	repo.AddBytesOut("rule1", "client1", 50)
+
+	// 删表注入持续写失败 (模拟瞬时数据库故障)
+	if err := db.Migrator().DropTable(&model.TrafficStats{}); err != nil {
+		t.Fatalf("failed to drop table: %v", err)
+	}
+
+	if err := repo.FlushToDatabase(); err == nil {
+		t.Fatal("flush should fail when table is missing")
+	}
+
+	// 写失败后增量应回到内存计数, 不丢失
+	stats := repo.getOrCreateStats("rule1", "client1")
+	if stats.BytesIn != 100 {
+		t.Errorf("bytes_in after failed flush = %d, want 100", stats.BytesIn)
+	}
+	if stats.BytesOut != 50 {
+		t.Errorf("bytes_out after failed flush = %d, want 50", stats.BytesOut)
+	}
+
+	// 数据库恢复后, 下一轮刷新把保留的增量落库
+	if err := db.AutoMigrate(&model.TrafficStats{}); err != nil {
+		t.Fatalf("failed to recreate table: %v", err)
+	}
+	if err := repo.FlushToDatabase(); err != nil {
+		t.Fatalf("flush after recovery failed: %v", err)
+	}
+
+	var row model.TrafficStats
+	if err := db.Where("rule_id = ? AND client_id = ?", "rule1", "client1").First(&row).Error; err != nil {
+		t.Fatalf("failed to query stats: %v", err)
+	}
+	if row.BytesIn != 100 || row.BytesOut != 50 {
+		t.Errorf("recovered flush wrote bytes_in=%d bytes_out=%d, want 100/50", row.BytesIn, row.BytesOut)
+	}
+}
+
+func TestTrafficRepository_LabeledSummaryRows(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	// 同一规则下两个标签各自计量
+	repo.AddBytesInLabeled("rule1", "client1", "tenant-a", 100)
+	repo.AddBytesOutLabeled("rule1", "client1", "tenant-a", 30)
+	repo.AddBytesInLabeled("rule1", "client1", "tenant-b", 40)
+
+	if err := repo.FlushToDatabase(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	summaries, err := repo.GetSummaryByRule()
+	if err != nil {
+		t.Fatalf("failed to get summary: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 summary rows (one per label), got %d", len(summaries))
+	}
+
+	byLabel := make(map[string]model.TrafficSummary)
+	for _, s := range summaries {
+		byLabel[s.Label] = s
+	}
+	if s, ok := byLabel["tenant-a"]; !ok {
+		t.Error("missing summary row for label tenant-a")
+	} else if s.BytesIn != 100 || s.BytesOut != 30 {
+		t.Errorf("tenant-a bytes_in=%d bytes_out=%d, want 100/30", s.BytesIn, s.BytesOut)
+	}
+	if s, ok := byLabel["tenant-b"]; !ok {
+		t.Error("missing summary row for label tenant-b")
+	} else if s.BytesIn != 40 {
+		t.Errorf("tenant-b bytes_in=%d, want 40", s.BytesIn)
+	}
+}
+
+func TestTrafficRepository_LabelCardinalityBound(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	// 超过上限的新标签并入溢出桶, 不再产生新条目
+	total := maxTrafficLabelsPerRule + 8
+	for i := 0; i < total; i++ {
+		repo.AddBytesInLabeled("rule1", "client1", fmt.Sprintf("label-%d", i), 10)
+	}
+
+	repo.mu.RLock()
+	distinct := make(map[string]struct{})
+	for _, s := range repo.realtimeStats {
+		if s.RuleID == "rule1" && s.Label != "" {
+			distinct[s.Label] = struct{}{}
+		}
+	}
+	repo.mu.RUnlock()
+
+	// 溢出桶自身占一个条目
+	if len(distinct) > maxTrafficLabelsPerRule+1 {
+		t.Errorf("distinct labels = %d, want <= %d", len(distinct), maxTrafficLabelsPerRule+1)
+	}
+	if _, ok := distinct[trafficLabelOverflow]; !ok {
+		t.Error("overflow bucket not created")
+	}
+
+	// 超限部分的流量都计入溢出桶
+	overflow := repo.getOrCreateLabeledStats("rule1", "client1", trafficLabelOverflow)
+	if want := int64((total - maxTrafficLabelsPerRule) * 10); overflow.BytesIn != want {
+		t.Errorf("overflow bucket bytes_in = %d, want %d", overflow.BytesIn, want)
+	}
+
+	// 已存在的标签不受上限影响, 继续累加到自己的条目
+	repo.AddBytesInLabeled("rule1", "client1", "label-0", 5)
+	stats := repo.getOrCreateLabeledStats("rule1", "client1", "label-0")
+	if stats.BytesIn != 15 {
+		t.Errorf("label-0 bytes_in = %d, want 15", stats.BytesIn)
+	}
+}
+
+func TestTrafficRepository_HourlyGranularitySplitsRows(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+	repo.SetPeriodGranularity(PeriodHourly)
+
+	base := time.Date(2026, 8, 28, 10, 30, 0, 0, time.UTC)
+
+	// 两次刷新落在不同小时, 应各自形成一行
+	repo.AddBytesIn("rule1", "client1", 100)
+	if err := repo.flushToDatabaseAt(base); err != nil {
+		t.Fatalf("first flush failed: %v", err)
+	}
+	repo.AddBytesIn("rule1", "client1", 40)
+	if err := repo.flushToDatabaseAt(base.Add(time.Hour)); err != nil {
+		t.Fatalf("second flush failed: %v", err)
+	}
+
+	var rows []model.TrafficStats
+	if err := db.Where("rule_id = ?", "rule1").Order("period_start").Find(&rows).Error; err != nil {
+		t.Fatalf("failed to query stats: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows (one per hour), got %d", len(rows))
+	}
+
+	if got := rows[0].PeriodStart.UTC(); got != time.Date(2026, 8, 28, 10, 0, 0, 0, time.UTC) {
+		t.Errorf("first period_start = %v, want hour-truncated 10:00", got)
+	}
+	if got := rows[0].PeriodEnd.Sub(rows[0].PeriodStart); got != time.Hour {
+		t.Errorf("first period length = %v, want 1h", got)
+	}
+	if rows[0].BytesIn != 100 || rows[1].BytesIn != 40 {
+		t.Errorf("bytes_in per row = %d/%d, want 100/40", rows[0].BytesIn, rows[1].BytesIn)
+	}
+}
+
+func TestTrafficRepository_DailyGranularityMergesRows(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	base := time.Date(2026, 8, 28, 10, 30, 0, 0, time.UTC)
+
+	// 默认按日分桶: 同一天内不同小时的刷新累加进同一行
+	repo.AddBytesIn("rule1", "client1", 100)
+	if err := repo.flushToDatabaseAt(base); err != nil {
+		t.Fatalf("first flush failed: %v", err)
+	}
+	repo.AddBytesIn("rule1", "client1", 40)
+	if err := repo.flushToDatabaseAt(base.Add(time.Hour)); err != nil {
+		t.Fatalf("second flush failed: %v", err)
+	}
+
+	var rows []model.TrafficStats
+	if err := db.Where("rule_id = ?", "rule1").Find(&rows).Error; err != nil {
+		t.Fatalf("failed to query stats: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 daily row, got %d", len(rows))
+	}
+	if rows[0].BytesIn != 140 {
+		t.Errorf("bytes_in = %d, want 140", rows[0].BytesIn)
+	}
+	if got := rows[0].PeriodEnd.Sub(rows[0].PeriodStart); got != 24*time.Hour {
+		t.Errorf("period length = %v, want 24h", got)
+	}
+}
+
+func TestSetPeriodGranularity_UnknownFallsBackToDaily(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTrafficRepository(db)
+	repo.SetPeriodGranularity("weekly")
+
+	now := time.Date(2026, 8, 28, 10, 30, 0, 0, time.UTC)
+	start, end := repo.currentPeriod(now)
+	if start != time.Date(2026, 8, 28, 0, 0, 0, 0, time.UTC) || end.Sub(start) != 24*time.Hour {
+		t.Errorf("unknown granularity period = [%v, %v), want daily bucket", start, end)
+	}
+}
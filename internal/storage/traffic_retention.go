@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TrafficRetention 定期删除超过保留期的按日流量明细行的后台任务。
+// traffic_stats 按规则/客户端逐日累积, 不清理会无限增长; 保留窗口内的行不受影响
+type TrafficRetention struct {
+	traffic   *TrafficRepository
+	interval  time.Duration // 清理扫描间隔
+	retention time.Duration // 明细保留时长
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewTrafficRetention(traffic *TrafficRepository, interval, retention time.Duration) *TrafficRetention {
+	return &TrafficRetention{
+		traffic:   traffic,
+		interval:  interval,
+		retention: retention,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (s *TrafficRetention) Start() {
+	s.wg.Add(1)
+	go s.run()
+	log.Info().
+		Dur("interval", s.interval).
+		Dur("retention", s.retention).
+		Msg("Traffic retention started")
+}
+
+func (s *TrafficRetention) Stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+	log.Info().Msg("Traffic retention stopped")
+}
+
+func (s *TrafficRetention) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.purge()
+		}
+	}
+}
+
+func (s *TrafficRetention) purge() {
+	purged, err := s.traffic.PurgeTrafficStatsBefore(time.Now().Add(-s.retention))
+	if err != nil {
+		log.Error().Err(err).Msg("Traffic retention purge failed")
+		return
+	}
+	if purged > 0 {
+		log.Info().Int64("count", purged).Msg("Purged expired traffic stats")
+	}
+}
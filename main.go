@@ -2,16 +2,21 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net"
 	"os"
 	"sync"
+	"time"
 )
 
 type ForwardConfig struct {
 	Local  string `json:"local"`
 	Export string `json:"export"`
+
+	// IdleTimeoutSec 空闲超时 (秒, 0 不限制): 双向都无数据超过该时长时关闭连接
+	IdleTimeoutSec int `json:"idle_timeout_sec"`
 }
 
 func main() {
@@ -53,8 +58,8 @@ func main() {
 
 func createDefaultConfig(path string) {
 	defaultCfg := []ForwardConfig{
-		{Local: "0.0.0.0:8080", Export: "127.0.0.1:80"},
-		{Local: "0.0.0.0:8443", Export: "127.0.0.1:443"},
+		{Local: "0.0.0.0:8080", Export: "127.0.0.1:80", IdleTimeoutSec: 300},
+		{Local: "0.0.0.0:8443", Export: "127.0.0.1:443", IdleTimeoutSec: 300},
 	}
 	data, _ := json.MarshalIndent(defaultCfg, "", "  ")
 	if err := os.WriteFile(path, data, 0644); err != nil {
@@ -78,11 +83,39 @@ func startForward(cfg ForwardConfig) {
 			log.Printf("接受连接失败: %v", err)
 			continue
 		}
-		go handleConnection(conn, cfg.Export)
+		go handleConnection(conn, cfg.Export, time.Duration(cfg.IdleTimeoutSec)*time.Second)
 	}
 }
 
-func handleConnection(src net.Conn, target string) {
+// idleReader 每次读取前刷新读超时，有数据流动时超时不断后移
+type idleReader struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.conn.Read(p)
+}
+
+// copyWithIdleTimeout 带空闲超时的单向复制。任一方向空闲超时后关闭两端，
+// 让另一方向的阻塞读取也立即退出，避免静默连接永久占用 goroutine 和 socket
+func copyWithIdleTimeout(dst, src net.Conn, idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		io.Copy(dst, src)
+		return
+	}
+
+	_, err := io.Copy(dst, &idleReader{conn: src, timeout: idleTimeout})
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		log.Printf("连接空闲超过 %v，关闭: %s", idleTimeout, src.RemoteAddr())
+		src.Close()
+		dst.Close()
+	}
+}
+
+func handleConnection(src net.Conn, target string, idleTimeout time.Duration) {
 	defer src.Close()
 
 	dst, err := net.Dial("tcp", target)
@@ -100,15 +133,19 @@ func handleConnection(src net.Conn, target string) {
 	// src -> dst
 	go func() {
 		defer wg.Done()
-		io.Copy(dst, src)
-		dst.(*net.TCPConn).CloseWrite()
+		copyWithIdleTimeout(dst, src, idleTimeout)
+		if tc, ok := dst.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
 	}()
 
 	// dst -> src
 	go func() {
 		defer wg.Done()
-		io.Copy(src, dst)
-		src.(*net.TCPConn).CloseWrite()
+		copyWithIdleTimeout(src, dst, idleTimeout)
+		if tc, ok := src.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
 	}()
 
 	wg.Wait()
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandleConnection_IdleTeardown(t *testing.T) {
+	// 静默目标: 接受连接后不读不写
+	targetLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer targetLn.Close()
+	go func() {
+		for {
+			conn, err := targetLn.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// 入口监听: 取一个静默的 src 连接交给 handleConnection
+	srcLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer srcLn.Close()
+
+	clientConn, err := net.Dial("tcp", srcLn.Addr().String())
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer clientConn.Close()
+
+	srcConn, err := srcLn.Accept()
+	if err != nil {
+		t.Fatalf("接受连接失败: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handleConnection(srcConn, targetLn.Addr().String(), 200*time.Millisecond)
+		close(done)
+	}()
+
+	// 双向静默, 超过空闲窗口后应拆除整条连接
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("空闲连接未在超时窗口内被拆除")
+	}
+}
+
+func TestHandleConnection_NoTimeoutWhenDataFlows(t *testing.T) {
+	// 回显目标
+	targetLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer targetLn.Close()
+	go func() {
+		conn, err := targetLn.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 64)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			conn.Write(buf[:n])
+		}
+	}()
+
+	srcLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer srcLn.Close()
+
+	clientConn, err := net.Dial("tcp", srcLn.Addr().String())
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer clientConn.Close()
+
+	srcConn, err := srcLn.Accept()
+	if err != nil {
+		t.Fatalf("接受连接失败: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handleConnection(srcConn, targetLn.Addr().String(), 200*time.Millisecond)
+		close(done)
+	}()
+
+	// 持续低频写入 (间隔小于空闲窗口), 超时应不断后移
+	buf := make([]byte, 5)
+	for i := 0; i < 5; i++ {
+		if _, err := clientConn.Write([]byte("hello")); err != nil {
+			t.Fatalf("第 %d 次写入失败: %v", i, err)
+		}
+		clientConn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := clientConn.Read(buf); err != nil {
+			t.Fatalf("第 %d 次回显读取失败: %v", i, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("有数据流动的连接不应被空闲超时拆除")
+	default:
+	}
+	clientConn.Close()
+}
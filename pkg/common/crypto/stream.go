@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrReplayedNonce 表示收到的计数器不大于已见过的最大值 (重放或乱序)
+	ErrReplayedNonce = errors.New("replayed or out-of-order nonce")
+	// ErrNoncePrefixMismatch 表示 nonce 前缀与本流不一致
+	ErrNoncePrefixMismatch = errors.New("nonce prefix mismatch")
+)
+
+// streamNoncePrefixLen 每条流固定的随机前缀长度, 其余 8 字节为单调计数器
+const streamNoncePrefixLen = 4
+
+// StreamCrypto 在 AESCrypto 之上叠加重放防护:
+// nonce = 4 字节随机流前缀 + 8 字节单调计数器。
+// 解密端要求计数器严格递增, 重放的 ciphertext+nonce 会被拒绝。
+type StreamCrypto struct {
+	inner *AESCrypto
+
+	mu          sync.Mutex
+	sendPrefix  [streamNoncePrefixLen]byte
+	sendCounter uint64
+
+	recvPrefix    [streamNoncePrefixLen]byte
+	recvPrefixSet bool
+	recvCounter   uint64
+}
+
+func NewStreamCrypto(inner *AESCrypto) (*StreamCrypto, error) {
+	s := &StreamCrypto{inner: inner}
+	if _, err := io.ReadFull(rand.Reader, s.sendPrefix[:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// EncryptNext 用下一个计数器值加密一帧
+func (s *StreamCrypto) EncryptNext(plaintext []byte) (ciphertext, nonce []byte, err error) {
+	s.mu.Lock()
+	s.sendCounter++
+	counter := s.sendCounter
+	s.mu.Unlock()
+
+	nonce = make([]byte, s.inner.gcm.NonceSize())
+	copy(nonce, s.sendPrefix[:])
+	binary.BigEndian.PutUint64(nonce[streamNoncePrefixLen:], counter)
+
+	ciphertext = s.inner.gcm.Seal(nil, nonce, plaintext, nil)
+	return ciphertext, nonce, nil
+}
+
+// DecryptNext 解密一帧并校验新鲜度:
+// 计数器必须大于之前见过的最大值 (允许因丢包跳号, 不允许回退或重复)。
+func (s *StreamCrypto) DecryptNext(ciphertext, nonce []byte) ([]byte, error) {
+	if len(nonce) != s.inner.gcm.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	counter := binary.BigEndian.Uint64(nonce[streamNoncePrefixLen:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 首帧锁定对端前缀, 后续帧必须一致
+	if !s.recvPrefixSet {
+		copy(s.recvPrefix[:], nonce[:streamNoncePrefixLen])
+	} else if [streamNoncePrefixLen]byte(nonce[:streamNoncePrefixLen]) != s.recvPrefix {
+		return nil, ErrNoncePrefixMismatch
+	}
+
+	if counter <= s.recvCounter {
+		return nil, ErrReplayedNonce
+	}
+
+	plaintext, err := s.inner.gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		// 解密失败不推进计数器, 避免伪造帧导致合法帧被拒
+		return nil, err
+	}
+
+	if !s.recvPrefixSet {
+		s.recvPrefixSet = true
+	}
+	s.recvCounter = counter
+	return plaintext, nil
+}
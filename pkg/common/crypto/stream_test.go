@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newStreamPair(t *testing.T) (*StreamCrypto, *StreamCrypto) {
+	t.Helper()
+
+	key, _ := GenerateKey()
+	inner, err := NewAESCrypto(key)
+	if err != nil {
+		t.Fatalf("NewAESCrypto() error: %v", err)
+	}
+
+	sender, err := NewStreamCrypto(inner)
+	if err != nil {
+		t.Fatalf("NewStreamCrypto() error: %v", err)
+	}
+	receiver, err := NewStreamCrypto(inner)
+	if err != nil {
+		t.Fatalf("NewStreamCrypto() error: %v", err)
+	}
+	return sender, receiver
+}
+
+func TestStreamCrypto_InOrderFrames(t *testing.T) {
+	sender, receiver := newStreamPair(t)
+
+	frames := [][]byte{
+		[]byte("frame one"),
+		[]byte("frame two"),
+		[]byte("frame three"),
+	}
+
+	for _, frame := range frames {
+		ciphertext, nonce, err := sender.EncryptNext(frame)
+		if err != nil {
+			t.Fatalf("EncryptNext() error: %v", err)
+		}
+
+		plaintext, err := receiver.DecryptNext(ciphertext, nonce)
+		if err != nil {
+			t.Fatalf("DecryptNext() error: %v", err)
+		}
+		if !bytes.Equal(plaintext, frame) {
+			t.Errorf("decrypted frame mismatch: got %q, want %q", plaintext, frame)
+		}
+	}
+}
+
+func TestStreamCrypto_ReplayRejected(t *testing.T) {
+	sender, receiver := newStreamPair(t)
+
+	ciphertext, nonce, err := sender.EncryptNext([]byte("secret"))
+	if err != nil {
+		t.Fatalf("EncryptNext() error: %v", err)
+	}
+
+	// 第一次解密成功
+	if _, err := receiver.DecryptNext(ciphertext, nonce); err != nil {
+		t.Fatalf("first DecryptNext() error: %v", err)
+	}
+
+	// 重放同一帧应被拒绝
+	if _, err := receiver.DecryptNext(ciphertext, nonce); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("expected ErrReplayedNonce for replayed frame, got %v", err)
+	}
+}
+
+func TestStreamCrypto_OldFrameRejected(t *testing.T) {
+	sender, receiver := newStreamPair(t)
+
+	ct1, n1, _ := sender.EncryptNext([]byte("first"))
+	ct2, n2, _ := sender.EncryptNext([]byte("second"))
+
+	// 先收到第二帧
+	if _, err := receiver.DecryptNext(ct2, n2); err != nil {
+		t.Fatalf("DecryptNext() error: %v", err)
+	}
+
+	// 回放更早的帧应被拒绝
+	if _, err := receiver.DecryptNext(ct1, n1); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("expected ErrReplayedNonce for stale frame, got %v", err)
+	}
+}
+
+func TestStreamCrypto_GapsAllowed(t *testing.T) {
+	sender, receiver := newStreamPair(t)
+
+	sender.EncryptNext([]byte("dropped"))
+	ct, nonce, _ := sender.EncryptNext([]byte("survives"))
+
+	// 中间丢帧不影响后续帧解密
+	plaintext, err := receiver.DecryptNext(ct, nonce)
+	if err != nil {
+		t.Fatalf("DecryptNext() error: %v", err)
+	}
+	if string(plaintext) != "survives" {
+		t.Errorf("unexpected plaintext: %q", plaintext)
+	}
+}
+
+func TestStreamCrypto_PrefixMismatchRejected(t *testing.T) {
+	key, _ := GenerateKey()
+	inner, err := NewAESCrypto(key)
+	if err != nil {
+		t.Fatalf("NewAESCrypto() error: %v", err)
+	}
+	sender, _ := NewStreamCrypto(inner)
+	receiver, _ := NewStreamCrypto(inner)
+
+	ct1, n1, _ := sender.EncryptNext([]byte("first"))
+	if _, err := receiver.DecryptNext(ct1, n1); err != nil {
+		t.Fatalf("DecryptNext() error: %v", err)
+	}
+
+	// 同密钥但不同前缀的发送流, 伪装继续发帧应被拒绝
+	otherSender, _ := NewStreamCrypto(inner)
+	otherSender.EncryptNext([]byte("skip"))
+	otherCt, otherNonce, _ := otherSender.EncryptNext([]byte("forged"))
+
+	if _, err := receiver.DecryptNext(otherCt, otherNonce); !errors.Is(err, ErrNoncePrefixMismatch) {
+		t.Errorf("expected ErrNoncePrefixMismatch, got %v", err)
+	}
+}
+
+func TestStreamCrypto_TamperedCiphertextDoesNotAdvanceCounter(t *testing.T) {
+	sender, receiver := newStreamPair(t)
+
+	ct, nonce, _ := sender.EncryptNext([]byte("legit"))
+
+	// 篡改密文: 解密失败且不应推进计数器
+	tampered := make([]byte, len(ct))
+	copy(tampered, ct)
+	tampered[0] ^= 0xFF
+	if _, err := receiver.DecryptNext(tampered, nonce); err == nil {
+		t.Fatal("expected error for tampered ciphertext")
+	}
+
+	// 合法帧仍然可以解密
+	if _, err := receiver.DecryptNext(ct, nonce); err != nil {
+		t.Errorf("legit frame should still decrypt after tampered attempt: %v", err)
+	}
+}
@@ -23,6 +23,28 @@ const (
 	ErrCodeConflict       = -32004
 )
 
+// CodedError 带稳定错误码的方法错误。
+// RPC handler 会把 Code 映射到 JSON-RPC error.code, 客户端无需匹配字符串。
+type CodedError struct {
+	Code    int
+	Message string
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+func NewCodedError(code int, message string) *CodedError {
+	return &CodedError{Code: code, Message: message}
+}
+
+// 常用错误构造器
+func ErrInvalidParams(message string) *CodedError { return NewCodedError(ErrCodeInvalidParams, message) }
+func ErrNotFound(message string) *CodedError      { return NewCodedError(ErrCodeNotFound, message) }
+func ErrConflict(message string) *CodedError      { return NewCodedError(ErrCodeConflict, message) }
+func ErrUnauthorized(message string) *CodedError  { return NewCodedError(ErrCodeAuthRequired, message) }
+func ErrPermDenied(message string) *CodedError    { return NewCodedError(ErrCodePermDenied, message) }
+
 type RpcRequest struct {
 	JsonRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
@@ -0,0 +1,55 @@
+package rulehash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// HashKey 规则下发时携带规范化哈希的字段名
+const HashKey = "config_hash"
+
+// CanonicalHash 计算规则配置的规范化 SHA-256 哈希
+// encoding/json 对 map 按键名排序输出, 因此同一内容的序列化结果是确定性的,
+// 服务端 (typed map) 与客户端 (JSON 解码后的 map) 计算结果一致
+func CanonicalHash(rule map[string]interface{}) string {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CanonicalHashStruct 对 typed DTO 计算规范化哈希: 先经 JSON 往返转成 map
+// (剔除哈希字段) 再复用 CanonicalHash, 保证 struct 字段序不影响结果,
+// 与对解码后 map 的计算结果一致
+func CanonicalHashStruct(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return ""
+	}
+	delete(m, HashKey)
+	return CanonicalHash(m)
+}
+
+// Verify 校验规则 map 中携带的哈希与剔除哈希字段后的内容是否一致
+// 返回 (本地计算值, 是否一致); 规则未携带哈希时视为一致
+func Verify(rule map[string]interface{}) (string, bool) {
+	expected, ok := rule[HashKey].(string)
+	if !ok || expected == "" {
+		return "", true
+	}
+	stripped := make(map[string]interface{}, len(rule)-1)
+	for k, v := range rule {
+		if k != HashKey {
+			stripped[k] = v
+		}
+	}
+	got := CanonicalHash(stripped)
+	return got, got == expected
+}
@@ -0,0 +1,71 @@
+package rulehash
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// serverSideRule 模拟服务端构造的 typed map (int / []string 等原生类型)
+func serverSideRule() map[string]interface{} {
+	return map[string]interface{}{
+		"id":                     "rule-1",
+		"type":                   "relay",
+		"listen_addr":            "0.0.0.0:8080",
+		"relay_chain":            []string{"client-a", "@group-1"},
+		"exit_addr":              "10.0.0.1:80",
+		"pool_conns":             true,
+		"tcp_keepalive_interval": 30,
+	}
+}
+
+func TestCanonicalHash_SameAfterJSONRoundTrip(t *testing.T) {
+	serverRule := serverSideRule()
+	serverHash := CanonicalHash(serverRule)
+	if serverHash == "" {
+		t.Fatal("expected non-empty hash")
+	}
+
+	// 客户端视角: 规则经过 JSON 编解码后 (数字变 float64, 切片变 []interface{})
+	data, err := json.Marshal(serverRule)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var clientRule map[string]interface{}
+	if err := json.Unmarshal(data, &clientRule); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if clientHash := CanonicalHash(clientRule); clientHash != serverHash {
+		t.Errorf("hash mismatch after round trip: server=%s client=%s", serverHash, clientHash)
+	}
+}
+
+func TestCanonicalHash_ChangesWhenFieldChanges(t *testing.T) {
+	base := CanonicalHash(serverSideRule())
+
+	changed := serverSideRule()
+	changed["exit_addr"] = "10.0.0.2:80"
+	if CanonicalHash(changed) == base {
+		t.Error("expected hash to change when a field changes")
+	}
+}
+
+func TestVerify_DetectsTamperedRule(t *testing.T) {
+	rule := serverSideRule()
+	rule[HashKey] = CanonicalHash(serverSideRule())
+
+	if _, ok := Verify(rule); !ok {
+		t.Error("expected untouched rule to verify")
+	}
+
+	rule["listen_addr"] = "0.0.0.0:9999"
+	if _, ok := Verify(rule); ok {
+		t.Error("expected modified rule to fail verification")
+	}
+}
+
+func TestVerify_NoHashIsAccepted(t *testing.T) {
+	if _, ok := Verify(serverSideRule()); !ok {
+		t.Error("rule without config_hash should verify")
+	}
+}
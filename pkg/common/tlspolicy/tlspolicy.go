@@ -0,0 +1,55 @@
+package tlspolicy
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// Policy TLS 协商策略: 最低协议版本与密码套件白名单
+// 零值策略 Build 出的配置强制 TLS 1.2+, 密码套件用 Go 默认
+type Policy struct {
+	// MinVersion 最低 TLS 版本: "1.2" (默认) / "1.3"
+	MinVersion string
+
+	// CipherSuites 密码套件白名单 (IANA 名称, 如 "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	// 为空时使用 Go 默认套件; 仅对 TLS 1.2 及以下生效, TLS 1.3 套件不可配置
+	CipherSuites []string
+}
+
+// versionByName 支持的最低版本取值
+var versionByName = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build 将策略转换为 *tls.Config; 未知版本或套件名返回错误
+func (p Policy) Build() (*tls.Config, error) {
+	minVersion := uint16(tls.VersionTLS12)
+	if p.MinVersion != "" {
+		v, ok := versionByName[p.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS min version %q (supported: 1.2, 1.3)", p.MinVersion)
+		}
+		minVersion = v
+	}
+
+	cfg := &tls.Config{MinVersion: minVersion}
+
+	if len(p.CipherSuites) > 0 {
+		byName := make(map[string]uint16)
+		for _, cs := range tls.CipherSuites() {
+			byName[cs.Name] = cs.ID
+		}
+		ids := make([]uint16, 0, len(p.CipherSuites))
+		for _, name := range p.CipherSuites {
+			id, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+			}
+			ids = append(ids, id)
+		}
+		cfg.CipherSuites = ids
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,84 @@
+package tlspolicy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBuild_DefaultsToTLS12(t *testing.T) {
+	cfg, err := Policy{}.Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected default min version TLS 1.2, got %x", cfg.MinVersion)
+	}
+	if cfg.CipherSuites != nil {
+		t.Error("expected default cipher suites (nil)")
+	}
+}
+
+func TestBuild_MinVersion13(t *testing.T) {
+	cfg, err := Policy{MinVersion: "1.3"}.Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected min version TLS 1.3, got %x", cfg.MinVersion)
+	}
+}
+
+func TestBuild_RejectsUnknownVersion(t *testing.T) {
+	if _, err := (Policy{MinVersion: "1.1"}).Build(); err == nil {
+		t.Error("expected error for TLS 1.1 min version")
+	}
+	if _, err := (Policy{MinVersion: "ssl3"}).Build(); err == nil {
+		t.Error("expected error for bogus version")
+	}
+}
+
+func TestBuild_CipherAllowlist(t *testing.T) {
+	cfg, err := Policy{
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}.Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	if len(cfg.CipherSuites) != 1 || cfg.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("unexpected cipher suites: %v", cfg.CipherSuites)
+	}
+}
+
+func TestBuild_RejectsUnknownCipher(t *testing.T) {
+	if _, err := (Policy{CipherSuites: []string{"TLS_FANCY_NONSENSE"}}).Build(); err == nil {
+		t.Error("expected error for unknown cipher suite")
+	}
+}
+
+func TestPolicy_RejectsTLS11Peer(t *testing.T) {
+	// 只支持 TLS 1.1 的服务端
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{
+		MinVersion: tls.VersionTLS10,
+		MaxVersion: tls.VersionTLS11,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	clientTLS, err := Policy{MinVersion: "1.2"}.Build()
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	clientTLS.InsecureSkipVerify = true // 自签名测试证书
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: clientTLS},
+	}
+	resp, err := client.Get(ts.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected handshake with TLS 1.1 server to fail under 1.2 minimum")
+	}
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// AuditLog 管理操作审计记录: 谁在什么时间调用了哪个变更方法
+type AuditLog struct {
+	ID     uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Method string `json:"method" gorm:"size:100;not null;index"`
+	Actor  string `json:"actor" gorm:"size:100;index"`
+
+	// Params 脱敏后的参数摘要 (token/密码等敏感字段已替换为占位符)
+	Params string `json:"params" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
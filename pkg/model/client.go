@@ -25,6 +25,13 @@ type Client struct {
 	Token     string `json:"token" gorm:"size:64;uniqueIndex"`
 	SecretKey string `json:"-" gorm:"size:64"`
 
+	// 密钥轮换: 轮换后旧密钥保留一段宽限期, 等待客户端重新注册换取新密钥
+	PrevSecretKey   string     `json:"-" gorm:"size:64"`
+	SecretRotatedAt *time.Time `json:"-"`
+
+	// 标签 (key=value, 如 region=eu), 供规则按 @tag:key=value 引用选择出口
+	Tags StringMap `json:"tags,omitempty" gorm:"type:text"`
+
 	// 连接状态
 	Status   ClientStatus `json:"status" gorm:"size:20"`
 	LastIP   string       `json:"last_ip" gorm:"size:45"`
@@ -51,6 +58,20 @@ func (c *Client) SetDefaults() {
 	}
 }
 
+// AcceptsSecretKey 校验密钥: 当前密钥始终有效, 旧密钥仅在轮换后的宽限期内有效
+func (c *Client) AcceptsSecretKey(key string, grace time.Duration) bool {
+	if key == "" {
+		return false
+	}
+	if key == c.SecretKey {
+		return true
+	}
+	if c.PrevSecretKey == "" || key != c.PrevSecretKey || c.SecretRotatedAt == nil {
+		return false
+	}
+	return time.Since(*c.SecretRotatedAt) < grace
+}
+
 // GetRelayAddr 获取中继地址（优先使用配置的 RelayIP，否则使用 LastIP）
 func (c *Client) GetRelayAddr() string {
 	if c.RelayIP != "" {
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ClientRule 服务端下发给客户端的转发规则 DTO
+// 服务端按此结构序列化, 客户端按此结构反序列化, 双方共享同一份字段定义,
+// 避免 map[string]interface{} 的松散访问
+type ClientRule struct {
+	ID         string      `json:"id"`
+	Type       ForwardType `json:"type"`
+	ListenAddr string      `json:"listen_addr"`
+
+	// direct (CanaryPercent% 的新连接分流到 CanaryTarget, 用于金丝雀发布)
+	TargetAddr    string `json:"target_addr,omitempty"`
+	CanaryTarget  string `json:"canary_target,omitempty"`
+	CanaryPercent int    `json:"canary_percent,omitempty"`
+
+	// sni
+	SNIMap map[string]string `json:"sni_map,omitempty"`
+
+	// relay
+	RelayChain    []string `json:"relay_chain,omitempty"`
+	ExitAddr      string   `json:"exit_addr,omitempty"`
+	FallbackGroup string   `json:"fallback_group,omitempty"`
+	PoolConns     bool     `json:"pool_conns,omitempty"`
+	DrainOnClose  bool     `json:"drain_on_close,omitempty"`
+
+	// 连接级详细日志 (只有开启的规则才逐连接打 Debug 日志)
+	VerboseLogging bool `json:"verbose_logging,omitempty"`
+
+	// 流量镜像 tap 地址 (可选, 仅 direct 规则下发): 载荷副本尽力而为送往该地址
+	MirrorAddr string `json:"mirror_addr,omitempty"`
+
+	// TCP 调优 (nil/0 表示使用客户端默认值)
+	TCPNoDelay           *bool `json:"tcp_nodelay,omitempty"`
+	TCPKeepAliveInterval int   `json:"tcp_keepalive_interval,omitempty"`
+
+	// 监听与拨号侧请求 TCP Fast Open (仅内核支持时生效)
+	TCPFastOpen bool `json:"tcp_fast_open,omitempty"`
+
+	// ConfigHash 规范化哈希, 供客户端检测双方序列化漂移
+	ConfigHash string `json:"config_hash,omitempty"`
+}
+
+// Validate 校验必需字段, 客户端据此跳过坏规则而不是 panic
+func (r *ClientRule) Validate() error {
+	if r.ID == "" {
+		return errors.New("missing id")
+	}
+	if r.ListenAddr == "" {
+		return errors.New("missing listen_addr")
+	}
+	switch r.Type {
+	case ForwardTypeDirect:
+		if r.TargetAddr == "" {
+			return errors.New("missing target_addr")
+		}
+		if r.CanaryPercent < 0 || r.CanaryPercent > 100 {
+			return fmt.Errorf("canary_percent %d out of range [0,100]", r.CanaryPercent)
+		}
+		if r.CanaryPercent > 0 && r.CanaryTarget == "" {
+			return errors.New("missing canary_target for canary_percent > 0")
+		}
+	case ForwardTypeSNI, ForwardTypeRelay, ForwardTypeSocks5, ForwardTypeHTTPConnect:
+	default:
+		return fmt.Errorf("unknown rule type %q", r.Type)
+	}
+	return nil
+}
@@ -12,6 +12,13 @@ type ForwardType string
 const (
 	ForwardTypeDirect ForwardType = "direct"
 	ForwardTypeRelay  ForwardType = "relay"
+	ForwardTypeSNI    ForwardType = "sni"
+	// ForwardTypeSocks5 监听端口讲 SOCKS5, 目标由本地应用动态指定,
+	// 出口由规则决定 (无中继链直接拨号, 有中继链经隧道由出口拨号)
+	ForwardTypeSocks5 ForwardType = "socks5"
+	// ForwardTypeHTTPConnect 监听端口讲 HTTP CONNECT (标准 HTTP 工具的正向代理),
+	// 目标与出口的语义同 socks5
+	ForwardTypeHTTPConnect ForwardType = "http_connect"
 )
 
 // RuleStatus 规则运行状态
@@ -54,6 +61,36 @@ func (StringSlice) GormDataType() string {
 	return "text"
 }
 
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return json.Marshal(m)
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan StringMap: %v", value)
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+func (StringMap) GormDataType() string {
+	return "text"
+}
+
 type ForwardRule struct {
 	ID      string      `json:"id" gorm:"primaryKey;size:36"`
 	Name    string      `json:"name" gorm:"size:100;not null"`
@@ -66,10 +103,57 @@ type ForwardRule struct {
 	// 直接转发
 	TargetAddr string `json:"target_addr,omitempty" gorm:"size:255"`
 
+	// 金丝雀分流 (仅 direct): CanaryPercent% 的新连接转发到 CanaryTarget
+	CanaryTarget  string `json:"canary_target,omitempty" gorm:"size:255"`
+	CanaryPercent int    `json:"canary_percent,omitempty"`
+
+	// Via (仅 direct): 设置后监听客户端不直接拨号, 而是经该客户端单跳中继后由其拨号目标。
+	// 等价于 relay_chain=[Via] 的简化写法, 下发时转换为 relay 规则
+	Via string `json:"via,omitempty" gorm:"size:100"`
+
 	// 中继转发
 	RelayChain StringSlice `json:"relay_chain,omitempty" gorm:"type:text"`
 	ExitAddr   string      `json:"exit_addr,omitempty" gorm:"size:255"`
 
+	// 备用代理组 (可选): 首跳目标离线时服务端从该组选择替代出口
+	FallbackGroup string `json:"fallback_group,omitempty" gorm:"size:100"`
+
+	// 钉选出口节点 (可选, 仅中继链引用代理组时生效): 设置后该规则的流量绕过负载均衡
+	// 固定走此节点 (节点健康时, 否则回退正常均衡), 清空恢复正常均衡。用于临时调试定位
+	PinnedNodeID string `json:"pinned_node_id,omitempty" gorm:"size:36"`
+
+	// SNI 路由 (SNI -> 目标地址, 支持 *.example.com 通配和 * 兜底)
+	SNIMap StringMap `json:"sni_map,omitempty" gorm:"type:text"`
+
+	// 出口连接池 (仅对可复用连接的协议安全, 默认关闭)
+	PoolConns bool `json:"pool_conns,omitempty"`
+
+	// 出口关闭前半关排空 (对需要干净收尾的协议开启, 保证最后写入的数据送达目标)
+	DrainOnClose bool `json:"drain_on_close,omitempty"`
+
+	// 连接级详细日志 (默认关闭, 只有开启的规则才逐连接打 Debug 日志)
+	VerboseLogging bool `json:"verbose_logging,omitempty"`
+
+	// 流量镜像 (可选): 转发的载荷以尽力而为方式复制一份发往该地址 (用于调试/IDS),
+	// 镜像经有界队列异步送出, 慢速 tap 不回压主链路, 送不出去的副本直接丢弃
+	MirrorAddr string `json:"mirror_addr,omitempty" gorm:"size:255"`
+
+	// 启用时间窗 (可选): 到达 EnabledFrom 自动启用, 过了 EnabledUntil 自动禁用
+	EnabledFrom  *time.Time `json:"enabled_from,omitempty"`
+	EnabledUntil *time.Time `json:"enabled_until,omitempty"`
+
+	// TCP 调优 (nil/0 表示沿用默认: NoDelay 开启, KeepAlive 30s)
+	TCPNoDelay           *bool `json:"tcp_nodelay,omitempty"`
+	TCPKeepAliveInterval int   `json:"tcp_keepalive_interval,omitempty"` // 秒, 0 = 默认
+
+	// TCPFastOpen 监听与拨号侧请求 TCP Fast Open (仅内核支持时生效, 否则静默回退)
+	TCPFastOpen bool `json:"tcp_fast_open,omitempty"`
+
+	// ConnectConcurrency 建连并发上限 (0 = 不限制): 限制同时处于 Connect→ConnAck
+	// 建连阶段的连接数, 超出的在服务端短暂排队, 避免突发新建连接成批冲击出口目标。
+	// 只约束建连阶段, 已建立的连接数不受此限制
+	ConnectConcurrency int `json:"connect_concurrency,omitempty"`
+
 	// 运行状态
 	Status    RuleStatus `json:"status" gorm:"size:20"`
 	LastError string     `json:"last_error,omitempty" gorm:"size:500"`
@@ -82,6 +166,17 @@ func (ForwardRule) TableName() string {
 	return "forward_rules"
 }
 
+// WithinSchedule 判断当前时刻是否在规则的启用时间窗内 (未配置的边界视为不限制)
+func (r *ForwardRule) WithinSchedule(now time.Time) bool {
+	if r.EnabledFrom != nil && now.Before(*r.EnabledFrom) {
+		return false
+	}
+	if r.EnabledUntil != nil && !now.Before(*r.EnabledUntil) {
+		return false
+	}
+	return true
+}
+
 // SetDefaults 设置默认值
 func (r *ForwardRule) SetDefaults() {
 	if r.Type == "" {
@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -452,3 +453,53 @@ func TestProxyGroupJSONSerialization(t *testing.T) {
 		t.Error("LoadBalanceMethod mismatch")
 	}
 }
+
+func TestClientRule_RelayRoundTrip(t *testing.T) {
+	noDelay := false
+	rule := ClientRule{
+		ID:                   "relay-rule",
+		Type:                 ForwardTypeRelay,
+		ListenAddr:           "0.0.0.0:8080",
+		RelayChain:           []string{"@group-1", "client-2"},
+		ExitAddr:             "10.0.0.1:80",
+		FallbackGroup:        "@group-2",
+		PoolConns:            true,
+		DrainOnClose:         true,
+		TCPNoDelay:           &noDelay,
+		TCPKeepAliveInterval: 45,
+		ConfigHash:           "abc123",
+	}
+
+	data, err := json.Marshal(rule)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	var decoded ClientRule
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(rule, decoded) {
+		t.Errorf("round trip mismatch:\n  sent: %+v\n  got:  %+v", rule, decoded)
+	}
+}
+
+func TestClientRule_Validate(t *testing.T) {
+	valid := ClientRule{ID: "r", Type: ForwardTypeRelay, ListenAddr: ":1"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid rule, got %v", err)
+	}
+
+	invalid := []ClientRule{
+		{Type: ForwardTypeRelay, ListenAddr: ":1"},                // 缺 ID
+		{ID: "r", Type: ForwardTypeRelay},                         // 缺 ListenAddr
+		{ID: "r", Type: ForwardTypeDirect, ListenAddr: ":1"},      // direct 缺 TargetAddr
+		{ID: "r", Type: ForwardType("bogus"), ListenAddr: ":1"},   // 未知类型
+	}
+	for i, rule := range invalid {
+		if err := rule.Validate(); err == nil {
+			t.Errorf("case %d: expected validation error for %+v", i, rule)
+		}
+	}
+}
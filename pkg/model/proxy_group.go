@@ -14,6 +14,15 @@ const (
 	LoadBalanceIPHash     LoadBalanceMethod = "ip_hash"
 )
 
+// Valid 判断是否为已知的负载均衡方法
+func (m LoadBalanceMethod) Valid() bool {
+	switch m {
+	case LoadBalanceRoundRobin, LoadBalanceRandom, LoadBalanceLeastConn, LoadBalanceIPHash:
+		return true
+	}
+	return false
+}
+
 // NodeStatus 节点状态
 type NodeStatus string
 
@@ -75,6 +84,9 @@ type ProxyGroupNode struct {
 	LastCheckOK bool       `json:"last_check_ok"`
 	FailCount   int        `json:"fail_count"`
 
+	// LastCheckError 最近一次探测失败的原因 (探测成功时清空)
+	LastCheckError string `json:"last_check_error,omitempty" gorm:"type:text"`
+
 	// 连接统计 (仅实时活跃连接)
 	ActiveConns int `json:"active_conns"`
 
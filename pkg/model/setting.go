@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Setting 运行期可调的服务端设置 (key-value)。
+// 与 TOML 配置互补: TOML 提供默认值, settings 表中的值覆盖并跨重启保留
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"size:500"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Setting) TableName() string {
+	return "settings"
+}
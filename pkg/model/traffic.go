@@ -8,8 +8,11 @@ import (
 // TrafficStats 流量统计
 type TrafficStats struct {
 	ID       string `json:"id" gorm:"primaryKey;type:varchar(36)"`
-	RuleID   string `json:"rule_id" gorm:"type:varchar(36);not null;index"`
-	ClientID string `json:"client_id" gorm:"type:varchar(36);not null;index"`
+	RuleID   string `json:"rule_id" gorm:"type:varchar(36);not null;index;uniqueIndex:idx_traffic_rule_client_label_period"`
+	ClientID string `json:"client_id" gorm:"type:varchar(36);not null;index;uniqueIndex:idx_traffic_rule_client_label_period"`
+
+	// Label 入口附带的统计标签 (如租户 ID), 同一规则按标签细分; 未附带时为空
+	Label string `json:"label,omitempty" gorm:"type:varchar(64);not null;default:'';uniqueIndex:idx_traffic_rule_client_label_period"`
 
 	// 流量统计 (字节)
 	BytesIn    int64 `json:"bytes_in" gorm:"default:0"`
@@ -21,7 +24,7 @@ type TrafficStats struct {
 	ActiveConns int   `json:"active_conns" gorm:"default:0"`
 
 	// 时间范围 (用于按时间段统计)
-	PeriodStart time.Time `json:"period_start" gorm:"index"`
+	PeriodStart time.Time `json:"period_start" gorm:"index;uniqueIndex:idx_traffic_rule_client_label_period"`
 	PeriodEnd   time.Time `json:"period_end"`
 
 	CreatedAt time.Time `json:"created_at"`
@@ -39,6 +42,9 @@ type TrafficSummary struct {
 	ClientID   string `json:"client_id"`
 	ClientName string `json:"client_name"`
 
+	// Label 统计标签 (入口附带时按标签细分, 未附带为空)
+	Label string `json:"label,omitempty"`
+
 	BytesIn    int64 `json:"bytes_in"`
 	BytesOut   int64 `json:"bytes_out"`
 	TotalBytes int64 `json:"total_bytes"`
@@ -50,6 +56,11 @@ type TrafficSummary struct {
 	BytesInStr    string `json:"bytes_in_str"`
 	BytesOutStr   string `json:"bytes_out_str"`
 	TotalBytesStr string `json:"total_bytes_str"`
+
+	// 压缩统计 (载荷压缩开启且有记录时填充; Ratio 为压缩前/压缩后)
+	CompressionRawBytes        int64   `json:"compression_raw_bytes,omitempty"`
+	CompressionCompressedBytes int64   `json:"compression_compressed_bytes,omitempty"`
+	CompressionRatio           float64 `json:"compression_ratio,omitempty"`
 }
 
 // FormatBytes 格式化字节数为人类可读的字符串